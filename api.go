@@ -31,6 +31,7 @@ import (
 
 	"github.com/pilosa/pilosa/v2/pql"
 	"github.com/pilosa/pilosa/v2/roaring"
+	"github.com/pilosa/pilosa/v2/sql"
 	"github.com/pilosa/pilosa/v2/stats"
 	"github.com/pilosa/pilosa/v2/tracing"
 	"github.com/pkg/errors"
@@ -136,26 +137,213 @@ func (api *API) Query(ctx context.Context, req *QueryRequest) (QueryResponse, er
 	span, ctx := tracing.StartSpanFromContext(ctx, "API.Query")
 	defer span.Finish()
 
+	q, execOpts, err := api.parseQuery(req)
+	if err != nil {
+		return QueryResponse{}, err
+	}
+	resp, err := api.server.executor.Execute(ctx, req.Index, q, req.Shards, execOpts)
+	if err != nil {
+		return QueryResponse{}, errors.Wrap(err, "executing")
+	}
+
+	return resp, nil
+}
+
+// parseQuery validates the request and parses its PQL query, returning the
+// query and the options to execute it with.
+func (api *API) parseQuery(req *QueryRequest) (*pql.Query, *execOptions, error) {
 	if err := api.validate(apiQuery); err != nil {
-		return QueryResponse{}, errors.Wrap(err, "validating api method")
+		return nil, nil, errors.Wrap(err, "validating api method")
+	}
+
+	if err := req.WriteConsistency.validate(); err != nil {
+		return nil, nil, err
 	}
 
 	q, err := pql.NewParser(strings.NewReader(req.Query)).Parse()
 	if err != nil {
-		return QueryResponse{}, errors.Wrap(err, "parsing")
+		return nil, nil, errors.Wrap(err, "parsing")
+	}
+	return q, &execOptions{
+		Remote:           req.Remote,
+		ExcludeRowAttrs:  req.ExcludeRowAttrs, // NOTE: Kept for Pilosa 1.x compat.
+		ExcludeColumns:   req.ExcludeColumns,  // NOTE: Kept for Pilosa 1.x compat.
+		ColumnAttrs:      req.ColumnAttrs,     // NOTE: Kept for Pilosa 1.x compat.
+		Atomic:           req.Atomic,
+		WriteConsistency: req.WriteConsistency,
+	}, nil
+}
+
+// QueryStream parses a PQL query out of the request and executes it, passing
+// the results to fn as they are produced rather than building a complete
+// QueryResponse. Bitmap results are passed as batches of columns as each
+// shard is reduced.
+func (api *API) QueryStream(ctx context.Context, req *QueryRequest, fn func(QueryResultChunk) error) error {
+	span, ctx := tracing.StartSpanFromContext(ctx, "API.QueryStream")
+	defer span.Finish()
+
+	if req.ColumnAttrs {
+		return NewBadRequestError(errors.New("column attributes cannot be streamed"))
+	}
+	q, execOpts, err := api.parseQuery(req)
+	if err != nil {
+		return err
 	}
-	execOpts := &execOptions{
-		Remote:          req.Remote,
-		ExcludeRowAttrs: req.ExcludeRowAttrs, // NOTE: Kept for Pilosa 1.x compat.
-		ExcludeColumns:  req.ExcludeColumns,  // NOTE: Kept for Pilosa 1.x compat.
-		ColumnAttrs:     req.ColumnAttrs,     // NOTE: Kept for Pilosa 1.x compat.
+	execOpts.Stream = fn
+	if _, err := api.server.executor.Execute(ctx, req.Index, q, req.Shards, execOpts); err != nil {
+		return errors.Wrap(err, "executing")
 	}
-	resp, err := api.server.executor.Execute(ctx, req.Index, q, req.Shards, execOpts)
+	return nil
+}
+
+// DeleteColumn clears a column from every field and view of an index,
+// including the existence field. The column is an ID, or a key if the index
+// uses keys. If keys or attrs is set, the column's key or attributes are
+// also removed. Returns true if any data was removed.
+func (api *API) DeleteColumn(ctx context.Context, indexName, column string, keys, attrs bool) (bool, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx, "API.DeleteColumn")
+	defer span.Finish()
+
+	if err := api.validate(apiQuery); err != nil {
+		return false, errors.Wrap(err, "validating api method")
+	}
+
+	index := api.holder.Index(indexName)
+	if index == nil {
+		return false, newNotFoundError(ErrIndexNotFound, indexName)
+	}
+
+	var columns interface{} = []interface{}{column}
+	if !index.Keys() {
+		id, err := strconv.ParseUint(column, 10, 64)
+		if err != nil {
+			return false, NewBadRequestError(errors.Wrap(err, "parsing column ID"))
+		}
+		columns = []uint64{id}
+	}
+
+	q := &pql.Query{Calls: []*pql.Call{{
+		Name: "Delete",
+		Args: map[string]interface{}{"columns": columns, "keys": keys, "attrs": attrs},
+	}}}
+	resp, err := api.server.executor.Execute(ctx, indexName, q, nil, &execOptions{})
 	if err != nil {
-		return QueryResponse{}, errors.Wrap(err, "executing")
+		return false, errors.Wrap(err, "executing")
 	}
+	changed, _ := resp.Results[0].(bool)
+	return changed, nil
+}
 
-	return resp, nil
+// SQLResult is the tabular result of a SQL statement.
+type SQLResult struct {
+	Columns []string        `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+}
+
+// SQL parses a SQL statement, translates it into PQL, and executes it. Errors
+// in the statement, including unsupported constructs, are returned as
+// BadRequestErrors.
+func (api *API) SQL(ctx context.Context, query string) (*SQLResult, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx, "API.SQL")
+	defer span.Finish()
+
+	if err := api.validate(apiQuery); err != nil {
+		return nil, errors.Wrap(err, "validating api method")
+	}
+
+	stmt, err := sql.Parse(query)
+	if err != nil {
+		return nil, NewBadRequestError(errors.Wrap(err, "parsing"))
+	}
+	index := api.holder.Index(stmt.Index)
+	if index == nil {
+		return nil, newNotFoundError(ErrIndexNotFound, stmt.Index)
+	}
+
+	schema := sql.Schema{
+		Fields:         make(map[string]string),
+		TrackExistence: index.trackExistence,
+	}
+	for _, f := range index.Fields() {
+		schema.Fields[f.Name()] = f.Type()
+	}
+	plan, err := sql.Translate(stmt, schema)
+	if err != nil {
+		return nil, NewBadRequestError(errors.Wrap(err, "translating"))
+	}
+
+	resp, err := api.server.executor.Execute(ctx, stmt.Index, plan.Query, nil, &execOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "executing")
+	}
+	return sqlResult(plan, resp.Results)
+}
+
+// sqlResult converts the results of executing plan into a table.
+func sqlResult(plan *sql.Plan, results []interface{}) (*SQLResult, error) {
+	res := &SQLResult{Columns: plan.Columns, Rows: [][]interface{}{}}
+	switch plan.Type {
+	case sql.PlanAggregate:
+		row := make([]interface{}, len(results))
+		for i, result := range results {
+			switch r := result.(type) {
+			case uint64:
+				row[i] = r
+			case ValCount:
+				// Aggregates over no values are NULL.
+				if r.Count > 0 {
+					row[i] = r.Val
+				}
+			default:
+				return nil, errors.Errorf("unexpected aggregate result type: %T", result)
+			}
+		}
+		res.Rows = append(res.Rows, row)
+
+	case sql.PlanGroupBy:
+		groups, ok := results[0].([]GroupCount)
+		if !ok {
+			return nil, errors.Errorf("unexpected group by result type: %T", results[0])
+		}
+		for _, gc := range groups {
+			row := make([]interface{}, len(plan.Select))
+			for i, item := range plan.Select {
+				if item.Func == "COUNT" {
+					row[i] = gc.Count
+					continue
+				}
+				for _, fr := range gc.Group {
+					if fr.Field != item.Field {
+						continue
+					} else if fr.RowKey != "" {
+						row[i] = fr.RowKey
+					} else {
+						row[i] = fr.RowID
+					}
+				}
+			}
+			res.Rows = append(res.Rows, row)
+		}
+
+	case sql.PlanColumns:
+		r, ok := results[0].(*Row)
+		if !ok {
+			return nil, errors.Errorf("unexpected row result type: %T", results[0])
+		}
+		if len(r.Keys) > 0 {
+			for _, key := range r.Keys {
+				res.Rows = append(res.Rows, []interface{}{key})
+			}
+		} else {
+			for _, id := range r.Columns() {
+				res.Rows = append(res.Rows, []interface{}{id})
+			}
+		}
+		if plan.Limit > 0 && uint64(len(res.Rows)) > plan.Limit {
+			res.Rows = res.Rows[:plan.Limit]
+		}
+	}
+	return res, nil
 }
 
 // CreateIndex makes a new Pilosa index.
@@ -374,6 +562,12 @@ func (api *API) ImportRoaring(ctx context.Context, indexName, fieldName string,
 		return errors.Wrap(err, "validating api method")
 	}
 
+	// Imports forwarded from another node are covered by its backup barrier.
+	if !remote {
+		api.holder.backupBarrier.enter()
+		defer api.holder.backupBarrier.exit()
+	}
+
 	nodes := api.cluster.shardNodes(indexName, shard)
 
 	field := api.holder.Field(indexName, fieldName)
@@ -390,7 +584,26 @@ func (api *API) ImportRoaring(ctx context.Context, indexName, fieldName string,
 
 	for _, node := range nodes {
 		node := node
-		if node.ID == api.server.nodeID {
+		if node.ID == api.server.nodeID && req.Token != "" {
+			go func() {
+				errCh <- api.importOnce(field, shard, req.Token, func() error {
+					jobErrCh := make(chan error, 1)
+					api.importWork <- importJob{
+						ctx:     ctx,
+						req:     req,
+						shard:   shard,
+						field:   field,
+						errChan: jobErrCh,
+					}
+					select {
+					case <-ctx.Done():
+						return ctx.Err()
+					case err := <-jobErrCh:
+						return err
+					}
+				})
+			}()
+		} else if node.ID == api.server.nodeID {
 			api.importWork <- importJob{
 				ctx:     ctx,
 				req:     req,
@@ -496,7 +709,10 @@ func (api *API) DeleteAvailableShard(_ context.Context, indexName, fieldName str
 }
 
 // ExportCSV encodes the fragment designated by the index,field,shard as
-// CSV of the form <row>,<col>
+// CSV. Set and mutex fields are written as <row>,<col>, int fields as
+// <col>,<value>, bool fields as <col>,<true|false>, and time fields as
+// <row>,<col>,<timestamp>. Timestamps are read from the views of the finest
+// unit of the field's time quantum, and are empty for bits set without one.
 func (api *API) ExportCSV(ctx context.Context, indexName string, fieldName string, shard uint64, w io.Writer) error {
 	span, _ := tracing.StartSpanFromContext(ctx, "API.ExportCSV")
 	defer span.Finish()
@@ -523,46 +739,101 @@ func (api *API) ExportCSV(ctx context.Context, indexName string, fieldName strin
 		return newNotFoundError(ErrFieldNotFound, fieldName)
 	}
 
-	// Find the fragment.
-	f := api.holder.fragment(indexName, fieldName, viewStandard, shard)
-	if f == nil {
-		return ErrFragmentNotFound
-	}
-
 	// Wrap writer with a CSV writer.
 	cw := csv.NewWriter(w)
 
-	// Define the function to write each bit as a string,
+	// Define functions to write row and column IDs as strings,
 	// translating to keys where necessary.
-	var n int
-	fn := func(rowID, columnID uint64) error {
-		var rowStr string
-		var colStr string
-		var err error
-
+	rowString := func(rowID uint64) (string, error) {
 		if field.keys() {
-			if rowStr, err = field.translateStore.TranslateID(rowID); err != nil {
-				return errors.Wrap(err, "translating row")
+			s, err := field.translateStore.TranslateID(rowID)
+			return s, errors.Wrap(err, "translating row")
+		}
+		return strconv.FormatUint(rowID, 10), nil
+	}
+	columnString := func(columnID uint64) (string, error) {
+		if index.Keys() {
+			s, err := index.translateStore.TranslateID(columnID)
+			return s, errors.Wrap(err, "translating column")
+		}
+		return strconv.FormatUint(columnID, 10), nil
+	}
+
+	var n int
+	switch {
+	case field.Type() == FieldTypeInt:
+		if api.holder.fragment(indexName, fieldName, ViewBSIGroupPrefix+fieldName, shard) == nil {
+			return ErrFragmentNotFound
+		}
+		values, err := intValues(field, shard)
+		if err != nil {
+			return errors.Wrap(err, "reading values")
+		}
+		for _, v := range values {
+			colStr, err := columnString(v.columnID)
+			if err != nil {
+				return err
+			}
+			n++
+			if err := cw.Write([]string{colStr, strconv.FormatInt(v.value, 10)}); err != nil {
+				return errors.Wrap(err, "writing CSV")
 			}
-		} else {
-			rowStr = strconv.FormatUint(rowID, 10)
 		}
 
-		if index.Keys() {
-			if colStr, err = index.translateStore.TranslateID(columnID); err != nil {
-				return errors.Wrap(err, "translating column")
+	case field.Type() == FieldTypeTime && field.TimeQuantum() != "":
+		bits, err := timeBits(field, shard)
+		if err != nil {
+			return errors.Wrap(err, "reading time views")
+		}
+		for _, bit := range bits {
+			rowStr, err := rowString(bit.rowID)
+			if err != nil {
+				return err
+			}
+			colStr, err := columnString(bit.columnID)
+			if err != nil {
+				return err
+			}
+			var timestamp string
+			if bit.timestamp != 0 {
+				timestamp = time.Unix(0, bit.timestamp).UTC().Format(TimeFormat)
+			}
+			n++
+			if err := cw.Write([]string{rowStr, colStr, timestamp}); err != nil {
+				return errors.Wrap(err, "writing CSV")
 			}
-		} else {
-			colStr = strconv.FormatUint(columnID, 10)
 		}
 
-		n++
-		return cw.Write([]string{rowStr, colStr})
-	}
+	default:
+		// Find the fragment.
+		f := api.holder.fragment(indexName, fieldName, viewStandard, shard)
+		if f == nil {
+			return ErrFragmentNotFound
+		}
+
+		// Define the function to write each bit as a string. Bool fields
+		// are written with the column first, as for int fields.
+		fn := func(rowID, columnID uint64) error {
+			colStr, err := columnString(columnID)
+			if err != nil {
+				return err
+			}
+
+			n++
+			if field.Type() == FieldTypeBool {
+				return cw.Write([]string{colStr, strconv.FormatBool(rowID == trueRowID)})
+			}
+			rowStr, err := rowString(rowID)
+			if err != nil {
+				return err
+			}
+			return cw.Write([]string{rowStr, colStr})
+		}
 
-	// Iterate over each column.
-	if err := f.forEachBit(fn); err != nil {
-		return errors.Wrap(err, "writing CSV")
+		// Iterate over each column.
+		if err := f.forEachBit(fn); err != nil {
+			return errors.Wrap(err, "writing CSV")
+		}
 	}
 
 	// Ensure data is flushed.
@@ -612,7 +883,9 @@ func (api *API) FragmentBlockData(ctx context.Context, body io.Reader) ([]byte,
 	}
 
 	var resp = BlockDataResponse{}
-	resp.RowIDs, resp.ColumnIDs = f.blockData(int(req.Block))
+	if resp.RowIDs, resp.ColumnIDs, err = f.blockData(int(req.Block)); err != nil {
+		return nil, errors.Wrap(err, "reading block")
+	}
 
 	// Encode response.
 	buf, err := api.Serializer.Marshal(&resp)
@@ -639,8 +912,8 @@ func (api *API) FragmentBlocks(ctx context.Context, indexName, fieldName, viewNa
 	}
 
 	// Retrieve blocks.
-	blocks := f.Blocks()
-	return blocks, nil
+	blocks, err := f.Blocks()
+	return blocks, errors.Wrap(err, "reading blocks")
 }
 
 // FragmentData returns all data in the specified fragment.
@@ -687,8 +960,7 @@ func (api *API) RecalculateCaches(ctx context.Context) error {
 	if err != nil {
 		return errors.Wrap(err, "broacasting message")
 	}
-	api.holder.recalculateCaches()
-	return nil
+	return errors.Wrap(api.holder.recalculateCaches(), "recalculating caches")
 }
 
 // ClusterMessage is for internal use. It decodes a protobuf message out of
@@ -893,6 +1165,14 @@ func (api *API) FieldAttrDiff(ctx context.Context, indexName string, fieldName s
 type ImportOptions struct {
 	Clear          bool
 	IgnoreKeyCheck bool
+
+	// If true, the imported rows replace each column's existing rows in
+	// the field's standard view.
+	Upsert bool
+
+	// If set, an import retried with the same token returns the outcome
+	// of the first attempt instead of being applied again.
+	Token string
 }
 
 // ImportOption is a functional option type for API.Import.
@@ -916,6 +1196,25 @@ func OptImportOptionsIgnoreKeyCheck(b bool) ImportOption {
 	}
 }
 
+// OptImportOptionsUpsert is a functional option on ImportOption
+// used to specify whether each imported column's existing bits in the
+// field should be cleared before the import.
+func OptImportOptionsUpsert(b bool) ImportOption {
+	return func(o *ImportOptions) error {
+		o.Upsert = b
+		return nil
+	}
+}
+
+// OptImportOptionsToken is a functional option on ImportOption
+// used to specify an idempotency token for the import.
+func OptImportOptionsToken(token string) ImportOption {
+	return func(o *ImportOptions) error {
+		o.Token = token
+		return nil
+	}
+}
+
 // Import bulk imports data into a particular index,field,shard.
 func (api *API) Import(ctx context.Context, req *ImportRequest, opts ...ImportOption) error {
 	span, _ := tracing.StartSpanFromContext(ctx, "API.Import")
@@ -931,6 +1230,12 @@ func (api *API) Import(ctx context.Context, req *ImportRequest, opts ...ImportOp
 		return errors.Wrap(err, "setting up import options")
 	}
 
+	// Imports forwarded from another node are covered by its backup barrier.
+	if !options.IgnoreKeyCheck {
+		api.holder.backupBarrier.enter()
+		defer api.holder.backupBarrier.exit()
+	}
+
 	index, field, err := api.indexField(req.Index, req.Field, req.Shard)
 	if err != nil {
 		return errors.Wrap(err, "getting index and field")
@@ -1001,30 +1306,42 @@ func (api *API) Import(ctx context.Context, req *ImportRequest, opts ...ImportOp
 		return errors.Wrap(err, "validating shard ownership")
 	}
 
-	// Convert timestamps to time.Time.
-	timestamps := make([]*time.Time, len(req.Timestamps))
-	for i, ts := range req.Timestamps {
-		if ts == 0 {
-			continue
+	return api.importOnce(field, req.Shard, options.Token, func() error {
+		// Convert timestamps to time.Time.
+		timestamps := make([]*time.Time, len(req.Timestamps))
+		for i, ts := range req.Timestamps {
+			if ts == 0 {
+				continue
+			}
+			t := time.Unix(0, ts).UTC()
+			timestamps[i] = &t
 		}
-		t := time.Unix(0, ts).UTC()
-		timestamps[i] = &t
-	}
 
-	// Import columnIDs into existence field.
-	if !options.Clear {
-		if err := importExistenceColumns(index, req.ColumnIDs); err != nil {
-			api.server.logger.Printf("import existence error: index=%s, field=%s, shard=%d, columns=%d, err=%s", req.Index, req.Field, req.Shard, len(req.ColumnIDs), err)
-			return errors.Wrap(err, "importing existence columns")
+		// Import columnIDs into existence field.
+		if !options.Clear {
+			if err := importExistenceColumns(index, req.ColumnIDs); err != nil {
+				api.server.logger.Printf("import existence error: index=%s, field=%s, shard=%d, columns=%d, err=%s", req.Index, req.Field, req.Shard, len(req.ColumnIDs), err)
+				return errors.Wrap(err, "importing existence columns")
+			}
 		}
-	}
 
-	// Import into fragment.
-	err = field.Import(req.RowIDs, req.ColumnIDs, timestamps, opts...)
-	if err != nil {
-		api.server.logger.Printf("import error: index=%s, field=%s, shard=%d, columns=%d, err=%s", req.Index, req.Field, req.Shard, len(req.ColumnIDs), err)
+		// Import into fragment.
+		err := field.Import(req.RowIDs, req.ColumnIDs, timestamps, opts...)
+		if err != nil {
+			api.server.logger.Printf("import error: index=%s, field=%s, shard=%d, columns=%d, err=%s", req.Index, req.Field, req.Shard, len(req.ColumnIDs), err)
+		}
+		return errors.Wrap(err, "importing")
+	})
+}
+
+// importOnce calls fn to import data into a shard of field. If token is set
+// and an import with the same token already succeeded, fn isn't called and
+// nil is returned. Failed imports may be retried with the same token.
+func (api *API) importOnce(field *Field, shard uint64, token string, fn func() error) error {
+	if token == "" {
+		return fn()
 	}
-	return errors.Wrap(err, "importing")
+	return field.importTokens.do(shard, token, fn)
 }
 
 // ImportValue bulk imports values into a particular field.
@@ -1042,6 +1359,12 @@ func (api *API) ImportValue(ctx context.Context, req *ImportValueRequest, opts .
 		return errors.Wrap(err, "setting up import options")
 	}
 
+	// Imports forwarded from another node are covered by its backup barrier.
+	if !options.IgnoreKeyCheck {
+		api.holder.backupBarrier.enter()
+		defer api.holder.backupBarrier.exit()
+	}
+
 	index, field, err := api.indexField(req.Index, req.Field, req.Shard)
 	if err != nil {
 		return errors.Wrap(err, "getting index and field")
@@ -1096,20 +1419,22 @@ func (api *API) ImportValue(ctx context.Context, req *ImportValueRequest, opts .
 		return errors.Wrap(err, "validating shard ownership")
 	}
 
-	// Import columnIDs into existence field.
-	if !options.Clear {
-		if err := importExistenceColumns(index, req.ColumnIDs); err != nil {
-			api.server.logger.Printf("import existence error: index=%s, field=%s, shard=%d, columns=%d, err=%s", req.Index, req.Field, req.Shard, len(req.ColumnIDs), err)
-			return errors.Wrap(err, "importing existence columns")
+	return api.importOnce(field, req.Shard, options.Token, func() error {
+		// Import columnIDs into existence field.
+		if !options.Clear {
+			if err := importExistenceColumns(index, req.ColumnIDs); err != nil {
+				api.server.logger.Printf("import existence error: index=%s, field=%s, shard=%d, columns=%d, err=%s", req.Index, req.Field, req.Shard, len(req.ColumnIDs), err)
+				return errors.Wrap(err, "importing existence columns")
+			}
 		}
-	}
 
-	// Import into fragment.
-	err = field.importValue(req.ColumnIDs, req.Values, options)
-	if err != nil {
-		api.server.logger.Printf("import error: index=%s, field=%s, shard=%d, columns=%d, err=%s", req.Index, req.Field, req.Shard, len(req.ColumnIDs), err)
-	}
-	return errors.Wrap(err, "importing")
+		// Import into fragment.
+		err := field.importValue(req.ColumnIDs, req.Values, options)
+		if err != nil {
+			api.server.logger.Printf("import error: index=%s, field=%s, shard=%d, columns=%d, err=%s", req.Index, req.Field, req.Shard, len(req.ColumnIDs), err)
+		}
+		return errors.Wrap(err, "importing")
+	})
 }
 
 func importExistenceColumns(index *Index, columnIDs []uint64) error {
@@ -1319,6 +1644,20 @@ func (api *API) TranslateKeys(r io.Reader) ([]byte, error) {
 	return buf, nil
 }
 
+// translateKeys translates the keys of an index, or of one of its fields, to
+// IDs, creating any which don't exist. Only the primary translate node can
+// create keys, so other nodes send them there.
+func (api *API) translateKeys(ctx context.Context, indexName, fieldName string, keys []string) ([]uint64, error) {
+	if node := api.cluster.translatePrimaryNode(); node != nil && node.ID != api.Node().ID {
+		return api.server.defaultClient.TranslateKeysNode(ctx, &node.URI, indexName, fieldName, keys)
+	}
+	store, err := api.holder.TranslateStore(indexName, fieldName)
+	if err != nil {
+		return nil, err
+	}
+	return store.TranslateKeys(keys)
+}
+
 // PrimaryReplicaNodeURL returns the URL of the cluster's primary replica.
 func (api *API) PrimaryReplicaNodeURL() url.URL {
 	node := api.cluster.PrimaryReplicaNode()
@@ -1374,11 +1713,26 @@ const (
 	//apiVersion // not implemented
 	apiViews
 	apiApplySchema
+	apiIngest
+	apiExportIndex
+	apiExportShard
+	apiBackup
+	apiBlockBackup
+	apiUnblockBackup
+	apiPinBackup
+	apiBackupFragment
+	apiReleaseBackup
+	apiRestore
+	apiRestoreFragment
+	apiRestoreAttrs
+	apiRestoreKeys
 )
 
 var methodsCommon = map[apiMethod]struct{}{
 	apiClusterMessage: {},
 	apiSetCoordinator: {},
+	apiUnblockBackup:  {},
+	apiReleaseBackup:  {},
 }
 
 var methodsResizing = map[apiMethod]struct{}{
@@ -1408,4 +1762,15 @@ var methodsNormal = map[apiMethod]struct{}{
 	apiShardNodes:           {},
 	apiViews:                {},
 	apiApplySchema:          {},
+	apiIngest:               {},
+	apiExportIndex:          {},
+	apiExportShard:          {},
+	apiBackup:               {},
+	apiBlockBackup:          {},
+	apiPinBackup:            {},
+	apiBackupFragment:       {},
+	apiRestore:              {},
+	apiRestoreFragment:      {},
+	apiRestoreAttrs:         {},
+	apiRestoreKeys:          {},
 }
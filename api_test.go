@@ -261,6 +261,103 @@ func TestAPI_ImportValue(t *testing.T) {
 }
 
 // offsetModHasher represents a simple, mod-based hashing offset by 1.
+func TestAPI_SQL(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	m := c[0]
+	ctx := context.Background()
+
+	idx := pilosa.IndexOptions{TrackExistence: true}
+	c.CreateField(t, "i", idx, "color")
+	c.CreateField(t, "i", idx, "size", pilosa.OptFieldTypeSet(pilosa.DefaultCacheType, 100))
+	c.CreateField(t, "i", idx, "age", pilosa.OptFieldTypeInt(0, 1000))
+	c.CreateField(t, "i", idx, "active", pilosa.OptFieldTypeBool())
+	c.Query(t, "i", fmt.Sprintf(`
+		Set(1, color=1) Set(1, size=10) Set(1, age=20) Set(1, active=true)
+		Set(2, color=1) Set(2, size=20) Set(2, age=30) Set(2, active=false)
+		Set(%d, color=2) Set(%d, size=10) Set(%d, age=40) Set(%d, active=true)
+		Set(4, color=2)
+	`, pilosa.ShardWidth+3, pilosa.ShardWidth+3, pilosa.ShardWidth+3, pilosa.ShardWidth+3))
+
+	tests := []struct {
+		sql  string
+		cols []string
+		rows [][]interface{}
+	}{
+		{
+			sql:  "SELECT COUNT(*) FROM i",
+			cols: []string{"count(*)"},
+			rows: [][]interface{}{{uint64(4)}},
+		},
+		{
+			sql:  "select count(*), sum(age), min(age), max(age) from i where color = 1",
+			cols: []string{"count(*)", "sum(age)", "min(age)", "max(age)"},
+			rows: [][]interface{}{{uint64(2), int64(50), int64(20), int64(30)}},
+		},
+		{
+			sql:  "SELECT COUNT(*) FROM i WHERE age > 20 AND size = 10",
+			cols: []string{"count(*)"},
+			rows: [][]interface{}{{uint64(1)}},
+		},
+		{
+			sql:  "SELECT SUM(age) FROM i WHERE active != TRUE",
+			cols: []string{"sum(age)"},
+			rows: [][]interface{}{{int64(30)}},
+		},
+		{
+			sql:  "SELECT SUM(age) FROM i WHERE color = 3",
+			cols: []string{"sum(age)"},
+			rows: [][]interface{}{{nil}},
+		},
+		{
+			sql:  "SELECT _id FROM i WHERE color != 1 LIMIT 1",
+			cols: []string{"_id"},
+			rows: [][]interface{}{{uint64(4)}},
+		},
+		{
+			sql:  "SELECT color, size, COUNT(*) FROM i GROUP BY color, size",
+			cols: []string{"color", "size", "count(*)"},
+			rows: [][]interface{}{
+				{uint64(1), uint64(10), uint64(1)},
+				{uint64(1), uint64(20), uint64(1)},
+				{uint64(2), uint64(10), uint64(1)},
+			},
+		},
+		{
+			sql:  "SELECT COUNT(*), color FROM i WHERE age >= 30 GROUP BY color LIMIT 1",
+			cols: []string{"count(*)", "color"},
+			rows: [][]interface{}{{uint64(1), uint64(1)}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.sql, func(t *testing.T) {
+			res, err := m.API.SQL(ctx, test.sql)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(res.Columns, test.cols) {
+				t.Fatalf("unexpected columns: %v", res.Columns)
+			} else if !reflect.DeepEqual(res.Rows, test.rows) {
+				t.Fatalf("unexpected rows: %v", res.Rows)
+			}
+		})
+	}
+
+	t.Run("Errors", func(t *testing.T) {
+		for sql, msg := range map[string]string{
+			"SELECT COUNT(*) FROM x":                    "index not found",
+			"SELECT COUNT(*) FROM i WHERE color = 1 OR": "OR conditions are not supported",
+			"SELECT SUM(color) FROM i":                  "SUM requires an int field",
+			"SELECT color FROM i":                       "color requires it to appear in GROUP BY",
+			"SELECT COUNT(*) FROM i WHERE nope = 1":     "field not found: nope",
+		} {
+			if _, err := m.API.SQL(ctx, sql); err == nil || !strings.Contains(err.Error(), msg) {
+				t.Errorf("%s: expected error containing %q, got %v", sql, msg, err)
+			}
+		}
+	})
+}
+
 type offsetModHasher struct{}
 
 func (*offsetModHasher) Hash(key uint64, n int) int {
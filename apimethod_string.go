@@ -33,11 +33,24 @@ func _() {
 	_ = x[apiShardNodes-22]
 	_ = x[apiViews-23]
 	_ = x[apiApplySchema-24]
+	_ = x[apiIngest-25]
+	_ = x[apiExportIndex-26]
+	_ = x[apiExportShard-27]
+	_ = x[apiBackup-28]
+	_ = x[apiBlockBackup-29]
+	_ = x[apiUnblockBackup-30]
+	_ = x[apiPinBackup-31]
+	_ = x[apiBackupFragment-32]
+	_ = x[apiReleaseBackup-33]
+	_ = x[apiRestore-34]
+	_ = x[apiRestoreFragment-35]
+	_ = x[apiRestoreAttrs-36]
+	_ = x[apiRestoreKeys-37]
 }
 
-const _apiMethod_name = "apiClusterMessageapiCreateFieldapiCreateIndexapiDeleteFieldapiDeleteAvailableShardapiDeleteIndexapiDeleteViewapiExportCSVapiFragmentBlockDataapiFragmentBlocksapiFragmentDataapiFieldapiFieldAttrDiffapiImportapiImportValueapiIndexapiIndexAttrDiffapiQueryapiRecalculateCachesapiRemoveNodeapiResizeAbortapiSetCoordinatorapiShardNodesapiViewsapiApplySchema"
+const _apiMethod_name = "apiClusterMessageapiCreateFieldapiCreateIndexapiDeleteFieldapiDeleteAvailableShardapiDeleteIndexapiDeleteViewapiExportCSVapiFragmentBlockDataapiFragmentBlocksapiFragmentDataapiFieldapiFieldAttrDiffapiImportapiImportValueapiIndexapiIndexAttrDiffapiQueryapiRecalculateCachesapiRemoveNodeapiResizeAbortapiSetCoordinatorapiShardNodesapiViewsapiApplySchemaapiIngestapiExportIndexapiExportShardapiBackupapiBlockBackupapiUnblockBackupapiPinBackupapiBackupFragmentapiReleaseBackupapiRestoreapiRestoreFragmentapiRestoreAttrsapiRestoreKeys"
 
-var _apiMethod_index = [...]uint16{0, 17, 31, 45, 59, 82, 96, 109, 121, 141, 158, 173, 181, 197, 206, 220, 228, 244, 252, 272, 285, 299, 316, 329, 337, 351}
+var _apiMethod_index = [...]uint16{0, 17, 31, 45, 59, 82, 96, 109, 121, 141, 158, 173, 181, 197, 206, 220, 228, 244, 252, 272, 285, 299, 316, 329, 337, 351, 360, 374, 388, 397, 411, 427, 439, 456, 472, 482, 500, 515, 529}
 
 func (i apiMethod) String() string {
 	if i < 0 || i >= apiMethod(len(_apiMethod_index)-1) {
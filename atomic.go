@@ -0,0 +1,713 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pilosa
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pilosa/pilosa/v2/pql"
+	"github.com/pilosa/pilosa/v2/tracing"
+	"github.com/pkg/errors"
+)
+
+// atomicBatchTimeout is how long a node keeps an atomic batch, either staged
+// or, once committed, so that the coordinator can still roll it back.
+var atomicBatchTimeout = time.Minute
+
+// atomicBatchSeq is used to generate unique batch identifiers.
+var atomicBatchSeq uint64
+
+// atomicMutation is a validated Set() or Clear() call of an atomic query.
+type atomicMutation struct {
+	field     *Field
+	columnID  uint64
+	rowID     uint64
+	value     int64
+	timestamp *time.Time
+	clear     bool
+}
+
+// shard returns the shard written by the mutation.
+func (m *atomicMutation) shard() uint64 { return m.columnID / ShardWidth }
+
+// parseAtomicMutation validates c as a write of an atomic query, without
+// applying it.
+func parseAtomicMutation(idx *Index, c *pql.Call) (*atomicMutation, error) {
+	if c.Name != "Set" && c.Name != "Clear" {
+		return nil, ErrAtomicCallNotAllowed
+	}
+	m := &atomicMutation{clear: c.Name == "Clear"}
+
+	columnID, ok, err := c.UintArg("_" + columnLabel)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s() column: %v", c.Name, err)
+	} else if !ok {
+		return nil, fmt.Errorf("%s() column argument '%v' required", c.Name, columnLabel)
+	}
+	m.columnID = columnID
+
+	fieldName, err := c.FieldArg()
+	if err != nil {
+		return nil, fmt.Errorf("%s() argument required: field", c.Name)
+	}
+	if m.field = idx.Field(fieldName); m.field == nil {
+		return nil, newNotFoundError(ErrFieldNotFound, fieldName)
+	}
+
+	// Int fields are only written by Set() and are range checked up front
+	// so that no value can fail once the batch is being applied.
+	if m.field.Type() == FieldTypeInt {
+		if m.clear {
+			return nil, fmt.Errorf("Clear() is not supported on int field '%s' in an atomic query", fieldName)
+		}
+		value, ok, err := c.IntArg(fieldName)
+		if err != nil {
+			return nil, fmt.Errorf("reading Set() row: %v", err)
+		} else if !ok {
+			return nil, fmt.Errorf("Set() row argument '%v' required", rowLabel)
+		}
+		bsig := m.field.bsiGroup(fieldName)
+		if bsig == nil {
+			return nil, ErrBSIGroupNotFound
+		} else if value < bsig.Min {
+			return nil, ErrBSIGroupValueTooLow
+		} else if value > bsig.Max {
+			return nil, ErrBSIGroupValueTooHigh
+		}
+		m.value = value
+		return m, nil
+	}
+
+	rowID, ok, err := c.UintArg(fieldName)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s() row: %v", c.Name, err)
+	} else if !ok {
+		return nil, fmt.Errorf("%s() row argument '%v' required", c.Name, rowLabel)
+	}
+	m.rowID = rowID
+
+	if s, ok := c.Args["_timestamp"].(string); ok && !m.clear {
+		t, err := time.Parse(TimeFormat, s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date: %s", s)
+		}
+		m.timestamp = &t
+	}
+	return m, nil
+}
+
+// atomicOp is a single write staged against a fragment.
+type atomicOp struct {
+	mutation int
+	frag     *fragment
+	rowID    uint64
+	columnID uint64
+	clear    bool
+
+	// If bsig is set, the op writes value to the column's bit planes.
+	bsig  *bsiGroup
+	value int64
+}
+
+// atomicChange records a bit flipped by an atomic batch so that it can be
+// reverted.
+type atomicChange struct {
+	frag     *fragment
+	rowID    uint64
+	columnID uint64
+	set      bool
+
+	// If value is set, the bit is part of an int value written by the
+	// batch, and is only reverted along with the rest of the value.
+	value *atomicValue
+}
+
+// atomicValue is an int value written to a column by an atomic batch.
+type atomicValue struct {
+	bitDepth uint
+	value    int64
+}
+
+// stageAtomicMutations resolves the fragments written by each mutation,
+// creating them as needed. Nothing is written to the fragments yet.
+func stageAtomicMutations(idx *Index, mutations []*atomicMutation) ([]atomicOp, error) {
+	var ops []atomicOp
+	for i, m := range mutations {
+		shard := m.shard()
+
+		if m.clear {
+			// Clear the bit in the standard view and every time view.
+			for _, v := range m.field.views() {
+				if v.name != viewStandard && !strings.HasPrefix(v.name, viewStandard+"_") {
+					continue
+				}
+				if frag := v.Fragment(shard); frag != nil {
+					ops = append(ops, atomicOp{mutation: i, frag: frag, rowID: m.rowID, columnID: m.columnID, clear: true})
+				}
+			}
+			continue
+		}
+
+		// Set column on existence field.
+		if ef := idx.existenceField(); ef != nil {
+			frag, err := createFragmentIfNotExists(ef, viewStandard, shard)
+			if err != nil {
+				return nil, errors.Wrap(err, "staging existence column")
+			}
+			ops = append(ops, atomicOp{mutation: i, frag: frag, rowID: 0, columnID: m.columnID})
+		}
+
+		if m.field.Type() == FieldTypeInt {
+			bsig := m.field.bsiGroup(m.field.name)
+			baseValue := m.value - bsig.Base
+			if err := m.field.growBitDepth(bsig, bitDepthInt64(baseValue)); err != nil {
+				return nil, err
+			}
+			frag, err := createFragmentIfNotExists(m.field, ViewBSIGroupPrefix+m.field.name, shard)
+			if err != nil {
+				return nil, err
+			}
+			ops = append(ops, atomicOp{mutation: i, frag: frag, columnID: m.columnID, bsig: bsig, value: baseValue})
+			continue
+		}
+
+		var viewNames []string
+		if !m.field.options.NoStandardView {
+			viewNames = append(viewNames, viewStandard)
+		}
+		if m.timestamp != nil {
+			viewNames = append(viewNames, viewsByTime(viewStandard, *m.timestamp, m.field.TimeQuantum())...)
+		}
+		for _, name := range viewNames {
+			frag, err := createFragmentIfNotExists(m.field, name, shard)
+			if err != nil {
+				return nil, err
+			}
+			ops = append(ops, atomicOp{mutation: i, frag: frag, rowID: m.rowID, columnID: m.columnID})
+		}
+	}
+	return ops, nil
+}
+
+// createFragmentIfNotExists returns the fragment for a shard of a field's
+// view, creating the view and fragment if necessary.
+func createFragmentIfNotExists(f *Field, viewName string, shard uint64) (*fragment, error) {
+	view, err := f.createViewIfNotExists(viewName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "creating view %s", viewName)
+	}
+	frag, err := view.CreateFragmentIfNotExists(shard)
+	if err != nil {
+		return nil, errors.Wrapf(err, "creating fragment %s/%d", viewName, shard)
+	}
+	return frag, nil
+}
+
+// lockFragments write locks every fragment in frags, in a consistent
+// order so that concurrent batches can't deadlock, and reopens any which
+// were closed. The returned function releases the locks.
+func lockFragments(frags []*fragment) (unlock func(), err error) {
+	locked, unlockAll := lockFragmentsNoReopen(frags)
+
+	var mustClose []*fragment
+	unlock = func() {
+		for _, frag := range mustClose {
+			frag.safeClose()
+		}
+		unlockAll()
+	}
+	for _, frag := range locked {
+		mc, err := frag.reopen()
+		if err != nil {
+			unlock()
+			return nil, errors.Wrap(err, "reopening")
+		} else if mc {
+			mustClose = append(mustClose, frag)
+		}
+	}
+	return unlock, nil
+}
+
+// lockFragmentsNoReopen is lockFragments for callers which don't read or
+// write the fragments' storage, so closed fragments aren't reopened and cold
+// ones aren't loaded. It returns the fragments locked, without duplicates.
+func lockFragmentsNoReopen(frags []*fragment) (locked []*fragment, unlock func()) {
+	seen := make(map[*fragment]struct{}, len(frags))
+	locked = make([]*fragment, 0, len(frags))
+	for _, frag := range frags {
+		if _, ok := seen[frag]; !ok {
+			seen[frag] = struct{}{}
+			locked = append(locked, frag)
+		}
+	}
+	sort.Slice(locked, func(i, j int) bool { return locked[i].path < locked[j].path })
+
+	for _, frag := range locked {
+		frag.mu.Lock()
+	}
+	return locked, func() {
+		for i := len(locked) - 1; i >= 0; i-- {
+			locked[i].mu.Unlock()
+		}
+	}
+}
+
+// applyAtomicOps applies ops while holding the lock of every fragment they
+// write, so that no reader can observe part of the batch. If an op fails,
+// the bits already flipped are reverted before returning the error.
+//
+// changed reports for each of the n mutations whether it flipped any bit.
+func applyAtomicOps(ops []atomicOp, n int) (changes []atomicChange, changed []bool, err error) {
+	frags := make([]*fragment, len(ops))
+	for i := range ops {
+		frags[i] = ops[i].frag
+	}
+	unlock, err := lockFragments(frags)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer unlock()
+
+	changed = make([]bool, n)
+	var value *atomicValue
+	write := func(op *atomicOp, rowID uint64, clear bool) error {
+		var c bool
+		var err error
+		if clear {
+			c, err = op.frag.unprotectedClearBit(rowID, op.columnID)
+		} else {
+			c, err = op.frag.unprotectedSetBit(rowID, op.columnID)
+		}
+		if err != nil {
+			return err
+		} else if c {
+			changes = append(changes, atomicChange{frag: op.frag, rowID: rowID, columnID: op.columnID, set: !clear, value: value})
+			changed[op.mutation] = true
+		}
+		return nil
+	}
+
+	for i := range ops {
+		value = nil
+		if op := &ops[i]; op.bsig != nil {
+			value = &atomicValue{bitDepth: op.bsig.BitDepth, value: op.value}
+		}
+		if err = applyAtomicOp(&ops[i], write); err != nil {
+			if rerr := revertAtomicChanges(changes); rerr != nil {
+				return nil, nil, errors.Wrapf(rerr, "reverting after: %v", err)
+			}
+			return nil, nil, err
+		}
+	}
+	return changes, changed, nil
+}
+
+// applyAtomicOp performs the bit writes for a single op.
+func applyAtomicOp(op *atomicOp, write func(op *atomicOp, rowID uint64, clear bool) error) error {
+	if op.bsig != nil {
+		uvalue := uint64(op.value)
+		if op.value < 0 {
+			uvalue = uint64(-op.value)
+		}
+		if err := write(op, bsiExistsBit, false); err != nil {
+			return errors.Wrap(err, "writing not-null bit")
+		} else if err := write(op, bsiSignBit, op.value >= 0); err != nil {
+			return errors.Wrap(err, "writing sign bit")
+		}
+		for i := uint(0); i < op.bsig.BitDepth; i++ {
+			if err := write(op, uint64(BSIOffsetBit+i), uvalue&(1<<i) == 0); err != nil {
+				return errors.Wrap(err, "writing value bit")
+			}
+		}
+		return nil
+	}
+
+	// Mutex and bool fields hold at most one row per column.
+	if !op.clear && op.frag.mutexVector != nil {
+		if existingRowID, found, err := op.frag.mutexVector.Get(op.columnID); err != nil {
+			return errors.Wrap(err, "getting mutex vector data")
+		} else if found && existingRowID != op.rowID {
+			if err := write(op, existingRowID, true); err != nil {
+				return errors.Wrap(err, "clearing mutex value")
+			}
+		}
+	}
+	return write(op, op.rowID, op.clear)
+}
+
+// revertAtomicChanges undoes changes, most recent first. The fragments must
+// already be locked.
+//
+// Other requests may have written the same bits since the changes were
+// made, so a bit is only reverted if it still holds the value the batch
+// wrote, and an int value only if the whole value is unchanged. A bit
+// cleared from a mutex field is only restored if the column has no other
+// row set.
+func revertAtomicChanges(changes []atomicChange) error {
+	unchanged := make(map[*atomicValue]bool)
+	for i := len(changes) - 1; i >= 0; i-- {
+		c := changes[i]
+		if c.value != nil {
+			ok, found := unchanged[c.value]
+			if !found {
+				v, exists, err := c.frag.unprotectedValue(c.columnID, c.value.bitDepth)
+				if err != nil {
+					return errors.Wrap(err, "reading value")
+				}
+				ok = exists && v == c.value.value
+				unchanged[c.value] = ok
+			}
+			if !ok {
+				continue
+			}
+		} else if v, err := c.frag.bit(c.rowID, c.columnID); err != nil {
+			return errors.Wrap(err, "reading bit")
+		} else if v != c.set {
+			continue
+		}
+
+		var err error
+		if c.set {
+			_, err = c.frag.unprotectedClearBit(c.rowID, c.columnID)
+		} else if c.frag.mutexVector != nil && c.value == nil {
+			if _, found, gerr := c.frag.mutexVector.Get(c.columnID); gerr != nil {
+				err = gerr
+			} else if !found {
+				_, err = c.frag.unprotectedSetBit(c.rowID, c.columnID)
+			}
+		} else {
+			_, err = c.frag.unprotectedSetBit(c.rowID, c.columnID)
+		}
+		if err != nil {
+			return errors.Wrap(err, "reverting bit")
+		}
+	}
+	return nil
+}
+
+// atomicBatch is the part of an atomic batch staged on this node. Its ops
+// are only written to the fragments when the coordinator commits it. After
+// that the changes are kept, so that the coordinator can still roll them
+// back if the batch failed on another node, until the batch is released or
+// expires.
+type atomicBatch struct {
+	mu      sync.Mutex
+	ops     []atomicOp
+	n       int
+	applied bool
+	changes []atomicChange
+	timer   *time.Timer
+}
+
+// prepareAtomicBatch stages mutations on this node under id, without
+// writing them. The batch is dropped if it isn't released or rolled back
+// within atomicBatchTimeout.
+func (e *executor) prepareAtomicBatch(idx *Index, id string, mutations []*atomicMutation) error {
+	ops, err := stageAtomicMutations(idx, mutations)
+	if err != nil {
+		return errors.Wrap(err, "staging")
+	}
+
+	e.atomicMu.Lock()
+	defer e.atomicMu.Unlock()
+	if e.atomicBatches == nil {
+		e.atomicBatches = make(map[string]*atomicBatch)
+	}
+	if _, ok := e.atomicBatches[id]; ok {
+		return fmt.Errorf("atomic batch %s already exists", id)
+	}
+	e.atomicBatches[id] = &atomicBatch{
+		ops:   ops,
+		n:     len(mutations),
+		timer: time.AfterFunc(atomicBatchTimeout, func() { e.removeAtomicBatch(id) }),
+	}
+	return nil
+}
+
+// commitAtomicBatch writes the ops of a staged batch, returning whether
+// each of its mutations changed any data.
+func (e *executor) commitAtomicBatch(id string) ([]bool, error) {
+	e.atomicMu.Lock()
+	b := e.atomicBatches[id]
+	e.atomicMu.Unlock()
+	if b == nil {
+		return nil, fmt.Errorf("atomic batch %s not found or expired", id)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.applied {
+		return nil, fmt.Errorf("atomic batch %s already committed", id)
+	}
+	changes, changed, err := applyAtomicOps(b.ops, b.n)
+	if err != nil {
+		return nil, err
+	}
+	b.ops, b.changes, b.applied = nil, changes, true
+	return changed, nil
+}
+
+// rollbackAtomicBatch drops a batch. If it was already committed, its
+// changes are reverted, except for those overwritten by other requests
+// since.
+func (e *executor) rollbackAtomicBatch(id string) error {
+	b := e.removeAtomicBatch(id)
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.applied {
+		return nil
+	}
+	frags := make([]*fragment, len(b.changes))
+	for i := range b.changes {
+		frags[i] = b.changes[i].frag
+	}
+	unlock, err := lockFragments(frags)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return revertAtomicChanges(b.changes)
+}
+
+// removeAtomicBatch removes a batch from the executor and returns it, or
+// nil if it doesn't exist. A committed batch which is removed can no longer
+// be rolled back.
+func (e *executor) removeAtomicBatch(id string) *atomicBatch {
+	e.atomicMu.Lock()
+	defer e.atomicMu.Unlock()
+	b := e.atomicBatches[id]
+	if b != nil {
+		b.timer.Stop()
+		delete(e.atomicBatches, id)
+	}
+	return b
+}
+
+// executeAtomic executes the calls of an atomic query. Every call is
+// validated before anything is written. The batch is then applied in two
+// phases: each owner of an affected shard first stages its part of the
+// batch, and only once every owner has staged it are the writes committed,
+// each owner writing its part with all of the fragments involved locked.
+// If staging fails on any node, nothing is written. If committing fails on
+// a node, the nodes which already committed roll their writes back.
+//
+// The result of each call is true if it changed any data.
+func (e *executor) executeAtomic(ctx context.Context, index string, calls []*pql.Call, opt *execOptions) ([]interface{}, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx, "Executor.executeAtomic")
+	defer span.Finish()
+
+	idx := e.Holder.Index(index)
+	if idx == nil {
+		return nil, newNotFoundError(ErrIndexNotFound, index)
+	}
+
+	mutations := make([]*atomicMutation, len(calls))
+	for i, c := range calls {
+		m, err := parseAtomicMutation(idx, c)
+		if err != nil {
+			return nil, err
+		}
+		mutations[i] = m
+	}
+
+	// Group the mutations by the nodes which own their shards.
+	var nodes []*Node
+	byNode := make(map[string][]int)
+	for i, m := range mutations {
+		for _, node := range e.Cluster.shardNodes(index, m.shard()) {
+			if _, ok := byNode[node.ID]; !ok {
+				nodes = append(nodes, node)
+			}
+			byNode[node.ID] = append(byNode[node.ID], i)
+		}
+	}
+
+	id := fmt.Sprintf("%s-%d-%d", e.Node.ID, time.Now().UnixNano(), atomic.AddUint64(&atomicBatchSeq, 1))
+
+	// Stage the batch on every node.
+	var prepared []*Node
+	for _, node := range nodes {
+		if err := e.prepareAtomicBatchOn(ctx, node, idx, id, calls, mutations, byNode[node.ID]); err != nil {
+			if rerr := e.finishAtomicBatch(ctx, index, id, prepared, true); rerr != nil {
+				return nil, errors.Wrapf(rerr, "rolling back after: %v", err)
+			}
+			return nil, errors.Wrapf(err, "staging on node %s", node.ID)
+		}
+		prepared = append(prepared, node)
+	}
+
+	// Commit it on every node.
+	results := make([]interface{}, len(calls))
+	for i := range results {
+		results[i] = false
+	}
+	for _, node := range nodes {
+		changed, err := e.commitAtomicBatchOn(ctx, node, index, id, len(byNode[node.ID]))
+		if err != nil {
+			if rerr := e.finishAtomicBatch(ctx, index, id, nodes, true); rerr != nil {
+				return nil, errors.Wrapf(rerr, "rolling back after: %v", err)
+			}
+			return nil, errors.Wrapf(err, "committing on node %s", node.ID)
+		}
+		for j, i := range byNode[node.ID] {
+			if changed[j] {
+				results[i] = true
+			}
+		}
+	}
+
+	// Every node has committed its writes, so they no longer need to be
+	// kept for rolling back.
+	if err := e.finishAtomicBatch(ctx, index, id, nodes, false); err != nil {
+		e.Holder.Logger.Printf("releasing atomic batch %s: %s", id, err)
+	}
+	return results, nil
+}
+
+// prepareAtomicBatchOn stages the mutations at indexes on node.
+func (e *executor) prepareAtomicBatchOn(ctx context.Context, node *Node, idx *Index, id string, calls []*pql.Call, mutations []*atomicMutation, indexes []int) error {
+	if node.ID == e.Node.ID {
+		local := make([]*atomicMutation, len(indexes))
+		for j, i := range indexes {
+			local[j] = mutations[i]
+		}
+		return e.prepareAtomicBatch(idx, id, local)
+	}
+
+	c := &pql.Call{Name: "Atomic", Args: map[string]interface{}{"batch": id}}
+	for _, i := range indexes {
+		c.Children = append(c.Children, calls[i])
+	}
+	_, err := e.remoteExec(ctx, node, idx.Name(), &pql.Query{Calls: []*pql.Call{c}}, nil)
+	return err
+}
+
+// commitAtomicBatchOn commits the batch staged on node, returning whether
+// each of its n mutations changed any data.
+func (e *executor) commitAtomicBatchOn(ctx context.Context, node *Node, index, id string, n int) ([]bool, error) {
+	if node.ID == e.Node.ID {
+		return e.commitAtomicBatch(id)
+	}
+
+	c := &pql.Call{Name: "Atomic", Args: map[string]interface{}{"batch": id, "commit": true}}
+	res, err := e.remoteExec(ctx, node, index, &pql.Query{Calls: []*pql.Call{c}}, nil)
+	if err != nil {
+		return nil, err
+	}
+	row, ok := res[0].(*Row)
+	if !ok {
+		return nil, fmt.Errorf("unexpected Atomic() result: %T", res[0])
+	}
+	changed := make([]bool, n)
+	for _, j := range row.Columns() {
+		if j < uint64(len(changed)) {
+			changed[j] = true
+		}
+	}
+	return changed, nil
+}
+
+// finishAtomicBatch releases or rolls back a batch on each of nodes.
+func (e *executor) finishAtomicBatch(ctx context.Context, index, id string, nodes []*Node, rollback bool) error {
+	for _, node := range nodes {
+		if node.ID == e.Node.ID {
+			if !rollback {
+				e.removeAtomicBatch(id)
+			} else if err := e.rollbackAtomicBatch(id); err != nil {
+				return err
+			}
+			continue
+		}
+
+		action := "release"
+		if rollback {
+			action = "rollback"
+		}
+		c := &pql.Call{Name: "Atomic", Args: map[string]interface{}{"batch": id, action: true}}
+		if _, err := e.remoteExec(ctx, node, index, &pql.Query{Calls: []*pql.Call{c}}, nil); err != nil {
+			return errors.Wrapf(err, "%s on node %s", action, node.ID)
+		}
+	}
+	return nil
+}
+
+// executeAtomicCall executes an Atomic() call sent by the coordinator of an
+// atomic query. With Set() and Clear() children, it stages them on this
+// node. Otherwise it commits, rolls back or releases a staged batch. A
+// commit returns a row with a column set for each child that changed any
+// data.
+func (e *executor) executeAtomicCall(ctx context.Context, index string, c *pql.Call, opt *execOptions) (interface{}, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "Executor.executeAtomicCall")
+	defer span.Finish()
+
+	if !opt.Remote {
+		return nil, errors.New("Atomic() is internal, use the atomic query option instead")
+	}
+	id, ok := c.Args["batch"].(string)
+	if !ok || id == "" {
+		return nil, errors.New("Atomic() argument required: batch")
+	}
+
+	if rollback, _, err := c.BoolArg("rollback"); err != nil {
+		return nil, err
+	} else if rollback {
+		return true, e.rollbackAtomicBatch(id)
+	}
+	if release, _, err := c.BoolArg("release"); err != nil {
+		return nil, err
+	} else if release {
+		e.removeAtomicBatch(id)
+		return true, nil
+	}
+	if commit, _, err := c.BoolArg("commit"); err != nil {
+		return nil, err
+	} else if commit {
+		changed, err := e.commitAtomicBatch(id)
+		if err != nil {
+			return nil, err
+		}
+		row := NewRow()
+		for i, v := range changed {
+			if v {
+				row.SetBit(uint64(i))
+			}
+		}
+		return row, nil
+	}
+
+	idx := e.Holder.Index(index)
+	if idx == nil {
+		return nil, newNotFoundError(ErrIndexNotFound, index)
+	}
+	mutations := make([]*atomicMutation, len(c.Children))
+	for i, child := range c.Children {
+		m, err := parseAtomicMutation(idx, child)
+		if err != nil {
+			return nil, err
+		}
+		mutations[i] = m
+	}
+	return true, e.prepareAtomicBatch(idx, id, mutations)
+}
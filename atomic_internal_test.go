@@ -0,0 +1,172 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pilosa
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Ensure a failing op reverts the ops of the batch applied before it.
+func TestApplyAtomicOps(t *testing.T) {
+	f := mustOpenFragment("i", "f", viewStandard, 0, "")
+	defer f.Clean(t)
+	m := mustOpenMutexFragment("i", "m", viewStandard, 0, "")
+	defer m.Clean(t)
+	b := mustOpenBSIFragment("i", "v", ViewBSIGroupPrefix+"v", 0)
+	defer b.Clean(t)
+	bsig := &bsiGroup{BitDepth: 4}
+
+	if _, err := f.setBit(1, 100); err != nil {
+		t.Fatal(err)
+	} else if _, err := m.setBit(2, 100); err != nil {
+		t.Fatal(err)
+	} else if _, err := b.setValue(100, bsig.BitDepth, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	ops := []atomicOp{
+		{mutation: 0, frag: f, rowID: 1, columnID: 100, clear: true},
+		{mutation: 1, frag: m, rowID: 3, columnID: 100},
+		{mutation: 2, frag: b, columnID: 100, bsig: bsig, value: -12},
+	}
+
+	t.Run("Rollback", func(t *testing.T) {
+		// A column outside of the fragment's shard can't be written.
+		bad := append(append([]atomicOp{}, ops...), atomicOp{mutation: 3, frag: f, rowID: 1, columnID: 2 * ShardWidth})
+		if _, _, err := applyAtomicOps(bad, 4); err == nil {
+			t.Fatal("expected error")
+		}
+		if cols := f.row(1).Columns(); !reflect.DeepEqual(cols, []uint64{100}) {
+			t.Fatalf("unexpected row 1: %v", cols)
+		} else if cols := m.row(2).Columns(); !reflect.DeepEqual(cols, []uint64{100}) {
+			t.Fatalf("unexpected mutex row 2: %v", cols)
+		} else if cols := m.row(3).Columns(); len(cols) != 0 {
+			t.Fatalf("unexpected mutex row 3: %v", cols)
+		} else if v, ok, err := b.value(100, bsig.BitDepth); err != nil || !ok || v != 3 {
+			t.Fatalf("unexpected value: %d, %v, %v", v, ok, err)
+		}
+	})
+
+	t.Run("Apply", func(t *testing.T) {
+		changes, changed, err := applyAtomicOps(ops, 3)
+		if err != nil {
+			t.Fatal(err)
+		} else if !reflect.DeepEqual(changed, []bool{true, true, true}) {
+			t.Fatalf("unexpected changed: %v", changed)
+		}
+		if cols := f.row(1).Columns(); len(cols) != 0 {
+			t.Fatalf("unexpected row 1: %v", cols)
+		} else if cols := m.row(2).Columns(); len(cols) != 0 {
+			t.Fatalf("unexpected mutex row 2: %v", cols)
+		} else if cols := m.row(3).Columns(); !reflect.DeepEqual(cols, []uint64{100}) {
+			t.Fatalf("unexpected mutex row 3: %v", cols)
+		} else if v, ok, err := b.value(100, bsig.BitDepth); err != nil || !ok || v != -12 {
+			t.Fatalf("unexpected value: %d, %v, %v", v, ok, err)
+		}
+
+		// The recorded changes restore the original state.
+		unlock, err := lockFragments([]*fragment{f, m, b})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = revertAtomicChanges(changes)
+		unlock()
+		if err != nil {
+			t.Fatal(err)
+		} else if v, ok, err := b.value(100, bsig.BitDepth); err != nil || !ok || v != 3 {
+			t.Fatalf("unexpected value: %d, %v, %v", v, ok, err)
+		} else if cols := m.row(2).Columns(); !reflect.DeepEqual(cols, []uint64{100}) {
+			t.Fatalf("unexpected mutex row 2: %v", cols)
+		}
+	})
+}
+
+// Ensure a staged batch is only written when committed, and that rolling it
+// back keeps values written by other requests since.
+func TestExecutor_AtomicBatch(t *testing.T) {
+	h := newHolder()
+	if err := h.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	e := newExecutor()
+	defer e.Close()
+	e.Holder = h.Holder
+
+	idx := h.MustCreateIndexIfNotExists("i", IndexOptions{})
+	m, err := idx.CreateField("m", OptFieldTypeMutex(CacheTypeNone, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := idx.CreateField("v", OptFieldTypeInt(0, 100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.SetBit(2, 1, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	mutations := []*atomicMutation{{field: m, columnID: 1, rowID: 3}, {field: v, columnID: 1, value: 5}}
+	if err := e.prepareAtomicBatch(idx, "a", mutations); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing is written until the batch is committed.
+	if _, exists, err := v.Value(1); err != nil {
+		t.Fatal(err)
+	} else if exists {
+		t.Fatal("expected no value before commit")
+	}
+	if changed, err := e.commitAtomicBatch("a"); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(changed, []bool{true, true}) {
+		t.Fatalf("unexpected changed: %v", changed)
+	} else if value, _, err := v.Value(1); err != nil || value != 5 {
+		t.Fatalf("unexpected value: %d, %v", value, err)
+	} else if _, err := e.commitAtomicBatch("a"); err == nil {
+		t.Fatal("expected error committing twice")
+	}
+
+	// Other requests overwrite the batch's writes before it is rolled back.
+	if _, err := v.SetValue(1, 7); err != nil {
+		t.Fatal(err)
+	} else if _, err := m.SetBit(4, 1, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.rollbackAtomicBatch("a"); err != nil {
+		t.Fatal(err)
+	}
+	if value, _, err := v.Value(1); err != nil || value != 7 {
+		t.Fatalf("unexpected value: %d, %v", value, err)
+	}
+	for rowID, exp := range map[uint64][]uint64{2: nil, 3: nil, 4: {1}} {
+		if cols := m.view(viewStandard).Fragment(0).row(rowID).Columns(); len(cols) != len(exp) || len(exp) > 0 && !reflect.DeepEqual(cols, exp) {
+			t.Fatalf("unexpected mutex row %d: %v", rowID, cols)
+		}
+	}
+
+	// A batch which is never committed or rolled back expires.
+	defer func(d time.Duration) { atomicBatchTimeout = d }(atomicBatchTimeout)
+	atomicBatchTimeout = time.Millisecond
+	if err := e.prepareAtomicBatch(idx, "b", mutations); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if _, err := e.commitAtomicBatch("b"); err == nil {
+		t.Fatal("expected expired batch")
+	}
+}
@@ -0,0 +1,774 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pilosa
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pilosa/pilosa/v2/tracing"
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+	"golang.org/x/sync/errgroup"
+)
+
+// A backup is a tar archive holding a manifest and the schema, and for each
+// index a directory with its column keys and attributes, and a directory for
+// each field with its row keys, row attributes and fragments:
+//
+//	manifest.json
+//	schema.json
+//	<index>/keys.ndjson
+//	<index>/attrs.json
+//	<index>/<field>/keys.ndjson
+//	<index>/<field>/attrs.json
+//	<index>/<field>/views/<view>/fragments/<shard>.tar
+//
+// The manifest is a BackupManifest, and the schema is in the format of GET
+// /schema. Keys are translate entries, one JSON object per line, attributes
+// are a JSON object of attributes by ID, and fragments are archives in the
+// format of fragment.WriteTo. Keys and attributes are left out when there are
+// none.
+//
+// An incremental backup only holds the keys added since its base backup, and
+// the fragments changed since. The archive of a fragment whose data file
+// hasn't been replaced by a snapshot holds an "ops" entry, with the ops
+// appended to the data file since, in place of its "data" entry. Attributes
+// are always backed up in full.
+const (
+	backupManifestName = "manifest.json"
+	backupSchemaName   = "schema.json"
+	backupKeysName     = "keys.ndjson"
+	backupAttrsName    = "attrs.json"
+)
+
+// backupDir is the directory, within the holder's, in which fragments are
+// pinned for backups.
+const backupDir = ".backup"
+
+// backupBlockTimeout is how long a node blocks writes for a backup before
+// unblocking them itself, in case the coordinator fails to.
+const backupBlockTimeout = time.Minute
+
+// backupTranslateBatchSize is the number of keys translated at once when
+// backing up a translate store.
+const backupTranslateBatchSize = 10000
+
+// BackupFragment identifies a fragment pinned for a backup.
+type BackupFragment struct {
+	Index string `json:"index"`
+	Field string `json:"field"`
+	View  string `json:"view"`
+	Shard uint64 `json:"shard"`
+}
+
+// BackupFragmentInfo describes a fragment pinned for a backup on a node.
+type BackupFragmentInfo struct {
+	BackupFragment
+
+	// Generation identifies the fragment's data file, which changes whenever
+	// the file is replaced.
+	Generation uint64 `json:"generation"`
+
+	// Size is the size of the data file when pinned.
+	Size int64 `json:"size"`
+}
+
+// BackupManifest describes a backup, so that a later incremental backup can
+// hold only what changed since.
+type BackupManifest struct {
+	ID string `json:"id"`
+
+	// Base is the ID of the backup an incremental backup is applied on top
+	// of. It is empty for a full backup.
+	Base string `json:"base,omitempty"`
+
+	// Fragments are the fragments backed up, as pinned on the node they were
+	// copied from, including those left out of an incremental backup as
+	// they hadn't changed.
+	Fragments []BackupFragmentInfo `json:"fragments"`
+
+	// Keys is the greatest ID backed up from each translate store, by the
+	// name of its entry.
+	Keys map[string]uint64 `json:"keys,omitempty"`
+}
+
+// path returns the name of the fragment in a backup.
+func (bf BackupFragment) path() string {
+	return path.Join(bf.Index, bf.Field, "views", bf.View, "fragments", strconv.FormatUint(bf.Shard, 10)+".tar")
+}
+
+// backupReplica is a fragment pinned for a backup on a node.
+type backupReplica struct {
+	node *Node
+	info BackupFragmentInfo
+}
+
+// backupPins holds the fragments pinned for a backup.
+type backupPins struct {
+	path      string
+	fragments map[BackupFragment]*fragmentPin
+}
+
+// backupBarrier blocks the writes made through a node while the nodes of
+// the cluster pin their fragments for a backup. A write holds the barrier of
+// the node it's made through until it's applied on every node it writes to,
+// while the parts of it forwarded to other nodes don't, so once every node
+// has blocked writes none are in progress anywhere in the cluster.
+type backupBarrier struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	writes int    // writes in progress
+	id     string // backup blocking writes, if any
+	timer  *time.Timer
+}
+
+// newBackupBarrier returns a new instance of backupBarrier.
+func newBackupBarrier() *backupBarrier {
+	b := &backupBarrier{}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+// enter waits while writes are blocked, then counts a write in progress
+// until exit is called.
+func (b *backupBarrier) enter() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for b.id != "" {
+		b.cond.Wait()
+	}
+	b.writes++
+}
+
+// exit ends a write started by enter.
+func (b *backupBarrier) exit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.writes--
+	if b.writes == 0 {
+		b.cond.Broadcast()
+	}
+}
+
+// block blocks writes for the backup id, and waits for those in progress to
+// end. Writes are unblocked after timeout unless unblock is called first.
+func (b *backupBarrier) block(id string, timeout time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.id != "" {
+		return errors.Errorf("writes are already blocked by backup %s", b.id)
+	}
+	b.id = id
+	for b.writes > 0 && b.id == id {
+		b.cond.Wait()
+	}
+	if b.id != id {
+		return errors.Errorf("backup %s was released while waiting for writes", id)
+	}
+	b.timer = time.AfterFunc(timeout, func() { b.unblock(id) })
+	return nil
+}
+
+// unblock unblocks writes if they're blocked for the backup id.
+func (b *backupBarrier) unblock(id string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.id != id {
+		return
+	}
+	b.id = ""
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	b.cond.Broadcast()
+}
+
+// hold keeps writes blocked for the backup id until the returned function is
+// called. It fails if writes aren't blocked for the backup.
+func (b *backupBarrier) hold(id string) (release func(), err error) {
+	b.mu.Lock()
+	if b.id != id {
+		b.mu.Unlock()
+		return nil, errors.Errorf("writes aren't blocked for backup %s", id)
+	}
+	return b.mu.Unlock, nil
+}
+
+// pinBackup pins every fragment of the holder for the backup id, and
+// returns them. Writes must be blocked for the backup, so that every node
+// pins its fragments at the same point in time.
+func (h *Holder) pinBackup(id string) ([]BackupFragmentInfo, error) {
+	h.backupMu.Lock()
+	defer h.backupMu.Unlock()
+
+	if _, ok := h.backups[id]; ok {
+		return nil, errors.Errorf("backup %s is already pinned", id)
+	}
+	release, err := h.backupBarrier.hold(id)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	var infos []BackupFragmentInfo
+	var frags []*fragment
+	for _, index := range h.Indexes() {
+		for _, field := range index.Fields() {
+			for _, view := range field.views() {
+				for _, f := range view.allFragments() {
+					bf := BackupFragment{Index: index.Name(), Field: field.Name(), View: view.name, Shard: f.shard}
+					infos = append(infos, BackupFragmentInfo{BackupFragment: bf})
+					frags = append(frags, f)
+				}
+			}
+		}
+	}
+
+	pins := &backupPins{
+		path:      filepath.Join(h.Path, backupDir, id),
+		fragments: make(map[BackupFragment]*fragmentPin, len(frags)),
+	}
+	if err := os.MkdirAll(pins.path, 0777); err != nil {
+		return nil, errors.Wrap(err, "creating directory")
+	}
+
+	// Pinning only links the fragments' data files, so they aren't
+	// reopened and the storage of cold fragments isn't loaded.
+	_, unlock := lockFragmentsNoReopen(frags)
+	defer unlock()
+	for i, f := range frags {
+		p, err := f.unprotectedPin(filepath.Join(pins.path, strconv.Itoa(i)))
+		if err != nil {
+			os.RemoveAll(pins.path)
+			return nil, errors.Wrapf(err, "pinning fragment %s/%s/%s/%d", f.index, f.field, f.view, f.shard)
+		}
+		pins.fragments[infos[i].BackupFragment] = p
+		infos[i].Generation, infos[i].Size = p.generation, p.size
+	}
+
+	h.backups[id] = pins
+	return infos, nil
+}
+
+// backupFragment returns a fragment pinned for the backup id.
+func (h *Holder) backupFragment(id string, bf BackupFragment) (*fragmentPin, error) {
+	h.backupMu.Lock()
+	defer h.backupMu.Unlock()
+
+	pins, ok := h.backups[id]
+	if !ok {
+		return nil, ErrBackupNotFound
+	}
+	p, ok := pins.fragments[bf]
+	if !ok {
+		return nil, ErrFragmentNotFound
+	}
+	return p, nil
+}
+
+// releaseBackup removes the fragments pinned for the backup id, and
+// unblocks writes if they're still blocked for it.
+func (h *Holder) releaseBackup(id string) error {
+	h.backupBarrier.unblock(id)
+
+	h.backupMu.Lock()
+	defer h.backupMu.Unlock()
+
+	pins, ok := h.backups[id]
+	if !ok {
+		return ErrBackupNotFound
+	}
+	delete(h.backups, id)
+	return errors.Wrap(os.RemoveAll(pins.path), "removing pins")
+}
+
+// Backup writes a backup of every index to w. It must be called on the
+// coordinator, which holds the primary translate stores. When base is the
+// manifest of an earlier backup, the backup is an incremental backup holding
+// only what changed since.
+//
+// Every node first blocks writes and waits for those in progress, including
+// the parts of them applied on other nodes, to end. Once writes are blocked
+// on every node, every node pins its fragments, and writes are only unblocked
+// once all of them are pinned. The backup then holds the fragments of the
+// whole cluster as they were at a single point in time, while writes
+// continue. Keys and attributes are read once the fragments are pinned, so
+// that every key of the backed up fragments is included.
+func (api *API) Backup(ctx context.Context, base *BackupManifest, w io.Writer) error {
+	span, ctx := tracing.StartSpanFromContext(ctx, "API.Backup")
+	defer span.Finish()
+
+	if err := api.validate(apiBackup); err != nil {
+		return errors.Wrap(err, "validating api method")
+	}
+	if !api.cluster.isCoordinator() {
+		return ErrNodeNotCoordinator
+	}
+
+	id := uuid.NewV4().String()
+	nodes := api.cluster.Nodes()
+	defer api.releaseBackupNodes(id, nodes)
+	if err := api.blockBackupNodes(ctx, id, nodes); err != nil {
+		return err
+	}
+	pinned, err := api.pinBackupNodes(ctx, id, nodes)
+	api.unblockBackupNodes(id, nodes)
+	if err != nil {
+		return err
+	}
+
+	// Choose the replica to copy each fragment from, and the offset from
+	// which to copy its data file.
+	manifest := &BackupManifest{ID: id, Keys: make(map[string]uint64)}
+	prev := make(map[BackupFragment]BackupFragmentInfo)
+	if base != nil {
+		manifest.Base = base.ID
+		for _, info := range base.Fragments {
+			prev[info.BackupFragment] = info
+		}
+	}
+	replicas := make(map[BackupFragment]backupReplica, len(pinned))
+	offsets := make(map[BackupFragment]int64)
+	for bf, rs := range pinned {
+		r, offset := api.chooseBackupReplica(bf, rs, prev)
+		replicas[bf] = r
+		offsets[bf] = offset
+		manifest.Fragments = append(manifest.Fragments, r.info)
+	}
+	sort.Slice(manifest.Fragments, func(i, j int) bool {
+		return manifest.Fragments[i].path() < manifest.Fragments[j].path()
+	})
+
+	keys, err := api.holder.backupKeyRanges(base)
+	if err != nil {
+		return err
+	}
+	for name, kr := range keys {
+		manifest.Keys[name] = kr.stop
+	}
+
+	tw := tar.NewWriter(w)
+	bw := &backupWriter{tw: tw, dir: filepath.Join(api.holder.Path, backupDir, id)}
+
+	if err := bw.writeJSON(backupManifestName, manifest); err != nil {
+		return errors.Wrap(err, "writing manifest")
+	}
+
+	schema := struct {
+		Indexes []*IndexInfo `json:"indexes"`
+	}{Indexes: api.holder.limitedSchema()}
+	if err := bw.writeJSON(backupSchemaName, schema); err != nil {
+		return errors.Wrap(err, "writing schema")
+	}
+
+	for _, index := range api.holder.Indexes() {
+		api.server.logger.Printf("backing up index %s", index.Name())
+		if kr, ok := keys[path.Join(index.Name(), backupKeysName)]; ok {
+			if err := bw.writeKeys(path.Join(index.Name(), backupKeysName), kr); err != nil {
+				return errors.Wrapf(err, "writing keys of index %s", index.Name())
+			}
+		}
+		if err := bw.writeAttrs(path.Join(index.Name(), backupAttrsName), index.ColumnAttrStore()); err != nil {
+			return errors.Wrapf(err, "writing attributes of index %s", index.Name())
+		}
+		for _, field := range index.Fields() {
+			if kr, ok := keys[path.Join(index.Name(), field.Name(), backupKeysName)]; ok {
+				if err := bw.writeKeys(path.Join(index.Name(), field.Name(), backupKeysName), kr); err != nil {
+					return errors.Wrapf(err, "writing keys of field %s", field.Name())
+				}
+			}
+			if err := bw.writeAttrs(path.Join(index.Name(), field.Name(), backupAttrsName), field.RowAttrStore()); err != nil {
+				return errors.Wrapf(err, "writing attributes of field %s", field.Name())
+			}
+		}
+
+		// Write the fragments of the index by shard, so that progress can be
+		// followed one shard at a time. Fragments without ops appended since
+		// the base backup are left out.
+		var bfs []BackupFragment
+		for bf, r := range replicas {
+			if bf.Index == index.Name() && (offsets[bf] == 0 || offsets[bf] < r.info.Size) {
+				bfs = append(bfs, bf)
+			}
+		}
+		sort.Slice(bfs, func(i, j int) bool {
+			if bfs[i].Shard != bfs[j].Shard {
+				return bfs[i].Shard < bfs[j].Shard
+			} else if bfs[i].Field != bfs[j].Field {
+				return bfs[i].Field < bfs[j].Field
+			}
+			return bfs[i].View < bfs[j].View
+		})
+		for i, bf := range bfs {
+			if i == 0 || bf.Shard != bfs[i-1].Shard {
+				api.server.logger.Printf("backing up index %s shard %d", index.Name(), bf.Shard)
+			}
+			if err := bw.write(bf.path(), func(w io.Writer) error {
+				return api.copyBackupFragment(ctx, id, replicas[bf], offsets[bf], w)
+			}); err != nil {
+				return errors.Wrapf(err, "writing fragment %s/%s/%d", bf.Field, bf.View, bf.Shard)
+			}
+		}
+	}
+
+	return errors.Wrap(tw.Close(), "closing archive")
+}
+
+// backupKeyRange is a range of IDs of a translate store to back up, after
+// start up to and including stop.
+type backupKeyRange struct {
+	store       TranslateStore
+	start, stop uint64
+}
+
+// backupKeyRanges returns the keys to back up from each translate store, by
+// the name of its entry. Keys are only ever added, so an incremental backup
+// holds those added since its base.
+func (h *Holder) backupKeyRanges(base *BackupManifest) (map[string]backupKeyRange, error) {
+	m := make(map[string]backupKeyRange)
+	add := func(name string, store TranslateStore) error {
+		maxID, err := store.MaxID()
+		if err != nil {
+			return errors.Wrapf(err, "reading max id of %s", name)
+		}
+		kr := backupKeyRange{store: store, stop: maxID}
+		if base != nil && base.Keys[name] <= maxID {
+			kr.start = base.Keys[name]
+		}
+		m[name] = kr
+		return nil
+	}
+
+	for _, index := range h.Indexes() {
+		if index.Keys() {
+			if err := add(path.Join(index.Name(), backupKeysName), index.TranslateStore()); err != nil {
+				return nil, err
+			}
+		}
+		for _, field := range index.Fields() {
+			if field.keys() {
+				if err := add(path.Join(index.Name(), field.Name(), backupKeysName), field.TranslateStore()); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return m, nil
+}
+
+// chooseBackupReplica returns the replica to copy a fragment from, and the offset
+// of its data file to copy from. A replica whose data file is the one backed
+// up by the base backup is copied from the size it was then, so that only the
+// ops appended since are copied. Otherwise the whole fragment is copied from
+// the first of the shard's owners to have pinned it, or from another replica.
+func (api *API) chooseBackupReplica(bf BackupFragment, rs []backupReplica, prev map[BackupFragment]BackupFragmentInfo) (backupReplica, int64) {
+	if info, ok := prev[bf]; ok {
+		for _, r := range rs {
+			if r.info.Generation == info.Generation && r.info.Size >= info.Size {
+				return r, info.Size
+			}
+		}
+	}
+
+	for _, owner := range api.cluster.shardNodes(bf.Index, bf.Shard) {
+		for _, r := range rs {
+			if r.node.ID == owner.ID {
+				return r, 0
+			}
+		}
+	}
+	return rs[0], 0
+}
+
+// blockBackupNodes blocks writes on every node for the backup id.
+func (api *API) blockBackupNodes(ctx context.Context, id string, nodes []*Node) error {
+	var eg errgroup.Group
+	for _, node := range nodes {
+		node := node
+		eg.Go(func() error {
+			var err error
+			if node.ID == api.Node().ID {
+				err = api.holder.backupBarrier.block(id, backupBlockTimeout)
+			} else {
+				err = api.server.defaultClient.BlockBackup(ctx, &node.URI, id)
+			}
+			return errors.Wrapf(err, "blocking writes to node %s", node.ID)
+		})
+	}
+	return eg.Wait()
+}
+
+// unblockBackupNodes unblocks writes on every node for the backup id. Errors
+// are only logged, as writes are also unblocked when the backup is released
+// or its block times out.
+func (api *API) unblockBackupNodes(id string, nodes []*Node) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	for _, node := range nodes {
+		if node.ID == api.Node().ID {
+			api.holder.backupBarrier.unblock(id)
+		} else if err := api.server.defaultClient.UnblockBackup(ctx, &node.URI, id); err != nil {
+			api.server.logger.Printf("unblocking writes for backup %s on node %s: %v", id, node.ID, err)
+		}
+	}
+}
+
+// pinBackupNodes pins the fragments of every node for the backup id, and
+// returns the replicas of each pinned fragment.
+func (api *API) pinBackupNodes(ctx context.Context, id string, nodes []*Node) (map[BackupFragment][]backupReplica, error) {
+	pinned := make([][]BackupFragmentInfo, len(nodes))
+	var eg errgroup.Group
+	for i, node := range nodes {
+		i, node := i, node
+		eg.Go(func() (err error) {
+			if node.ID == api.Node().ID {
+				pinned[i], err = api.holder.pinBackup(id)
+			} else {
+				pinned[i], err = api.server.defaultClient.PinBackup(ctx, &node.URI, id)
+			}
+			return errors.Wrapf(err, "pinning fragments of node %s", node.ID)
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	m := make(map[BackupFragment][]backupReplica)
+	for i, infos := range pinned {
+		for _, info := range infos {
+			m[info.BackupFragment] = append(m[info.BackupFragment], backupReplica{node: nodes[i], info: info})
+		}
+	}
+	return m, nil
+}
+
+// releaseBackupNodes releases the fragments pinned for the backup id on every
+// node. Errors are only logged, as pins are also removed when a node restarts.
+func (api *API) releaseBackupNodes(id string, nodes []*Node) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	for _, node := range nodes {
+		var err error
+		if node.ID == api.Node().ID {
+			err = api.holder.releaseBackup(id)
+		} else {
+			err = api.server.defaultClient.ReleaseBackup(ctx, &node.URI, id)
+		}
+		if err != nil && errors.Cause(err) != ErrBackupNotFound {
+			api.server.logger.Printf("releasing backup %s on node %s: %v", id, node.ID, err)
+		}
+	}
+}
+
+// copyBackupFragment copies a fragment pinned on a replica to w, from offset
+// of its data file on.
+func (api *API) copyBackupFragment(ctx context.Context, id string, r backupReplica, offset int64, w io.Writer) error {
+	if r.node.ID == api.Node().ID {
+		p, err := api.BackupFragment(ctx, id, r.info.BackupFragment, offset)
+		if err != nil {
+			return err
+		}
+		_, err = p.WriteTo(w)
+		return err
+	}
+
+	rc, err := api.server.defaultClient.BackupFragment(ctx, &r.node.URI, id, r.info.BackupFragment, offset)
+	if err != nil {
+		return errors.Wrapf(err, "reading from node %s", r.node.ID)
+	}
+	defer rc.Close()
+	_, err = io.Copy(w, rc)
+	return errors.Wrapf(err, "reading from node %s", r.node.ID)
+}
+
+// BlockBackup blocks writes made through this node for the backup id, and
+// waits for those in progress to end.
+func (api *API) BlockBackup(ctx context.Context, id string) error {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.BlockBackup")
+	defer span.Finish()
+
+	if err := api.validate(apiBlockBackup); err != nil {
+		return errors.Wrap(err, "validating api method")
+	}
+	return api.holder.backupBarrier.block(id, backupBlockTimeout)
+}
+
+// UnblockBackup unblocks writes made through this node if they're blocked
+// for the backup id.
+func (api *API) UnblockBackup(ctx context.Context, id string) error {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.UnblockBackup")
+	defer span.Finish()
+
+	if err := api.validate(apiUnblockBackup); err != nil {
+		return errors.Wrap(err, "validating api method")
+	}
+	api.holder.backupBarrier.unblock(id)
+	return nil
+}
+
+// PinBackup pins every fragment of this node for the backup id, and returns
+// them. Writes must be blocked for the backup first.
+func (api *API) PinBackup(ctx context.Context, id string) ([]BackupFragmentInfo, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.PinBackup")
+	defer span.Finish()
+
+	if err := api.validate(apiPinBackup); err != nil {
+		return nil, errors.Wrap(err, "validating api method")
+	}
+	return api.holder.pinBackup(id)
+}
+
+// BackupFragment returns a fragment of this node pinned for the backup id.
+// When offset isn't zero, only the ops appended to its data file after
+// offset are returned.
+func (api *API) BackupFragment(ctx context.Context, id string, bf BackupFragment, offset int64) (io.WriterTo, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.BackupFragment")
+	defer span.Finish()
+
+	if err := api.validate(apiBackupFragment); err != nil {
+		return nil, errors.Wrap(err, "validating api method")
+	}
+	p, err := api.holder.backupFragment(id, bf)
+	if err != nil {
+		return nil, err
+	}
+	return p.since(offset)
+}
+
+// ReleaseBackup removes the fragments of this node pinned for the backup id.
+func (api *API) ReleaseBackup(ctx context.Context, id string) error {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.ReleaseBackup")
+	defer span.Finish()
+
+	if err := api.validate(apiReleaseBackup); err != nil {
+		return errors.Wrap(err, "validating api method")
+	}
+	return api.holder.releaseBackup(id)
+}
+
+// backupWriter writes the entries of a backup. As the size of an entry must
+// be known before it's written, entries are first written to a temporary file
+// in dir.
+type backupWriter struct {
+	tw  *tar.Writer
+	dir string
+}
+
+// write writes an entry named name, with the contents written by fn.
+func (bw *backupWriter) write(name string, fn func(w io.Writer) error) error {
+	file, err := ioutil.TempFile(bw.dir, "entry")
+	if err != nil {
+		return errors.Wrap(err, "creating temporary file")
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	if err := fn(file); err != nil {
+		return err
+	}
+	size, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return errors.Wrap(err, "seeking")
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return errors.Wrap(err, "seeking")
+	}
+
+	if err := bw.tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0600,
+		Size:    size,
+		ModTime: time.Now(),
+	}); err != nil {
+		return errors.Wrap(err, "writing header")
+	}
+	_, err = io.CopyN(bw.tw, file, size)
+	return errors.Wrap(err, "copying")
+}
+
+// writeJSON writes an entry holding v as JSON.
+func (bw *backupWriter) writeJSON(name string, v interface{}) error {
+	return bw.write(name, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(v)
+	})
+}
+
+// writeKeys writes an entry holding a range of keys of a translate store.
+func (bw *backupWriter) writeKeys(name string, kr backupKeyRange) error {
+	if kr.start >= kr.stop {
+		return nil
+	}
+
+	return bw.write(name, func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		for start := kr.start + 1; start <= kr.stop; start += backupTranslateBatchSize {
+			ids := make([]uint64, 0, backupTranslateBatchSize)
+			for id := start; id <= kr.stop && id < start+backupTranslateBatchSize; id++ {
+				ids = append(ids, id)
+			}
+			keys, err := kr.store.TranslateIDs(ids)
+			if err != nil {
+				return errors.Wrap(err, "translating ids")
+			}
+			for i, key := range keys {
+				if key == "" {
+					continue
+				}
+				if err := enc.Encode(TranslateEntry{ID: ids[i], Key: key}); err != nil {
+					return errors.Wrap(err, "encoding key")
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// writeAttrs writes an entry holding the attributes of an attribute store.
+func (bw *backupWriter) writeAttrs(name string, store AttrStore) error {
+	blks, err := store.Blocks()
+	if err != nil {
+		return errors.Wrap(err, "reading blocks")
+	}
+	attrs := make(map[uint64]map[string]interface{})
+	for _, blk := range blks {
+		m, err := store.BlockData(blk.ID)
+		if err != nil {
+			return errors.Wrapf(err, "reading block %d", blk.ID)
+		}
+		for id, a := range m {
+			attrs[id] = a
+		}
+	}
+	if len(attrs) == 0 {
+		return nil
+	}
+	return bw.writeJSON(name, attrs)
+}
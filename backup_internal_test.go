@@ -0,0 +1,225 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pilosa
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// pinBackup pins the fragments of h for the backup id, blocking writes while
+// they're pinned as API.Backup does.
+func pinBackup(h *tHolder, id string) ([]BackupFragmentInfo, error) {
+	if err := h.backupBarrier.block(id, time.Minute); err != nil {
+		return nil, err
+	}
+	defer h.backupBarrier.unblock(id)
+	return h.pinBackup(id)
+}
+
+// Ensure a pinned fragment keeps its data while writes continue.
+func TestHolder_PinBackup(t *testing.T) {
+	h := newHolder()
+	if err := h.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	h.SetBit("i", "f", 1, 1)
+	h.SetBit("i", "f", 1, 2)
+
+	infos, err := pinBackup(h, "b")
+	if err != nil {
+		t.Fatal(err)
+	} else if len(infos) != 1 || infos[0].Generation == 0 || infos[0].Size == 0 {
+		t.Fatalf("unexpected pinned fragments: %+v", infos)
+	}
+	bfs := []BackupFragment{infos[0].BackupFragment}
+	if exp := []BackupFragment{{Index: "i", Field: "f", View: viewStandard, Shard: 0}}; !reflect.DeepEqual(bfs, exp) {
+		t.Fatalf("unexpected pinned fragments: %+v", bfs)
+	}
+
+	// Write to the fragment, and replace its data file with a snapshot.
+	h.SetBit("i", "f", 1, 3)
+	if err := h.fragment("i", "f", viewStandard, 0).Snapshot(); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := h.backupFragment("b", bfs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := p.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	f := mustOpenFragment("i", "f", viewStandard, 0, "")
+	defer f.Clean(t)
+	if _, err := f.ReadFrom(&buf); err != nil {
+		t.Fatal(err)
+	} else if cols := f.row(1).Columns(); !reflect.DeepEqual(cols, []uint64{1, 2}) {
+		t.Fatalf("unexpected pinned columns: %v", cols)
+	}
+
+	if err := h.releaseBackup("b"); err != nil {
+		t.Fatal(err)
+	} else if _, err := os.Stat(filepath.Join(h.Path, backupDir, "b")); !os.IsNotExist(err) {
+		t.Fatalf("expected pins to be removed: %v", err)
+	} else if _, err := h.backupFragment("b", bfs[0]); err != ErrBackupNotFound {
+		t.Fatalf("unexpected error: %v", err)
+	} else if err := h.releaseBackup("b"); err != ErrBackupNotFound {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure the ops appended to a fragment since it was pinned can be replayed
+// on top of the pinned fragment.
+func TestHolder_PinBackupSince(t *testing.T) {
+	h := newHolder()
+	if err := h.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	h.SetBit("i", "f", 1, 1)
+	bf := BackupFragment{Index: "i", Field: "f", View: viewStandard, Shard: 0}
+	base, err := pinBackup(h, "base")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.releaseBackup("base")
+
+	h.SetBit("i", "f", 1, 2)
+	h.SetBit("i", "f", 2, 3)
+	infos, err := pinBackup(h, "incr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.releaseBackup("incr")
+	if infos[0].Generation != base[0].Generation || infos[0].Size <= base[0].Size {
+		t.Fatalf("unexpected pinned fragment: %+v, base %+v", infos[0], base[0])
+	}
+
+	// Restore the base, then replay the ops on top of it.
+	f := mustOpenFragment("i", "f", viewStandard, 0, "")
+	defer f.Clean(t)
+	for _, pin := range []struct {
+		id     string
+		offset int64
+	}{{"base", 0}, {"incr", base[0].Size}} {
+		p, err := h.backupFragment(pin.id, bf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p, err = p.since(pin.offset); err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if _, err := p.WriteTo(&buf); err != nil {
+			t.Fatal(err)
+		} else if _, err := f.ReadFrom(&buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cols := f.row(1).Columns(); !reflect.DeepEqual(cols, []uint64{1, 2}) {
+		t.Fatalf("unexpected columns: %v", cols)
+	} else if cols := f.row(2).Columns(); !reflect.DeepEqual(cols, []uint64{3}) {
+		t.Fatalf("unexpected columns: %v", cols)
+	}
+
+	// A snapshot replaces the data file.
+	if err := h.fragment("i", "f", viewStandard, 0).Snapshot(); err != nil {
+		t.Fatal(err)
+	}
+	snap, err := pinBackup(h, "snap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.releaseBackup("snap")
+	if snap[0].Generation == infos[0].Generation {
+		t.Fatalf("expected a new generation after a snapshot: %+v", snap[0])
+	}
+}
+
+// Ensure fragments are only pinned while writes are blocked, and that
+// blocking writes waits for those in progress.
+func TestHolder_BackupBarrier(t *testing.T) {
+	h := newHolder()
+	if err := h.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	b := h.backupBarrier
+
+	if _, err := h.pinBackup("a"); err == nil {
+		t.Fatal("expected error pinning without blocking writes")
+	}
+
+	// Blocking waits for the write in progress.
+	b.enter()
+	blocked := make(chan error)
+	go func() { blocked <- b.block("a", time.Minute) }()
+	select {
+	case err := <-blocked:
+		t.Fatalf("blocked while a write is in progress: %v", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	b.exit()
+	if err := <-blocked; err != nil {
+		t.Fatal(err)
+	}
+
+	// Writes wait until unblocked, and only one backup blocks at a time.
+	entered := make(chan struct{})
+	go func() {
+		b.enter()
+		close(entered)
+	}()
+	if err := b.block("b", time.Minute); err == nil {
+		t.Fatal("expected error blocking for a second backup")
+	} else if _, err := h.pinBackup("a"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-entered:
+		t.Fatal("write entered while blocked")
+	case <-time.After(10 * time.Millisecond):
+	}
+	b.unblock("a")
+	<-entered
+	b.exit()
+
+	// Releasing a backup unblocks writes, as does the timeout.
+	if err := b.block("c", time.Minute); err != nil {
+		t.Fatal(err)
+	} else if err := h.releaseBackup("c"); err != ErrBackupNotFound {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b.enter()
+	b.exit()
+	if err := b.block("d", time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	b.enter()
+	b.exit()
+	if _, err := h.pinBackup("d"); err == nil {
+		t.Fatal("expected error pinning once the block timed out")
+	}
+	h.releaseBackup("a")
+}
@@ -41,6 +41,30 @@ func OpenTranslateStore(path, index, field string) (pilosa.TranslateStore, error
 	return s, nil
 }
 
+// InspectTranslateStore returns the number of keys and the greatest ID of the
+// translate store at path. The store is opened read-only, and can't be
+// inspected while a server holds it open.
+func InspectTranslateStore(path string) (n int, maxID uint64, err error) {
+	db, err := bolt.Open(path, 0666, &bolt.Options{Timeout: 1 * time.Second, ReadOnly: true})
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "opening")
+	}
+	defer db.Close()
+
+	err = db.View(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket([]byte("ids"))
+		if bkt == nil {
+			return nil
+		}
+		n = bkt.Stats().KeyN
+		if key, _ := bkt.Cursor().Last(); key != nil {
+			maxID = btou64(key)
+		}
+		return nil
+	})
+	return n, maxID, err
+}
+
 // Ensure type implements interface.
 var _ pilosa.TranslateStore = &TranslateStore{}
 
@@ -240,6 +264,26 @@ func (s *TranslateStore) TranslateKeys(keys []string) (ids []uint64, _ error) {
 	return ids, nil
 }
 
+// FindKeys returns the IDs associated with keys. Keys which do not have
+// an associated id are returned as zero and are not created.
+func (s *TranslateStore) FindKeys(keys []string) ([]uint64, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]uint64, len(keys))
+	if err := s.db.View(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket([]byte("keys"))
+		for i, key := range keys {
+			ids[i] = findIDByKey(bkt, key)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
 // TranslateID converts an integer ID to a string key.
 // Returns a blank string if ID does not exist.
 func (s *TranslateStore) TranslateID(id uint64) (string, error) {
@@ -273,11 +317,17 @@ func (s *TranslateStore) TranslateIDs(ids []uint64) ([]string, error) {
 // ForceSet writes the id/key pair to the store even if read only. Used by replication.
 func (s *TranslateStore) ForceSet(id uint64, key string) error {
 	if err := s.db.Update(func(tx *bolt.Tx) (err error) {
-		if err := tx.Bucket([]byte("keys")).Put([]byte(key), u64tob(id)); err != nil {
+		bkt := tx.Bucket([]byte("keys"))
+		if err := bkt.Put([]byte(key), u64tob(id)); err != nil {
 			return err
 		} else if err := tx.Bucket([]byte("ids")).Put(u64tob(id), []byte(key)); err != nil {
 			return err
 		}
+
+		// Ensure new keys aren't assigned the forced ID.
+		if bkt.Sequence() < id {
+			return bkt.SetSequence(id)
+		}
 		return nil
 	}); err != nil {
 		return err
@@ -286,6 +336,24 @@ func (s *TranslateStore) ForceSet(id uint64, key string) error {
 	return nil
 }
 
+// DeleteIDs removes the id/key pairs for the given IDs even if read only.
+func (s *TranslateStore) DeleteIDs(ids []uint64) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		keys, idsBkt := tx.Bucket([]byte("keys")), tx.Bucket([]byte("ids"))
+		for _, id := range ids {
+			key := idsBkt.Get(u64tob(id))
+			if key == nil {
+				continue
+			} else if err := keys.Delete(key); err != nil {
+				return err
+			} else if err := idsBkt.Delete(u64tob(id)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
 // Reader returns a reader that streams the underlying data file.
 func (s *TranslateStore) EntryReader(ctx context.Context, offset uint64) (pilosa.TranslateEntryReader, error) {
 	ctx, cancel := context.WithCancel(ctx)
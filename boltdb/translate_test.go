@@ -17,6 +17,7 @@ import (
 	"context"
 	"io/ioutil"
 	"os"
+	"reflect"
 	"testing"
 	"time"
 
@@ -130,6 +131,107 @@ func TestTranslateStore_TranslateIDs(t *testing.T) {
 	}
 }
 
+func TestTranslateStore_DeleteIDs(t *testing.T) {
+	s := MustOpenNewTranslateStore()
+	defer MustCloseTranslateStore(s)
+
+	if _, err := s.TranslateKeys([]string{"foo", "bar"}); err != nil {
+		t.Fatal(err)
+	} else if err := s.DeleteIDs([]uint64{1, 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Ensure the deleted key is gone and the other remains.
+	if keys, err := s.TranslateIDs([]uint64{1, 2}); err != nil {
+		t.Fatal(err)
+	} else if got, want := keys[0], ""; got != want {
+		t.Fatalf("TranslateIDs()[0]=%s, want %s", got, want)
+	} else if got, want := keys[1], "bar"; got != want {
+		t.Fatalf("TranslateIDs()[1]=%s, want %s", got, want)
+	}
+
+	// Ensure a deleted key is assigned a new ID.
+	if id, err := s.TranslateKey("foo"); err != nil {
+		t.Fatal(err)
+	} else if id != 3 {
+		t.Fatalf("TranslateKey()=%d, want 3", id)
+	}
+}
+
+func TestTranslateStore_FindKeys(t *testing.T) {
+	s := MustOpenNewTranslateStore()
+	defer MustCloseTranslateStore(s)
+
+	if _, err := s.TranslateKeys([]string{"foo", "bar"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Ensure existing keys are found and unknown keys are not created.
+	if ids, err := s.FindKeys([]string{"bar", "baz", "foo"}); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(ids, []uint64{2, 0, 1}) {
+		t.Fatalf("FindKeys()=%v, want [2 0 1]", ids)
+	} else if max, err := s.MaxID(); err != nil {
+		t.Fatal(err)
+	} else if max != 2 {
+		t.Fatalf("MaxID()=%d, want 2", max)
+	}
+}
+
+func TestTranslateStore_ForceSet(t *testing.T) {
+	s := MustOpenNewTranslateStore()
+	defer MustCloseTranslateStore(s)
+
+	if err := s.ForceSet(1, "foo"); err != nil {
+		t.Fatal(err)
+	} else if err := s.ForceSet(3, "bar"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Ensure the forced keys translate.
+	if keys, err := s.TranslateIDs([]uint64{1, 2, 3}); err != nil {
+		t.Fatal(err)
+	} else if got, want := keys[0], "foo"; got != want {
+		t.Fatalf("TranslateIDs()[0]=%s, want %s", got, want)
+	} else if got, want := keys[1], ""; got != want {
+		t.Fatalf("TranslateIDs()[1]=%s, want %s", got, want)
+	} else if got, want := keys[2], "bar"; got != want {
+		t.Fatalf("TranslateIDs()[2]=%s, want %s", got, want)
+	}
+
+	// Ensure a new key is assigned an ID after the forced ones.
+	if id, err := s.TranslateKey("baz"); err != nil {
+		t.Fatal(err)
+	} else if got, want := id, uint64(4); got != want {
+		t.Fatalf("TranslateKey()=%d, want %d", got, want)
+	}
+}
+
+func TestInspectTranslateStore(t *testing.T) {
+	s := MustOpenNewTranslateStore()
+	defer os.Remove(s.Path)
+
+	if _, err := s.TranslateKeys([]string{"foo", "bar"}); err != nil {
+		t.Fatal(err)
+	} else if err := s.ForceSet(5, "baz"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Ensure the store can't be inspected while it's held open.
+	if _, _, err := boltdb.InspectTranslateStore(s.Path); err == nil {
+		t.Fatal("expected error")
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n, maxID, err := boltdb.InspectTranslateStore(s.Path); err != nil {
+		t.Fatal(err)
+	} else if n != 3 || maxID != 5 {
+		t.Fatalf("InspectTranslateStore()=%d, %d, want 3, 5", n, maxID)
+	}
+}
+
 func TestTranslateStore_EntryReader(t *testing.T) {
 	t.Run("OK", func(t *testing.T) {
 		s := MustOpenNewTranslateStore()
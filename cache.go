@@ -47,6 +47,10 @@ type cache interface {
 	// Rebuilds the cache.
 	Recalculate()
 
+	// Reports whether counts have been added since the cache was last
+	// rebuilt, so that Top may not reflect them.
+	Stale() bool
+
 	// Returns an ordered list of the top ranked bitmaps.
 	Top() []bitmapPair
 
@@ -99,6 +103,9 @@ func (c *lruCache) Invalidate() {}
 // Recalculate is a no-op.
 func (c *lruCache) Recalculate() {}
 
+// Stale always returns false since Top is computed on every call.
+func (c *lruCache) Stale() bool { return false }
+
 // IDs returns a list of all IDs in the cache.
 func (c *lruCache) IDs() []uint64 {
 	a := make([]uint64, 0, len(c.counts))
@@ -141,6 +148,10 @@ type rankCache struct {
 	updateN    int
 	updateTime time.Time
 
+	// dirty is set when entries have changed since rankings were last
+	// calculated.
+	dirty bool
+
 	// maxEntries is the user defined size of the cache
 	maxEntries uint32
 
@@ -176,6 +187,7 @@ func (c *rankCache) Add(id uint64, n uint64) {
 	}
 
 	c.entries[id] = n
+	c.dirty = true
 
 	c.invalidate()
 }
@@ -184,11 +196,13 @@ func (c *rankCache) Add(id uint64, n uint64) {
 func (c *rankCache) BulkAdd(id uint64, n uint64) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	if n < c.thresholdValue {
+	// As in Add, a count of 0 clears the cache value.
+	if n < c.thresholdValue && n > 0 {
 		return
 	}
 
 	c.entries[id] = n
+	c.dirty = true
 }
 
 // Get returns a count for a given id.
@@ -232,6 +246,14 @@ func (c *rankCache) Recalculate() {
 	c.recalculate()
 }
 
+// Stale reports whether entries have changed since the rankings were last
+// calculated.
+func (c *rankCache) Stale() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.dirty
+}
+
 func (c *rankCache) invalidate() {
 	// Don't invalidate more than once every X seconds.
 	// TODO: consider making this configurable.
@@ -269,6 +291,7 @@ func (c *rankCache) recalculate() {
 
 	// Reset counters.
 	c.updateTime, c.updateN = time.Now(), 0
+	c.dirty = false
 
 	// If size is larger than the threshold then trim it.
 	if len(c.entries) > c.thresholdBuffer {
@@ -480,6 +503,7 @@ func (c nopCache) IDs() []uint64          { return []uint64{} }
 func (c nopCache) Invalidate()                {}
 func (c nopCache) Len() int                   { return 0 }
 func (c nopCache) Recalculate()               {}
+func (c nopCache) Stale() bool                { return false }
 func (c nopCache) SetStats(stats.StatsClient) {}
 
 func (c nopCache) Top() []bitmapPair {
@@ -52,6 +52,7 @@ type InternalClient interface {
 	Nodes(ctx context.Context) ([]*Node, error)
 	Query(ctx context.Context, index string, queryRequest *QueryRequest) (*QueryResponse, error)
 	QueryNode(ctx context.Context, uri *URI, index string, queryRequest *QueryRequest) (*QueryResponse, error)
+	TranslateKeysNode(ctx context.Context, uri *URI, index, field string, keys []string) ([]uint64, error)
 	Import(ctx context.Context, index, field string, shard uint64, bits []Bit, opts ...ImportOption) error
 	ImportK(ctx context.Context, index, field string, bits []Bit, opts ...ImportOption) error
 	EnsureIndex(ctx context.Context, name string, options IndexOptions) error
@@ -60,6 +61,15 @@ type InternalClient interface {
 	ImportValue(ctx context.Context, index, field string, shard uint64, vals []FieldValue, opts ...ImportOption) error
 	ImportValueK(ctx context.Context, index, field string, vals []FieldValue, opts ...ImportOption) error
 	ExportCSV(ctx context.Context, index, field string, shard uint64, w io.Writer) error
+	ExportShard(ctx context.Context, uri *URI, index string, shard uint64) ([]ExportColumn, error)
+	BlockBackup(ctx context.Context, uri *URI, id string) error
+	UnblockBackup(ctx context.Context, uri *URI, id string) error
+	PinBackup(ctx context.Context, uri *URI, id string) ([]BackupFragmentInfo, error)
+	BackupFragment(ctx context.Context, uri *URI, id string, bf BackupFragment, offset int64) (io.ReadCloser, error)
+	ReleaseBackup(ctx context.Context, uri *URI, id string) error
+	RestoreFragment(ctx context.Context, uri *URI, bf BackupFragment, r io.Reader) error
+	RestoreAttrs(ctx context.Context, uri *URI, index, field string, r io.Reader) error
+	RestoreKeys(ctx context.Context, uri *URI, index, field string, r io.Reader) error
 	CreateField(ctx context.Context, index, field string) error
 	CreateFieldWithOptions(ctx context.Context, index, field string, opt FieldOptions) error
 	FragmentBlocks(ctx context.Context, uri *URI, index, field, view string, shard uint64) ([]FragmentBlock, error)
@@ -123,6 +133,9 @@ func (n nopInternalClient) Query(ctx context.Context, index string, queryRequest
 func (n nopInternalClient) QueryNode(ctx context.Context, uri *URI, index string, queryRequest *QueryRequest) (*QueryResponse, error) {
 	return nil, nil
 }
+func (n nopInternalClient) TranslateKeysNode(ctx context.Context, uri *URI, index, field string, keys []string) ([]uint64, error) {
+	return nil, nil
+}
 func (n nopInternalClient) Import(ctx context.Context, index, field string, shard uint64, bits []Bit, opts ...ImportOption) error {
 	return nil
 }
@@ -150,6 +163,27 @@ func (n nopInternalClient) ImportValueK(ctx context.Context, index, field string
 func (n nopInternalClient) ExportCSV(ctx context.Context, index, field string, shard uint64, w io.Writer) error {
 	return nil
 }
+func (n nopInternalClient) ExportShard(ctx context.Context, uri *URI, index string, shard uint64) ([]ExportColumn, error) {
+	return nil, nil
+}
+func (n nopInternalClient) BlockBackup(ctx context.Context, uri *URI, id string) error   { return nil }
+func (n nopInternalClient) UnblockBackup(ctx context.Context, uri *URI, id string) error { return nil }
+func (n nopInternalClient) PinBackup(ctx context.Context, uri *URI, id string) ([]BackupFragmentInfo, error) {
+	return nil, nil
+}
+func (n nopInternalClient) BackupFragment(ctx context.Context, uri *URI, id string, bf BackupFragment, offset int64) (io.ReadCloser, error) {
+	return nil, nil
+}
+func (n nopInternalClient) ReleaseBackup(ctx context.Context, uri *URI, id string) error { return nil }
+func (n nopInternalClient) RestoreFragment(ctx context.Context, uri *URI, bf BackupFragment, r io.Reader) error {
+	return nil
+}
+func (n nopInternalClient) RestoreAttrs(ctx context.Context, uri *URI, index, field string, r io.Reader) error {
+	return nil
+}
+func (n nopInternalClient) RestoreKeys(ctx context.Context, uri *URI, index, field string, r io.Reader) error {
+	return nil
+}
 func (n nopInternalClient) CreateField(ctx context.Context, index, field string) error { return nil }
 func (n nopInternalClient) CreateFieldWithOptions(ctx context.Context, index, field string, opt FieldOptions) error {
 	return nil
@@ -2032,6 +2032,18 @@ func (c *cluster) unprotectedPrimaryReplicaNode() *Node {
 	return c.nodes[pos-1]
 }
 
+// translatePrimaryNode returns the first node in c.Nodes, whose translate
+// stores create keys. The other nodes' stores are read only and replicate
+// from it.
+func (c *cluster) translatePrimaryNode() *Node {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if len(c.nodes) == 0 {
+		return nil
+	}
+	return c.nodes[0]
+}
+
 // setStatic is unprotected, but only called before the cluster has been started
 // (and therefore not concurrently).
 func (c *cluster) setStatic(hosts []string) error {
@@ -794,7 +794,10 @@ func TestCluster_ResizeStates(t *testing.T) {
 		node0Field := node0.holder.Field("i", "f")
 		node0View := node0Field.view("standard")
 		node0Fragment := node0View.Fragment(1)
-		node0Checksum := node0Fragment.Checksum()
+		node0Checksum, err := node0Fragment.Checksum()
+		if err != nil {
+			t.Fatal(err)
+		}
 
 		// addNode needs to block until the resize process has completed.
 		if err := tc.addNode(); err != nil {
@@ -827,7 +830,9 @@ func TestCluster_ResizeStates(t *testing.T) {
 		node1Fragment := node1View.Fragment(1)
 
 		// Ensure checksums are the same.
-		if chksum := node1Fragment.Checksum(); !bytes.Equal(chksum, node0Checksum) {
+		if chksum, err := node1Fragment.Checksum(); err != nil {
+			t.Fatal(err)
+		} else if !bytes.Equal(chksum, node0Checksum) {
 			t.Fatalf("expected standard view checksum to match: %x - %x", chksum, node0Checksum)
 		}
 
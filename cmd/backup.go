@@ -0,0 +1,61 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/pilosa/pilosa/v2/ctl"
+)
+
+var Backuper *ctl.BackupCommand
+
+func newBackupCommand(stdin io.Reader, stdout, stderr io.Writer) *cobra.Command {
+	Backuper = ctl.NewBackupCommand(stdin, stdout, stderr)
+	backupCmd := &cobra.Command{
+		Use:   "backup",
+		Short: "Back up every index of a cluster.",
+		Long: `
+Backs up every index of a cluster, while writes continue, to a tarball or a
+directory. The backup is made by the cluster's coordinator: every node pins
+its fragments at the start of the backup, so that the backup holds them as
+they were at that point.
+
+The backup holds the schema, the column and row keys, the column and row
+attributes, and an archive of each fragment. Progress is reported for each
+index and shard.
+
+With --incremental, only the changes since an earlier backup are backed up:
+the keys added since, and the fragments changed since. A fragment which
+hasn't been snapshotted since only holds the operations appended to it.
+Restore the earlier backup, then each incremental backup in order.
+`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return Backuper.Run(context.Background())
+		},
+	}
+	flags := backupCmd.Flags()
+
+	flags.StringVarP(&Backuper.Host, "host", "", "localhost:10101", "host:port of Pilosa.")
+	flags.StringVarP(&Backuper.Path, "output-file", "o", "", "Tarball to write the backup to")
+	flags.StringVarP(&Backuper.Dir, "output-dir", "d", "", "Directory to write the backup to, in place of a tarball")
+	flags.StringVarP(&Backuper.Incremental, "incremental", "", "", "Earlier backup, tarball or directory, to back up the changes since")
+	ctl.SetTLSConfig(flags, &Backuper.TLS.CertificatePath, &Backuper.TLS.CertificateKeyPath, &Backuper.TLS.CACertPath, &Backuper.TLS.SkipVerify, &Backuper.TLS.EnableClientVerification)
+
+	return backupCmd
+}
@@ -32,7 +32,9 @@ func newCheckCommand(stdin io.Reader, stdout io.Writer, stderr io.Writer) *cobra
 		Use:   "check <path> [path2]...",
 		Short: "Do a consistency check on a pilosa data file.",
 		Long: `
-Performs a consistency check on data files.
+Performs a consistency check on data files. Fragment data files
+written with per-container checksums (storage.checksums) also have
+their checksums verified.
 `,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if len(args) == 0 {
@@ -34,11 +34,20 @@ func newExportCommand(stdin io.Reader, stdout, stderr io.Writer) *cobra.Command
 Bulk exports a fragment to a CSV file. If the OUTFILE is not specified then
 the output is written to STDOUT.
 
-The format of the CSV file is:
+The format of the CSV file depends on the type of the field:
 
-	ROWID,COLUMNID
+	set, mutex: ROWID,COLUMNID
+	int:        COLUMNID,VALUE
+	bool:       COLUMNID,true|false
+	time:       ROWID,COLUMNID,TIMESTAMP
 
-The file does not contain any headers.
+Timestamps are read from the views of the finest unit of the field's time
+quantum, and are empty for bits set without one. The file does not contain
+any headers.
+
+If --format is given instead of a field, every column of the index is
+exported with its values in each field, as CSV with a header row, NDJSON or
+Parquet.
 `,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return Exporter.Run(context.Background())
@@ -49,6 +58,7 @@ The file does not contain any headers.
 	flags.StringVarP(&Exporter.Host, "host", "", "localhost:10101", "host:port of Pilosa.")
 	flags.StringVarP(&Exporter.Index, "index", "i", "", "Pilosa index to export")
 	flags.StringVarP(&Exporter.Field, "field", "f", "", "Field to export")
+	flags.StringVarP(&Exporter.Format, "format", "", "", "Export the whole index in this format: csv, ndjson or parquet")
 	flags.StringVarP(&Exporter.Path, "output-file", "o", "", "File to write export to - default stdout")
 	ctl.SetTLSConfig(flags, &Exporter.TLS.CertificatePath, &Exporter.TLS.CertificateKeyPath, &Exporter.TLS.CACertPath, &Exporter.TLS.SkipVerify, &Exporter.TLS.EnableClientVerification)
 
@@ -40,6 +40,19 @@ The format of the CSV file is:
 
 The file should contain no headers. The TIME column is optional and can be
 omitted. If it is present then its format should be YYYY-MM-DDTHH:MM.
+
+With --header, the first row of each file names its columns instead, and each
+row is a column of the index with values for several fields:
+
+	id,country,age,signup_time
+
+Each column named by --mapping is imported into a field. A mapping entry has
+the form COLUMN[=FIELD][:TYPE], where FIELD defaults to the column's name. By
+default, every column other than the ID and timestamp columns is imported into
+the field of the same name. The timestamp column, if any, is used for time
+fields. With --create, missing fields are created with the mapping's TYPE, or
+else a type inferred from the data: int if every value is an integer, and
+otherwise time if there is a timestamp column, or set.
 `,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			Importer.Paths = args
@@ -63,6 +76,10 @@ omitted. If it is present then its format should be YYYY-MM-DDTHH:MM.
 	flags.BoolVarP(&Importer.Sort, "sort", "", false, "Enables sorting before import.")
 	flags.BoolVarP(&Importer.CreateSchema, "create", "e", false, "Create the schema if it does not exist before import.")
 	flags.BoolVarP(&Importer.Clear, "clear", "", false, "Clear the data provided in the import.")
+	flags.BoolVar(&Importer.Header, "header", false, "Read column names from the first row and import into several fields.")
+	flags.StringSliceVarP(&Importer.Mapping, "mapping", "m", nil, "Header columns to import, as COLUMN[=FIELD][:TYPE]. Defaults to all columns.")
+	flags.StringVar(&Importer.IDColumn, "id-column", "id", "Header column holding the column ID or key.")
+	flags.StringVar(&Importer.TimestampColumn, "timestamp-column", "", "Header column holding the timestamp of time fields.")
 	ctl.SetTLSConfig(flags, &Importer.TLS.CertificatePath, &Importer.TLS.CertificateKeyPath, &Importer.TLS.CACertPath, &Importer.TLS.SkipVerify, &Importer.TLS.EnableClientVerification)
 
 	return importCmd
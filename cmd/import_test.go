@@ -106,6 +106,19 @@ field = "f1"
 				return v.Error()
 			},
 		},
+		{
+			args: []string{"import", "--index", "i1", "--header", "--mapping", "country,age=years:int", "--id-column", "user", "--timestamp-column", "ts"},
+			env:  map[string]string{},
+			validation: func() error {
+				v := validator{}
+				v.Check(cmd.Importer.Index, "i1")
+				v.Check(cmd.Importer.Header, true)
+				v.Check(cmd.Importer.Mapping, []string{"country", "age=years:int"})
+				v.Check(cmd.Importer.IDColumn, "user")
+				v.Check(cmd.Importer.TimestampColumn, "ts")
+				return v.Error()
+			},
+		},
 	}
 	executeDry(t, tests)
 }
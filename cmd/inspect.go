@@ -31,9 +31,15 @@ func newInspectCommand(stdin io.Reader, stdout, stderr io.Writer) *cobra.Command
 
 	inspectCmd := &cobra.Command{
 		Use:   "inspect",
-		Short: "Get stats on a pilosa data file.",
+		Short: "Get stats on a pilosa data file or data directory.",
 		Long: `
 Inspects a data file and provides stats.
+
+Given a data directory, inspects every fragment of every index, field and
+view, reporting bit and row counts, container types, the length of the ops
+log, the BSI bit depth, and whether the cache file is consistent with the
+fragment. The size of each translate store is reported too; translate stores
+can't be inspected while a server holds them open.
 `,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if len(args) == 0 {
@@ -45,5 +51,8 @@ Inspects a data file and provides stats.
 			return inspector.Run(context.Background())
 		},
 	}
+	flags := inspectCmd.Flags()
+	flags.BoolVarP(&inspector.JSON, "json", "", false, "Print the report as JSON")
+
 	return inspectCmd
 }
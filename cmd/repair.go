@@ -0,0 +1,63 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/pilosa/pilosa/v2/ctl"
+)
+
+var repairer *ctl.RepairCommand
+
+func newRepairCommand(stdin io.Reader, stdout, stderr io.Writer) *cobra.Command {
+	repairer = ctl.NewRepairCommand(stdin, stdout, stderr)
+
+	repairCmd := &cobra.Command{
+		Use:   "repair <data-dir>",
+		Short: "Repair the fragments of a pilosa data directory.",
+		Long: `
+Repairs the fragments of a data directory, such as after a crash, and reports
+what it changed. The server must be stopped first.
+
+Each fragment is validated. An incomplete or corrupt tail of its ops log is
+truncated, and a fragment which still can't be read, or fails its
+consistency check, is moved to the .quarantine directory of the data
+directory, along with its cache file. Missing or unreadable cache files are
+rebuilt from the fragments.
+
+Quarantined fragments are empty when the server starts again; their data can
+be restored from a replica or a backup.
+`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				return fmt.Errorf("path required")
+			} else if len(args) > 1 {
+				return fmt.Errorf("only one path allowed")
+			}
+			repairer.Path = args[0]
+			return repairer.Run(context.Background())
+		},
+	}
+	flags := repairCmd.Flags()
+	flags.BoolVarP(&repairer.ReportOnly, "report-only", "", false, "Report repairs without making them")
+	flags.BoolVarP(&repairer.JSON, "json", "", false, "Print the report as JSON")
+
+	return repairCmd
+}
@@ -0,0 +1,55 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/pilosa/pilosa/v2/ctl"
+)
+
+var Restorer *ctl.RestoreCommand
+
+func newRestoreCommand(stdin io.Reader, stdout, stderr io.Writer) *cobra.Command {
+	Restorer = ctl.NewRestoreCommand(stdin, stdout, stderr)
+	restoreCmd := &cobra.Command{
+		Use:   "restore",
+		Short: "Restore a backup into a cluster.",
+		Long: `
+Restores a backup, made with "pilosa backup", from a tarball or a directory
+into a cluster. The cluster may have a different number of nodes than the one
+that was backed up: the cluster's coordinator creates the schema, replays the
+column and row keys and attributes, and sends each fragment to the nodes that
+now own its shard.
+
+The restore fails if any index in the backup already exists in the cluster.
+Progress is reported for each index and shard.
+`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return Restorer.Run(context.Background())
+		},
+	}
+	flags := restoreCmd.Flags()
+
+	flags.StringVarP(&Restorer.Host, "host", "", "localhost:10101", "host:port of Pilosa.")
+	flags.StringVarP(&Restorer.Path, "input-file", "i", "", "Tarball to read the backup from")
+	flags.StringVarP(&Restorer.Dir, "input-dir", "d", "", "Directory to read the backup from, in place of a tarball")
+	ctl.SetTLSConfig(flags, &Restorer.TLS.CertificatePath, &Restorer.TLS.CertificateKeyPath, &Restorer.TLS.CACertPath, &Restorer.TLS.SkipVerify, &Restorer.TLS.EnableClientVerification)
+
+	return restoreCmd
+}
@@ -0,0 +1,263 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctl
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pilosa/pilosa/v2"
+	"github.com/pilosa/pilosa/v2/server"
+	"github.com/pkg/errors"
+)
+
+// BackupCommand represents a command for backing up every index of a
+// cluster.
+type BackupCommand struct {
+	// Remote host and port.
+	Host string
+
+	// Filename of the tarball to write the backup to.
+	Path string
+
+	// Directory to extract the backup into, in place of a tarball.
+	Dir string
+
+	// Earlier backup, as a tarball or a directory, on top of which to make
+	// an incremental backup.
+	Incremental string
+
+	// Standard input/output
+	*pilosa.CmdIO
+
+	TLS server.TLSConfig
+}
+
+// NewBackupCommand returns a new instance of BackupCommand.
+func NewBackupCommand(stdin io.Reader, stdout, stderr io.Writer) *BackupCommand {
+	return &BackupCommand{
+		CmdIO: pilosa.NewCmdIO(stdin, stdout, stderr),
+	}
+}
+
+// Run executes the backup.
+func (cmd *BackupCommand) Run(ctx context.Context) error {
+	logger := cmd.Logger()
+
+	// Validate arguments.
+	if cmd.Path == "" && cmd.Dir == "" {
+		return errors.New("output file or directory required")
+	} else if cmd.Path != "" && cmd.Dir != "" {
+		return errors.New("only one of output file and directory may be given")
+	}
+
+	// Read the manifest of the base backup.
+	var base *pilosa.BackupManifest
+	if cmd.Incremental != "" {
+		m, err := readBackupManifest(cmd.Incremental)
+		if err != nil {
+			return errors.Wrap(err, "reading base backup")
+		}
+		base = m
+		logger.Printf("backing up changes since backup %s", base.ID)
+	}
+
+	// Create a client to the server.
+	client, err := commandClient(cmd)
+	if err != nil {
+		return errors.Wrap(err, "creating client")
+	}
+
+	var w backupEntryWriter
+	if cmd.Path != "" {
+		f, err := os.Create(cmd.Path)
+		if err != nil {
+			return errors.Wrap(err, "creating file")
+		}
+		defer f.Close()
+		w = &tarballEntryWriter{f: f, tw: tar.NewWriter(f)}
+	} else {
+		if err := os.MkdirAll(cmd.Dir, 0777); err != nil {
+			return errors.Wrap(err, "creating directory")
+		}
+		w = dirEntryWriter(cmd.Dir)
+	}
+
+	// Read the backup as it arrives, so that progress can be reported.
+	pr, pw := io.Pipe()
+	go func() { pw.CloseWithError(client.Backup(ctx, base, pw)) }()
+	defer pr.Close()
+
+	progress := &backupProgress{logger: logger, verb: "backing up"}
+	tr := tar.NewReader(pr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return errors.Wrap(err, "reading backup")
+		}
+		progress.entry(hdr.Name)
+
+		if err := w.write(hdr, tr); err != nil {
+			return errors.Wrapf(err, "writing %s", hdr.Name)
+		}
+	}
+
+	return w.close()
+}
+
+func (cmd *BackupCommand) TLSHost() string {
+	return cmd.Host
+}
+
+func (cmd *BackupCommand) TLSConfiguration() server.TLSConfig {
+	return cmd.TLS
+}
+
+// readBackupManifest reads the manifest of a backup, from a tarball or a
+// directory.
+func readBackupManifest(path string) (*pilosa.BackupManifest, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var r io.Reader
+	if fi.IsDir() {
+		f, err := os.Open(filepath.Join(path, "manifest.json"))
+		if os.IsNotExist(err) {
+			return nil, errors.New("backup has no manifest")
+		} else if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	} else {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		// The manifest is the first entry of a backup.
+		tr := tar.NewReader(f)
+		if hdr, err := tr.Next(); err != nil {
+			return nil, errors.Wrap(err, "reading backup")
+		} else if hdr.Name != "manifest.json" {
+			return nil, errors.New("backup has no manifest")
+		}
+		r = tr
+	}
+
+	var m pilosa.BackupManifest
+	if err := json.NewDecoder(r).Decode(&m); err != nil {
+		return nil, errors.Wrap(err, "decoding manifest")
+	}
+	return &m, nil
+}
+
+// backupProgress logs the progress of a backup or a restore as the entries
+// of the backup pass. Entries are grouped by index, and fragments are ordered
+// by shard.
+type backupProgress struct {
+	logger *log.Logger
+	verb   string
+
+	index, shard string
+}
+
+// entry logs the start of each index and shard.
+func (p *backupProgress) entry(name string) {
+	parts := strings.Split(name, "/")
+	if len(parts) < 2 {
+		return
+	}
+	if parts[0] != p.index {
+		p.index, p.shard = parts[0], ""
+		p.logger.Printf("%s index: %s", p.verb, p.index)
+	}
+	if shard, ok := backupEntryShard(parts); ok && shard != p.shard {
+		p.shard = shard
+		p.logger.Printf("%s index: %s, shard: %s", p.verb, p.index, p.shard)
+	}
+}
+
+// backupEntryShard returns the shard of a fragment entry of a backup, from
+// the parts of its name.
+func backupEntryShard(parts []string) (string, bool) {
+	if len(parts) == 6 && parts[2] == "views" && parts[4] == "fragments" {
+		return strings.TrimSuffix(parts[5], ".tar"), true
+	}
+	return "", false
+}
+
+// backupEntryWriter writes the entries of a backup.
+type backupEntryWriter interface {
+	write(hdr *tar.Header, r io.Reader) error
+	close() error
+}
+
+// tarballEntryWriter writes the entries of a backup to a tarball.
+type tarballEntryWriter struct {
+	f  *os.File
+	tw *tar.Writer
+}
+
+func (w *tarballEntryWriter) write(hdr *tar.Header, r io.Reader) error {
+	if err := w.tw.WriteHeader(hdr); err != nil {
+		return errors.Wrap(err, "writing header")
+	}
+	_, err := io.Copy(w.tw, r)
+	return errors.Wrap(err, "copying")
+}
+
+func (w *tarballEntryWriter) close() error {
+	if err := w.tw.Close(); err != nil {
+		return errors.Wrap(err, "closing tarball")
+	}
+	return w.f.Close()
+}
+
+// dirEntryWriter writes the entries of a backup as files in a directory.
+type dirEntryWriter string
+
+func (w dirEntryWriter) write(hdr *tar.Header, r io.Reader) error {
+	path := filepath.Join(string(w), filepath.FromSlash(hdr.Name))
+	if !strings.HasPrefix(path, filepath.Clean(string(w))+string(filepath.Separator)) {
+		return errors.New("invalid entry name")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return errors.Wrap(err, "creating directory")
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return errors.Wrap(err, "creating file")
+	}
+	defer f.Close()
+	if _, err := io.Copy(f, r); err != nil {
+		return errors.Wrap(err, "copying")
+	}
+	return f.Close()
+}
+
+func (w dirEntryWriter) close() error { return nil }
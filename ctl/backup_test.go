@@ -0,0 +1,192 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctl
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/pilosa/pilosa/v2"
+	"github.com/pilosa/pilosa/v2/test"
+)
+
+func TestBackupCommand_Validation(t *testing.T) {
+	buf := bytes.Buffer{}
+	stdin, stdout, stderr := GetIO(buf)
+
+	cm := NewBackupCommand(stdin, stdout, stderr)
+	if err := cm.Run(context.Background()); err == nil || err.Error() != "output file or directory required" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cm.Path, cm.Dir = "backup.tar", "backup"
+	if err := cm.Run(context.Background()); err == nil || err.Error() != "only one of output file and directory may be given" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestBackupCommand_Run(t *testing.T) {
+	cluster := test.MustRunCluster(t, 3)
+	defer cluster.Close()
+	cmd := cluster[0]
+
+	cmd.MustCreateIndex(t, "i", pilosa.IndexOptions{Keys: true})
+	cmd.MustCreateField(t, "i", "f", pilosa.OptFieldKeys())
+	cmd.MustCreateIndex(t, "u", pilosa.IndexOptions{})
+	cmd.MustCreateField(t, "u", "g")
+	for _, q := range []struct{ index, query string }{
+		{"i", `Set("a", f="x")`},
+		{"i", `Set("b", f="y")`},
+		{"u", `Set(1, g=1) Set(1048577, g=1) Set(2097153, g=2)`},
+		{"u", `SetRowAttrs(g, 1, color="red")`},
+	} {
+		if _, err := cmd.Query(q.index, "", q.query); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files := map[string]string{
+		"i/keys.ndjson":   "{\"id\":1,\"key\":\"a\"}\n{\"id\":2,\"key\":\"b\"}\n",
+		"i/f/keys.ndjson": "{\"id\":1,\"key\":\"x\"}\n{\"id\":2,\"key\":\"y\"}\n",
+		"u/g/attrs.json":  "{\"1\":{\"color\":\"red\"}}\n",
+	}
+	names := []string{
+		"i/f/keys.ndjson",
+		"i/f/views/standard/fragments/0.tar",
+		"i/keys.ndjson",
+		"manifest.json",
+		"schema.json",
+		"u/g/attrs.json",
+		"u/g/views/standard/fragments/0.tar",
+		"u/g/views/standard/fragments/1.tar",
+		"u/g/views/standard/fragments/2.tar",
+	}
+
+	// checkFragment ensures a fragment archive holds the fragment's data.
+	checkFragment := func(t *testing.T, name string, r io.Reader) {
+		hdr, err := tar.NewReader(r).Next()
+		if err != nil {
+			t.Fatalf("reading fragment %s: %v", name, err)
+		} else if hdr.Name != "data" || hdr.Size == 0 {
+			t.Fatalf("unexpected fragment %s entry: %s (%d bytes)", name, hdr.Name, hdr.Size)
+		}
+	}
+
+	t.Run("Dir", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "pilosa-backup-")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		// Back up through a node which isn't the coordinator.
+		var stderr bytes.Buffer
+		cm := NewBackupCommand(os.Stdin, ioutil.Discard, &stderr)
+		cm.Host = cluster[1].API.Node().URI.HostPort()
+		cm.Dir = dir
+		if err := cm.Run(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		var got []string
+		if err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+			if err != nil || fi.IsDir() {
+				return err
+			}
+			name, err := filepath.Rel(dir, path)
+			got = append(got, filepath.ToSlash(name))
+			return err
+		}); err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, names) {
+			t.Fatalf("unexpected files: %v", got)
+		}
+
+		for name, exp := range files {
+			if buf, err := ioutil.ReadFile(filepath.Join(dir, name)); err != nil {
+				t.Fatal(err)
+			} else if string(buf) != exp {
+				t.Fatalf("unexpected %s: %s", name, buf)
+			}
+		}
+		if buf, err := ioutil.ReadFile(filepath.Join(dir, "schema.json")); err != nil {
+			t.Fatal(err)
+		} else if !strings.HasPrefix(string(buf), `{"indexes":[{"name":"i"`) {
+			t.Fatalf("unexpected schema: %s", buf)
+		}
+		f, err := os.Open(filepath.Join(dir, "u/g/views/standard/fragments/2.tar"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		checkFragment(t, "2", f)
+
+		if !strings.Contains(stderr.String(), "backing up index: u, shard: 2") {
+			t.Fatalf("expected progress: %s", stderr.String())
+		}
+	})
+
+	t.Run("Tarball", func(t *testing.T) {
+		file, err := ioutil.TempFile("", "pilosa-backup-")
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.Close()
+		defer os.Remove(file.Name())
+
+		cm := NewBackupCommand(os.Stdin, ioutil.Discard, ioutil.Discard)
+		cm.Host = cmd.API.Node().URI.HostPort()
+		cm.Path = file.Name()
+		if err := cm.Run(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		f, err := os.Open(file.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+
+		var got []string
+		tr := tar.NewReader(f)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, hdr.Name)
+			if strings.HasSuffix(hdr.Name, ".tar") {
+				checkFragment(t, hdr.Name, tr)
+			}
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, names) {
+			t.Fatalf("unexpected entries: %v", got)
+		}
+	})
+}
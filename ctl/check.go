@@ -20,6 +20,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"syscall"
 
 	"github.com/pilosa/pilosa/v2"
@@ -82,12 +83,13 @@ func (cmd *CheckCommand) checkBitmapFile(path string) (err error) {
 	}
 
 	// Memory map the file.
-	data, err := syscall.Mmap(int(f.Fd()), 0, int(fi.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	mapped, err := syscall.Mmap(int(f.Fd()), 0, int(fi.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
 	if err != nil {
 		return errors.Wrap(err, "mmapping")
 	}
+	data := mapped
 	defer func() {
-		e := syscall.Munmap(data)
+		e := syscall.Munmap(mapped)
 		if e != nil {
 			fmt.Fprintf(cmd.Stderr, "WARNING: munmap failed: %v", e)
 		}
@@ -97,6 +99,15 @@ func (cmd *CheckCommand) checkBitmapFile(path string) (err error) {
 			err = e
 		}
 	}()
+
+	// Decompress the data file of a cold fragment.
+	compressed := roaring.Compressed(data)
+	if compressed {
+		if data, err = roaring.Decompress(data); err != nil {
+			return errors.Wrap(err, "decompressing")
+		}
+	}
+
 	// Attach the mmap file to the bitmap.
 	bm := roaring.NewBitmap()
 	if err := bm.UnmarshalBinary(data); err != nil {
@@ -116,8 +127,20 @@ func (cmd *CheckCommand) checkBitmapFile(path string) (err error) {
 		}
 	}
 
-	// Print success message if no errors were found.
-	fmt.Fprintf(cmd.Stdout, "%s: ok\n", path)
+	// Print success message if no errors were found. Container checksums
+	// were verified by UnmarshalBinary if the file has them.
+	var notes []string
+	if compressed {
+		notes = append(notes, "compressed")
+	}
+	if roaring.Checksummed(data) {
+		notes = append(notes, "checksums verified")
+	}
+	if len(notes) > 0 {
+		fmt.Fprintf(cmd.Stdout, "%s: ok (%s)\n", path, strings.Join(notes, ", "))
+	} else {
+		fmt.Fprintf(cmd.Stdout, "%s: ok\n", path)
+	}
 
 	return nil
 }
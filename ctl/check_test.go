@@ -26,6 +26,8 @@ import (
 	"testing"
 
 	"context"
+
+	"github.com/pilosa/pilosa/v2/roaring"
 )
 
 func TestCheckCommand_RunCacheFile(t *testing.T) {
@@ -100,6 +102,53 @@ func TestCheckCommand_Run(t *testing.T) {
 	//	Todo: need correct roaring file for happy path
 }
 
+func TestCheckCommand_RunChecksummed(t *testing.T) {
+	file, err := ioutil.TempFile("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	if _, err := roaring.NewBitmap(1, 2, 100000).WriteChecksummedTo(file); err != nil {
+		t.Fatalf("writing to temp file: %v", err)
+	}
+	file.Close()
+
+	var buf bytes.Buffer
+	cm := NewCheckCommand(bytes.NewReader(nil), &buf, &buf)
+	cm.Paths = []string{file.Name()}
+	if err := cm.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if exp := file.Name() + ": ok (checksums verified)\n"; buf.String() != exp {
+		t.Fatalf("expected %q, got %q", exp, buf.String())
+	}
+}
+
+func TestCheckCommand_RunCompressed(t *testing.T) {
+	file, err := ioutil.TempFile("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	var buf bytes.Buffer
+	if _, err := roaring.NewBitmap(1, 2, 100000).WriteChecksummedTo(&buf); err != nil {
+		t.Fatal(err)
+	} else if _, err := roaring.Compress(file, buf.Bytes()); err != nil {
+		t.Fatalf("writing to temp file: %v", err)
+	}
+	file.Close()
+
+	buf.Reset()
+	cm := NewCheckCommand(bytes.NewReader(nil), &buf, &buf)
+	cm.Paths = []string{file.Name()}
+	if err := cm.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if exp := file.Name() + ": ok (compressed, checksums verified)\n"; buf.String() != exp {
+		t.Fatalf("expected %q, got %q", exp, buf.String())
+	}
+}
+
 // TempFileName generates a temporary filename with extension
 func TempFileName(prefix, suffix string) string {
 	randBytes := make([]byte, 16)
@@ -33,6 +33,10 @@ type ExportCommand struct {
 	Index string
 	Field string
 
+	// Format of an export of the whole index, in place of a field. One of
+	// csv, ndjson or parquet.
+	Format string
+
 	// Filename to export to.
 	Path string
 
@@ -56,8 +60,10 @@ func (cmd *ExportCommand) Run(ctx context.Context) error {
 	// Validate arguments.
 	if cmd.Index == "" {
 		return pilosa.ErrIndexRequired
-	} else if cmd.Field == "" {
+	} else if cmd.Field == "" && cmd.Format == "" {
 		return pilosa.ErrFieldRequired
+	} else if cmd.Field != "" && cmd.Format != "" {
+		return errors.New("format only applies to index exports")
 	}
 
 	// Use output file, if specified.
@@ -79,6 +85,14 @@ func (cmd *ExportCommand) Run(ctx context.Context) error {
 		return errors.Wrap(err, "creating client")
 	}
 
+	if cmd.Format != "" {
+		logger.Printf("exporting index: %s", cmd.Index)
+		if err := client.ExportIndex(ctx, cmd.Index, pilosa.ExportFormat(cmd.Format), w); err != nil {
+			return errors.Wrap(err, "exporting")
+		}
+		return cmd.close(w)
+	}
+
 	// Determine shard count.
 	maxShards, err := client.MaxShardByIndex(ctx)
 	if err != nil {
@@ -93,13 +107,16 @@ func (cmd *ExportCommand) Run(ctx context.Context) error {
 		}
 	}
 
-	// Close writer, if applicable.
+	return cmd.close(w)
+}
+
+// close closes the writer, if applicable.
+func (cmd *ExportCommand) close(w io.Writer) error {
 	if w, ok := w.(io.Closer); ok {
 		if err := w.Close(); err != nil {
 			return errors.Wrap(err, "closing")
 		}
 	}
-
 	return nil
 }
 
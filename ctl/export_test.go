@@ -71,3 +71,33 @@ func TestExportCommand_Run(t *testing.T) {
 		t.Fatalf("Export Run doesn't work: %s", err)
 	}
 }
+
+func TestExportCommand_RunIndex(t *testing.T) {
+	cluster := test.MustRunCluster(t, 1)
+	defer cluster.Close()
+	cmd := cluster[0]
+	cmd.MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	cmd.MustCreateField(t, "i", "f")
+	cmd.MustCreateField(t, "i", "v", pilosa.OptFieldTypeInt(0, 100))
+	cmd.MustQuery(t, &pilosa.QueryRequest{Index: "i", Query: `Set(1, f=2) Set(1, v=10) Set(3, f=4)`})
+
+	buf := bytes.Buffer{}
+	stdin, stdout, stderr := GetIO(buf)
+	cm := NewExportCommand(stdin, stdout, stderr)
+	cm.Host = cmd.API.Node().URI.HostPort()
+	cm.Index = "i"
+	cm.Format = "ndjson"
+
+	var out bytes.Buffer
+	cm.Stdout = &out
+	if err := cm.Run(context.Background()); err != nil {
+		t.Fatalf("Export Run doesn't work: %s", err)
+	} else if out.String() != "{\"_id\":1,\"f\":[2],\"v\":10}\n{\"_id\":3,\"f\":[4]}\n" {
+		t.Fatalf("unexpected export: %s", out.String())
+	}
+
+	cm.Field = "f"
+	if err := cm.Run(context.Background()); err == nil || err.Error() != "format only applies to index exports" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -20,9 +20,11 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"os"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/pilosa/pilosa/v2"
@@ -61,6 +63,21 @@ type ImportCommand struct { // nolint: maligned
 	// Enables sorting of data file before import.
 	Sort bool `json:"sort"`
 
+	// Header indicates that the first row of each file names its columns,
+	// which are imported into the fields given by Mapping instead of Field.
+	Header bool `json:"header"`
+
+	// Mapping of header columns to fields, each of the form
+	// COLUMN[=FIELD][:TYPE]. The field defaults to the column's name, and
+	// TYPE is only used when the field is created. If empty, every column
+	// other than IDColumn and TimestampColumn is imported.
+	Mapping []string `json:"mapping"`
+
+	// Header columns holding the column ID, or key, and the timestamp of
+	// the time fields.
+	IDColumn        string `json:"idColumn"`
+	TimestampColumn string `json:"timestampColumn"`
+
 	// Reusable client.
 	client pilosa.InternalClient
 
@@ -75,6 +92,7 @@ func NewImportCommand(stdin io.Reader, stdout, stderr io.Writer) *ImportCommand
 	return &ImportCommand{
 		CmdIO:      pilosa.NewCmdIO(stdin, stdout, stderr),
 		BufferSize: 10000000,
+		IDColumn:   "id",
 	}
 }
 
@@ -86,10 +104,12 @@ func (cmd *ImportCommand) Run(ctx context.Context) error {
 	// Index and field are validated early before the files are parsed.
 	if cmd.Index == "" {
 		return pilosa.ErrIndexRequired
-	} else if cmd.Field == "" {
+	} else if cmd.Field == "" && !cmd.Header {
 		return pilosa.ErrFieldRequired
 	} else if len(cmd.Paths) == 0 {
 		return errors.New("path required")
+	} else if len(cmd.Mapping) > 0 && !cmd.Header {
+		return errors.New("mapping requires a header")
 	}
 	// Create a client to the server.
 	client, err := commandClient(cmd)
@@ -98,6 +118,10 @@ func (cmd *ImportCommand) Run(ctx context.Context) error {
 	}
 	cmd.client = client
 
+	if cmd.Header {
+		return cmd.runHeader(ctx)
+	}
+
 	if cmd.CreateSchema {
 		if cmd.FieldOptions.Type == "" {
 			// set the correct type for the field
@@ -242,7 +266,7 @@ func (cmd *ImportCommand) bufferBits(ctx context.Context, useColumnKeys, useRowK
 
 		// If we've reached the buffer size then import bits.
 		if len(a) == cmd.BufferSize {
-			if err := cmd.importBits(ctx, useColumnKeys, useRowKeys, a); err != nil {
+			if err := cmd.importBits(ctx, cmd.Field, useColumnKeys, useRowKeys, a); err != nil {
 				return err
 			}
 			a = a[:0]
@@ -250,17 +274,17 @@ func (cmd *ImportCommand) bufferBits(ctx context.Context, useColumnKeys, useRowK
 	}
 
 	// If there are still bits in the buffer then flush them.
-	return cmd.importBits(ctx, useColumnKeys, useRowKeys, a)
+	return cmd.importBits(ctx, cmd.Field, useColumnKeys, useRowKeys, a)
 }
 
-// importBits sends batches of bits to the server.
-func (cmd *ImportCommand) importBits(ctx context.Context, useColumnKeys, useRowKeys bool, bits []pilosa.Bit) error {
+// importBits sends batches of bits for a field to the server.
+func (cmd *ImportCommand) importBits(ctx context.Context, field string, useColumnKeys, useRowKeys bool, bits []pilosa.Bit) error {
 	logger := log.New(cmd.Stderr, "", log.LstdFlags)
 
 	// If keys are used, all bits are sent to the primary translate store (i.e. coordinator).
 	if useColumnKeys || useRowKeys {
 		logger.Printf("importing keys: n=%d", len(bits))
-		if err := cmd.client.ImportK(ctx, cmd.Index, cmd.Field, bits, pilosa.OptImportOptionsClear(cmd.Clear)); err != nil {
+		if err := cmd.client.ImportK(ctx, cmd.Index, field, bits, pilosa.OptImportOptionsClear(cmd.Clear)); err != nil {
 			return errors.Wrap(err, "importing keys")
 		}
 		return nil
@@ -277,7 +301,7 @@ func (cmd *ImportCommand) importBits(ctx context.Context, useColumnKeys, useRowK
 		}
 
 		logger.Printf("importing shard: %d, n=%d", shard, len(chunk))
-		if err := cmd.client.Import(ctx, cmd.Index, cmd.Field, shard, chunk, pilosa.OptImportOptionsClear(cmd.Clear)); err != nil {
+		if err := cmd.client.Import(ctx, cmd.Index, field, shard, chunk, pilosa.OptImportOptionsClear(cmd.Clear)); err != nil {
 			return errors.Wrap(err, "importing")
 		}
 	}
@@ -347,7 +371,7 @@ func (cmd *ImportCommand) bufferValues(ctx context.Context, useColumnKeys bool,
 
 		// If we've reached the buffer size then import FieldValues.
 		if len(a) == cmd.BufferSize {
-			if err := cmd.importValues(ctx, useColumnKeys, a); err != nil {
+			if err := cmd.importValues(ctx, cmd.Field, useColumnKeys, a); err != nil {
 				return err
 			}
 			a = a[:0]
@@ -355,17 +379,17 @@ func (cmd *ImportCommand) bufferValues(ctx context.Context, useColumnKeys bool,
 	}
 
 	// If there are still values in the buffer then flush them.
-	return cmd.importValues(ctx, useColumnKeys, a)
+	return cmd.importValues(ctx, cmd.Field, useColumnKeys, a)
 }
 
-// importValues sends batches of FieldValues to the server.
-func (cmd *ImportCommand) importValues(ctx context.Context, useColumnKeys bool, vals []pilosa.FieldValue) error {
+// importValues sends batches of FieldValues for a field to the server.
+func (cmd *ImportCommand) importValues(ctx context.Context, field string, useColumnKeys bool, vals []pilosa.FieldValue) error {
 	logger := log.New(cmd.Stderr, "", log.LstdFlags)
 
 	// If keys are used, all values are sent to the primary translate store (i.e. coordinator).
 	if useColumnKeys {
 		logger.Printf("importing keyed values: n=%d", len(vals))
-		if err := cmd.client.ImportValueK(ctx, cmd.Index, cmd.Field, vals); err != nil {
+		if err := cmd.client.ImportValueK(ctx, cmd.Index, field, vals); err != nil {
 			return errors.Wrap(err, "importing keys")
 		}
 		return nil
@@ -382,7 +406,7 @@ func (cmd *ImportCommand) importValues(ctx context.Context, useColumnKeys bool,
 		}
 
 		logger.Printf("importing shard: %d, n=%d", shard, len(vals))
-		if err := cmd.client.ImportValue(ctx, cmd.Index, cmd.Field, shard, vals, pilosa.OptImportOptionsClear(cmd.Clear)); err != nil {
+		if err := cmd.client.ImportValue(ctx, cmd.Index, field, shard, vals, pilosa.OptImportOptionsClear(cmd.Clear)); err != nil {
 			return errors.Wrap(err, "importing values")
 		}
 	}
@@ -390,6 +414,353 @@ func (cmd *ImportCommand) importValues(ctx context.Context, useColumnKeys bool,
 	return nil
 }
 
+// importColumn is a header column which is imported into a field.
+type importColumn struct {
+	name  string // name in the header
+	field string
+	typ   string // type to create the field with, inferred if empty
+	i     int    // position in each row
+
+	// Options of the field, once it is known to exist.
+	opts *pilosa.FieldOptions
+}
+
+// parseImportMapping parses mapping entries of the form
+// COLUMN[=FIELD][:TYPE].
+func parseImportMapping(mapping []string) ([]importColumn, error) {
+	columns := make([]importColumn, 0, len(mapping))
+	for _, entry := range mapping {
+		var c importColumn
+		s := entry
+		if i := strings.Index(s, ":"); i >= 0 {
+			s, c.typ = s[:i], s[i+1:]
+		}
+		c.name, c.field = s, s
+		if i := strings.Index(s, "="); i >= 0 {
+			c.name, c.field = s[:i], s[i+1:]
+		}
+		if c.name == "" || c.field == "" {
+			return nil, fmt.Errorf("invalid mapping: %q", entry)
+		}
+
+		switch c.typ {
+		case "", pilosa.FieldTypeSet, pilosa.FieldTypeInt, pilosa.FieldTypeTime, pilosa.FieldTypeMutex, pilosa.FieldTypeBool:
+		default:
+			return nil, fmt.Errorf("invalid field type in mapping %q: %s", entry, c.typ)
+		}
+		columns = append(columns, c)
+	}
+	return columns, nil
+}
+
+// runHeader imports files whose first row names their columns.
+func (cmd *ImportCommand) runHeader(ctx context.Context) error {
+	logger := cmd.Logger()
+
+	mapping, err := parseImportMapping(cmd.Mapping)
+	if err != nil {
+		return err
+	}
+
+	if cmd.CreateSchema {
+		if err := cmd.client.EnsureIndex(ctx, cmd.Index, cmd.IndexOptions); err != nil {
+			return errors.Wrap(err, "creating index")
+		}
+	}
+	schema, err := cmd.client.Schema(ctx)
+	if err != nil {
+		return errors.Wrap(err, "getting schema")
+	}
+	var index *pilosa.IndexInfo
+	for _, ii := range schema {
+		if ii.Name == cmd.Index {
+			index = ii
+			break
+		}
+	}
+	if index == nil {
+		return pilosa.ErrIndexNotFound
+	}
+
+	for _, path := range cmd.Paths {
+		logger.Printf("parsing: %s", path)
+		if err := cmd.importHeaderPath(ctx, index, mapping, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importHeaderPath parses a file with a header row and imports each of its
+// mapped columns into a field.
+func (cmd *ImportCommand) importHeaderPath(ctx context.Context, index *pilosa.IndexInfo, mapping []importColumn, path string) error {
+	var r *csv.Reader
+
+	if path != "-" {
+		// Open file for reading.
+		f, err := os.Open(path)
+		if err != nil {
+			return errors.Wrap(err, "opening file")
+		}
+		defer f.Close()
+
+		r = csv.NewReader(f)
+	} else {
+		r = csv.NewReader(cmd.Stdin)
+	}
+
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil
+	} else if err != nil {
+		return errors.Wrap(err, "reading header")
+	}
+	position := func(name string) (int, error) {
+		for i, v := range header {
+			if v == name {
+				return i, nil
+			}
+		}
+		return -1, fmt.Errorf("column %q not found in header of %s", name, path)
+	}
+
+	idIdx, err := position(cmd.IDColumn)
+	if err != nil {
+		return err
+	}
+	tsIdx := -1
+	if cmd.TimestampColumn != "" {
+		if tsIdx, err = position(cmd.TimestampColumn); err != nil {
+			return err
+		}
+	}
+
+	// Determine the columns to import. Fields are resolved separately for
+	// each file, since their columns may be in a different order.
+	var columns []importColumn
+	if len(mapping) == 0 {
+		for i, name := range header {
+			if i != idIdx && i != tsIdx {
+				columns = append(columns, importColumn{name: name, field: name, i: i})
+			}
+		}
+	} else {
+		for _, c := range mapping {
+			if c.i, err = position(c.name); err != nil {
+				return err
+			}
+			columns = append(columns, c)
+		}
+	}
+
+	rows := make([][]string, 0, cmd.BufferSize)
+	rnum, first := 1, 2
+	for {
+		rnum++
+
+		// Read CSV row. Each row must have as many values as the header.
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return errors.Wrap(err, "reading")
+		}
+		rows = append(rows, record)
+
+		// If we've reached the buffer size then import rows.
+		if len(rows) == cmd.BufferSize {
+			if err := cmd.importRows(ctx, index, columns, idIdx, tsIdx, rows, first); err != nil {
+				return err
+			}
+			rows, first = rows[:0], rnum+1
+		}
+	}
+
+	// If there are still rows in the buffer then flush them.
+	return cmd.importRows(ctx, index, columns, idIdx, tsIdx, rows, first)
+}
+
+// importRows imports a batch of rows into the fields of columns. rnum is the
+// row number of the first row, and is used in error messages.
+func (cmd *ImportCommand) importRows(ctx context.Context, index *pilosa.IndexInfo, columns []importColumn, idIdx, tsIdx int, rows [][]string, rnum int) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	for i := range columns {
+		if columns[i].opts == nil {
+			if err := cmd.resolveField(ctx, index, &columns[i], tsIdx >= 0, rows); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Parse the column ID, or key, and timestamp of each row.
+	useColumnKeys := index.Options.Keys
+	columnIDs := make([]uint64, len(rows))
+	timestamps := make([]int64, len(rows))
+	for j, record := range rows {
+		if useColumnKeys {
+			if record[idIdx] == "" {
+				return fmt.Errorf("missing column key on row %d", rnum+j)
+			}
+		} else {
+			id, err := strconv.ParseUint(record[idIdx], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid column id on row %d: %q", rnum+j, record[idIdx])
+			}
+			columnIDs[j] = id
+		}
+
+		if tsIdx >= 0 && record[tsIdx] != "" {
+			t, err := time.Parse(pilosa.TimeFormat, record[tsIdx])
+			if err != nil {
+				return fmt.Errorf("invalid timestamp on row %d: %q", rnum+j, record[tsIdx])
+			}
+			timestamps[j] = t.UnixNano()
+		}
+	}
+
+	// Import each column into its field. Empty values are skipped.
+	for _, c := range columns {
+		if c.opts.Type == pilosa.FieldTypeInt {
+			vals := make([]pilosa.FieldValue, 0, len(rows))
+			for j, record := range rows {
+				v := record[c.i]
+				if v == "" {
+					continue
+				}
+				val := pilosa.FieldValue{ColumnID: columnIDs[j]}
+				if useColumnKeys {
+					val.ColumnKey = record[idIdx]
+				}
+				value, err := strconv.ParseInt(v, 10, 64)
+				if err != nil {
+					return fmt.Errorf("invalid value for %s on row %d: %q", c.name, rnum+j, v)
+				}
+				val.Value = value
+				vals = append(vals, val)
+			}
+			if err := cmd.importValues(ctx, c.field, useColumnKeys, vals); err != nil {
+				return err
+			}
+			continue
+		}
+
+		bits := make([]pilosa.Bit, 0, len(rows))
+		for j, record := range rows {
+			v := record[c.i]
+			if v == "" {
+				continue
+			}
+			bit := pilosa.Bit{ColumnID: columnIDs[j]}
+			if useColumnKeys {
+				bit.ColumnKey = record[idIdx]
+			}
+			if c.opts.Type == pilosa.FieldTypeTime {
+				bit.Timestamp = timestamps[j]
+			}
+
+			if c.opts.Keys {
+				bit.RowKey = v
+			} else if c.opts.Type == pilosa.FieldTypeBool {
+				b, err := strconv.ParseBool(v)
+				if err != nil {
+					return fmt.Errorf("invalid bool for %s on row %d: %q", c.name, rnum+j, v)
+				} else if b {
+					bit.RowID = 1
+				}
+			} else {
+				id, err := strconv.ParseUint(v, 10, 64)
+				if err != nil {
+					return fmt.Errorf("invalid row id for %s on row %d: %q", c.name, rnum+j, v)
+				}
+				bit.RowID = id
+			}
+			bits = append(bits, bit)
+		}
+		if err := cmd.importBits(ctx, c.field, useColumnKeys, c.opts.Keys, bits); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveField sets the options of a column's field, creating the field if
+// it doesn't exist and CreateSchema is set. The type of a created field is
+// inferred from rows unless the mapping gives it.
+func (cmd *ImportCommand) resolveField(ctx context.Context, index *pilosa.IndexInfo, c *importColumn, timestamps bool, rows [][]string) error {
+	for _, field := range index.Fields {
+		if field.Name == c.field {
+			opts := field.Options
+			c.opts = &opts
+			return nil
+		}
+	}
+	if !cmd.CreateSchema {
+		return errors.Wrap(pilosa.ErrFieldNotFound, c.field)
+	}
+
+	opts := cmd.FieldOptions
+	opts.Type = c.typ
+	if opts.Type == "" {
+		opts.Type = inferFieldType(rows, c.i, timestamps)
+	}
+	switch opts.Type {
+	case pilosa.FieldTypeInt:
+		if opts.Min == 0 && opts.Max == 0 {
+			opts.Min, opts.Max = math.MinInt64, math.MaxInt64
+		}
+	case pilosa.FieldTypeTime:
+		if opts.TimeQuantum == "" {
+			opts.TimeQuantum = "YMD"
+		}
+	}
+	if opts.Type != pilosa.FieldTypeInt && opts.Type != pilosa.FieldTypeBool && !opts.Keys {
+		opts.Keys = !allValues(rows, c.i, func(v string) error {
+			_, err := strconv.ParseUint(v, 10, 64)
+			return err
+		})
+	}
+
+	cmd.Logger().Printf("creating %s field: %s", opts.Type, c.field)
+	if err := cmd.client.EnsureFieldWithOptions(ctx, cmd.Index, c.field, opts); err != nil {
+		return errors.Wrapf(err, "creating field %s", c.field)
+	}
+	index.Fields = append(index.Fields, &pilosa.FieldInfo{Name: c.field, Options: opts})
+	c.opts = &opts
+	return nil
+}
+
+// inferFieldType returns the type of a field created for the values at
+// position i of rows: int if they are all integers, and otherwise time if
+// the rows have timestamps, or set.
+func inferFieldType(rows [][]string, i int, timestamps bool) string {
+	if allValues(rows, i, func(v string) error {
+		_, err := strconv.ParseInt(v, 10, 64)
+		return err
+	}) {
+		return pilosa.FieldTypeInt
+	} else if timestamps {
+		return pilosa.FieldTypeTime
+	}
+	return pilosa.FieldTypeSet
+}
+
+// allValues returns true if parse succeeds for every non-empty value at
+// position i of rows, and there is at least one.
+func allValues(rows [][]string, i int, parse func(string) error) bool {
+	var n int
+	for _, record := range rows {
+		if record[i] == "" {
+			continue
+		} else if err := parse(record[i]); err != nil {
+			return false
+		}
+		n++
+	}
+	return n > 0
+}
+
 func (cmd *ImportCommand) TLSHost() string {
 	return cmd.Host
 }
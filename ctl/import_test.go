@@ -28,6 +28,7 @@ import (
 
 	"github.com/pilosa/pilosa/v2"
 	"github.com/pilosa/pilosa/v2/test"
+	"github.com/pkg/errors"
 )
 
 func TestImportCommand_Validation(t *testing.T) {
@@ -573,3 +574,110 @@ func TestImportCommand_RunBool(t *testing.T) {
 		}
 	})
 }
+
+// Ensure that a file with a header is imported into several fields.
+func TestImportCommand_Header(t *testing.T) {
+	ctx := context.Background()
+	cluster := test.MustRunCluster(t, 1)
+	defer cluster.Close()
+	cmd := cluster[0]
+
+	newCommand := func(data string) *ImportCommand {
+		t.Helper()
+		buf := bytes.Buffer{}
+		stdin, stdout, stderr := GetIO(buf)
+		cm := NewImportCommand(stdin, stdout, stderr)
+		file, err := ioutil.TempFile("", "import-header.csv")
+		if err != nil {
+			t.Fatalf("creating tempfile: %v", err)
+		}
+		defer file.Close()
+		if _, err := file.Write([]byte(data)); err != nil {
+			t.Fatalf("writing to tempfile: %v", err)
+		}
+		cm.Host = cmd.API.Node().URI.HostPort()
+		cm.Header = true
+		cm.Paths = []string{file.Name()}
+		return cm
+	}
+
+	t.Run("Create", func(t *testing.T) {
+		cm := newCommand("id,country,age,signup_time\n1,us,30,2019-01-02T00:00\n2,de,,2019-02-03T00:00\n3,us,42,\n")
+		cm.Index = "i"
+		cm.CreateSchema = true
+		cm.TimestampColumn = "signup_time"
+		if err := cm.Run(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, tc := range []struct {
+			query string
+			exp   string
+		}{
+			{`Row(country=us)`, `{"results":[{"attrs":{},"columns":[1,3]}]}`},
+			{`Row(country=de, from=2019-02-01T00:00, to=2019-03-01T00:00)`, `{"results":[{"attrs":{},"columns":[2]}]}`},
+			{`Sum(field=age)`, `{"results":[{"value":72,"count":2}]}`},
+		} {
+			if body, err := cmd.Query("i", "", tc.query); err != nil {
+				t.Fatal(err)
+			} else if strings.TrimSpace(body) != tc.exp {
+				t.Fatalf("%s: unexpected result: %s", tc.query, body)
+			}
+		}
+	})
+
+	t.Run("Mapping", func(t *testing.T) {
+		cmd.MustCreateIndex(t, "j", pilosa.IndexOptions{Keys: true})
+		cmd.MustCreateField(t, "j", "active", pilosa.OptFieldTypeBool())
+
+		cm := newCommand("user,region,active,score\nalice,1,true,5\nbob,2,false,7\n")
+		cm.Index = "j"
+		cm.CreateSchema = true
+		cm.IDColumn = "user"
+		cm.Mapping = []string{"region=place:mutex", "active"}
+		if err := cm.Run(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, tc := range []struct {
+			query string
+			exp   string
+		}{
+			{`Row(place=2)`, `{"results":[{"attrs":{},"columns":[],"keys":["bob"]}]}`},
+			{`Row(active=true)`, `{"results":[{"attrs":{},"columns":[],"keys":["alice"]}]}`},
+		} {
+			if body, err := cmd.Query("j", "", tc.query); err != nil {
+				t.Fatal(err)
+			} else if strings.TrimSpace(body) != tc.exp {
+				t.Fatalf("%s: unexpected result: %s", tc.query, body)
+			}
+		}
+		if body, err := cmd.Query("j", "", `Row(score=5)`); err == nil {
+			t.Fatalf("expected unmapped column not to be imported: %s", body)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		cm := newCommand("id,size\n1,2\n")
+		cm.Index = "i"
+		if err := cm.Run(ctx); errors.Cause(err) != pilosa.ErrFieldNotFound {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		cm.Mapping = []string{"weight"}
+		if err := cm.Run(ctx); err == nil || !strings.Contains(err.Error(), `column "weight" not found`) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		cm.Mapping = []string{"size:float"}
+		if err := cm.Run(ctx); err == nil || !strings.Contains(err.Error(), "invalid field type") {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		cm.Header = false
+		cm.Field = "f"
+		if err := cm.Run(ctx); err == nil || err.Error() != "mapping requires a header" {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+}
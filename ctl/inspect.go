@@ -16,24 +16,37 @@ package ctl
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"syscall"
 	"text/tabwriter"
 	"time"
 	"unsafe"
 
+	"github.com/gogo/protobuf/proto"
 	"github.com/pilosa/pilosa/v2"
+	"github.com/pilosa/pilosa/v2/boltdb"
+	"github.com/pilosa/pilosa/v2/internal"
 	"github.com/pilosa/pilosa/v2/roaring"
 	"github.com/pkg/errors"
 )
 
-// InspectCommand represents a command for inspecting fragment data files.
+// InspectCommand represents a command for inspecting fragment data files,
+// or every fragment of a data directory.
 type InspectCommand struct {
-	// Path to data file
+	// Path to data file, or to data directory.
 	Path string
 
+	// Print reports as JSON.
+	JSON bool
+
 	// Standard input/output
 	*pilosa.CmdIO
 }
@@ -47,29 +60,38 @@ func NewInspectCommand(stdin io.Reader, stdout, stderr io.Writer) *InspectComman
 
 // Run executes the inspect command.
 func (cmd *InspectCommand) Run(_ context.Context) error {
-	// Open file handle.
-	f, err := os.Open(cmd.Path)
+	fi, err := os.Stat(cmd.Path)
 	if err != nil {
-		return errors.Wrap(err, "opening file")
+		return errors.Wrap(err, "statting path")
 	}
-	defer f.Close()
-
-	fi, err := f.Stat()
-	if err != nil {
-		return errors.Wrap(err, "statting file")
+	if fi.IsDir() {
+		return cmd.runDir()
+	} else if cmd.JSON {
+		frag, err := inspectFragmentFile(cmd.Path, "", nil)
+		if err != nil {
+			return err
+		}
+		return cmd.writeJSON(frag)
 	}
 
-	// Memory map the file.
-	data, err := syscall.Mmap(int(f.Fd()), 0, int(fi.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	data, unmap, err := mmapFile(cmd.Path)
 	if err != nil {
-		return errors.Wrap(err, "mmapping")
+		return err
 	}
 	defer func() {
-		err := syscall.Munmap(data)
-		if err != nil {
+		if err := unmap(); err != nil {
 			fmt.Fprintf(cmd.Stderr, "inspect command: munmap failed: %v", err)
 		}
 	}()
+	// Container offsets of a compressed file are given within its
+	// decompressed data.
+	if roaring.Compressed(data) {
+		fmt.Fprintf(cmd.Stderr, "decompressing bitmap...\n")
+		if data, err = roaring.Decompress(data); err != nil {
+			return errors.Wrap(err, "decompressing")
+		}
+	}
+
 	// Attach the mmap file to the bitmap.
 	t := time.Now()
 	fmt.Fprintf(cmd.Stderr, "unmarshalling bitmap...")
@@ -108,3 +130,390 @@ func (cmd *InspectCommand) Run(_ context.Context) error {
 
 	return nil
 }
+
+// runDir inspects every fragment and translate store of a data directory.
+func (cmd *InspectCommand) runDir() error {
+	indexes, err := inspectDataDir(cmd.Path)
+	if err != nil {
+		return err
+	}
+	if cmd.JSON {
+		return cmd.writeJSON(struct {
+			Indexes []*InspectIndex `json:"indexes"`
+		}{indexes})
+	}
+
+	fmt.Fprintln(cmd.Stdout, "== Fragments ==")
+	tw := tabwriter.NewWriter(cmd.Stdout, 0, 8, 1, ' ', 0)
+	fmt.Fprintln(tw, "INDEX\tFIELD\tVIEW\tSHARD\tBITS\tROWS\tARRAY\tBITMAP\tRUN\tOPS\tBIT DEPTH\tCACHE")
+	for _, index := range indexes {
+		for _, field := range index.Fields {
+			for _, view := range field.Views {
+				for _, frag := range view.Fragments {
+					if frag.Error != "" {
+						fmt.Fprintf(tw, "%s\t%s\t%s\t%d\terror: %s\n", index.Name, field.Name, view.Name, frag.Shard, frag.Error)
+						continue
+					}
+					bitDepth := "-"
+					if strings.HasPrefix(view.Name, pilosa.ViewBSIGroupPrefix) {
+						bitDepth = strconv.FormatUint(frag.BitDepth, 10)
+					}
+					fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%s\t%s\n",
+						index.Name, field.Name, view.Name, frag.Shard,
+						frag.Bits, frag.Rows,
+						frag.Containers["array"], frag.Containers["bitmap"], frag.Containers["run"],
+						frag.Ops, bitDepth, frag.Cache.status(),
+					)
+				}
+			}
+		}
+	}
+	tw.Flush()
+	fmt.Fprintln(cmd.Stdout, "")
+
+	fmt.Fprintln(cmd.Stdout, "== Translate Stores ==")
+	tw = tabwriter.NewWriter(cmd.Stdout, 0, 8, 1, ' ', 0)
+	fmt.Fprintln(tw, "INDEX\tFIELD\tSIZE\tKEYS\tMAX ID")
+	printKeys := func(index, field string, keys *InspectKeys) {
+		if keys == nil {
+			return
+		} else if keys.Error != "" {
+			fmt.Fprintf(tw, "%s\t%s\t%d\terror: %s\n", index, field, keys.Size, keys.Error)
+			return
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\n", index, field, keys.Size, keys.Keys, keys.MaxID)
+	}
+	for _, index := range indexes {
+		printKeys(index.Name, "-", index.Keys)
+		for _, field := range index.Fields {
+			printKeys(index.Name, field.Name, field.Keys)
+		}
+	}
+	return tw.Flush()
+}
+
+func (cmd *InspectCommand) writeJSON(v interface{}) error {
+	enc := json.NewEncoder(cmd.Stdout)
+	enc.SetIndent("", "  ")
+	return errors.Wrap(enc.Encode(v), "encoding report")
+}
+
+// InspectIndex is the report of an index of a data directory.
+type InspectIndex struct {
+	Name   string          `json:"name"`
+	Keys   *InspectKeys    `json:"keys,omitempty"`
+	Fields []*InspectField `json:"fields"`
+}
+
+// InspectField is the report of a field of a data directory.
+type InspectField struct {
+	Name      string         `json:"name"`
+	Type      string         `json:"type"`
+	CacheType string         `json:"cacheType,omitempty"`
+	CacheSize uint32         `json:"cacheSize,omitempty"`
+	BitDepth  uint64         `json:"bitDepth,omitempty"`
+	Keys      *InspectKeys   `json:"keys,omitempty"`
+	Views     []*InspectView `json:"views"`
+	Error     string         `json:"error,omitempty"`
+}
+
+// InspectView is the report of a view of a data directory.
+type InspectView struct {
+	Name      string             `json:"name"`
+	Fragments []*InspectFragment `json:"fragments"`
+}
+
+// InspectFragment is the report of a fragment data file.
+type InspectFragment struct {
+	Shard uint64 `json:"shard"`
+	Size  int64  `json:"size"`
+
+	// Bits is the number of bits set, and Rows the number of rows with bits.
+	Bits uint64 `json:"bits"`
+	Rows int    `json:"rows"`
+
+	// Containers is the number of containers of each type.
+	Containers map[string]int `json:"containers"`
+
+	// Ops is the number of ops appended to the data file since its last
+	// snapshot, and OpN the number of bits they changed.
+	Ops int `json:"ops"`
+	OpN int `json:"opN"`
+
+	// Compressed is set for a cold fragment, whose data file is compressed.
+	Compressed bool `json:"compressed,omitempty"`
+
+	// BitDepth is the number of value bits held by a fragment of a BSI view.
+	BitDepth uint64 `json:"bitDepth,omitempty"`
+
+	Cache *InspectCache `json:"cache,omitempty"`
+	Error string        `json:"error,omitempty"`
+}
+
+// InspectCache is the report of the cache file of a fragment. The cache is
+// consistent when each of its rows has bits, and, when the fragment has no
+// more rows than the cache holds, each row with bits is cached.
+type InspectCache struct {
+	Rows       int    `json:"rows"`
+	Stale      int    `json:"stale"`
+	Missing    int    `json:"missing"`
+	Consistent bool   `json:"consistent"`
+	Error      string `json:"error,omitempty"`
+}
+
+// status returns a short description of the cache.
+func (c *InspectCache) status() string {
+	switch {
+	case c == nil:
+		return "-"
+	case c.Error != "":
+		return "error: " + c.Error
+	case c.Consistent:
+		return "ok"
+	}
+	return fmt.Sprintf("inconsistent (%d stale, %d missing)", c.Stale, c.Missing)
+}
+
+// InspectKeys is the report of a translate store.
+type InspectKeys struct {
+	Size  int64  `json:"size"`
+	Keys  int    `json:"keys"`
+	MaxID uint64 `json:"maxID"`
+	Error string `json:"error,omitempty"`
+}
+
+// inspectDataDir inspects every index of a data directory.
+func inspectDataDir(path string) ([]*InspectIndex, error) {
+	names, err := inspectSubdirs(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading data directory")
+	}
+
+	var indexes []*InspectIndex
+	for _, name := range names {
+		indexPath := filepath.Join(path, name)
+		if _, err := os.Stat(filepath.Join(indexPath, ".meta")); err != nil {
+			continue // not an index
+		}
+		index := &InspectIndex{Name: name, Keys: inspectKeys(filepath.Join(indexPath, "keys"))}
+
+		fieldNames, err := inspectSubdirs(indexPath)
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading index %s", name)
+		}
+		for _, fieldName := range fieldNames {
+			field, err := inspectField(filepath.Join(indexPath, fieldName), fieldName)
+			if err != nil {
+				return nil, errors.Wrapf(err, "inspecting field %s/%s", name, fieldName)
+			}
+			index.Fields = append(index.Fields, field)
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes, nil
+}
+
+// inspectField inspects every fragment of a field directory.
+func inspectField(path, name string) (*InspectField, error) {
+	field := &InspectField{Name: name, Keys: inspectKeys(filepath.Join(path, "keys"))}
+
+	var opt internal.FieldOptions
+	if buf, err := ioutil.ReadFile(filepath.Join(path, ".meta")); err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, "reading meta")
+	} else if err := proto.Unmarshal(buf, &opt); err != nil {
+		field.Error = fmt.Sprintf("unmarshalling meta: %v", err)
+	}
+	field.Type, field.CacheType, field.CacheSize, field.BitDepth = opt.Type, opt.CacheType, opt.CacheSize, opt.BitDepth
+	if field.Type == "" {
+		field.Type = pilosa.FieldTypeSet
+	}
+
+	viewNames, err := inspectSubdirs(filepath.Join(path, "views"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, "reading views")
+	}
+	for _, viewName := range viewNames {
+		view := &InspectView{Name: viewName}
+		dir := filepath.Join(path, "views", viewName, "fragments")
+		shards, err := fragmentShards(dir)
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading view %s", viewName)
+		}
+
+		for _, shard := range shards {
+			cacheOpt := &opt
+			if !fieldCached(&opt) {
+				cacheOpt = nil
+			}
+			frag, err := inspectFragmentFile(filepath.Join(dir, strconv.FormatUint(shard, 10)), viewName, cacheOpt)
+			if err != nil {
+				frag = &InspectFragment{Error: err.Error()}
+			}
+			frag.Shard = shard
+			view.Fragments = append(view.Fragments, frag)
+		}
+		field.Views = append(field.Views, view)
+	}
+	return field, nil
+}
+
+// inspectFragmentFile inspects a fragment data file of the view. The cache
+// file of the fragment is checked when opt is given.
+func inspectFragmentFile(path, view string, opt *internal.FieldOptions) (*InspectFragment, error) {
+	data, unmap, err := mmapFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer unmap()
+
+	bm := roaring.NewBitmap()
+	if err := bm.UnmarshalBinary(data); err != nil {
+		return nil, errors.Wrap(err, "unmarshalling")
+	}
+	info := bm.Info()
+
+	frag := &InspectFragment{
+		Size:       int64(len(data)),
+		Containers: make(map[string]int),
+		Ops:        info.Ops,
+		OpN:        info.OpN,
+		Compressed: roaring.Compressed(data),
+	}
+	rows := make(map[uint64]struct{})
+	var maxRow uint64
+	for _, ci := range info.Containers {
+		frag.Containers[ci.Type]++
+		if ci.N == 0 {
+			continue
+		}
+		frag.Bits += uint64(ci.N)
+		row := (ci.Key << 16) / pilosa.ShardWidth
+		rows[row] = struct{}{}
+		if row > maxRow {
+			maxRow = row
+		}
+	}
+	frag.Rows = len(rows)
+	if strings.HasPrefix(view, pilosa.ViewBSIGroupPrefix) && maxRow >= pilosa.BSIOffsetBit {
+		frag.BitDepth = maxRow - pilosa.BSIOffsetBit + 1
+	}
+
+	if opt != nil {
+		frag.Cache = inspectCache(path+".cache", rows, opt.CacheSize)
+	}
+	return frag, nil
+}
+
+// inspectCache checks the cache file of a fragment against the rows of the
+// fragment.
+func inspectCache(path string, rows map[uint64]struct{}, size uint32) *InspectCache {
+	cache := &InspectCache{}
+	var pb internal.Cache
+	if buf, err := ioutil.ReadFile(path); os.IsNotExist(err) {
+		if len(rows) == 0 {
+			cache.Consistent = true
+			return cache
+		}
+	} else if err != nil {
+		cache.Error = err.Error()
+		return cache
+	} else if err := proto.Unmarshal(buf, &pb); err != nil {
+		cache.Error = fmt.Sprintf("unmarshalling: %v", err)
+		return cache
+	}
+
+	cached := make(map[uint64]struct{}, len(pb.IDs))
+	for _, id := range pb.IDs {
+		cached[id] = struct{}{}
+		if _, ok := rows[id]; !ok {
+			cache.Stale++
+		}
+	}
+	cache.Rows = len(cached)
+	if size == 0 || len(rows) <= int(size) {
+		for id := range rows {
+			if _, ok := cached[id]; !ok {
+				cache.Missing++
+			}
+		}
+	}
+	cache.Consistent = cache.Stale == 0 && cache.Missing == 0
+	return cache
+}
+
+// fieldCached returns whether the fragments of a field have cache files.
+func fieldCached(opt *internal.FieldOptions) bool {
+	return opt.CacheType != pilosa.CacheTypeNone && opt.Type != pilosa.FieldTypeInt
+}
+
+// fragmentShards returns the shards of the fragment data files in the
+// fragments directory of a view, in order.
+func fragmentShards(dir string) ([]uint64, error) {
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	var shards []uint64
+	for _, fi := range fis {
+		if shard, err := strconv.ParseUint(fi.Name(), 10, 64); err == nil && fi.Mode().IsRegular() {
+			shards = append(shards, shard)
+		}
+	}
+	sort.Slice(shards, func(i, j int) bool { return shards[i] < shards[j] })
+	return shards, nil
+}
+
+// inspectKeys inspects a translate store, returning nil if there is none.
+func inspectKeys(path string) *InspectKeys {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+	keys := &InspectKeys{Size: fi.Size()}
+	if keys.Keys, keys.MaxID, err = boltdb.InspectTranslateStore(path); err != nil {
+		keys.Error = err.Error()
+	}
+	return keys
+}
+
+// inspectSubdirs returns the names of the directories within path, leaving
+// out hidden ones.
+func inspectSubdirs(path string) ([]string, error) {
+	fis, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, fi := range fis {
+		if fi.IsDir() && !strings.HasPrefix(fi.Name(), ".") {
+			names = append(names, fi.Name())
+		}
+	}
+	return names, nil
+}
+
+// mmapFile maps a file into memory, returning its data and a function to
+// unmap it.
+func mmapFile(path string) ([]byte, func() error, error) {
+	// Open file handle.
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "opening file")
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "statting file")
+	} else if fi.Size() == 0 {
+		return nil, func() error { return nil }, nil
+	}
+
+	// Memory map the file.
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(fi.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "mmapping")
+	}
+	return data, func() error { return syscall.Munmap(data) }, nil
+}
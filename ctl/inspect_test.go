@@ -17,11 +17,15 @@ package ctl
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"io"
 	"io/ioutil"
 	"os"
 	"strings"
 	"testing"
+
+	"github.com/pilosa/pilosa/v2"
+	"github.com/pilosa/pilosa/v2/test"
 )
 
 func TestInspectCommand_Run(t *testing.T) {
@@ -58,3 +62,75 @@ func TestInspectCommand_Run(t *testing.T) {
 
 	//	Todo: need correct roaring file for happy path
 }
+
+func TestInspectCommand_RunDir(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	cmd := c[0]
+	defer os.RemoveAll(cmd.Config.DataDir)
+
+	cmd.MustCreateIndex(t, "i", pilosa.IndexOptions{Keys: true})
+	cmd.MustCreateField(t, "i", "f", pilosa.OptFieldKeys())
+	cmd.MustCreateField(t, "i", "n", pilosa.OptFieldTypeInt(0, 1000))
+	for _, q := range []string{
+		`Set("a", f="x") Set("b", f="x") Set("b", f="y")`,
+		`Set("a", n=5) Set("b", n=300)`,
+	} {
+		if _, err := cmd.Query("i", "", q); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Translate stores can't be inspected while the server holds them open.
+	if err := cmd.Command.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	cm := NewInspectCommand(os.Stdin, &buf, ioutil.Discard)
+	cm.Path = cmd.Config.DataDir
+	cm.JSON = true
+	if err := cm.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	var report struct {
+		Indexes []*InspectIndex `json:"indexes"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	} else if len(report.Indexes) != 1 {
+		t.Fatalf("unexpected indexes: %s", buf.String())
+	}
+	index := report.Indexes[0]
+	if index.Name != "i" || index.Keys == nil || index.Keys.Keys != 2 || index.Keys.MaxID != 2 {
+		t.Fatalf("unexpected index: %+v, keys %+v", index, index.Keys)
+	}
+
+	fields := make(map[string]*InspectField)
+	for _, field := range index.Fields {
+		fields[field.Name] = field
+	}
+	if f := fields["f"]; f == nil || f.Type != pilosa.FieldTypeSet || f.Keys == nil || f.Keys.Keys != 2 || len(f.Views) != 1 {
+		t.Fatalf("unexpected field f: %+v", f)
+	} else if frag := f.Views[0].Fragments[0]; frag.Bits != 3 || frag.Rows != 2 || frag.Containers["array"] != 2 || frag.Ops == 0 {
+		t.Fatalf("unexpected fragment of f: %+v", frag)
+	} else if frag.Cache == nil || !frag.Cache.Consistent || frag.Cache.Rows != 2 {
+		t.Fatalf("unexpected cache of f: %+v", frag.Cache)
+	}
+	if f := fields["n"]; f == nil || f.Type != pilosa.FieldTypeInt || f.BitDepth != 9 || len(f.Views) != 1 {
+		t.Fatalf("unexpected field n: %+v", f)
+	} else if frag := f.Views[0].Fragments[0]; f.Views[0].Name != "bsig_n" || frag.BitDepth != 9 || frag.Cache != nil {
+		t.Fatalf("unexpected fragment of n: %+v", frag)
+	}
+
+	// The text report holds a row for each fragment and translate store.
+	buf.Reset()
+	cm.JSON = false
+	if err := cm.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"== Fragments ==", "bsig_n", "== Translate Stores =="} {
+		if !strings.Contains(buf.String(), s) {
+			t.Fatalf("expected %q in report: %s", s, buf.String())
+		}
+	}
+}
@@ -0,0 +1,291 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"syscall"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/pilosa/pilosa/v2"
+	"github.com/pilosa/pilosa/v2/internal"
+	"github.com/pilosa/pilosa/v2/roaring"
+	"github.com/pkg/errors"
+)
+
+// RepairCommand represents a command for repairing the fragments of a data
+// directory, such as after a crash. The server must not be running.
+type RepairCommand struct {
+	// Path to data directory.
+	Path string
+
+	// Report repairs without making them.
+	ReportOnly bool
+
+	// Print the report as JSON.
+	JSON bool
+
+	// Standard input/output
+	*pilosa.CmdIO
+}
+
+// NewRepairCommand returns a new instance of RepairCommand.
+func NewRepairCommand(stdin io.Reader, stdout, stderr io.Writer) *RepairCommand {
+	return &RepairCommand{
+		CmdIO: pilosa.NewCmdIO(stdin, stdout, stderr),
+	}
+}
+
+// Repair actions.
+const (
+	repairTruncate     = "truncate"
+	repairRebuildCache = "rebuild-cache"
+	repairQuarantine   = "quarantine"
+)
+
+// RepairReport is the report of a repair of a data directory.
+type RepairReport struct {
+	// Fragments is the number of fragments checked.
+	Fragments int             `json:"fragments"`
+	Actions   []*RepairAction `json:"actions"`
+}
+
+// RepairAction is a repair made to a fragment, or, when only reporting, one
+// which would be made.
+type RepairAction struct {
+	Index  string `json:"index"`
+	Field  string `json:"field"`
+	View   string `json:"view"`
+	Shard  uint64 `json:"shard"`
+	Action string `json:"action"`
+	Detail string `json:"detail"`
+}
+
+// Run executes the repair command.
+func (cmd *RepairCommand) Run(_ context.Context) error {
+	if fi, err := os.Stat(cmd.Path); err != nil {
+		return errors.Wrap(err, "statting path")
+	} else if !fi.IsDir() {
+		return fmt.Errorf("not a data directory: %s", cmd.Path)
+	}
+
+	report := &RepairReport{Actions: []*RepairAction{}}
+	names, err := inspectSubdirs(cmd.Path)
+	if err != nil {
+		return errors.Wrap(err, "reading data directory")
+	}
+	for _, name := range names {
+		indexPath := filepath.Join(cmd.Path, name)
+		if _, err := os.Stat(filepath.Join(indexPath, ".meta")); err != nil {
+			continue // not an index
+		}
+		fieldNames, err := inspectSubdirs(indexPath)
+		if err != nil {
+			return errors.Wrapf(err, "reading index %s", name)
+		}
+		for _, fieldName := range fieldNames {
+			if err := cmd.repairField(report, name, fieldName); err != nil {
+				return errors.Wrapf(err, "repairing field %s/%s", name, fieldName)
+			}
+		}
+	}
+
+	if cmd.JSON {
+		enc := json.NewEncoder(cmd.Stdout)
+		enc.SetIndent("", "  ")
+		return errors.Wrap(enc.Encode(report), "encoding report")
+	}
+	for _, a := range report.Actions {
+		fmt.Fprintf(cmd.Stdout, "%s/%s/%s/%d: %s: %s\n", a.Index, a.Field, a.View, a.Shard, a.Action, a.Detail)
+	}
+	verb := "made"
+	if cmd.ReportOnly {
+		verb = "would make"
+	}
+	fmt.Fprintf(cmd.Stdout, "checked %d fragments, %s %d repairs\n", report.Fragments, verb, len(report.Actions))
+	return nil
+}
+
+// repairField repairs every fragment of a field. Cache files are rebuilt
+// only when the options of the field can be read.
+func (cmd *RepairCommand) repairField(report *RepairReport, index, field string) error {
+	path := filepath.Join(cmd.Path, index, field)
+
+	var opt *internal.FieldOptions
+	if buf, err := ioutil.ReadFile(filepath.Join(path, ".meta")); err == nil {
+		opt = &internal.FieldOptions{}
+		if err := proto.Unmarshal(buf, opt); err != nil {
+			fmt.Fprintf(cmd.Stderr, "%s/%s: not rebuilding caches: unmarshalling meta: %v\n", index, field, err)
+			opt = nil
+		}
+	} else if !os.IsNotExist(err) {
+		return errors.Wrap(err, "reading meta")
+	}
+	if opt != nil && !fieldCached(opt) {
+		opt = nil
+	}
+
+	viewNames, err := inspectSubdirs(filepath.Join(path, "views"))
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "reading views")
+	}
+	for _, view := range viewNames {
+		dir := filepath.Join(path, "views", view, "fragments")
+		shards, err := fragmentShards(dir)
+		if err != nil {
+			return errors.Wrapf(err, "reading view %s", view)
+		}
+		for _, shard := range shards {
+			report.Fragments++
+			actions, err := cmd.repairFragment(filepath.Join(dir, strconv.FormatUint(shard, 10)), opt)
+			if err != nil {
+				return errors.Wrapf(err, "repairing view %s shard %d", view, shard)
+			}
+			for _, a := range actions {
+				a.Index, a.Field, a.View, a.Shard = index, field, view, shard
+			}
+			report.Actions = append(report.Actions, actions...)
+		}
+	}
+	return nil
+}
+
+// repairFragment repairs a fragment data file: an incomplete or corrupt
+// tail of its ops log is truncated, and the file is quarantined if it still
+// can't be read or fails its consistency check. A missing or unreadable
+// cache file is rebuilt when opt is given.
+func (cmd *RepairCommand) repairFragment(path string, opt *internal.FieldOptions) ([]*RepairAction, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0666)
+	if err != nil {
+		return nil, errors.Wrap(err, "opening file")
+	}
+	defer f.Close()
+
+	// A running server holds a lock on each fragment it has open.
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		return nil, errors.Wrap(err, "locking file, is the server running?")
+	}
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading file")
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	bm := roaring.NewBitmap()
+	err = bm.UnmarshalBinary(data)
+	var truncate *roaring.OpError
+	if opErr, ok := errors.Cause(err).(*roaring.OpError); ok {
+		truncate = opErr
+		bm = roaring.NewBitmap()
+		err = bm.UnmarshalBinary(data[:opErr.Offset])
+	}
+	if err == nil {
+		err = bm.Check()
+	}
+	if err != nil {
+		detail := err.Error()
+		if !cmd.ReportOnly {
+			dst, qerr := pilosa.QuarantineFragment(cmd.Path, path)
+			if qerr != nil {
+				return nil, errors.Wrap(qerr, "quarantining")
+			}
+			detail = fmt.Sprintf("%s, moved to %s", detail, dst)
+		}
+		return []*RepairAction{{Action: repairQuarantine, Detail: detail}}, nil
+	}
+
+	var actions []*RepairAction
+	if truncate != nil {
+		if !cmd.ReportOnly {
+			if err := f.Truncate(truncate.Offset); err != nil {
+				return nil, errors.Wrap(err, "truncating")
+			} else if err := f.Sync(); err != nil {
+				return nil, errors.Wrap(err, "syncing")
+			}
+		}
+		actions = append(actions, &RepairAction{
+			Action: repairTruncate,
+			Detail: fmt.Sprintf("dropped %d bytes of ops log from offset %d: %s", len(data)-int(truncate.Offset), truncate.Offset, truncate.Err),
+		})
+	}
+
+	if opt != nil {
+		if detail, err := repairCacheFile(path+".cache", bm, opt.CacheSize, cmd.ReportOnly); err != nil {
+			return nil, errors.Wrap(err, "rebuilding cache")
+		} else if detail != "" {
+			actions = append(actions, &RepairAction{Action: repairRebuildCache, Detail: detail})
+		}
+	}
+	return actions, nil
+}
+
+// repairCacheFile rebuilds a missing or unreadable cache file from the rows
+// of the fragment, keeping the size rows with the most bits. It returns why
+// the file was rebuilt, or an empty string if it wasn't.
+func repairCacheFile(path string, bm *roaring.Bitmap, size uint32, reportOnly bool) (string, error) {
+	var detail string
+	if buf, err := ioutil.ReadFile(path); os.IsNotExist(err) {
+		detail = "missing"
+	} else if err != nil {
+		detail = err.Error()
+	} else if err := proto.Unmarshal(buf, &internal.Cache{}); err != nil {
+		detail = fmt.Sprintf("unmarshalling: %v", err)
+	} else {
+		return "", nil
+	}
+
+	counts := make(map[uint64]uint64)
+	for _, ci := range bm.Info().Containers {
+		if ci.N > 0 {
+			counts[(ci.Key<<16)/pilosa.ShardWidth] += uint64(ci.N)
+		}
+	}
+	if len(counts) == 0 && detail == "missing" {
+		return "", nil // an empty fragment needs no cache
+	}
+	ids := make([]uint64, 0, len(counts))
+	for id := range counts {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if counts[ids[i]] != counts[ids[j]] {
+			return counts[ids[i]] > counts[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+	if size > 0 && len(ids) > int(size) {
+		ids = ids[:size]
+	}
+
+	detail = fmt.Sprintf("%s, %d rows", detail, len(ids))
+	if reportOnly {
+		return detail, nil
+	}
+	buf, err := proto.Marshal(&internal.Cache{IDs: ids})
+	if err != nil {
+		return "", errors.Wrap(err, "marshalling")
+	}
+	return detail, errors.Wrap(ioutil.WriteFile(path, buf, 0666), "writing")
+}
@@ -0,0 +1,147 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pilosa/pilosa/v2"
+	"github.com/pilosa/pilosa/v2/internal"
+	"github.com/pilosa/pilosa/v2/test"
+)
+
+func TestRepairCommand_Run(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	cmd := c[0]
+	defer os.RemoveAll(cmd.Config.DataDir)
+
+	cmd.MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	cmd.MustCreateField(t, "i", "f")
+	cmd.MustCreateField(t, "i", "g")
+	cmd.MustCreateField(t, "i", "n", pilosa.OptFieldTypeInt(0, 1000))
+	for _, q := range []string{
+		`Set(1, f=10) Set(2, f=10) Set(2, f=20)`,
+		`Set(1, g=10)`,
+		`Set(1, n=5) Set(2, n=300)`,
+	} {
+		if _, err := cmd.Query("i", "", q); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	cm := NewRepairCommand(os.Stdin, &buf, ioutil.Discard)
+	cm.Path = cmd.Config.DataDir
+	cm.JSON = true
+
+	// Fragments can't be repaired while the server holds them open.
+	if err := cm.Run(context.Background()); err == nil || !strings.Contains(err.Error(), "server running") {
+		t.Fatalf("expected locking error, got %v", err)
+	}
+	if err := cmd.Command.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Append an incomplete op to f, drop the cache of g and corrupt n.
+	fragPath := func(field, view string) string {
+		return filepath.Join(cmd.Config.DataDir, "i", field, "views", view, "fragments", "0")
+	}
+	fi, err := os.Stat(fragPath("f", "standard"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(fragPath("f", "standard"), os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		t.Fatal(err)
+	} else if _, err := f.Write([]byte{0, 1, 0, 0, 0}); err != nil {
+		t.Fatal(err)
+	} else if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(fragPath("g", "standard") + ".cache"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(fragPath("n", "bsig_n"), []byte("not a fragment"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func() *RepairReport {
+		t.Helper()
+		buf.Reset()
+		if err := cm.Run(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		var report RepairReport
+		if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+			t.Fatal(err)
+		}
+		return &report
+	}
+	check := func(report *RepairReport) {
+		t.Helper()
+		actions := make(map[string]string)
+		for _, a := range report.Actions {
+			actions[a.Field+"/"+a.View] += a.Action + " "
+		}
+		if report.Fragments != 3 || len(actions) != 3 ||
+			actions["f/standard"] != "truncate " ||
+			actions["g/standard"] != "rebuild-cache " ||
+			actions["n/bsig_n"] != "quarantine " {
+			t.Fatalf("unexpected report: %s", buf.String())
+		}
+	}
+
+	// Only reporting changes nothing.
+	cm.ReportOnly = true
+	check(run())
+	check(run())
+	if _, err := os.Stat(fragPath("n", "bsig_n")); err != nil {
+		t.Fatal(err)
+	}
+
+	cm.ReportOnly = false
+	check(run())
+	if got, err := os.Stat(fragPath("f", "standard")); err != nil {
+		t.Fatal(err)
+	} else if got.Size() != fi.Size() {
+		t.Fatalf("expected size %d after truncating, got %d", fi.Size(), got.Size())
+	}
+	if frag, err := inspectFragmentFile(fragPath("g", "standard"), "standard", &internal.FieldOptions{}); err != nil {
+		t.Fatal(err)
+	} else if !frag.Cache.Consistent || frag.Cache.Rows != 1 {
+		t.Fatalf("unexpected cache: %+v", frag.Cache)
+	}
+	if _, err := os.Stat(fragPath("n", "bsig_n")); !os.IsNotExist(err) {
+		t.Fatalf("expected corrupt fragment to be moved, got %v", err)
+	}
+	quarantined, err := filepath.Glob(filepath.Join(cmd.Config.DataDir, pilosa.QuarantineDir, "*", "i", "n", "views", "bsig_n", "fragments", "0"))
+	if err != nil {
+		t.Fatal(err)
+	} else if len(quarantined) != 1 {
+		t.Fatalf("expected quarantined fragment, got %v", quarantined)
+	}
+
+	// Repaired fragments need no more repairs.
+	if report := run(); report.Fragments != 2 || len(report.Actions) != 0 {
+		t.Fatalf("unexpected report: %s", buf.String())
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctl
+
+import (
+	"archive/tar"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pilosa/pilosa/v2"
+	"github.com/pilosa/pilosa/v2/server"
+	"github.com/pkg/errors"
+)
+
+// RestoreCommand represents a command for restoring a backup into a cluster.
+type RestoreCommand struct {
+	// Remote host and port.
+	Host string
+
+	// Filename of the tarball to read the backup from.
+	Path string
+
+	// Directory holding an extracted backup, in place of a tarball.
+	Dir string
+
+	// Standard input/output
+	*pilosa.CmdIO
+
+	TLS server.TLSConfig
+}
+
+// NewRestoreCommand returns a new instance of RestoreCommand.
+func NewRestoreCommand(stdin io.Reader, stdout, stderr io.Writer) *RestoreCommand {
+	return &RestoreCommand{
+		CmdIO: pilosa.NewCmdIO(stdin, stdout, stderr),
+	}
+}
+
+// Run executes the restore.
+func (cmd *RestoreCommand) Run(ctx context.Context) error {
+	// Validate arguments.
+	if cmd.Path == "" && cmd.Dir == "" {
+		return errors.New("input file or directory required")
+	} else if cmd.Path != "" && cmd.Dir != "" {
+		return errors.New("only one of input file and directory may be given")
+	}
+
+	// Create a client to the server.
+	client, err := commandClient(cmd)
+	if err != nil {
+		return errors.Wrap(err, "creating client")
+	}
+
+	// Send the backup as it is read, so that progress can be reported.
+	pr, pw := io.Pipe()
+	go func() { pw.CloseWithError(cmd.writeBackup(pw)) }()
+
+	err = client.Restore(ctx, pr)
+	pr.Close()
+	return err
+}
+
+// writeBackup writes the backup to w as a tarball.
+func (cmd *RestoreCommand) writeBackup(w io.Writer) error {
+	progress := &backupProgress{logger: cmd.Logger(), verb: "restoring"}
+	tw := tar.NewWriter(w)
+
+	if cmd.Path != "" {
+		f, err := os.Open(cmd.Path)
+		if err != nil {
+			return errors.Wrap(err, "opening file")
+		}
+		defer f.Close()
+
+		tr := tar.NewReader(f)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return errors.Wrap(err, "reading backup")
+			}
+			progress.entry(hdr.Name)
+
+			if err := tw.WriteHeader(hdr); err != nil {
+				return errors.Wrap(err, "writing header")
+			} else if _, err := io.Copy(tw, tr); err != nil {
+				return errors.Wrapf(err, "copying %s", hdr.Name)
+			}
+		}
+		return tw.Close()
+	}
+
+	names, err := dirBackupEntries(cmd.Dir)
+	if err != nil {
+		return errors.Wrap(err, "reading directory")
+	}
+	for _, name := range names {
+		progress.entry(name)
+		if err := writeDirEntry(tw, cmd.Dir, name); err != nil {
+			return errors.Wrapf(err, "copying %s", name)
+		}
+	}
+	return tw.Close()
+}
+
+func (cmd *RestoreCommand) TLSHost() string {
+	return cmd.Host
+}
+
+func (cmd *RestoreCommand) TLSConfiguration() server.TLSConfig {
+	return cmd.TLS
+}
+
+// writeDirEntry writes a file of an extracted backup to tw.
+func writeDirEntry(tw *tar.Writer, dir, name string) error {
+	f, err := os.Open(filepath.Join(dir, filepath.FromSlash(name)))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0600,
+		Size:    fi.Size(),
+		ModTime: fi.ModTime(),
+	}); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// dirBackupEntries returns the names of the files of a backup extracted into
+// dir, in the order they were backed up: the manifest and the schema first,
+// then each index with its keys and attributes ahead of its fragments,
+// ordered by shard.
+func dirBackupEntries(dir string) ([]string, error) {
+	var names []string
+	if err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		} else if !fi.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		names = append(names, filepath.ToSlash(rel))
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]backupEntryKey, len(names))
+	for _, name := range names {
+		keys[name] = newBackupEntryKey(name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return keys[names[i]].less(keys[names[j]])
+	})
+	return names, nil
+}
+
+// backupEntryKey orders the entries of a backup.
+type backupEntryKey struct {
+	top      bool
+	index    string
+	fragment bool
+	shard    uint64
+	name     string
+}
+
+func newBackupEntryKey(name string) backupEntryKey {
+	parts := strings.Split(name, "/")
+	k := backupEntryKey{
+		top:   len(parts) == 1,
+		index: parts[0],
+		name:  name,
+	}
+	if shard, ok := backupEntryShard(parts); ok {
+		if n, err := strconv.ParseUint(shard, 10, 64); err == nil {
+			k.fragment, k.shard = true, n
+		}
+	}
+	return k
+}
+
+func (k backupEntryKey) less(other backupEntryKey) bool {
+	switch {
+	case k.top != other.top:
+		return k.top
+	case k.index != other.index:
+		return k.index < other.index
+	case k.fragment != other.fragment:
+		return !k.fragment
+	case k.shard != other.shard:
+		return k.shard < other.shard
+	}
+	return k.name < other.name
+}
@@ -0,0 +1,340 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctl
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pilosa/pilosa/v2"
+	"github.com/pilosa/pilosa/v2/server"
+	"github.com/pilosa/pilosa/v2/test"
+)
+
+func TestRestoreCommand_Validation(t *testing.T) {
+	buf := bytes.Buffer{}
+	stdin, stdout, stderr := GetIO(buf)
+
+	cm := NewRestoreCommand(stdin, stdout, stderr)
+	if err := cm.Run(context.Background()); err == nil || err.Error() != "input file or directory required" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cm.Path, cm.Dir = "backup.tar", "backup"
+	if err := cm.Run(context.Background()); err == nil || err.Error() != "only one of input file and directory may be given" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestRestoreCommand_Run(t *testing.T) {
+	src := test.MustRunCluster(t, 3)
+	defer src.Close()
+	cmd := src[0]
+
+	cmd.MustCreateIndex(t, "i", pilosa.IndexOptions{Keys: true})
+	cmd.MustCreateField(t, "i", "f", pilosa.OptFieldKeys())
+	cmd.MustCreateIndex(t, "u", pilosa.IndexOptions{})
+	cmd.MustCreateField(t, "u", "g")
+	cmd.MustCreateField(t, "u", "n", pilosa.OptFieldTypeInt(-10, 100))
+	for _, q := range []struct{ index, query string }{
+		{"i", `Set("a", f="x")`},
+		{"i", `Set("b", f="y")`},
+		{"u", `Set(1, g=1) Set(1048577, g=1) Set(2097153, g=2)`},
+		{"u", `Set(2097153, n=-5)`},
+		{"u", `SetRowAttrs(g, 1, color="red", size=3)`},
+		{"u", `SetColumnAttrs(1, active=true)`},
+	} {
+		if _, err := cmd.Query(q.index, "", q.query); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "pilosa-restore-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file, err := ioutil.TempFile("", "pilosa-restore-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	for _, cm := range []*BackupCommand{
+		{Host: cmd.API.Node().URI.HostPort(), Dir: dir},
+		{Host: cmd.API.Node().URI.HostPort(), Path: file.Name()},
+	} {
+		cm.CmdIO = pilosa.NewCmdIO(os.Stdin, ioutil.Discard, ioutil.Discard)
+		if err := cm.Run(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// checkCluster ensures the restored cluster holds the backed up data, and
+	// that new keys don't collide with the restored ones.
+	checkCluster := func(t *testing.T, c test.Cluster) {
+		for _, q := range []struct{ index, query, exp string }{
+			{"i", `Row(f="x")`, `"keys":["a"]`},
+			{"i", `Row(f="y")`, `"keys":["b"]`},
+			{"u", `Row(g=1)`, `{"attrs":{"color":"red","size":3},"columns":[1,1048577]}`},
+			{"u", `Row(g=2)`, `"columns":[2097153]`},
+			{"u", `Sum(field=n)`, `{"value":-5,"count":1}`},
+			{"i", `Set("c", f="z")`, `[true]`},
+			{"i", `Row(f="z")`, `"keys":["c"]`},
+			{"i", `Row(f="x")`, `"keys":["a"]`},
+		} {
+			if res, err := c[0].Query(q.index, "", q.query); err != nil {
+				t.Fatal(err)
+			} else if !strings.Contains(res, q.exp) {
+				t.Fatalf("unexpected result for %s: %s", q.query, res)
+			}
+		}
+		if res, err := c[0].Query("u", "columnAttrs=true", `Row(g=1)`); err != nil {
+			t.Fatal(err)
+		} else if !strings.Contains(res, `"columnAttrs":[{"id":1,"attrs":{"active":true}}]`) {
+			t.Fatalf("unexpected column attributes: %s", res)
+		}
+	}
+
+	t.Run("Tarball", func(t *testing.T) {
+		dst := test.MustRunCluster(t, 2)
+		defer dst.Close()
+
+		var stderr bytes.Buffer
+		cm := NewRestoreCommand(os.Stdin, ioutil.Discard, &stderr)
+		cm.Host = dst[1].API.Node().URI.HostPort()
+		cm.Path = file.Name()
+		if err := cm.Run(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		checkCluster(t, dst)
+
+		if !strings.Contains(stderr.String(), "restoring index: u, shard: 2") {
+			t.Fatalf("expected progress: %s", stderr.String())
+		}
+
+		// Restoring over existing indexes fails.
+		if err := cm.Run(context.Background()); err == nil || !strings.Contains(err.Error(), "index already exists") {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	// Keys are restored to the primary translate node, the first by ID, even
+	// when it isn't the coordinator.
+	t.Run("ReplicaCoordinator", func(t *testing.T) {
+		dst := test.MustRunCluster(t, 2,
+			[]server.CommandOption{server.OptCommandServerOptions(pilosa.OptServerNodeID("node1"))},
+			[]server.CommandOption{server.OptCommandServerOptions(pilosa.OptServerNodeID("node0"))},
+		)
+		defer dst.Close()
+
+		cm := NewRestoreCommand(os.Stdin, ioutil.Discard, ioutil.Discard)
+		cm.Host = dst[0].API.Node().URI.HostPort()
+		cm.Path = file.Name()
+		if err := cm.Run(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		checkCluster(t, test.Cluster{dst[1]})
+	})
+
+	t.Run("Dir", func(t *testing.T) {
+		dst := test.MustRunCluster(t, 1)
+		defer dst.Close()
+
+		cm := NewRestoreCommand(os.Stdin, ioutil.Discard, ioutil.Discard)
+		cm.Host = dst[0].API.Node().URI.HostPort()
+		cm.Dir = dir
+		if err := cm.Run(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		checkCluster(t, dst)
+	})
+}
+
+func TestDirBackupEntries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pilosa-restore-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	names := []string{
+		"manifest.json",
+		"schema.json",
+		"i/keys.ndjson",
+		"i/f/views/standard/fragments/2.tar",
+		"i/f/views/standard/fragments/10.tar",
+		"j/f/attrs.json",
+		"j/f/views/standard/fragments/0.tar",
+	}
+	for _, name := range names {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0777); err != nil {
+			t.Fatal(err)
+		} else if err := ioutil.WriteFile(filepath.Join(dir, name), nil, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got, err := dirBackupEntries(dir); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(got, names) {
+		t.Fatalf("unexpected order: %v", got)
+	}
+}
+
+func TestRestoreCommand_Incremental(t *testing.T) {
+	src := test.MustRunCluster(t, 3)
+	defer src.Close()
+	cmd := src[0]
+
+	cmd.MustCreateIndex(t, "i", pilosa.IndexOptions{Keys: true})
+	cmd.MustCreateField(t, "i", "f", pilosa.OptFieldKeys())
+	cmd.MustCreateIndex(t, "u", pilosa.IndexOptions{})
+	cmd.MustCreateField(t, "u", "g")
+	for _, q := range []struct{ index, query string }{
+		{"i", `Set("a", f="x")`},
+		{"u", `Set(1, g=1) Set(1048577, g=1) Set(2097153, g=2)`},
+	} {
+		if _, err := cmd.Query(q.index, "", q.query); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "pilosa-restore-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	full, incr := filepath.Join(dir, "full"), filepath.Join(dir, "incr.tar")
+
+	backup := NewBackupCommand(os.Stdin, ioutil.Discard, ioutil.Discard)
+	backup.Host = cmd.API.Node().URI.HostPort()
+	backup.Dir = full
+	if err := backup.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Change shard 0 of u, add a key, and create an index.
+	cmd.MustCreateIndex(t, "v", pilosa.IndexOptions{})
+	cmd.MustCreateField(t, "v", "h")
+	for _, q := range []struct{ index, query string }{
+		{"i", `Set("b", f="y")`},
+		{"u", `Set(2, g=1) Clear(1, g=1)`},
+		{"v", `Set(3, h=4)`},
+	} {
+		if _, err := cmd.Query(q.index, "", q.query); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	backup.Dir, backup.Path, backup.Incremental = "", incr, full
+	if err := backup.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	base, err := readBackupManifest(full)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := readBackupManifest(incr)
+	if err != nil {
+		t.Fatal(err)
+	} else if manifest.Base != base.ID || manifest.ID == base.ID {
+		t.Fatalf("unexpected manifest: %+v, base %s", manifest, base.ID)
+	} else if manifest.Keys["i/keys.ndjson"] != 2 || manifest.Keys["i/f/keys.ndjson"] != 2 {
+		t.Fatalf("unexpected manifest keys: %v", manifest.Keys)
+	}
+
+	// The incremental backup holds the new keys and the ops appended to the
+	// changed fragments.
+	f, err := os.Open(incr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	entries := make(map[string]string)
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		buf, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.HasSuffix(hdr.Name, ".tar") {
+			fh, err := tar.NewReader(bytes.NewReader(buf)).Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			entries[hdr.Name] = fh.Name
+		} else {
+			entries[hdr.Name] = string(buf)
+		}
+	}
+	for name, exp := range map[string]string{
+		"i/keys.ndjson":                      "{\"id\":2,\"key\":\"b\"}\n",
+		"i/f/keys.ndjson":                    "{\"id\":2,\"key\":\"y\"}\n",
+		"u/g/views/standard/fragments/0.tar": "ops",
+		"v/h/views/standard/fragments/0.tar": "data",
+	} {
+		if entries[name] != exp {
+			t.Fatalf("unexpected %s: %q", name, entries[name])
+		}
+	}
+	for _, name := range []string{"u/g/views/standard/fragments/1.tar", "u/g/views/standard/fragments/2.tar"} {
+		if _, ok := entries[name]; ok {
+			t.Fatalf("unexpected unchanged fragment %s", name)
+		}
+	}
+
+	// Restore the full backup, then the incremental backup on top of it.
+	dst := test.MustRunCluster(t, 2)
+	defer dst.Close()
+	for _, cm := range []*RestoreCommand{
+		{Host: dst[0].API.Node().URI.HostPort(), Dir: full},
+		{Host: dst[0].API.Node().URI.HostPort(), Path: incr},
+	} {
+		cm.CmdIO = pilosa.NewCmdIO(os.Stdin, ioutil.Discard, ioutil.Discard)
+		if err := cm.Run(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, q := range []struct{ index, query, exp string }{
+		{"i", `Row(f="x")`, `"keys":["a"]`},
+		{"i", `Row(f="y")`, `"keys":["b"]`},
+		{"u", `Row(g=1)`, `"columns":[2,1048577]`},
+		{"u", `Row(g=2)`, `"columns":[2097153]`},
+		{"v", `Row(h=4)`, `"columns":[3]`},
+	} {
+		if res, err := dst[0].Query(q.index, "", q.query); err != nil {
+			t.Fatal(err)
+		} else if !strings.Contains(res, q.exp) {
+			t.Fatalf("unexpected result for %s: %s", q.query, res)
+		}
+	}
+}
@@ -32,6 +32,7 @@ func BuildServerFlags(cmd *cobra.Command, srv *server.Command) {
 	flags.BoolVar(&srv.Config.Verbose, "verbose", srv.Config.Verbose, "Enable verbose logging")
 	flags.Uint64Var(&srv.Config.MaxMapCount, "max-map-count", srv.Config.MaxMapCount, "Limits the maximum number of active mmaps. Pilosa will fall back to reading files once this is exhausted. Set below your system's vm.max_map_count.")
 	flags.Uint64Var(&srv.Config.MaxFileCount, "max-file-count", srv.Config.MaxFileCount, "Soft limit on the maximum number of fragment files Pilosa keeps open simultaneously.")
+	flags.BoolVar(&srv.Config.QuarantineCorruptFragments, "quarantine-corrupt-fragments", srv.Config.QuarantineCorruptFragments, "Move fragments which can't be read aside on startup, rather than failing to start.")
 
 	// TLS
 	SetTLSConfig(flags, &srv.Config.TLS.CertificatePath, &srv.Config.TLS.CertificateKeyPath, &srv.Config.TLS.CACertPath, &srv.Config.TLS.SkipVerify, &srv.Config.TLS.EnableClientVerification)
@@ -45,6 +46,7 @@ func BuildServerFlags(cmd *cobra.Command, srv *server.Command) {
 	flags.IntVarP(&srv.Config.Cluster.ReplicaN, "cluster.replicas", "", 1, "Number of hosts each piece of data should be stored on.")
 	flags.StringSliceVarP(&srv.Config.Cluster.Hosts, "cluster.hosts", "", []string{}, "Comma separated list of hosts in cluster. Only used for testing.")
 	flags.DurationVarP((*time.Duration)(&srv.Config.Cluster.LongQueryTime), "cluster.long-query-time", "", time.Minute, "Duration that will trigger log and stat messages for slow queries.")
+	flags.StringVarP(&srv.Config.Cluster.WriteConsistency, "cluster.write-consistency", "", srv.Config.Cluster.WriteConsistency, "Default number of replicas which must acknowledge a write: one, quorum, or all.")
 
 	// Translation
 	flags.StringVarP(&srv.Config.Translation.PrimaryURL, "translation.primary-url", "", srv.Config.Translation.PrimaryURL, "DEPRECATED: URL for primary translation node for replication.")
@@ -69,6 +71,21 @@ func BuildServerFlags(cmd *cobra.Command, srv *server.Command) {
 	// AntiEntropy
 	flags.DurationVarP((*time.Duration)(&srv.Config.AntiEntropy.Interval), "anti-entropy.interval", "", (time.Duration)(srv.Config.AntiEntropy.Interval), "Interval at which to run anti-entropy routine.")
 
+	// HintedHandoff
+	flags.DurationVarP((*time.Duration)(&srv.Config.HintedHandoff.Interval), "hinted-handoff.interval", "", (time.Duration)(srv.Config.HintedHandoff.Interval), "Interval at which writes queued for unreachable replicas are replayed. 0 disables hinted handoff.")
+
+	// ResultCache
+	flags.IntVarP(&srv.Config.ResultCache.MaxEntries, "result-cache.max-entries", "", srv.Config.ResultCache.MaxEntries, "Number of per-shard Count, TopN, and GroupBy results to cache. 0 disables the cache.")
+	flags.IntVarP(&srv.Config.ResultCache.MaxResultSize, "result-cache.max-result-size", "", srv.Config.ResultCache.MaxResultSize, "Maximum number of items in a cached TopN or GroupBy result. 0 means no limit.")
+
+	// Storage
+	flags.BoolVarP(&srv.Config.Storage.Checksums, "storage.checksums", "", srv.Config.Storage.Checksums, "Write fragment data files with a checksum for each container, verified when they're opened.")
+	flags.DurationVarP((*time.Duration)(&srv.Config.Storage.ColdAfter), "storage.cold-after", "", (time.Duration)(srv.Config.Storage.ColdAfter), "Compress fragment data files which haven't been written to for this long. 0 disables compression.")
+
+	// Postgres
+	flags.StringVarP(&srv.Config.Postgres.Bind, "postgres.bind", "", srv.Config.Postgres.Bind, "host:port on which to accept PostgreSQL wire protocol connections. Empty disables the listener.")
+	flags.BoolVarP(&srv.Config.Postgres.Insecure, "postgres.insecure", "", srv.Config.Postgres.Insecure, "Accept unencrypted PostgreSQL connections when the server isn't configured to serve TLS.")
+
 	// Metric
 	flags.StringVarP(&srv.Config.Metric.Service, "metric.service", "", srv.Config.Metric.Service, "Where to send stats: can be expvar (in-memory served at /debug/vars), statsd or none.")
 	flags.StringVarP(&srv.Config.Metric.Host, "metric.host", "", srv.Config.Metric.Host, "URI to send metrics when metric.service is statsd.")
@@ -0,0 +1,367 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package arrow encodes query results in the Apache Arrow IPC streaming
+// format.
+package arrow
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/pilosa/pilosa/v2"
+	"github.com/pkg/errors"
+)
+
+// ContentType is the media type of the Arrow IPC streaming format.
+const ContentType = "application/vnd.apache.arrow.stream"
+
+// ErrUnsupportedResult is returned when marshalling a query response which
+// can't be represented as a single Arrow table.
+var ErrUnsupportedResult = errors.New("arrow encoding requires a single Row, Rows, or GroupBy result")
+
+// Serializer implements pilosa.Serializer for the Arrow IPC streaming format.
+// Only query responses can be marshalled, and unmarshalling is not supported.
+type Serializer struct{}
+
+// Marshal encodes a query response holding a single Row, Rows, or GroupBy
+// result as an Arrow stream. Columns holding translated keys are dictionary
+// encoded.
+func (Serializer) Marshal(m pilosa.Message) ([]byte, error) {
+	resp, ok := m.(*pilosa.QueryResponse)
+	if !ok {
+		return nil, errors.New("passed invalid pilosa.Message")
+	} else if len(resp.Results) != 1 {
+		return nil, ErrUnsupportedResult
+	}
+
+	cols, err := resultColumns(resp.Results[0])
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := writeStream(&buf, cols); err != nil {
+		return nil, errors.Wrap(err, "writing stream")
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal is not supported.
+func (Serializer) Unmarshal(buf []byte, m pilosa.Message) error {
+	return errors.New("arrow unmarshalling is not supported")
+}
+
+// column is a single column of the table encoded from a result. If keys is
+// set the column is dictionary encoded strings, otherwise it is values.
+type column struct {
+	name   string
+	values []uint64
+	keys   []string
+	dict   bool
+}
+
+// len returns the number of rows in the column.
+func (c *column) len() int {
+	if c.dict {
+		return len(c.keys)
+	}
+	return len(c.values)
+}
+
+// resultColumns converts a query result into table columns.
+func resultColumns(result interface{}) ([]*column, error) {
+	switch r := result.(type) {
+	case *pilosa.Row:
+		if len(r.Keys) > 0 {
+			return []*column{{name: "column", keys: r.Keys, dict: true}}, nil
+		}
+		return []*column{{name: "column", values: r.Columns()}}, nil
+
+	case pilosa.RowIdentifiers:
+		if len(r.Keys) > 0 {
+			return []*column{{name: "row", keys: r.Keys, dict: true}}, nil
+		}
+		return []*column{{name: "row", values: r.Rows}}, nil
+
+	case []pilosa.GroupCount:
+		var cols []*column
+		if len(r) > 0 {
+			for _, fr := range r[0].Group {
+				cols = append(cols, &column{name: fr.Field, dict: fr.RowKey != ""})
+			}
+		}
+		counts := &column{name: "count", values: make([]uint64, 0, len(r))}
+		for _, gc := range r {
+			if len(gc.Group) != len(cols) {
+				return nil, errors.New("inconsistent group size")
+			}
+			for i, fr := range gc.Group {
+				if cols[i].dict {
+					cols[i].keys = append(cols[i].keys, fr.RowKey)
+				} else {
+					cols[i].values = append(cols[i].values, fr.RowID)
+				}
+			}
+			counts.values = append(counts.values, gc.Count)
+		}
+		return append(cols, counts), nil
+
+	default:
+		return nil, ErrUnsupportedResult
+	}
+}
+
+// Arrow flatbuffer enumerations.
+const (
+	metadataVersionV5 = 4
+
+	messageHeaderSchema          = 1
+	messageHeaderDictionaryBatch = 2
+	messageHeaderRecordBatch     = 3
+
+	typeInt  = 2
+	typeUtf8 = 5
+)
+
+// continuation precedes each message in the stream.
+const continuation = 0xFFFFFFFF
+
+// writeStream writes cols as an Arrow stream consisting of a schema, one
+// dictionary batch per dictionary encoded column, and a single record batch.
+func writeStream(w io.Writer, cols []*column) error {
+	if err := writeMessage(w, schemaMessage(cols), nil); err != nil {
+		return errors.Wrap(err, "writing schema")
+	}
+
+	// Build dictionaries and the batch body. The batch holds dictionary
+	// indices in place of each dictionary encoded column.
+	var batch body
+	var nodes [][2]uint64
+	for i, col := range cols {
+		nodes = append(nodes, [2]uint64{uint64(col.len()), 0})
+		batch.add(nil) // validity; all values are non-null
+		if !col.dict {
+			batch.add(uint64Bytes(col.values))
+			continue
+		}
+
+		dictionary, indices := dictionaryEncode(col.keys)
+		batch.add(int32Bytes(indices))
+
+		var dict body
+		dict.add(nil)
+		offsets, data := utf8Bytes(dictionary)
+		dict.add(offsets)
+		dict.add(data)
+		msg := dictionaryBatchMessage(int64(i), len(dictionary), dict.buffers, dict.len())
+		if err := writeMessage(w, msg, dict.bytes()); err != nil {
+			return errors.Wrap(err, "writing dictionary batch")
+		}
+	}
+
+	var length int
+	if len(cols) > 0 {
+		length = cols[0].len()
+	}
+	msg := recordBatchMessage(length, nodes, batch.buffers, batch.len())
+	if err := writeMessage(w, msg, batch.bytes()); err != nil {
+		return errors.Wrap(err, "writing record batch")
+	}
+
+	// End of stream marker.
+	return writeUint32s(w, continuation, 0)
+}
+
+// writeMessage writes a flatbuffer encoded message and its body, padding the
+// metadata so that the body is 8-byte aligned.
+func writeMessage(w io.Writer, meta, body []byte) error {
+	padded := (len(meta) + 7) &^ 7
+	if err := writeUint32s(w, continuation, uint32(padded)); err != nil {
+		return err
+	} else if _, err := w.Write(meta); err != nil {
+		return err
+	} else if _, err := w.Write(make([]byte, padded-len(meta))); err != nil {
+		return err
+	}
+	_, err := w.Write(body)
+	return err
+}
+
+func writeUint32s(w io.Writer, vs ...uint32) error {
+	for _, v := range vs {
+		if err := binary.Write(w, binary.LittleEndian, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// body accumulates the buffers of a message body, each padded to 8 bytes.
+type body struct {
+	buf     bytes.Buffer
+	buffers [][2]uint64 // offset, length
+}
+
+func (b *body) add(p []byte) {
+	b.buffers = append(b.buffers, [2]uint64{uint64(b.buf.Len()), uint64(len(p))})
+	b.buf.Write(p)
+	b.buf.Write(make([]byte, (8-len(p)%8)%8))
+}
+
+func (b *body) len() int      { return b.buf.Len() }
+func (b *body) bytes() []byte { return b.buf.Bytes() }
+
+// dictionaryEncode returns the distinct keys, in the order first seen, and
+// the index of each key within them.
+func dictionaryEncode(keys []string) (dictionary []string, indices []int32) {
+	m := make(map[string]int32)
+	indices = make([]int32, len(keys))
+	for i, key := range keys {
+		j, ok := m[key]
+		if !ok {
+			j = int32(len(dictionary))
+			m[key] = j
+			dictionary = append(dictionary, key)
+		}
+		indices[i] = j
+	}
+	return dictionary, indices
+}
+
+func uint64Bytes(a []uint64) []byte {
+	p := make([]byte, 8*len(a))
+	for i, v := range a {
+		binary.LittleEndian.PutUint64(p[i*8:], v)
+	}
+	return p
+}
+
+func int32Bytes(a []int32) []byte {
+	p := make([]byte, 4*len(a))
+	for i, v := range a {
+		binary.LittleEndian.PutUint32(p[i*4:], uint32(v))
+	}
+	return p
+}
+
+// utf8Bytes returns the offsets and data buffers of a string array.
+func utf8Bytes(a []string) (offsets, data []byte) {
+	offsets = make([]byte, 4*(len(a)+1))
+	var n int
+	for i, s := range a {
+		data = append(data, s...)
+		n += len(s)
+		binary.LittleEndian.PutUint32(offsets[(i+1)*4:], uint32(n))
+	}
+	return offsets, data
+}
+
+// schemaMessage returns a Schema message describing cols.
+func schemaMessage(cols []*column) []byte {
+	var b builder
+
+	fields := make([]int, len(cols))
+	for i, col := range cols {
+		name := b.createString(col.name)
+		children := b.createOffsetVector(nil)
+
+		var typ, typeType, dict int
+		if col.dict {
+			b.startTable(0)
+			typ, typeType = b.endTable(), typeUtf8
+
+			indexType := intType(&b, 32, true)
+			b.startTable(4)
+			b.addUint64(0, uint64(i))
+			b.addOffset(1, indexType)
+			b.addBool(2, false)
+			dict = b.endTable()
+		} else {
+			typ, typeType = intType(&b, 64, false), typeInt
+		}
+
+		b.startTable(7)
+		b.addOffset(0, name)
+		b.addOffset(3, typ)
+		b.addOffset(5, children)
+		if dict != 0 {
+			b.addOffset(4, dict)
+		}
+		b.addUint8(2, uint8(typeType))
+		b.addBool(1, false)
+		fields[i] = b.endTable()
+	}
+	fieldVec := b.createOffsetVector(fields)
+
+	b.startTable(4)
+	b.addOffset(1, fieldVec)
+	b.addUint16(0, 0) // little endian
+	schema := b.endTable()
+
+	return b.finish(message(&b, messageHeaderSchema, schema, 0))
+}
+
+// recordBatchMessage returns a RecordBatch message.
+func recordBatchMessage(length int, nodes, buffers [][2]uint64, bodyLength int) []byte {
+	var b builder
+	batch := recordBatch(&b, length, nodes, buffers)
+	return b.finish(message(&b, messageHeaderRecordBatch, batch, bodyLength))
+}
+
+// dictionaryBatchMessage returns a DictionaryBatch message for a string
+// dictionary.
+func dictionaryBatchMessage(id int64, length int, buffers [][2]uint64, bodyLength int) []byte {
+	var b builder
+	batch := recordBatch(&b, length, [][2]uint64{{uint64(length), 0}}, buffers)
+
+	b.startTable(3)
+	b.addUint64(0, uint64(id))
+	b.addOffset(1, batch)
+	b.addBool(2, false)
+	dict := b.endTable()
+
+	return b.finish(message(&b, messageHeaderDictionaryBatch, dict, bodyLength))
+}
+
+// recordBatch writes a RecordBatch table and returns its offset.
+func recordBatch(b *builder, length int, nodes, buffers [][2]uint64) int {
+	nodeVec := b.createStructVector(nodes)
+	bufferVec := b.createStructVector(buffers)
+
+	b.startTable(4)
+	b.addUint64(0, uint64(length))
+	b.addOffset(1, nodeVec)
+	b.addOffset(2, bufferVec)
+	return b.endTable()
+}
+
+// message writes a Message table wrapping header and returns its offset.
+func message(b *builder, headerType uint8, header int, bodyLength int) int {
+	b.startTable(5)
+	b.addUint64(3, uint64(bodyLength))
+	b.addOffset(2, header)
+	b.addUint16(0, metadataVersionV5)
+	b.addUint8(1, headerType)
+	return b.endTable()
+}
+
+// intType writes an Int type table and returns its offset.
+func intType(b *builder, bitWidth uint32, signed bool) int {
+	b.startTable(2)
+	b.addUint32(0, bitWidth)
+	b.addBool(1, signed)
+	return b.endTable()
+}
@@ -0,0 +1,268 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package arrow
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/pilosa/pilosa/v2"
+)
+
+// TestSerializer_Marshal checks the streams written for each result type
+// against the golden files in testdata. The golden files were verified by
+// reading them with the Apache Arrow Go implementation: its ipc.Reader for
+// streams without dictionaries, and its generated FlatBuffers accessors for
+// the messages of streams with dictionary encoded columns.
+func TestSerializer_Marshal(t *testing.T) {
+	tests := []struct {
+		name   string
+		result interface{}
+		exp    map[string]interface{}
+	}{
+		{
+			name:   "RowIDs",
+			result: pilosa.NewRow(1, 5, pilosa.ShardWidth+2),
+			exp:    map[string]interface{}{"column": []uint64{1, 5, pilosa.ShardWidth + 2}},
+		},
+		{
+			name:   "RowIDsShards",
+			result: pilosa.NewRow(pilosa.ShardWidth+1, pilosa.ShardWidth+2, (3*pilosa.ShardWidth)+4),
+			exp:    map[string]interface{}{"column": []uint64{pilosa.ShardWidth + 1, pilosa.ShardWidth + 2, (3 * pilosa.ShardWidth) + 4}},
+		},
+		{
+			name:   "RowKeys",
+			result: &pilosa.Row{Keys: []string{"a", "b", "c"}},
+			exp:    map[string]interface{}{"column": []string{"a", "b", "c"}},
+		},
+		{
+			name:   "RowsIDs",
+			result: pilosa.RowIdentifiers{Rows: []uint64{3, 4}},
+			exp:    map[string]interface{}{"row": []uint64{3, 4}},
+		},
+		{
+			name:   "RowsKeys",
+			result: pilosa.RowIdentifiers{Keys: []string{"x"}},
+			exp:    map[string]interface{}{"row": []string{"x"}},
+		},
+		{
+			name: "GroupBy",
+			result: []pilosa.GroupCount{
+				{Group: []pilosa.FieldRow{{Field: "f", RowID: 1}, {Field: "g", RowID: 7, RowKey: "seven"}}, Count: 10},
+				{Group: []pilosa.FieldRow{{Field: "f", RowID: 1}, {Field: "g", RowID: 8, RowKey: "eight"}}, Count: 3},
+				{Group: []pilosa.FieldRow{{Field: "f", RowID: 2}, {Field: "g", RowID: 7, RowKey: "seven"}}, Count: 1},
+			},
+			exp: map[string]interface{}{
+				"f":     []uint64{1, 1, 2},
+				"g":     []string{"seven", "eight", "seven"},
+				"count": []uint64{10, 3, 1},
+			},
+		},
+		{
+			name:   "GroupByEmpty",
+			result: []pilosa.GroupCount{},
+			exp:    map[string]interface{}{"count": []uint64{}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf, err := Serializer{}.Marshal(&pilosa.QueryResponse{Results: []interface{}{test.result}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := readStream(t, buf); !reflect.DeepEqual(got, test.exp) {
+				t.Fatalf("unexpected table: %#v", got)
+			}
+			if golden, err := ioutil.ReadFile(filepath.Join("testdata", test.name+".arrow")); err != nil {
+				t.Fatal(err)
+			} else if !bytes.Equal(buf, golden) {
+				t.Fatalf("stream differs from golden file:\n got: %x\nwant: %x", buf, golden)
+			}
+		})
+	}
+
+	t.Run("Unsupported", func(t *testing.T) {
+		if _, err := (Serializer{}).Marshal(&pilosa.QueryResponse{Results: []interface{}{uint64(3)}}); err != ErrUnsupportedResult {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := (Serializer{}).Marshal(&pilosa.QueryResponse{Results: []interface{}{pilosa.NewRow(), pilosa.NewRow()}}); err != ErrUnsupportedResult {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+}
+
+// readStream decodes an Arrow stream of uint64 and dictionary encoded string
+// columns into a map of column name to values.
+func readStream(t *testing.T, buf []byte) map[string]interface{} {
+	t.Helper()
+
+	type field struct {
+		name string
+		dict bool
+	}
+	var fields []field
+	dicts := make(map[uint64][]string)
+	out := make(map[string]interface{})
+
+	for {
+		if binary.LittleEndian.Uint32(buf) != continuation {
+			t.Fatal("missing continuation marker")
+		}
+		n := int(binary.LittleEndian.Uint32(buf[4:]))
+		if n == 0 {
+			if len(buf) != 8 {
+				t.Fatalf("trailing data after end of stream: %d bytes", len(buf)-8)
+			}
+			return out
+		} else if n%8 != 0 {
+			t.Fatalf("unaligned metadata length: %d", n)
+		}
+		msg := rootTable(buf[8 : 8+n])
+		if v := msg.uint16(0); v != metadataVersionV5 {
+			t.Fatalf("unexpected version: %d", v)
+		}
+		bodyLength := int(msg.uint64(3))
+		body := buf[8+n : 8+n+bodyLength]
+		buf = buf[8+n+bodyLength:]
+
+		switch msg.uint8(1) {
+		case messageHeaderSchema:
+			schema := msg.table(2)
+			for i := 0; i < schema.vectorLen(1); i++ {
+				f := schema.vectorTable(1, i)
+				_, dict := f.fieldPos(4)
+				fields = append(fields, field{name: f.string(0), dict: dict})
+				if typ := f.uint8(2); dict && typ != typeUtf8 || !dict && typ != typeInt {
+					t.Fatalf("unexpected type for %s: %d", f.string(0), typ)
+				}
+			}
+
+		case messageHeaderDictionaryBatch:
+			dict := msg.table(2)
+			batch := dict.table(1)
+			offsets := batch.buffer(body, 1)
+			data := batch.buffer(body, 2)
+			var keys []string
+			for i := 0; i < int(batch.uint64(0)); i++ {
+				start := binary.LittleEndian.Uint32(offsets[i*4:])
+				end := binary.LittleEndian.Uint32(offsets[(i+1)*4:])
+				keys = append(keys, string(data[start:end]))
+			}
+			dicts[dict.uint64(0)] = keys
+
+		case messageHeaderRecordBatch:
+			batch := msg.table(2)
+			length := int(batch.uint64(0))
+			for i, f := range fields {
+				values := batch.buffer(body, i*2+1)
+				if f.dict {
+					keys := []string{}
+					for j := 0; j < length; j++ {
+						keys = append(keys, dicts[uint64(i)][binary.LittleEndian.Uint32(values[j*4:])])
+					}
+					out[f.name] = keys
+				} else {
+					vals := []uint64{}
+					for j := 0; j < length; j++ {
+						vals = append(vals, binary.LittleEndian.Uint64(values[j*8:]))
+					}
+					out[f.name] = vals
+				}
+			}
+
+		default:
+			t.Fatalf("unexpected message type: %d", msg.uint8(1))
+		}
+	}
+}
+
+// fbTable is a minimal FlatBuffers table reader.
+type fbTable struct {
+	buf []byte
+	pos int
+}
+
+func rootTable(buf []byte) fbTable {
+	return fbTable{buf: buf, pos: int(binary.LittleEndian.Uint32(buf))}
+}
+
+// fieldPos returns the position of field i and whether it is present.
+func (t fbTable) fieldPos(i int) (int, bool) {
+	vt := t.pos - int(int32(binary.LittleEndian.Uint32(t.buf[t.pos:])))
+	if 4+2*i >= int(binary.LittleEndian.Uint16(t.buf[vt:])) {
+		return 0, false
+	}
+	off := int(binary.LittleEndian.Uint16(t.buf[vt+4+2*i:]))
+	return t.pos + off, off != 0
+}
+
+func (t fbTable) uint8(i int) uint8 {
+	p, _ := t.fieldPos(i)
+	return t.buf[p]
+}
+
+func (t fbTable) uint16(i int) uint16 {
+	p, _ := t.fieldPos(i)
+	return binary.LittleEndian.Uint16(t.buf[p:])
+}
+
+func (t fbTable) uint64(i int) uint64 {
+	p, ok := t.fieldPos(i)
+	if !ok {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(t.buf[p:])
+}
+
+func (t fbTable) deref(i int) int {
+	p, _ := t.fieldPos(i)
+	return p + int(binary.LittleEndian.Uint32(t.buf[p:]))
+}
+
+func (t fbTable) table(i int) fbTable { return fbTable{buf: t.buf, pos: t.deref(i)} }
+
+func (t fbTable) string(i int) string {
+	p := t.deref(i)
+	n := int(binary.LittleEndian.Uint32(t.buf[p:]))
+	return string(t.buf[p+4 : p+4+n])
+}
+
+func (t fbTable) vectorLen(i int) int {
+	return int(binary.LittleEndian.Uint32(t.buf[t.deref(i):]))
+}
+
+func (t fbTable) vectorTable(i, j int) fbTable {
+	p := t.deref(i) + 4 + 4*j
+	return fbTable{buf: t.buf, pos: p + int(binary.LittleEndian.Uint32(t.buf[p:]))}
+}
+
+// buffer returns the j-th body buffer of a RecordBatch table.
+func (t fbTable) buffer(body []byte, j int) []byte {
+	p := t.deref(2) + 4 + 16*j
+	if p%8 != 0 {
+		panic("unaligned buffer struct")
+	}
+	off := binary.LittleEndian.Uint64(t.buf[p:])
+	n := binary.LittleEndian.Uint64(t.buf[p+8:])
+	if off%8 != 0 {
+		panic("unaligned buffer")
+	}
+	return body[off : off+n]
+}
@@ -0,0 +1,176 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package arrow
+
+import (
+	"encoding/binary"
+)
+
+// builder is a minimal FlatBuffers builder, sufficient for the Arrow
+// metadata messages written by this package. As with the reference
+// implementation, the buffer is built back to front so that every object is
+// written before the objects which refer to it.
+//
+// Offsets returned by the builder are measured from the end of the buffer.
+type builder struct {
+	buf      []byte // the finished portion of the buffer
+	minAlign int
+
+	// Table currently being built.
+	slots       []int
+	objectStart int
+}
+
+// offset returns the current offset from the end of the buffer.
+func (b *builder) offset() int { return len(b.buf) }
+
+func (b *builder) prepend(p []byte) {
+	buf := make([]byte, len(p)+len(b.buf))
+	copy(buf, p)
+	copy(buf[len(p):], b.buf)
+	b.buf = buf
+}
+
+// prep pads the buffer so that, after writing additional bytes, the next
+// value written of the given size will be aligned.
+func (b *builder) prep(size, additional int) {
+	if size > b.minAlign {
+		b.minAlign = size
+	}
+	if pad := (size - (len(b.buf)+additional)%size) % size; pad > 0 {
+		b.prepend(make([]byte, pad))
+	}
+}
+
+func (b *builder) prependBool(v bool) {
+	if v {
+		b.prependUint8(1)
+	} else {
+		b.prependUint8(0)
+	}
+}
+
+func (b *builder) prependUint8(v uint8) {
+	b.prep(1, 0)
+	b.prepend([]byte{v})
+}
+
+func (b *builder) prependUint16(v uint16) {
+	b.prep(2, 0)
+	var p [2]byte
+	binary.LittleEndian.PutUint16(p[:], v)
+	b.prepend(p[:])
+}
+
+func (b *builder) prependUint32(v uint32) {
+	b.prep(4, 0)
+	var p [4]byte
+	binary.LittleEndian.PutUint32(p[:], v)
+	b.prepend(p[:])
+}
+
+func (b *builder) prependUint64(v uint64) {
+	b.prep(8, 0)
+	var p [8]byte
+	binary.LittleEndian.PutUint64(p[:], v)
+	b.prepend(p[:])
+}
+
+// prependUOffset writes a forward reference to the object at off.
+func (b *builder) prependUOffset(off int) {
+	b.prep(4, 0)
+	b.prependUint32(uint32(b.offset() - off + 4))
+}
+
+// createString writes a null-terminated string and returns its offset.
+func (b *builder) createString(s string) int {
+	b.prep(4, len(s)+1)
+	b.prepend(append([]byte(s), 0))
+	b.prependUint32(uint32(len(s)))
+	return b.offset()
+}
+
+// createOffsetVector writes a vector of references to the objects at offs
+// and returns its offset.
+func (b *builder) createOffsetVector(offs []int) int {
+	b.prep(4, 4*len(offs))
+	for i := len(offs) - 1; i >= 0; i-- {
+		b.prependUOffset(offs[i])
+	}
+	b.prependUint32(uint32(len(offs)))
+	return b.offset()
+}
+
+// createStructVector writes a vector of structs, each consisting of a pair of
+// 64-bit integers, and returns its offset. Arrow's FieldNode and Buffer
+// structs both have this layout.
+func (b *builder) createStructVector(pairs [][2]uint64) int {
+	b.prep(4, 16*len(pairs))
+	b.prep(8, 16*len(pairs))
+	for i := len(pairs) - 1; i >= 0; i-- {
+		b.prependUint64(pairs[i][1])
+		b.prependUint64(pairs[i][0])
+	}
+	b.prependUint32(uint32(len(pairs)))
+	return b.offset()
+}
+
+// startTable begins a table with n fields.
+func (b *builder) startTable(n int) {
+	b.slots = make([]int, n)
+	b.objectStart = b.offset()
+}
+
+// slot records that the value just written is field i of the current table.
+func (b *builder) slot(i int) { b.slots[i] = b.offset() }
+
+func (b *builder) addBool(i int, v bool)     { b.prependBool(v); b.slot(i) }
+func (b *builder) addUint8(i int, v uint8)   { b.prependUint8(v); b.slot(i) }
+func (b *builder) addUint16(i int, v uint16) { b.prependUint16(v); b.slot(i) }
+func (b *builder) addUint32(i int, v uint32) { b.prependUint32(v); b.slot(i) }
+func (b *builder) addUint64(i int, v uint64) { b.prependUint64(v); b.slot(i) }
+func (b *builder) addOffset(i int, off int)  { b.prependUOffset(off); b.slot(i) }
+
+// endTable writes the current table's vtable and returns the table's offset.
+func (b *builder) endTable() int {
+	// Placeholder for the offset to the vtable.
+	b.prependUint32(0)
+	objectOffset := b.offset()
+
+	// Write the vtable, which immediately precedes the table.
+	for i := len(b.slots) - 1; i >= 0; i-- {
+		var off uint16
+		if b.slots[i] != 0 {
+			off = uint16(objectOffset - b.slots[i])
+		}
+		b.prependUint16(off)
+	}
+	b.prependUint16(uint16(objectOffset - b.objectStart))
+	b.prependUint16(uint16((len(b.slots) + 2) * 2))
+
+	// Point the table at its vtable.
+	pos := len(b.buf) - objectOffset
+	binary.LittleEndian.PutUint32(b.buf[pos:], uint32(b.offset()-objectOffset))
+
+	b.slots = nil
+	return objectOffset
+}
+
+// finish writes a reference to the root table and returns the buffer.
+func (b *builder) finish(root int) []byte {
+	b.prep(b.minAlign, 4)
+	b.prependUOffset(root)
+	return b.buf
+}
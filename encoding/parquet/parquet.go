@@ -0,0 +1,391 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package parquet writes tables in the Apache Parquet file format. Only flat
+// schemas are supported, and columns are written uncompressed with the plain
+// encoding, one data page per column per row group.
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ContentType is the media type of Parquet files.
+const ContentType = "application/vnd.apache.parquet"
+
+// magic begins and ends every Parquet file.
+const magic = "PAR1"
+
+// Type is the type of the values of a column.
+type Type int
+
+// Column types, with the Go type of their values.
+const (
+	Bool      Type = iota // bool
+	Int64                 // int64
+	Uint64                // uint64
+	String                // string
+	Timestamp             // time.Time, stored in milliseconds
+)
+
+// Repetition determines the number of values a column holds in each row.
+type Repetition int32
+
+// Column repetitions.
+const (
+	Required Repetition = 0 // one value
+	Optional Repetition = 1 // a value or nil
+	Repeated Repetition = 2 // a []interface{} of any number of values
+)
+
+// Column describes a column of a table.
+type Column struct {
+	Name       string
+	Type       Type
+	Repetition Repetition
+}
+
+// Parquet enumerations.
+const (
+	physicalBoolean   = 0
+	physicalInt64     = 2
+	physicalByteArray = 6
+
+	convertedUTF8            = 0
+	convertedTimestampMillis = 9
+	convertedUint64          = 14
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	codecUncompressed = 0
+
+	pageData = 0
+)
+
+// Writer writes rows to a Parquet file. Rows are buffered until Flush is
+// called, which writes them as a row group.
+type Writer struct {
+	w       io.Writer
+	offset  int64
+	columns []Column
+	chunks  []*columnChunk
+
+	rows      int64 // buffered rows
+	numRows   int64 // written rows
+	rowGroups []interface{}
+}
+
+// columnChunk holds the buffered values of a column.
+type columnChunk struct {
+	values bytes.Buffer
+	bools  []bool
+	defs   []byte // definition levels, unless the column is required
+	reps   []byte // repetition levels, if the column is repeated
+	n      int    // number of values, including nulls
+}
+
+// NewWriter returns a Writer which writes a table with the given columns to
+// w. The file is only complete once Close is called.
+func NewWriter(w io.Writer, columns []Column) (*Writer, error) {
+	pw := &Writer{
+		w:       w,
+		columns: columns,
+		chunks:  make([]*columnChunk, len(columns)),
+	}
+	for i := range pw.chunks {
+		pw.chunks[i] = &columnChunk{}
+	}
+	if err := pw.write([]byte(magic)); err != nil {
+		return nil, errors.Wrap(err, "writing magic")
+	}
+	return pw, nil
+}
+
+// WriteRow buffers a row, holding a value for each column. The Writer can't
+// be used after an error.
+func (w *Writer) WriteRow(row []interface{}) error {
+	if len(row) != len(w.columns) {
+		return fmt.Errorf("row has %d values, expected %d", len(row), len(w.columns))
+	}
+	for i, col := range w.columns {
+		if err := w.chunks[i].add(col, row[i]); err != nil {
+			return errors.Wrapf(err, "column %s", col.Name)
+		}
+	}
+	w.rows++
+	return nil
+}
+
+// Flush writes the buffered rows as a row group.
+func (w *Writer) Flush() error {
+	if w.rows == 0 {
+		return nil
+	}
+
+	var size int64
+	chunks := make([]interface{}, len(w.columns))
+	for i, col := range w.columns {
+		c := w.chunks[i]
+		page := c.page(col)
+		header := appendStruct(nil, tstruct{
+			{1, int32(pageData)},
+			{2, int32(len(page))},
+			{3, int32(len(page))},
+			{5, tstruct{
+				{1, int32(c.n)},
+				{2, int32(encodingPlain)},
+				{3, int32(encodingRLE)},
+				{4, int32(encodingRLE)},
+			}},
+		})
+
+		offset := w.offset
+		if err := w.write(header); err != nil {
+			return errors.Wrap(err, "writing page header")
+		} else if err := w.write(page); err != nil {
+			return errors.Wrap(err, "writing page")
+		}
+		chunkSize := int64(len(header) + len(page))
+		size += chunkSize
+
+		typ, _ := col.Type.physical()
+		chunks[i] = tstruct{
+			{2, offset},
+			{3, tstruct{
+				{1, typ},
+				{2, tlist{thriftI32, []interface{}{int32(encodingPlain), int32(encodingRLE)}}},
+				{3, tlist{thriftBinary, []interface{}{col.Name}}},
+				{4, int32(codecUncompressed)},
+				{5, int64(c.n)},
+				{6, chunkSize},
+				{7, chunkSize},
+				{9, offset},
+			}},
+		}
+		w.chunks[i] = &columnChunk{}
+	}
+
+	w.rowGroups = append(w.rowGroups, tstruct{
+		{1, tlist{thriftStruct, chunks}},
+		{2, size},
+		{3, w.rows},
+	})
+	w.numRows += w.rows
+	w.rows = 0
+	return nil
+}
+
+// Close flushes any buffered rows and writes the file footer. It does not
+// close the underlying writer.
+func (w *Writer) Close() error {
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	schema := []interface{}{tstruct{
+		{4, "schema"},
+		{5, int32(len(w.columns))},
+	}}
+	for _, col := range w.columns {
+		typ, converted := col.Type.physical()
+		elem := tstruct{
+			{1, typ},
+			{3, int32(col.Repetition)},
+			{4, col.Name},
+		}
+		if converted >= 0 {
+			elem = append(elem, tfield{6, converted})
+		}
+		schema = append(schema, elem)
+	}
+
+	meta := appendStruct(nil, tstruct{
+		{1, int32(1)},
+		{2, tlist{thriftStruct, schema}},
+		{3, w.numRows},
+		{4, tlist{thriftStruct, w.rowGroups}},
+		{6, "pilosa"},
+	})
+	if err := w.write(meta); err != nil {
+		return errors.Wrap(err, "writing metadata")
+	}
+	var footer [4]byte
+	binary.LittleEndian.PutUint32(footer[:], uint32(len(meta)))
+	if err := w.write(append(footer[:], magic...)); err != nil {
+		return errors.Wrap(err, "writing footer")
+	}
+	return nil
+}
+
+// write writes p and advances the file offset.
+func (w *Writer) write(p []byte) error {
+	n, err := w.w.Write(p)
+	w.offset += int64(n)
+	return err
+}
+
+// physical returns the physical and converted types of t. The converted type
+// is -1 if values aren't annotated.
+func (t Type) physical() (int32, int32) {
+	switch t {
+	case Bool:
+		return physicalBoolean, -1
+	case Uint64:
+		return physicalInt64, convertedUint64
+	case String:
+		return physicalByteArray, convertedUTF8
+	case Timestamp:
+		return physicalInt64, convertedTimestampMillis
+	default:
+		return physicalInt64, -1
+	}
+}
+
+// add buffers the value of a column in a row.
+func (c *columnChunk) add(col Column, v interface{}) error {
+	switch col.Repetition {
+	case Required:
+		if v == nil {
+			return errors.New("missing required value")
+		}
+		c.n++
+		return c.addValue(col.Type, v)
+
+	case Optional:
+		c.n++
+		if v == nil {
+			c.defs = append(c.defs, 0)
+			return nil
+		}
+		c.defs = append(c.defs, 1)
+		return c.addValue(col.Type, v)
+
+	default:
+		var values []interface{}
+		if v != nil {
+			var ok bool
+			if values, ok = v.([]interface{}); !ok {
+				return fmt.Errorf("invalid repeated value: %T", v)
+			}
+		}
+		if len(values) == 0 {
+			c.n++
+			c.defs, c.reps = append(c.defs, 0), append(c.reps, 0)
+			return nil
+		}
+		for i, v := range values {
+			rep := byte(1)
+			if i == 0 {
+				rep = 0
+			}
+			c.n++
+			c.defs, c.reps = append(c.defs, 1), append(c.reps, rep)
+			if err := c.addValue(col.Type, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// addValue buffers a non-null value using the plain encoding. Booleans are
+// bit-packed when the page is built.
+func (c *columnChunk) addValue(typ Type, v interface{}) error {
+	var buf [8]byte
+	var ok bool
+	switch typ {
+	case Bool:
+		var b bool
+		if b, ok = v.(bool); ok {
+			c.bools = append(c.bools, b)
+		}
+	case Int64:
+		var i int64
+		if i, ok = v.(int64); ok {
+			binary.LittleEndian.PutUint64(buf[:], uint64(i))
+			c.values.Write(buf[:])
+		}
+	case Uint64:
+		var u uint64
+		if u, ok = v.(uint64); ok {
+			binary.LittleEndian.PutUint64(buf[:], u)
+			c.values.Write(buf[:])
+		}
+	case String:
+		var s string
+		if s, ok = v.(string); ok {
+			binary.LittleEndian.PutUint32(buf[:4], uint32(len(s)))
+			c.values.Write(buf[:4])
+			c.values.WriteString(s)
+		}
+	case Timestamp:
+		var t time.Time
+		if t, ok = v.(time.Time); ok {
+			binary.LittleEndian.PutUint64(buf[:], uint64(t.UnixNano()/int64(time.Millisecond)))
+			c.values.Write(buf[:])
+		}
+	}
+	if !ok {
+		return fmt.Errorf("invalid value: %T", v)
+	}
+	return nil
+}
+
+// page returns the data page of the buffered values: the repetition levels
+// of repeated columns, the definition levels of columns which aren't
+// required, and then the values.
+func (c *columnChunk) page(col Column) []byte {
+	var buf []byte
+	if col.Repetition == Repeated {
+		buf = appendLevels(buf, c.reps)
+	}
+	if col.Repetition != Required {
+		buf = appendLevels(buf, c.defs)
+	}
+	if col.Type == Bool {
+		packed := make([]byte, (len(c.bools)+7)/8)
+		for i, b := range c.bools {
+			if b {
+				packed[i/8] |= 1 << uint(i%8)
+			}
+		}
+		return append(buf, packed...)
+	}
+	return append(buf, c.values.Bytes()...)
+}
+
+// appendLevels appends levels of at most 1 using the RLE hybrid encoding,
+// as runs of repeated values, prefixed with their length.
+func appendLevels(buf []byte, levels []byte) []byte {
+	var runs []byte
+	for i := 0; i < len(levels); {
+		j := i
+		for j < len(levels) && levels[j] == levels[i] {
+			j++
+		}
+		runs = appendUvarint(runs, uint64(j-i)<<1)
+		runs = append(runs, levels[i])
+		i = j
+	}
+	var length [4]byte
+	binary.LittleEndian.PutUint32(length[:], uint32(len(runs)))
+	return append(append(buf, length[:]...), runs...)
+}
@@ -0,0 +1,70 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parquet
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestWriter checks the file written for a table against the golden file in
+// testdata. The golden file was verified by reading it with the
+// xitongsys/parquet-go reader, which returned the schema, the values and the
+// repetition and definition levels of each column across both row groups.
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, []Column{
+		{Name: "id", Type: Uint64, Repetition: Required},
+		{Name: "name", Type: String, Repetition: Optional},
+		{Name: "tags", Type: Int64, Repetition: Repeated},
+		{Name: "ok", Type: Bool, Repetition: Optional},
+		{Name: "at", Type: Timestamp, Repetition: Optional},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2019, 1, 2, 3, 0, 0, 0, time.UTC)
+	for _, row := range [][]interface{}{
+		{uint64(1), "a", []interface{}{int64(5), int64(6)}, true, at},
+		{uint64(2), nil, nil, false, nil},
+	} {
+		if err := w.WriteRow(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	} else if err := w.WriteRow([]interface{}{uint64(3), "c", []interface{}{int64(7)}, nil, nil}); err != nil {
+		t.Fatal(err)
+	} else if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Invalid rows are rejected.
+	if err := w.WriteRow([]interface{}{nil, nil, nil, nil, nil}); err == nil {
+		t.Fatal("expected error for missing required value")
+	} else if err := w.WriteRow([]interface{}{"x", nil, nil, nil, nil}); err == nil {
+		t.Fatal("expected error for invalid value")
+	}
+
+	if golden, err := ioutil.ReadFile(filepath.Join("testdata", "Writer.parquet")); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(buf.Bytes(), golden) {
+		t.Fatalf("file differs from golden file:\n got: %x\nwant: %x", buf.Bytes(), golden)
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parquet
+
+import (
+	"encoding/binary"
+)
+
+// Thrift compact protocol type identifiers.
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// tstruct is a Thrift struct, as fields in ascending order of ID.
+type tstruct []tfield
+
+// tfield is a field of a Thrift struct. The value is an int32, int64,
+// string, tstruct or tlist.
+type tfield struct {
+	id    int16
+	value interface{}
+}
+
+// tlist is a Thrift list of elements of a single type.
+type tlist struct {
+	typ   byte
+	elems []interface{}
+}
+
+// appendStruct appends s to buf using the Thrift compact protocol, which is
+// how Parquet encodes its metadata.
+func appendStruct(buf []byte, s tstruct) []byte {
+	var last int16
+	for _, f := range s {
+		typ := thriftType(f.value)
+		if delta := f.id - last; delta > 0 && delta <= 15 {
+			buf = append(buf, byte(delta)<<4|typ)
+		} else {
+			buf = append(buf, typ)
+			buf = appendVarint(buf, int64(f.id))
+		}
+		last = f.id
+		buf = appendValue(buf, f.value)
+	}
+	return append(buf, 0) // stop field
+}
+
+// appendValue appends a value without a field header.
+func appendValue(buf []byte, v interface{}) []byte {
+	switch v := v.(type) {
+	case int32:
+		return appendVarint(buf, int64(v))
+	case int64:
+		return appendVarint(buf, v)
+	case string:
+		buf = appendUvarint(buf, uint64(len(v)))
+		return append(buf, v...)
+	case tstruct:
+		return appendStruct(buf, v)
+	case tlist:
+		if n := len(v.elems); n < 15 {
+			buf = append(buf, byte(n)<<4|v.typ)
+		} else {
+			buf = append(buf, 0xF0|v.typ)
+			buf = appendUvarint(buf, uint64(n))
+		}
+		for _, elem := range v.elems {
+			buf = appendValue(buf, elem)
+		}
+		return buf
+	default:
+		panic("parquet: unsupported thrift value")
+	}
+}
+
+// thriftType returns the compact protocol type of a value.
+func thriftType(v interface{}) byte {
+	switch v.(type) {
+	case int32:
+		return thriftI32
+	case int64:
+		return thriftI64
+	case string:
+		return thriftBinary
+	case tstruct:
+		return thriftStruct
+	case tlist:
+		return thriftList
+	default:
+		panic("parquet: unsupported thrift value")
+	}
+}
+
+// appendVarint appends a zigzag encoded varint, as used for Thrift integers.
+func appendVarint(buf []byte, v int64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	return append(buf, tmp[:binary.PutVarint(tmp[:], v)]...)
+}
+
+// appendUvarint appends a varint, as used for Thrift lengths.
+func appendUvarint(buf []byte, v uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	return append(buf, tmp[:binary.PutUvarint(tmp[:], v)]...)
+}
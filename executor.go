@@ -54,11 +54,34 @@ type executor struct {
 	// Maximum number of Set() or Clear() commands per request.
 	MaxWritesPerRequest int
 
+	// Default number of replicas which must acknowledge a Set() or Clear().
+	WriteConsistency WriteConsistency
+
+	// Queue of writes for replicas which couldn't be reached. Nil if
+	// unreachable replicas fail the write instead.
+	handoff *hintedHandoff
+
 	workersWG      sync.WaitGroup
 	workerPoolSize int
 	work           chan job
+
+	// Cache of per-shard read results. Nil if disabled.
+	resultCache *resultCache
+
+	// Serializes Add() and CompareAndSet() calls on the same column, from
+	// reading the value through to copying it to the replicas.
+	updateValueMu [updateValueLockN]sync.Mutex
+
+	// Atomic batches staged on this node, by batch id, until they are
+	// released or rolled back.
+	atomicMu      sync.Mutex
+	atomicBatches map[string]*atomicBatch
 }
 
+// updateValueLockN is the number of locks that columns are striped across
+// for Add() and CompareAndSet() calls.
+const updateValueLockN = 64
+
 // executorOption is a functional option type for pilosa.Executor
 type executorOption func(e *executor) error
 
@@ -76,6 +99,15 @@ func optExecutorWorkerPoolSize(size int) executorOption {
 	}
 }
 
+func optExecutorResultCache(maxEntries, maxResultSize int) executorOption {
+	return func(e *executor) error {
+		if maxEntries > 0 {
+			e.resultCache = newResultCache(maxEntries, maxResultSize)
+		}
+		return nil
+	}
+}
+
 // newExecutor returns a new instance of Executor.
 func newExecutor(opts ...executorOption) *executor {
 	e := &executor{
@@ -151,6 +183,14 @@ func (e *executor) Execute(ctx context.Context, index string, q *pql.Query, shar
 		}
 	}
 
+	// Writes made through this node hold back backups until they're applied
+	// on every node. Their remote parts are covered by the node they're
+	// made through.
+	if !opt.Remote && writesData(q) {
+		e.Holder.backupBarrier.enter()
+		defer e.Holder.backupBarrier.exit()
+	}
+
 	results, err := e.execute(ctx, index, q, shards, opt)
 	if err != nil {
 		return resp, err
@@ -251,16 +291,38 @@ func (e *executor) execute(ctx context.Context, index string, q *pql.Query, shar
 
 	// Optimize handling for bulk attribute insertion.
 	if hasOnlySetRowAttrs(q.Calls) {
-		return e.executeBulkSetRowAttrs(ctx, index, q.Calls, opt)
+		results, err := e.executeBulkSetRowAttrs(ctx, index, q.Calls, opt)
+		if err != nil || opt.Stream == nil {
+			return results, err
+		}
+		return nil, e.streamResults(ctx, index, q.Calls, results, opt)
+	}
+
+	// Atomic queries apply all of their writes together or not at all.
+	if opt.Atomic {
+		results, err := e.executeAtomic(ctx, index, q.Calls, opt)
+		if err != nil || opt.Stream == nil {
+			return results, err
+		}
+		return nil, e.streamResults(ctx, index, q.Calls, results, opt)
 	}
 
 	// Execute each call serially.
 	results := make([]interface{}, 0, len(q.Calls))
-	for _, call := range q.Calls {
+	for i, call := range q.Calls {
 		if err := validateQueryContext(ctx); err != nil {
 			return nil, err
 		}
 
+		// Streamed results are passed on as soon as they're available
+		// instead of being collected.
+		if opt.Stream != nil {
+			if err := e.executeCallStream(ctx, index, i, call, shards, opt); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
 		v, err := e.executeCall(ctx, index, call, shards, opt)
 		if err != nil {
 			return nil, err
@@ -270,6 +332,132 @@ func (e *executor) execute(ctx context.Context, index string, q *pql.Query, shar
 	return results, nil
 }
 
+// queryStreamBatchSize is the maximum number of columns in each chunk of a
+// streamed bitmap result.
+const queryStreamBatchSize = 10000
+
+// executeCallStream executes the i-th call of a query and streams its result
+// to opt.Stream.
+func (e *executor) executeCallStream(ctx context.Context, index string, i int, c *pql.Call, shards []uint64, opt *execOptions) error {
+	// Tag every chunk streamed while executing the call with its index.
+	callOpt := *opt
+	callOpt.Stream = func(chunk QueryResultChunk) error {
+		chunk.Index = i
+		return opt.Stream(chunk)
+	}
+
+	v, err := e.executeCall(ctx, index, c, shards, &callOpt)
+	if err != nil {
+		return err
+	} else if err := validateQueryContext(ctx); err != nil {
+		return err
+	}
+
+	if !opt.Remote {
+		idx := e.Holder.Index(index)
+		if idx == nil {
+			return newNotFoundError(ErrIndexNotFound, index)
+		}
+		if v, err = e.translateResult(index, idx, c, v); err != nil {
+			return err
+		}
+	}
+	return e.streamResult(i, v, opt)
+}
+
+// streamResults translates complete results, if necessary, and passes them
+// to opt.Stream.
+func (e *executor) streamResults(ctx context.Context, index string, calls []*pql.Call, results []interface{}, opt *execOptions) error {
+	if !opt.Remote {
+		idx := e.Holder.Index(index)
+		if idx == nil {
+			return newNotFoundError(ErrIndexNotFound, index)
+		}
+		if err := e.translateResults(ctx, index, idx, calls, results); err != nil {
+			return err
+		}
+	}
+	for i, v := range results {
+		if err := e.streamResult(i, v, opt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// streamResult passes the remainder of the i-th result to opt.Stream,
+// followed by a chunk marking the result as done.
+func (e *executor) streamResult(i int, v interface{}, opt *execOptions) error {
+	row, ok := v.(*Row)
+	if !ok {
+		return opt.Stream(QueryResultChunk{Index: i, Result: v, Done: true})
+	}
+
+	for keys := row.Keys; len(keys) > 0; {
+		n := len(keys)
+		if n > queryStreamBatchSize {
+			n = queryStreamBatchSize
+		}
+		if err := opt.Stream(QueryResultChunk{Index: i, Keys: keys[:n]}); err != nil {
+			return err
+		}
+		keys = keys[n:]
+	}
+	for _, segment := range row.Segments() {
+		if err := streamColumns(segment.Columns(), func(columns []uint64) error {
+			return opt.Stream(QueryResultChunk{Index: i, Columns: columns})
+		}); err != nil {
+			return err
+		}
+	}
+	return opt.Stream(QueryResultChunk{Index: i, Attrs: row.Attrs, Done: true})
+}
+
+// streamRowColumns passes the columns of row to opt.Stream in batches,
+// translating them to keys if necessary.
+func (e *executor) streamRowColumns(index string, row *Row, opt *execOptions) error {
+	idx := e.Holder.Index(index)
+	if idx == nil {
+		return newNotFoundError(ErrIndexNotFound, index)
+	}
+	translate := idx.Keys() && !opt.Remote
+
+	for _, segment := range row.Segments() {
+		if err := streamColumns(segment.Columns(), func(columns []uint64) error {
+			if !translate {
+				return opt.Stream(QueryResultChunk{Columns: columns})
+			}
+			keys := make([]string, len(columns))
+			for j, col := range columns {
+				key, err := idx.translateStore.TranslateID(col)
+				if err != nil {
+					return err
+				}
+				keys[j] = key
+			}
+			return opt.Stream(QueryResultChunk{Keys: keys})
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// streamColumns calls fn with successive batches of columns.
+func streamColumns(columns []uint64, fn func([]uint64) error) error {
+	for len(columns) > 0 {
+		n := len(columns)
+		if n > queryStreamBatchSize {
+			n = queryStreamBatchSize
+		}
+		if err := fn(columns[:n]); err != nil {
+			return err
+		}
+		columns = columns[n:]
+	}
+	return nil
+}
+
 // executeCall executes a call.
 func (e *executor) executeCall(ctx context.Context, index string, c *pql.Call, shards []uint64, opt *execOptions) (interface{}, error) {
 	span, ctx := tracing.StartSpanFromContext(ctx, "Executor.executeCall")
@@ -310,8 +498,16 @@ func (e *executor) executeCall(ctx context.Context, index string, c *pql.Call, s
 		return e.executeClearBit(ctx, index, c, opt)
 	case "ClearRow":
 		return e.executeClearRow(ctx, index, c, shards, opt)
+	case "Delete":
+		return e.executeDelete(ctx, index, c, shards, opt)
 	case "Store":
 		return e.executeSetRow(ctx, index, c, shards, opt)
+	case "SetValue", "Increment":
+		return e.executeUpdateValues(ctx, index, c, shards, opt)
+	case "Add", "CompareAndSet":
+		return e.executeUpdateValue(ctx, index, c, opt)
+	case "Atomic":
+		return e.executeAtomicCall(ctx, index, c, opt)
 	case "Count":
 		e.Holder.Stats.CountWithCustomTags(c.Name, 1, 1.0, []string{indexTag})
 		return e.executeCount(ctx, index, c, shards, opt)
@@ -340,18 +536,25 @@ func (e *executor) executeCall(ctx context.Context, index string, c *pql.Call, s
 
 // validateCallArgs ensures that the value types in call.Args are expected.
 func (e *executor) validateCallArgs(c *pql.Call) error {
-	if _, ok := c.Args["ids"]; ok {
-		switch v := c.Args["ids"].(type) {
+	for _, key := range []string{"ids", "columns"} {
+		if _, ok := c.Args[key]; !ok {
+			continue
+		}
+		switch v := c.Args[key].(type) {
 		case []int64, []uint64:
 			// noop
 		case []interface{}:
 			b := make([]int64, len(v))
 			for i := range v {
-				b[i] = v[i].(int64)
+				id, ok := v[i].(int64)
+				if !ok {
+					return fmt.Errorf("invalid call.Args[%s]: %v", key, v)
+				}
+				b[i] = id
 			}
-			c.Args["ids"] = b
+			c.Args[key] = b
 		default:
-			return fmt.Errorf("invalid call.Args[ids]: %s", v)
+			return fmt.Errorf("invalid call.Args[%s]: %s", key, v)
 		}
 	}
 	return nil
@@ -384,6 +587,13 @@ func (e *executor) executeOptionsCall(ctx context.Context, index string, c *pql.
 			return nil, errors.New("Query(): excludeColumns must be a bool")
 		}
 	}
+	if arg, ok := c.Args["snapshot"]; ok {
+		if value, ok := arg.(bool); ok {
+			optCopy.Snapshot = value
+		} else {
+			return nil, errors.New("Query(): snapshot must be a bool")
+		}
+	}
 	if arg, ok := c.Args["shards"]; ok {
 		if optShards, ok := arg.([]interface{}); ok {
 			shards = []uint64{}
@@ -399,6 +609,9 @@ func (e *executor) executeOptionsCall(ctx context.Context, index string, c *pql.
 			return nil, errors.New("Query(): shards must be a list of unsigned integers")
 		}
 	}
+	if optCopy.Snapshot && querySnapshotFromContext(ctx) == nil {
+		ctx = withQuerySnapshot(ctx, newQuerySnapshot(e.Holder, index))
+	}
 	return e.executeCall(ctx, index, c.Children[0], shards, optCopy)
 }
 
@@ -598,10 +811,31 @@ func (e *executor) executeBitmapCall(ctx context.Context, index string, c *pql.C
 		return other
 	}
 
+	// When streaming, pass columns on as they're reduced rather than
+	// merging them. Reduction happens both in the local mapper and here,
+	// so the stream must be protected.
+	var streamMu sync.Mutex
+	var streamErr error
+	if opt.Stream != nil && !opt.ExcludeColumns {
+		reduceFn = func(prev, v interface{}) interface{} {
+			streamMu.Lock()
+			defer streamMu.Unlock()
+			if streamErr == nil {
+				streamErr = e.streamRowColumns(index, v.(*Row), opt)
+			}
+			return NewRow()
+		}
+	}
+
 	other, err := e.mapReduce(ctx, index, shards, c, opt, mapFn, reduceFn)
 	if err != nil {
 		return nil, errors.Wrap(err, "map reduce")
 	}
+	streamMu.Lock()
+	defer streamMu.Unlock()
+	if streamErr != nil {
+		return nil, errors.Wrap(streamErr, "streaming")
+	}
 
 	// Attach attributes for non-BSI Row() calls.
 	// If the column label is used then return column attributes.
@@ -702,7 +936,7 @@ func (e *executor) executeSumCountShard(ctx context.Context, index string, c *pq
 		return ValCount{}, nil
 	}
 
-	fragment := e.Holder.fragment(index, fieldName, viewBSIGroupPrefix+fieldName, shard)
+	fragment := e.fragment(ctx, index, fieldName, ViewBSIGroupPrefix+fieldName, shard)
 	if fragment == nil {
 		return ValCount{}, nil
 	}
@@ -743,7 +977,7 @@ func (e *executor) executeMinShard(ctx context.Context, index string, c *pql.Cal
 		return ValCount{}, nil
 	}
 
-	fragment := e.Holder.fragment(index, fieldName, viewBSIGroupPrefix+fieldName, shard)
+	fragment := e.fragment(ctx, index, fieldName, ViewBSIGroupPrefix+fieldName, shard)
 	if fragment == nil {
 		return ValCount{}, nil
 	}
@@ -781,7 +1015,7 @@ func (e *executor) executeMaxShard(ctx context.Context, index string, c *pql.Cal
 		return ValCount{}, nil
 	}
 
-	fragment := e.Holder.fragment(index, fieldName, viewBSIGroupPrefix+fieldName, shard)
+	fragment := e.fragment(ctx, index, fieldName, ViewBSIGroupPrefix+fieldName, shard)
 	if fragment == nil {
 		return ValCount{}, nil
 	}
@@ -813,12 +1047,15 @@ func (e *executor) executeMinRowShard(ctx context.Context, index string, c *pql.
 		return Pair{}, nil
 	}
 
-	fragment := e.Holder.fragment(index, fieldName, viewStandard, shard)
+	fragment := e.fragment(ctx, index, fieldName, viewStandard, shard)
 	if fragment == nil {
 		return Pair{}, nil
 	}
 
-	minRowID, count := fragment.minRow(filter)
+	minRowID, count, err := fragment.minRow(filter)
+	if err != nil {
+		return Pair{}, errors.Wrap(err, "finding min row")
+	}
 	return Pair{
 		ID:    minRowID,
 		Count: count,
@@ -842,12 +1079,15 @@ func (e *executor) executeMaxRowShard(ctx context.Context, index string, c *pql.
 		return Pair{}, nil
 	}
 
-	fragment := e.Holder.fragment(index, fieldName, viewStandard, shard)
+	fragment := e.fragment(ctx, index, fieldName, viewStandard, shard)
 	if fragment == nil {
 		return Pair{}, nil
 	}
 
-	maxRowID, count := fragment.maxRow(filter)
+	maxRowID, count, err := fragment.maxRow(filter)
+	if err != nil {
+		return Pair{}, errors.Wrap(err, "finding max row")
+	}
 	return Pair{
 		ID:    maxRowID,
 		Count: count,
@@ -904,9 +1144,9 @@ func (e *executor) executeTopNShards(ctx context.Context, index string, c *pql.C
 	defer span.Finish()
 
 	// Execute calls in bulk on each remote node and merge.
-	mapFn := func(shard uint64) (interface{}, error) {
+	mapFn := e.cachedMapFn(ctx, index, c, "", func(shard uint64) (interface{}, error) {
 		return e.executeTopNShard(ctx, index, c, shard)
-	}
+	})
 
 	// Merge returned results at coordinating node.
 	reduceFn := func(prev, v interface{}) interface{} {
@@ -971,7 +1211,7 @@ func (e *executor) executeTopNShard(ctx context.Context, index string, c *pql.Ca
 		fieldName = defaultField
 	}
 
-	f := e.Holder.fragment(index, fieldName, viewStandard, shard)
+	f := e.fragment(ctx, index, fieldName, viewStandard, shard)
 	if f == nil {
 		return nil, nil
 	} else if f.CacheType == CacheTypeNone {
@@ -1116,9 +1356,9 @@ func (e *executor) executeGroupBy(ctx context.Context, index string, c *pql.Call
 	}
 
 	// Execute calls in bulk on each remote node and merge.
-	mapFn := func(shard uint64) (interface{}, error) {
+	mapFn := e.cachedMapFn(ctx, index, c, fmt.Sprint(childRows), func(shard uint64) (interface{}, error) {
 		return e.executeGroupByShard(ctx, index, c, filter, shard, childRows)
-	}
+	})
 	// Merge returned results at coordinating node.
 	reduceFn := func(prev, v interface{}) interface{} {
 		other, _ := prev.([]GroupCount)
@@ -1243,7 +1483,7 @@ func (e *executor) executeGroupByShard(ctx context.Context, index string, c *pql
 		}
 	}
 
-	iter, err := newGroupByIterator(childRows, c.Children, filterRow, index, shard, e.Holder)
+	iter, err := newGroupByIterator(ctx, e, childRows, c.Children, filterRow, index, shard)
 	if err != nil {
 		return nil, errors.Wrapf(err, "getting group by iterator for shard %d", shard)
 	}
@@ -1316,7 +1556,7 @@ func (e *executor) executeRows(ctx context.Context, index string, c *pql.Call, s
 	return results, nil
 }
 
-func (e *executor) executeRowsShard(_ context.Context, index string, fieldName string, c *pql.Call, shard uint64) (RowIDs, error) {
+func (e *executor) executeRowsShard(ctx context.Context, index string, fieldName string, c *pql.Call, shard uint64) (RowIDs, error) {
 	// Fetch index.
 	idx := e.Holder.Index(index)
 	if idx == nil {
@@ -1426,12 +1666,15 @@ func (e *executor) executeRowsShard(_ context.Context, index string, fieldName s
 	}
 
 	for _, view := range views {
-		frag := e.Holder.fragment(index, fieldName, view, shard)
+		frag := e.fragment(ctx, index, fieldName, view, shard)
 		if frag == nil {
 			continue
 		}
 
-		viewRows := frag.rows(start, filters...)
+		viewRows, err := frag.rows(start, filters...)
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading rows of view %s", view)
+		}
 		rowIDs = rowIDs.merge(viewRows, limit)
 	}
 
@@ -1488,11 +1731,11 @@ func (e *executor) executeRowShard(ctx context.Context, index string, c *pql.Cal
 
 	// Simply return row if times are not set.
 	if c.Name == "Row" && fromTime.IsZero() && toTime.IsZero() {
-		frag := e.Holder.fragment(index, fieldName, viewStandard, shard)
+		frag := e.fragment(ctx, index, fieldName, viewStandard, shard)
 		if frag == nil {
 			return NewRow(), nil
 		}
-		return frag.row(rowID), nil
+		return frag.readRow(rowID)
 	}
 
 	// If no quantum exists then return an empty bitmap.
@@ -1512,11 +1755,15 @@ func (e *executor) executeRowShard(ctx context.Context, index string, c *pql.Cal
 	views := viewsByTimeRange(viewStandard, fromTime, toTime, q)
 	rows := make([]*Row, 0, len(views))
 	for _, view := range views {
-		f := e.Holder.fragment(index, fieldName, view, shard)
+		f := e.fragment(ctx, index, fieldName, view, shard)
 		if f == nil {
 			continue
 		}
-		rows = append(rows, f.row(rowID))
+		row, err := f.readRow(rowID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading view %s", view)
+		}
+		rows = append(rows, row)
 	}
 	if len(rows) == 0 {
 		return &Row{}, nil
@@ -1573,7 +1820,7 @@ func (e *executor) executeRowBSIGroupShard(ctx context.Context, index string, c
 		}
 
 		// Retrieve fragment.
-		frag := e.Holder.fragment(index, fieldName, viewBSIGroupPrefix+fieldName, shard)
+		frag := e.fragment(ctx, index, fieldName, ViewBSIGroupPrefix+fieldName, shard)
 		if frag == nil {
 			return NewRow(), nil
 		}
@@ -1607,7 +1854,7 @@ func (e *executor) executeRowBSIGroupShard(ctx context.Context, index string, c
 		}
 
 		// Retrieve fragment.
-		frag := e.Holder.fragment(index, fieldName, viewBSIGroupPrefix+fieldName, shard)
+		frag := e.fragment(ctx, index, fieldName, ViewBSIGroupPrefix+fieldName, shard)
 		if frag == nil {
 			return NewRow(), nil
 		}
@@ -1640,7 +1887,7 @@ func (e *executor) executeRowBSIGroupShard(ctx context.Context, index string, c
 		}
 
 		// Retrieve fragment.
-		frag := e.Holder.fragment(index, fieldName, viewBSIGroupPrefix+fieldName, shard)
+		frag := e.fragment(ctx, index, fieldName, ViewBSIGroupPrefix+fieldName, shard)
 		if frag == nil {
 			return NewRow(), nil
 		}
@@ -1749,12 +1996,12 @@ func (e *executor) executeNotShard(ctx context.Context, index string, c *pql.Cal
 		return nil, errors.Errorf("index does not support existence tracking: %s", index)
 	}
 
-	var existenceRow *Row
-	existenceFrag := e.Holder.fragment(index, existenceFieldName, viewStandard, shard)
-	if existenceFrag == nil {
-		existenceRow = NewRow()
-	} else {
-		existenceRow = existenceFrag.row(0)
+	existenceRow := NewRow()
+	if existenceFrag := e.fragment(ctx, index, existenceFieldName, viewStandard, shard); existenceFrag != nil {
+		var err error
+		if existenceRow, err = existenceFrag.readRow(0); err != nil {
+			return nil, errors.Wrap(err, "reading existence row")
+		}
 	}
 
 	row, err := e.executeBitmapCallShard(ctx, index, c.Children[0], shard)
@@ -1798,13 +2045,13 @@ func (e *executor) executeCount(ctx context.Context, index string, c *pql.Call,
 	}
 
 	// Execute calls in bulk on each remote node and merge.
-	mapFn := func(shard uint64) (interface{}, error) {
+	mapFn := e.cachedMapFn(ctx, index, c, "", func(shard uint64) (interface{}, error) {
 		row, err := e.executeBitmapCallShard(ctx, index, c.Children[0], shard)
 		if err != nil {
 			return 0, err
 		}
 		return row.Count(), nil
-	}
+	})
 
 	// Merge returned results at coordinating node.
 	reduceFn := func(prev, v interface{}) interface{} {
@@ -1864,32 +2111,9 @@ func (e *executor) executeClearBitField(ctx context.Context, index string, c *pq
 	span, ctx := tracing.StartSpanFromContext(ctx, "Executor.executeClearBitField")
 	defer span.Finish()
 
-	shard := colID / ShardWidth
-	ret := false
-	for _, node := range e.Cluster.shardNodes(index, shard) {
-		// Update locally if host matches.
-		if node.ID == e.Node.ID {
-			val, err := f.ClearBit(rowID, colID)
-			if err != nil {
-				return false, err
-			} else if val {
-				ret = true
-			}
-			continue
-		}
-		// Do not forward call if this is already being forwarded.
-		if opt.Remote {
-			continue
-		}
-
-		// Forward call to remote node otherwise.
-		res, err := e.remoteExec(ctx, node, index, &pql.Query{Calls: []*pql.Call{c}}, nil)
-		if err != nil {
-			return false, err
-		}
-		ret = res[0].(bool)
-	}
-	return ret, nil
+	return e.writeReplicas(ctx, index, colID/ShardWidth, c, opt, func() (bool, error) {
+		return f.ClearBit(rowID, colID)
+	})
 }
 
 // executeClearRow executes a ClearRow() call.
@@ -1975,6 +2199,174 @@ func (e *executor) executeClearRowShard(ctx context.Context, index string, c *pq
 	return changed, nil
 }
 
+// executeDelete executes a Delete() call.
+//
+// The coordinating node resolves the child bitmap call (or the explicit
+// "columns" argument) to a list of columns and forwards to each node the
+// columns in the shards it owns. Each node clears the columns from its own
+// fragments. Column attributes and keys are stored on all nodes, so when
+// either is removed the full list is forwarded to every node instead.
+func (e *executor) executeDelete(ctx context.Context, index string, c *pql.Call, shards []uint64, opt *execOptions) (bool, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx, "Executor.executeDelete")
+	defer span.Finish()
+
+	idx := e.Holder.Index(index)
+	if idx == nil {
+		return false, newNotFoundError(ErrIndexNotFound, index)
+	}
+
+	keys, _, err := c.BoolArg("keys")
+	if err != nil {
+		return false, errors.Wrap(err, "reading Delete() keys")
+	}
+	attrs, _, err := c.BoolArg("attrs")
+	if err != nil {
+		return false, errors.Wrap(err, "reading Delete() attrs")
+	}
+
+	columns, ok, err := c.UintSliceArg("columns")
+	if err != nil {
+		return false, errors.Wrap(err, "reading Delete() columns")
+	} else if !ok {
+		if len(c.Children) != 1 {
+			return false, errors.New("Delete() requires a single bitmap argument or a columns list")
+		}
+		row, err := e.executeBitmapCall(ctx, index, c.Children[0], shards, &execOptions{ExcludeRowAttrs: true})
+		if err != nil {
+			return false, errors.Wrap(err, "resolving Delete() columns")
+		}
+		columns = row.Columns()
+	} else if len(c.Children) > 0 {
+		return false, errors.New("Delete() accepts either a bitmap argument or a columns list, not both")
+	}
+	if len(columns) == 0 {
+		return false, nil
+	}
+
+	changed, err := e.deleteColumns(idx, columns, keys, attrs)
+	if err != nil {
+		return false, err
+	}
+
+	// Do not forward call if this is already being forwarded.
+	if opt.Remote {
+		return changed, nil
+	}
+
+	// Group the columns by the nodes owning their shards.
+	nodeColumns := make(map[string][]uint64)
+	if keys || attrs {
+		for _, node := range e.Cluster.nodes {
+			nodeColumns[node.ID] = columns
+		}
+	} else {
+		for _, col := range columns {
+			for _, node := range e.Cluster.ShardNodes(index, col/ShardWidth) {
+				nodeColumns[node.ID] = append(nodeColumns[node.ID], col)
+			}
+		}
+	}
+
+	// Execute on remote nodes in parallel.
+	type deleteResponse struct {
+		changed bool
+		err     error
+	}
+	var nodes []*Node
+	for _, node := range Nodes(e.Cluster.nodes).FilterID(e.Node.ID) {
+		if len(nodeColumns[node.ID]) > 0 {
+			nodes = append(nodes, node)
+		}
+	}
+	resp := make(chan deleteResponse, len(nodes))
+	for _, node := range nodes {
+		go func(node *Node) {
+			other := &pql.Call{
+				Name: "Delete",
+				Args: map[string]interface{}{"columns": nodeColumns[node.ID], "keys": keys, "attrs": attrs},
+			}
+			res, err := e.remoteExec(ctx, node, index, &pql.Query{Calls: []*pql.Call{other}}, nil)
+			if err != nil {
+				resp <- deleteResponse{err: err}
+				return
+			} else if len(res) != 1 {
+				resp <- deleteResponse{err: fmt.Errorf("Delete() sent to node %s returned %d results", node.ID, len(res))}
+				return
+			}
+			v, ok := res[0].(bool)
+			if !ok {
+				resp <- deleteResponse{err: fmt.Errorf("Delete() sent to node %s returned unexpected result: %T", node.ID, res[0])}
+				return
+			}
+			resp <- deleteResponse{changed: v}
+		}(node)
+	}
+
+	// Return first error.
+	for range nodes {
+		r := <-resp
+		if r.err != nil {
+			return false, r.err
+		}
+		changed = changed || r.changed
+	}
+	return changed, nil
+}
+
+// deleteColumns clears columns from every local fragment of every field and
+// view in idx, including the existence field. Column attributes and keys are
+// removed if attrs and keys are set, respectively.
+func (e *executor) deleteColumns(idx *Index, columns []uint64, keys, attrs bool) (bool, error) {
+	byShard := make(map[uint64][]uint64)
+	for _, col := range columns {
+		byShard[col/ShardWidth] = append(byShard[col/ShardWidth], col)
+	}
+
+	changed := false
+	for _, field := range idx.Fields() {
+		for _, view := range field.views() {
+			for shard, cols := range byShard {
+				fragment := view.Fragment(shard)
+				if fragment == nil {
+					continue
+				}
+				cleared, err := fragment.clearColumns(cols)
+				if err != nil {
+					return false, errors.Wrapf(err, "clearing columns on field %s view %s shard %d", field.Name(), view.name, shard)
+				}
+				changed = changed || cleared
+			}
+		}
+	}
+
+	if attrs {
+		store := idx.ColumnAttrStore()
+		for _, col := range columns {
+			m, err := store.Attrs(col)
+			if err != nil {
+				return false, errors.Wrapf(err, "reading attributes for column %d", col)
+			} else if len(m) == 0 {
+				continue
+			}
+			for k := range m {
+				m[k] = nil
+			}
+			if err := store.SetAttrs(col, m); err != nil {
+				return false, errors.Wrapf(err, "removing attributes for column %d", col)
+			}
+			changed = true
+		}
+	}
+
+	if keys && idx.Keys() {
+		if err := idx.TranslateStore().DeleteIDs(columns); err != nil {
+			return false, errors.Wrap(err, "removing column keys")
+		}
+	}
+
+	return changed, nil
+}
+
 // executeSetRow executes a Store() call.
 func (e *executor) executeSetRow(ctx context.Context, index string, c *pql.Call, shards []uint64, opt *execOptions) (bool, error) {
 	// Ensure the field type supports Store().
@@ -2013,6 +2405,106 @@ func (e *executor) executeSetRow(ctx context.Context, index string, c *pql.Call,
 	return r, err
 }
 
+// executeUpdateValues executes a SetValue() or Increment() call, which
+// rewrite the values of an int field for every column in a row.
+func (e *executor) executeUpdateValues(ctx context.Context, index string, c *pql.Call, shards []uint64, opt *execOptions) (bool, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx, "Executor.executeUpdateValues")
+	defer span.Finish()
+
+	fieldName := callArgString(c, "field")
+	if fieldName == "" {
+		return false, fmt.Errorf("%s() argument required: field", c.Name)
+	}
+	field := e.Holder.Field(index, fieldName)
+	if field == nil {
+		return false, newNotFoundError(ErrFieldNotFound, fieldName)
+	} else if field.Type() != FieldTypeInt {
+		return false, fmt.Errorf("%s() is not supported on %s field types", c.Name, field.Type())
+	}
+	if len(c.Children) != 1 {
+		return false, fmt.Errorf("%s() requires a single row argument", c.Name)
+	}
+
+	argName := "value"
+	if c.Name == "Increment" {
+		argName = "by"
+	}
+	value, ok, err := c.IntArg(argName)
+	if err != nil {
+		return false, errors.Wrapf(err, "reading %s() %s", c.Name, argName)
+	} else if !ok {
+		return false, fmt.Errorf("%s() argument required: %s", c.Name, argName)
+	}
+
+	mapFn := func(shard uint64) (interface{}, error) {
+		filter, err := e.executeBitmapCallShard(ctx, index, c.Children[0], shard)
+		if err != nil {
+			return false, errors.Wrap(err, "getting filter row")
+		}
+		if c.Name == "Increment" {
+			return field.IncrementValues(shard, filter, value)
+		}
+		return field.SetValues(shard, filter, value)
+	}
+
+	// Merge returned results at coordinating node.
+	reduceFn := func(prev, v interface{}) interface{} {
+		val := v.(bool)
+		if prev == nil {
+			return val
+		}
+		return val || prev.(bool)
+	}
+
+	// Remote calls only update the shards sent to this node.
+	if opt.Remote {
+		result, err := e.mapperLocal(ctx, shards, mapFn, reduceFn)
+		if err != nil {
+			return false, errors.Wrapf(err, "updating %s shards", c.Name)
+		}
+		changed, _ := result.(bool)
+		return changed, nil
+	}
+
+	// Unlike reads, which are mapped to a single owner of each shard, the
+	// update is sent to every replica of each shard.
+	nodeShards := make(map[*Node][]uint64)
+	for _, shard := range shards {
+		for _, node := range e.Cluster.ShardNodes(index, shard) {
+			nodeShards[node] = append(nodeShards[node], shard)
+		}
+	}
+
+	ch := make(chan mapResponse, len(nodeShards))
+	for node, shards := range nodeShards {
+		go func(node *Node, shards []uint64) {
+			resp := mapResponse{node: node, shards: shards}
+			if node.ID == e.Node.ID {
+				resp.result, resp.err = e.mapperLocal(ctx, shards, mapFn, reduceFn)
+			} else {
+				var results []interface{}
+				results, resp.err = e.remoteExec(ctx, node, index, &pql.Query{Calls: []*pql.Call{c}}, shards)
+				if len(results) > 0 {
+					resp.result = results[0]
+				}
+			}
+			ch <- resp
+		}(node, shards)
+	}
+
+	// Return first error.
+	var changed bool
+	for range nodeShards {
+		resp := <-ch
+		if resp.err != nil {
+			return false, errors.Wrapf(resp.err, "updating %s on node %s", c.Name, resp.node.ID)
+		}
+		v, _ := resp.result.(bool)
+		changed = changed || v
+	}
+	return changed, nil
+}
+
 // executeSetRowShard executes a SetRow() call for a single shard.
 func (e *executor) executeSetRowShard(ctx context.Context, index string, c *pql.Call, shard uint64) (bool, error) {
 	fieldName, err := c.FieldArg()
@@ -2143,34 +2635,9 @@ func (e *executor) executeSetBitField(ctx context.Context, index string, c *pql.
 	span, ctx := tracing.StartSpanFromContext(ctx, "Executor.executeSetBitField")
 	defer span.Finish()
 
-	shard := colID / ShardWidth
-	ret := false
-
-	for _, node := range e.Cluster.shardNodes(index, shard) {
-		// Update locally if host matches.
-		if node.ID == e.Node.ID {
-			val, err := f.SetBit(rowID, colID, timestamp)
-			if err != nil {
-				return false, err
-			} else if val {
-				ret = true
-			}
-			continue
-		}
-
-		// Do not forward call if this is already being forwarded.
-		if opt.Remote {
-			continue
-		}
-
-		// Forward call to remote node otherwise.
-		res, err := e.remoteExec(ctx, node, index, &pql.Query{Calls: []*pql.Call{c}}, nil)
-		if err != nil {
-			return false, err
-		}
-		ret = res[0].(bool)
-	}
-	return ret, nil
+	return e.writeReplicas(ctx, index, colID/ShardWidth, c, opt, func() (bool, error) {
+		return f.SetBit(rowID, colID, timestamp)
+	})
 }
 
 // executeSetValueField executes a Set() call for a specific int field.
@@ -2178,34 +2645,130 @@ func (e *executor) executeSetValueField(ctx context.Context, index string, c *pq
 	span, ctx := tracing.StartSpanFromContext(ctx, "Executor.executeSetValueField")
 	defer span.Finish()
 
-	shard := colID / ShardWidth
-	ret := false
+	return e.writeReplicas(ctx, index, colID/ShardWidth, c, opt, func() (bool, error) {
+		return f.SetValue(colID, value)
+	})
+}
 
-	for _, node := range e.Cluster.shardNodes(index, shard) {
-		// Update locally if host matches.
-		if node.ID == e.Node.ID {
-			val, err := f.SetValue(colID, value)
+// executeUpdateValue executes an Add() or CompareAndSet() call.
+//
+// The update is applied by the shard's primary owner, which reads and writes
+// the value under the fragment lock. The primary then writes the resulting
+// value to the other replicas with Set() so that they all hold the same
+// value even if they had diverged.
+//
+// The result is a ValCount holding the column's value afterwards; Count is 1
+// if the update was applied and 0 if a CompareAndSet() didn't match.
+func (e *executor) executeUpdateValue(ctx context.Context, index string, c *pql.Call, opt *execOptions) (ValCount, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx, "Executor.executeUpdateValue")
+	defer span.Finish()
+
+	fieldName := callArgString(c, "field")
+	if fieldName == "" {
+		return ValCount{}, fmt.Errorf("%s() argument required: field", c.Name)
+	}
+	field := e.Holder.Field(index, fieldName)
+	if field == nil {
+		return ValCount{}, newNotFoundError(ErrFieldNotFound, fieldName)
+	} else if field.Type() != FieldTypeInt {
+		return ValCount{}, fmt.Errorf("%s() is not supported on %s field types", c.Name, field.Type())
+	}
+
+	// The column may be given positionally or as a "col" argument.
+	colID, ok, err := c.UintArg("_" + columnLabel)
+	if err != nil {
+		return ValCount{}, errors.Wrapf(err, "reading %s() column", c.Name)
+	} else if !ok {
+		if colID, ok, err = c.UintArg("col"); err != nil {
+			return ValCount{}, errors.Wrapf(err, "reading %s() col", c.Name)
+		} else if !ok {
+			return ValCount{}, fmt.Errorf("%s() argument required: col", c.Name)
+		}
+	}
+
+	var update func() (int64, bool, error)
+	switch c.Name {
+	case "Add":
+		by, ok, err := c.IntArg("by")
+		if err != nil {
+			return ValCount{}, errors.Wrap(err, "reading Add() by")
+		} else if !ok {
+			return ValCount{}, errors.New("Add() argument required: by")
+		}
+		update = func() (int64, bool, error) {
+			value, err := field.AddValue(colID, by)
+			return value, true, err
+		}
+	case "CompareAndSet":
+		value, ok, err := c.IntArg("value")
+		if err != nil {
+			return ValCount{}, errors.Wrap(err, "reading CompareAndSet() value")
+		} else if !ok {
+			return ValCount{}, errors.New("CompareAndSet() argument required: value")
+		}
+		// A null expect matches a column without a value.
+		var expect *int64
+		if v, ok := c.Args["expect"]; !ok {
+			return ValCount{}, errors.New("CompareAndSet() argument required: expect")
+		} else if v != nil {
+			i, _, err := c.IntArg("expect")
 			if err != nil {
-				return false, err
-			} else if val {
-				ret = true
+				return ValCount{}, errors.Wrap(err, "reading CompareAndSet() expect")
 			}
-			continue
+			expect = &i
+		}
+		update = func() (int64, bool, error) {
+			return field.CompareAndSetValue(colID, expect, value)
 		}
+	}
 
-		// Do not forward call if this is already being forwarded.
+	nodes := e.Cluster.shardNodes(index, colID/ShardWidth)
+	if len(nodes) == 0 {
+		return ValCount{}, errShardUnavailable
+	}
+
+	// Forward the call to the primary owner if it isn't this node.
+	if nodes[0].ID != e.Node.ID {
 		if opt.Remote {
-			continue
+			return ValCount{}, fmt.Errorf("%s() forwarded to a node which is not the primary owner", c.Name)
 		}
-
-		// Forward call to remote node otherwise.
-		res, err := e.remoteExec(ctx, node, index, &pql.Query{Calls: []*pql.Call{c}}, nil)
+		res, err := e.remoteExec(ctx, nodes[0], index, &pql.Query{Calls: []*pql.Call{c}}, nil)
 		if err != nil {
-			return false, err
+			return ValCount{}, err
+		} else if len(res) != 1 {
+			return ValCount{}, fmt.Errorf("%s() forwarded to node %s returned %d results", c.Name, nodes[0].ID, len(res))
+		}
+		vc, ok := res[0].(ValCount)
+		if !ok {
+			return ValCount{}, fmt.Errorf("%s() forwarded to node %s returned unexpected result: %T", c.Name, nodes[0].ID, res[0])
+		}
+		return vc, nil
+	}
+
+	// Hold the column's lock until the replicas have been updated so that
+	// they receive new values in the same order they were applied here.
+	mu := &e.updateValueMu[colID%updateValueLockN]
+	mu.Lock()
+	defer mu.Unlock()
+
+	value, ok, err := update()
+	if err != nil {
+		return ValCount{}, err
+	} else if !ok {
+		return ValCount{Val: value}, nil
+	}
+
+	// Copy the new value to the other replicas.
+	set := &pql.Call{
+		Name: "Set",
+		Args: map[string]interface{}{"_" + columnLabel: colID, fieldName: value},
+	}
+	for _, node := range nodes[1:] {
+		if _, err := e.remoteExec(ctx, node, index, &pql.Query{Calls: []*pql.Call{set}}, nil); err != nil {
+			return ValCount{}, errors.Wrap(err, "setting replica value")
 		}
-		ret = res[0].(bool)
 	}
-	return ret, nil
+	return ValCount{Val: value, Count: 1}, nil
 }
 
 // executeSetRowAttrs executes a SetRowAttrs() call.
@@ -2538,7 +3101,12 @@ func (e *executor) mapper(ctx context.Context, ch chan mapResponse, nodes []*Nod
 			if n.ID == e.Node.ID {
 				resp.result, resp.err = e.mapperLocal(ctx, nodeShards, mapFn, reduceFn)
 			} else if !opt.Remote {
-				results, err := e.remoteExec(ctx, n, index, &pql.Query{Calls: []*pql.Call{c}}, nodeShards)
+				// Remote nodes pin their own shards.
+				call := c
+				if opt.Snapshot {
+					call = &pql.Call{Name: "Options", Args: map[string]interface{}{"snapshot": true}, Children: []*pql.Call{c}}
+				}
+				results, err := e.remoteExec(ctx, n, index, &pql.Query{Calls: []*pql.Call{call}}, nodeShards)
 				if len(results) > 0 {
 					resp.result = results[0]
 				}
@@ -2640,6 +3208,13 @@ func (e *executor) translateCall(index string, idx *Index, c *pql.Call) error {
 		fieldName = callArgString(c, "_field")
 		rowKey = "previous"
 		colKey = "column"
+	case "Add", "CompareAndSet":
+		// The column may be given positionally or as a "col" argument.
+		// There is no row to translate.
+		colKey = "col"
+		if _, ok := c.Args["_"+columnLabel]; ok {
+			colKey = "_" + columnLabel
+		}
 	case "GroupBy":
 		return errors.Wrap(e.translateGroupByCall(index, idx, c), "translating GroupBy")
 	default:
@@ -2708,6 +3283,29 @@ func (e *executor) translateCall(index string, idx *Index, c *pql.Call) error {
 		}
 	}
 
+	// Translate the column list of a Delete() call.
+	if v, ok := c.Args["columns"].([]interface{}); ok && c.Name == "Delete" && idx.Keys() {
+		keys := make([]string, len(v))
+		for i := range v {
+			if keys[i], ok = v[i].(string); !ok {
+				return errors.New("column values must be strings when index 'keys' option enabled")
+			}
+		}
+		// Keys are looked up without being created, so unknown keys are
+		// dropped and non-primary nodes can translate the list too.
+		found, err := idx.translateStore.FindKeys(keys)
+		if err != nil {
+			return err
+		}
+		ids := make([]uint64, 0, len(found))
+		for _, id := range found {
+			if id != 0 {
+				ids = append(ids, id)
+			}
+		}
+		c.Args["columns"] = ids
+	}
+
 	// Translate child calls.
 	for _, child := range c.Children {
 		if err := e.translateCall(index, idx, child); err != nil {
@@ -2924,6 +3522,17 @@ func (e *executor) detectRangeCall(c *pql.Call) bool {
 	return false
 }
 
+// writesData reports whether any call of q writes to fragments or attributes.
+func writesData(q *pql.Query) bool {
+	for _, c := range q.Calls {
+		switch c.Name {
+		case "Set", "Clear", "ClearRow", "Delete", "Store", "SetValue", "Increment", "Add", "CompareAndSet", "Atomic", "SetRowAttrs", "SetColumnAttrs":
+			return true
+		}
+	}
+	return false
+}
+
 // validateQueryContext returns a query-appropriate error if the context is done.
 func validateQueryContext(ctx context.Context) error {
 	select {
@@ -2962,6 +3571,22 @@ type execOptions struct {
 	ExcludeRowAttrs bool
 	ExcludeColumns  bool
 	ColumnAttrs     bool
+
+	// If true, the query's Set() and Clear() calls are applied together
+	// or not at all.
+	Atomic bool
+
+	// Number of replicas which must acknowledge each Set() or Clear().
+	// Empty uses the server default.
+	WriteConsistency WriteConsistency
+
+	// If true, reads see each shard as it was when first read by the
+	// query. Writes made while the query runs are not seen.
+	Snapshot bool
+
+	// If set, results are passed to Stream as they are produced
+	// instead of being returned.
+	Stream func(QueryResultChunk) error
 }
 
 // hasOnlySetRowAttrs returns true if calls only contains SetRowAttrs() calls.
@@ -2984,7 +3609,7 @@ func needsShards(calls []*pql.Call) bool {
 	}
 	for _, call := range calls {
 		switch call.Name {
-		case "Clear", "Set", "SetRowAttrs", "SetColumnAttrs":
+		case "Clear", "Set", "SetRowAttrs", "SetColumnAttrs", "Atomic":
 			continue
 		case "Count", "TopN", "Rows":
 			return true
@@ -3085,7 +3710,7 @@ type groupByIterator struct {
 }
 
 // newGroupByIterator initializes a new groupByIterator.
-func newGroupByIterator(rowIDs []RowIDs, children []*pql.Call, filter *Row, index string, shard uint64, holder *Holder) (*groupByIterator, error) {
+func newGroupByIterator(ctx context.Context, e *executor, rowIDs []RowIDs, children []*pql.Call, filter *Row, index string, shard uint64) (*groupByIterator, error) {
 	gbi := &groupByIterator{
 		rowIters: make([]*rowIterator, len(children)),
 		rows: make([]struct {
@@ -3103,12 +3728,12 @@ func newGroupByIterator(rowIDs []RowIDs, children []*pql.Call, filter *Row, inde
 		if fieldName, ok = call.Args["_field"].(string); !ok {
 			return nil, errors.Errorf("%s call must have field with valid (string) field name. Got %v of type %[2]T", call.Name, call.Args["_field"])
 		}
-		if holder.Field(index, fieldName) == nil {
+		if e.Holder.Field(index, fieldName) == nil {
 			return nil, newNotFoundError(ErrFieldNotFound, fieldName)
 		}
 		gbi.fields[i].Field = fieldName
 		// Fetch fragment.
-		frag := holder.fragment(index, fieldName, viewStandard, shard)
+		frag := e.fragment(ctx, index, fieldName, viewStandard, shard)
 		if frag == nil { // this means this whole shard doesn't have all it needs to continue
 			return nil, nil
 		}
@@ -3116,7 +3741,11 @@ func newGroupByIterator(rowIDs []RowIDs, children []*pql.Call, filter *Row, inde
 		if len(rowIDs[i]) > 0 {
 			filters = append(filters, filterWithRows(rowIDs[i]))
 		}
-		gbi.rowIters[i] = frag.rowIterator(i != 0, filters...)
+		rowIter, err := frag.rowIterator(i != 0, filters...)
+		if err != nil {
+			return nil, errors.Wrap(err, "reading rows")
+		}
+		gbi.rowIters[i] = rowIter
 
 		prev, hasPrev, err := call.UintArg("previous")
 		if err != nil {
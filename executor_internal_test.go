@@ -15,9 +15,12 @@
 package pilosa
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"os"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -222,3 +225,100 @@ func TestFieldRowMarshalJSON(t *testing.T) {
 		t.Fatalf("unexpected json: %s", b)
 	}
 }
+
+// resultsQueryClient responds to every query with results.
+type resultsQueryClient struct {
+	results []interface{}
+}
+
+func (c *resultsQueryClient) QueryNode(ctx context.Context, uri *URI, index string, req *QueryRequest) (*QueryResponse, error) {
+	return &QueryResponse{Results: c.results}, nil
+}
+
+// newRemoteTestExecutor returns an executor for node1 of a cluster of two
+// nodes, which sends remote queries to client.
+func newRemoteTestExecutor(t *testing.T, client InternalQueryClient) *executor {
+	e := newExecutor(optExecutorInternalQueryClient(client))
+	e.Holder = NewHolder()
+	e.Holder.Path, _ = ioutil.TempDir(*TempDir, "")
+	if err := e.Holder.Open(); err != nil {
+		t.Fatal(err)
+	}
+	if idx, err := e.Holder.CreateIndex("i", IndexOptions{}); err != nil {
+		t.Fatal(err)
+	} else if _, err := idx.CreateField("n", OptFieldTypeInt(0, 100)); err != nil {
+		t.Fatal(err)
+	}
+
+	e.Cluster = newCluster()
+	for _, id := range []string{"node0", "node1"} {
+		e.Cluster.addNodeBasicSorted(&Node{ID: id, URI: URI{Scheme: "http", Host: id, Port: 10101}})
+	}
+	e.Node = e.Cluster.nodeByID("node1")
+	return e
+}
+
+// closeRemoteTestExecutor closes an executor returned by
+// newRemoteTestExecutor.
+func closeRemoteTestExecutor(e *executor) {
+	e.Close()
+	e.Holder.Close()
+	os.RemoveAll(e.Holder.Path)
+}
+
+// Ensure an Add() forwarded to the primary owner of its column fails, rather
+// than panicking, when the owner doesn't respond with a value.
+func TestExecutor_ExecuteUpdateValue_RemoteResult(t *testing.T) {
+	client := &resultsQueryClient{}
+	e := newRemoteTestExecutor(t, client)
+	defer closeRemoteTestExecutor(e)
+
+	// Find a column owned by the other node.
+	var col uint64
+	for e.Cluster.shardNodes("i", col/ShardWidth)[0].ID == e.Node.ID {
+		col += ShardWidth
+	}
+	q, err := pql.ParseString(fmt.Sprintf(`Add(%d, field=n, by=1)`, col))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, results := range [][]interface{}{nil, {true}} {
+		client.results = results
+		if _, err := e.Execute(context.Background(), "i", q, nil, nil); err == nil {
+			t.Fatalf("expected error for results %v", results)
+		}
+	}
+
+	client.results = []interface{}{ValCount{Val: 1, Count: 1}}
+	if resp, err := e.Execute(context.Background(), "i", q, nil, nil); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(resp.Results, client.results) {
+		t.Fatalf("unexpected results: %v", resp.Results)
+	}
+}
+
+// Ensure a Delete() fails, rather than panicking, when another node doesn't
+// respond with whether it changed anything.
+func TestExecutor_ExecuteDelete_RemoteResult(t *testing.T) {
+	client := &resultsQueryClient{}
+	e := newRemoteTestExecutor(t, client)
+	defer closeRemoteTestExecutor(e)
+
+	q, err := pql.ParseString(`Delete(columns=[1])`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, results := range [][]interface{}{nil, {ValCount{}}} {
+		client.results = results
+		if _, err := e.Execute(context.Background(), "i", q, nil, nil); err == nil {
+			t.Fatalf("expected error for results %v", results)
+		}
+	}
+
+	client.results = []interface{}{true}
+	if resp, err := e.Execute(context.Background(), "i", q, nil, nil); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(resp.Results, []interface{}{true}) {
+		t.Fatalf("unexpected results: %v", resp.Results)
+	}
+}
@@ -25,6 +25,7 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -508,6 +509,52 @@ func TestExecutor_Execute_Count(t *testing.T) {
 
 }
 
+// Ensure cached Count, TopN, and GroupBy results are invalidated by writes.
+func TestExecutor_Execute_ResultCache(t *testing.T) {
+	c := test.MustRunCluster(t, 1, []server.CommandOption{
+		server.OptCommandServerOptions(pilosa.OptServerResultCache(100, 0)),
+	})
+	defer c.Close()
+	c.CreateField(t, "i", pilosa.IndexOptions{TrackExistence: true}, "f")
+	c.CreateField(t, "i", pilosa.IndexOptions{TrackExistence: true}, "g")
+
+	c.Query(t, "i", fmt.Sprintf(`Set(1, f=10) Set(2, f=10) Set(%d, f=11) Set(1, g=1)`, ShardWidth+1))
+	c[0].MustRecalculateCaches(t)
+
+	// Run each query twice so the second is served from the cache. TopN
+	// rankings are rebuilt once after each write so that they are current.
+	check := func(query string, exp interface{}) {
+		t.Helper()
+		for i := 0; i < 2; i++ {
+			if res := c.Query(t, "i", query); !reflect.DeepEqual(res.Results[0], exp) {
+				t.Fatalf("%s: unexpected result: %s", query, spew.Sdump(res.Results[0]))
+			}
+		}
+	}
+
+	check(`Count(Row(f=10))`, uint64(2))
+	check(`Count(Not(Row(f=11)))`, uint64(2))
+	check(`TopN(f)`, []pilosa.Pair{{ID: 10, Count: 2}, {ID: 11, Count: 1}})
+	check(`GroupBy(Rows(f), filter=Row(g=1))`, []pilosa.GroupCount{
+		{Group: []pilosa.FieldRow{{Field: "f", RowID: 10}}, Count: 1},
+	})
+
+	c.Query(t, "i", fmt.Sprintf(`Set(3, f=10) Set(2, g=1) Set(%d, f=11)`, ShardWidth+2))
+	c[0].MustRecalculateCaches(t)
+
+	check(`Count(Row(f=10))`, uint64(3))
+	check(`Count(Not(Row(f=11)))`, uint64(3))
+	check(`TopN(f)`, []pilosa.Pair{{ID: 10, Count: 3}, {ID: 11, Count: 2}})
+	check(`GroupBy(Rows(f), filter=Row(g=1))`, []pilosa.GroupCount{
+		{Group: []pilosa.FieldRow{{Field: "f", RowID: 10}}, Count: 2},
+	})
+
+	c.Query(t, "i", `ClearRow(f=10)`)
+
+	check(`Count(Row(f=10))`, uint64(0))
+	check(`GroupBy(Rows(f), filter=Row(g=1))`, []pilosa.GroupCount{})
+}
+
 // Ensure a set query can be executed.
 func TestExecutor_Execute_Set(t *testing.T) {
 	t.Run("RowIDColumnID", func(t *testing.T) {
@@ -2736,6 +2783,36 @@ func TestExecutor_ExecuteOptions(t *testing.T) {
 		}
 	})
 
+	t.Run("snapshot", func(t *testing.T) {
+		writeQuery := fmt.Sprintf(`
+			Set(100, f=10)
+			Set(%d, f=10)
+			Set(%d, f=20)`, ShardWidth, ShardWidth*2)
+		c := test.MustRunCluster(t, 3)
+		defer c.Close()
+		c.CreateField(t, "i", pilosa.IndexOptions{}, "f")
+		c.Query(t, "i", writeQuery)
+
+		// Shards owned by other nodes are read from snapshots there.
+		for i := range c {
+			res, err := c[i].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `Options(Count(Union(Row(f=10), Row(f=20))), snapshot=true)`})
+			if err != nil {
+				t.Fatal(err)
+			} else if n := res.Results[0].(uint64); n != 3 {
+				t.Fatalf("unexpected count on node %d: %d", i, n)
+			}
+		}
+	})
+
+	t.Run("invalidSnapshot", func(t *testing.T) {
+		c := test.MustRunCluster(t, 1)
+		defer c.Close()
+		c.CreateField(t, "i", pilosa.IndexOptions{}, "f")
+		if _, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `Options(Row(f=10), snapshot=1)`}); err == nil || !strings.Contains(err.Error(), "snapshot must be a bool") {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
 	t.Run("multipleOpt", func(t *testing.T) {
 		writeQuery := `
 			Set(100, f=10)
@@ -3108,6 +3185,110 @@ func TestExecutor_Execute_ClearRow(t *testing.T) {
 	})
 }
 
+func TestExecutor_Execute_Delete(t *testing.T) {
+	t.Run("IDs", func(t *testing.T) {
+		c := test.MustRunCluster(t, 3)
+		defer c.Close()
+		c.CreateField(t, "i", pilosa.IndexOptions{TrackExistence: true}, "f")
+		c.CreateField(t, "i", pilosa.IndexOptions{TrackExistence: true}, "v", pilosa.OptFieldTypeInt(0, 100))
+		c.CreateField(t, "i", pilosa.IndexOptions{TrackExistence: true}, "t", pilosa.OptFieldTypeTime(pilosa.TimeQuantum("YMD")))
+
+		cols := []uint64{3, ShardWidth + 1, 2*ShardWidth + 5}
+		for _, col := range cols {
+			c.Query(t, "i", fmt.Sprintf(`Set(%d, f=1) Set(%d, v=10) Set(%d, t=1, 2019-01-01T00:00) SetColumnAttrs(%d, name="x")`, col, col, col, col))
+		}
+		c.Query(t, "i", fmt.Sprintf(`Set(%d, f=2) Set(%d, v=20) Set(%d, t=1, 2019-01-01T00:00)`, ShardWidth+2, ShardWidth+2, ShardWidth+2))
+
+		if res := c.Query(t, "i", `Delete(Row(f=1), attrs=true)`).Results[0].(bool); !res {
+			t.Fatalf("unexpected delete result: %v", res)
+		}
+		if res := c.Query(t, "i", `Delete(Row(f=1), attrs=true)`).Results[0].(bool); res {
+			t.Fatalf("unexpected second delete result: %v", res)
+		}
+
+		if bits := c.Query(t, "i", `Row(f=1)`).Results[0].(*pilosa.Row).Columns(); len(bits) != 0 {
+			t.Fatalf("unexpected columns: %v", bits)
+		}
+
+		// Int fields, time views and the existence field are cleared too.
+		for _, q := range []string{
+			`Row(v > 0)`,
+			`Row(t=1, from=2018-01-01T00:00, to=2020-01-01T00:00)`,
+			`Not(Row(f=3))`,
+		} {
+			if bits := c.Query(t, "i", q).Results[0].(*pilosa.Row).Columns(); !reflect.DeepEqual(bits, []uint64{ShardWidth + 2}) {
+				t.Fatalf("unexpected columns for %s: %v", q, bits)
+			}
+		}
+
+		// Column attributes are removed from every node.
+		for _, m := range c {
+			for _, col := range cols {
+				if attrs, err := m.Server.Holder().Index("i").ColumnAttrStore().Attrs(col); err != nil {
+					t.Fatal(err)
+				} else if len(attrs) != 0 {
+					t.Fatalf("unexpected attrs on column %d: %v", col, attrs)
+				}
+			}
+		}
+	})
+
+	t.Run("Keys", func(t *testing.T) {
+		c := test.MustRunCluster(t, 3)
+		defer c.Close()
+		c.CreateField(t, "k", pilosa.IndexOptions{Keys: true, TrackExistence: true}, "f")
+		c.Query(t, "k", `Set("a", f=1) Set("b", f=1) Set("c", f=2)`)
+		ids, err := c[0].Server.Holder().Index("k").TranslateStore().TranslateKeys([]string{"a", "c"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if res := c.Query(t, "k", `Delete(columns=["a", "c"], keys=true)`).Results[0].(bool); !res {
+			t.Fatalf("unexpected delete result: %v", res)
+		}
+		if keys := c.Query(t, "k", `Row(f=1)`).Results[0].(*pilosa.Row).Keys; !reflect.DeepEqual(keys, []string{"b"}) {
+			t.Fatalf("unexpected keys: %v", keys)
+		}
+
+		// Deleted keys no longer translate on any node.
+		for _, m := range c {
+			if keys, err := m.Server.Holder().Index("k").TranslateStore().TranslateIDs(ids); err != nil {
+				t.Fatal(err)
+			} else if !reflect.DeepEqual(keys, []string{"", ""}) {
+				t.Fatalf("unexpected keys: %v", keys)
+			}
+		}
+
+		// Unknown keys are ignored on any node, without being created.
+		for _, m := range c {
+			if res, err := m.API.Query(context.Background(), &pilosa.QueryRequest{Index: "k", Query: `Delete(columns=["b", "z"])`}); err != nil {
+				t.Fatal(err)
+			} else if len(res.Results) != 1 {
+				t.Fatalf("unexpected results: %v", res.Results)
+			}
+			if ids, err := m.Server.Holder().Index("k").TranslateStore().FindKeys([]string{"z"}); err != nil {
+				t.Fatal(err)
+			} else if ids[0] != 0 {
+				t.Fatalf("unexpected id for unknown key: %d", ids[0])
+			}
+		}
+		if cols := c.Query(t, "k", `Row(f=1)`).Results[0].(*pilosa.Row).Columns(); len(cols) != 0 {
+			t.Fatalf("unexpected columns: %v", cols)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		c := test.MustRunCluster(t, 1)
+		defer c.Close()
+		c.CreateField(t, "i", pilosa.IndexOptions{}, "f")
+		for _, q := range []string{`Delete()`, `Delete(Row(f=1), Row(f=2))`, `Delete(Row(f=1), columns=[1])`} {
+			if _, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: q}); err == nil {
+				t.Fatalf("expected error for %s", q)
+			}
+		}
+	})
+}
+
 // Ensure a row can be set.
 func TestExecutor_Execute_SetRow(t *testing.T) {
 	t.Run("Set_NewRow", func(t *testing.T) {
@@ -3247,6 +3428,344 @@ func TestExecutor_Execute_SetRow(t *testing.T) {
 	})
 }
 
+// Ensure SetValue() and Increment() queries rewrite int values in bulk on
+// every replica.
+func TestExecutor_Execute_UpdateValues(t *testing.T) {
+	c := test.MustRunCluster(t, 3, []server.CommandOption{
+		server.OptCommandServerOptions(pilosa.OptServerReplicaN(2)),
+	})
+	defer c.Close()
+	c.CreateField(t, "i", pilosa.IndexOptions{}, "f")
+	c.CreateField(t, "i", pilosa.IndexOptions{}, "v", pilosa.OptFieldTypeInt(-100, 1000))
+	c.Query(t, "i", fmt.Sprintf(`
+		Set(1, f=1) Set(%d, f=1) Set(%d, f=1) Set(3, f=2)
+		Set(1, v=5) Set(%d, v=-3) Set(3, v=7)`,
+		ShardWidth+1, 2*ShardWidth+1, ShardWidth+1))
+
+	t.Run("Increment", func(t *testing.T) {
+		if res := c.Query(t, "i", `Increment(Row(f=1), field=v, by=10)`).Results[0].(bool); !res {
+			t.Fatalf("unexpected result: %v", res)
+		}
+		// Columns without a value are not incremented.
+		if vc := c.Query(t, "i", `Sum(Row(f=1), field=v)`).Results[0].(pilosa.ValCount); vc != (pilosa.ValCount{Val: 22, Count: 2}) {
+			t.Fatalf("unexpected sum: %+v", vc)
+		}
+		if vc := c.Query(t, "i", `Sum(Row(f=2), field=v)`).Results[0].(pilosa.ValCount); vc != (pilosa.ValCount{Val: 7, Count: 1}) {
+			t.Fatalf("unexpected sum: %+v", vc)
+		}
+	})
+
+	t.Run("SetValue", func(t *testing.T) {
+		if res := c.Query(t, "i", `SetValue(Row(f=1), field=v, value=600)`).Results[0].(bool); !res {
+			t.Fatalf("unexpected result: %v", res)
+		}
+		if vc := c.Query(t, "i", `Sum(Row(f=1), field=v)`).Results[0].(pilosa.ValCount); vc != (pilosa.ValCount{Val: 1800, Count: 3}) {
+			t.Fatalf("unexpected sum: %+v", vc)
+		}
+		if bits := c.Query(t, "i", `Row(v == 600)`).Results[0].(*pilosa.Row).Columns(); !reflect.DeepEqual(bits, []uint64{1, ShardWidth + 1, 2*ShardWidth + 1}) {
+			t.Fatalf("unexpected columns: %v", bits)
+		}
+
+		// Setting the same value again changes nothing.
+		if res := c.Query(t, "i", `SetValue(Row(f=1), field=v, value=600)`).Results[0].(bool); res {
+			t.Fatalf("unexpected result: %v", res)
+		}
+	})
+
+	t.Run("Replicas", func(t *testing.T) {
+		for _, col := range []uint64{1, ShardWidth + 1, 2*ShardWidth + 1} {
+			nodes, err := c[0].API.ShardNodes(context.Background(), "i", col/ShardWidth)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, m := range c {
+				if !pilosa.Nodes(nodes).ContainsID(m.API.Node().ID) {
+					continue
+				}
+				if v, exists, err := m.Server.Holder().Field("i", "v").Value(col); err != nil {
+					t.Fatal(err)
+				} else if !exists || v != 600 {
+					t.Fatalf("unexpected value for column %d on node %s: %d, %v", col, m.API.Node().ID, v, exists)
+				}
+			}
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		for q, msg := range map[string]string{
+			`Increment(Row(f=1), field=v, by=1000)`: pilosa.ErrBSIGroupValueTooHigh.Error(),
+			`SetValue(Row(f=1), field=f, value=1)`:  "not supported on set field types",
+			`SetValue(Row(f=1), field=v)`:           "argument required: value",
+			`Increment(field=v, by=1)`:              "requires a single row argument",
+		} {
+			if _, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: q}); err == nil || !strings.Contains(err.Error(), msg) {
+				t.Fatalf("unexpected error for %s: %v", q, err)
+			}
+		}
+	})
+}
+
+// Ensure Add() and CompareAndSet() queries update a value atomically on every
+// replica.
+func TestExecutor_Execute_UpdateValue(t *testing.T) {
+	c := test.MustRunCluster(t, 3, []server.CommandOption{
+		server.OptCommandServerOptions(pilosa.OptServerReplicaN(2)),
+	})
+	defer c.Close()
+	c.CreateField(t, "i", pilosa.IndexOptions{}, "n", pilosa.OptFieldTypeInt(-10, 1000))
+
+	// replicaValues returns the value of col on each node which owns it.
+	replicaValues := func(col uint64) []int64 {
+		nodes, err := c[0].API.ShardNodes(context.Background(), "i", col/ShardWidth)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var values []int64
+		for _, m := range c {
+			for _, node := range nodes {
+				if node.ID != m.API.Node().ID {
+					continue
+				}
+				v, _, err := m.Server.Holder().Field("i", "n").Value(col)
+				if err != nil {
+					t.Fatal(err)
+				}
+				values = append(values, v)
+			}
+		}
+		return values
+	}
+
+	col := uint64(2*ShardWidth + 1)
+	t.Run("Add", func(t *testing.T) {
+		// A column without a value is treated as zero.
+		for i, exp := range []int64{5, 10, 7} {
+			by := []int{5, 5, -3}[i]
+			vc := c.Query(t, "i", fmt.Sprintf(`Add(col=%d, field=n, by=%d)`, col, by)).Results[0].(pilosa.ValCount)
+			if vc != (pilosa.ValCount{Val: exp, Count: 1}) {
+				t.Fatalf("unexpected result: %+v", vc)
+			}
+		}
+		if values := replicaValues(col); !reflect.DeepEqual(values, []int64{7, 7}) {
+			t.Fatalf("unexpected replica values: %v", values)
+		}
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(m *test.Command) {
+				defer wg.Done()
+				q := &pilosa.QueryRequest{Index: "i", Query: fmt.Sprintf(`Add(col=%d, field=n, by=1)`, col)}
+				if _, err := m.API.Query(context.Background(), q); err != nil {
+					t.Error(err)
+				}
+			}(c[i%len(c)])
+		}
+		wg.Wait()
+		if values := replicaValues(col); !reflect.DeepEqual(values, []int64{27, 27}) {
+			t.Fatalf("unexpected replica values: %v", values)
+		}
+	})
+
+	t.Run("CompareAndSet", func(t *testing.T) {
+		if vc := c.Query(t, "i", fmt.Sprintf(`CompareAndSet(col=%d, field=n, expect=3, value=4)`, col)).Results[0].(pilosa.ValCount); vc != (pilosa.ValCount{Val: 27, Count: 0}) {
+			t.Fatalf("unexpected result: %+v", vc)
+		}
+		if vc := c.Query(t, "i", fmt.Sprintf(`CompareAndSet(col=%d, field=n, expect=27, value=4)`, col)).Results[0].(pilosa.ValCount); vc != (pilosa.ValCount{Val: 4, Count: 1}) {
+			t.Fatalf("unexpected result: %+v", vc)
+		}
+		if values := replicaValues(col); !reflect.DeepEqual(values, []int64{4, 4}) {
+			t.Fatalf("unexpected replica values: %v", values)
+		}
+
+		// A null expect only matches a column without a value.
+		if vc := c.Query(t, "i", fmt.Sprintf(`CompareAndSet(col=%d, field=n, expect=null, value=1)`, col)).Results[0].(pilosa.ValCount); vc.Count != 0 {
+			t.Fatalf("unexpected result: %+v", vc)
+		}
+		if vc := c.Query(t, "i", `CompareAndSet(col=1, field=n, expect=null, value=1)`).Results[0].(pilosa.ValCount); vc != (pilosa.ValCount{Val: 1, Count: 1}) {
+			t.Fatalf("unexpected result: %+v", vc)
+		}
+	})
+
+	t.Run("Base", func(t *testing.T) {
+		// A column without a value is zero, not the field's base value.
+		c.CreateField(t, "i", pilosa.IndexOptions{}, "m", pilosa.OptFieldTypeInt(100, 1000))
+		if vc := c.Query(t, "i", `Add(5, field=m, by=105)`).Results[0].(pilosa.ValCount); vc != (pilosa.ValCount{Val: 105, Count: 1}) {
+			t.Fatalf("unexpected result: %+v", vc)
+		}
+		if vc := c.Query(t, "i", `CompareAndSet(6, field=m, expect=200, value=300)`).Results[0].(pilosa.ValCount); vc != (pilosa.ValCount{Val: 0, Count: 0}) {
+			t.Fatalf("unexpected result: %+v", vc)
+		}
+		if vc := c.Query(t, "i", `CompareAndSet(5, field=m, expect=105, value=300)`).Results[0].(pilosa.ValCount); vc != (pilosa.ValCount{Val: 300, Count: 1}) {
+			t.Fatalf("unexpected result: %+v", vc)
+		}
+	})
+
+	t.Run("Keys", func(t *testing.T) {
+		c.CreateField(t, "k", pilosa.IndexOptions{Keys: true}, "n", pilosa.OptFieldTypeInt(-10, 1000))
+		for i, q := range []string{`Add("a", field=n, by=2)`, `Add(col="a", field=n, by=3)`} {
+			if vc := c.Query(t, "k", q).Results[0].(pilosa.ValCount); vc != (pilosa.ValCount{Val: int64(2 + 3*i), Count: 1}) {
+				t.Fatalf("unexpected result for %s: %+v", q, vc)
+			}
+		}
+		if vc := c.Query(t, "k", `CompareAndSet("a", field=n, expect=5, value=6)`).Results[0].(pilosa.ValCount); vc != (pilosa.ValCount{Val: 6, Count: 1}) {
+			t.Fatalf("unexpected result: %+v", vc)
+		}
+		if vc := c.Query(t, "k", `Sum(field=n)`).Results[0].(pilosa.ValCount); vc != (pilosa.ValCount{Val: 6, Count: 1}) {
+			t.Fatalf("unexpected sum: %+v", vc)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		for q, msg := range map[string]string{
+			fmt.Sprintf(`Add(col=%d, field=n, by=1000)`, col): pilosa.ErrBSIGroupValueTooHigh.Error(),
+			`Add(field=n, by=1)`:                              "argument required: col",
+			`CompareAndSet(col=1, field=n, value=1)`:          "argument required: expect",
+		} {
+			if _, err := c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: q}); err == nil || !strings.Contains(err.Error(), msg) {
+				t.Fatalf("unexpected error for %s: %v", q, err)
+			}
+		}
+	})
+}
+
+func TestExecutor_Execute_Atomic(t *testing.T) {
+	c := test.MustRunCluster(t, 3, []server.CommandOption{
+		server.OptCommandServerOptions(pilosa.OptServerReplicaN(2)),
+	})
+	defer c.Close()
+	c.CreateField(t, "i", pilosa.IndexOptions{}, "a")
+	c.CreateField(t, "i", pilosa.IndexOptions{}, "b", pilosa.OptFieldTypeMutex(pilosa.CacheTypeRanked, 100))
+	c.CreateField(t, "i", pilosa.IndexOptions{}, "v", pilosa.OptFieldTypeInt(-10, 100))
+	c.CreateField(t, "i", pilosa.IndexOptions{}, "x")
+
+	atomicQuery := func(query string) (pilosa.QueryResponse, error) {
+		return c[0].API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: query, Atomic: true})
+	}
+
+	// owners returns the nodes which own col's shard, primary first.
+	owners := func(t *testing.T, col uint64) []*test.Command {
+		nodes, err := c[0].API.ShardNodes(context.Background(), "i", col/ShardWidth)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var cmds []*test.Command
+		for _, node := range nodes {
+			for _, m := range c {
+				if node.ID == m.API.Node().ID {
+					cmds = append(cmds, m)
+				}
+			}
+		}
+		return cmds
+	}
+
+	// replicaHasBit returns whether each owner of col, primary first, has
+	// it set in the field's row.
+	replicaHasBit := func(t *testing.T, field string, rowID, col uint64) []bool {
+		var ret []bool
+		for _, m := range owners(t, col) {
+			resp, err := m.API.Query(context.Background(), &pilosa.QueryRequest{
+				Index:  "i",
+				Query:  fmt.Sprintf("Row(%s=%d)", field, rowID),
+				Shards: []uint64{col / ShardWidth},
+				Remote: true,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			found := false
+			for _, v := range resp.Results[0].(*pilosa.Row).Columns() {
+				if v == col {
+					found = true
+				}
+			}
+			ret = append(ret, found)
+		}
+		return ret
+	}
+
+	t.Run("Apply", func(t *testing.T) {
+		col := uint64(ShardWidth + 1)
+		resp, err := atomicQuery(fmt.Sprintf(`Set(%d, a=1) Set(%d, b=2) Set(%d, v=-5) Set(%d, b=3) Set(%d, a=1) Clear(%d, a=9)`, col, col, col, col, 3*ShardWidth+2, col))
+		if err != nil {
+			t.Fatal(err)
+		} else if !reflect.DeepEqual(resp.Results, []interface{}{true, true, true, true, true, false}) {
+			t.Fatalf("unexpected results: %v", resp.Results)
+		}
+
+		if got := replicaHasBit(t, "a", 1, col); !reflect.DeepEqual(got, []bool{true, true}) {
+			t.Fatalf("unexpected a=1: %v", got)
+		} else if got := replicaHasBit(t, "a", 1, 3*ShardWidth+2); !reflect.DeepEqual(got, []bool{true, true}) {
+			t.Fatalf("unexpected a=1 in shard 3: %v", got)
+		} else if got := replicaHasBit(t, "b", 2, col); !reflect.DeepEqual(got, []bool{false, false}) {
+			t.Fatalf("unexpected b=2: %v", got)
+		} else if got := replicaHasBit(t, "b", 3, col); !reflect.DeepEqual(got, []bool{true, true}) {
+			t.Fatalf("unexpected b=3: %v", got)
+		}
+		for _, m := range owners(t, col) {
+			if v, ok, err := m.Server.Holder().Field("i", "v").Value(col); err != nil {
+				t.Fatal(err)
+			} else if !ok || v != -5 {
+				t.Fatalf("unexpected value: %d, %v", v, ok)
+			}
+		}
+
+		// Rewriting the same data changes nothing.
+		resp, err = atomicQuery(fmt.Sprintf(`Set(%d, a=1) Set(%d, v=-5)`, col, col))
+		if err != nil {
+			t.Fatal(err)
+		} else if !reflect.DeepEqual(resp.Results, []interface{}{false, false}) {
+			t.Fatalf("unexpected results: %v", resp.Results)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		col := uint64(2*ShardWidth + 3)
+		if _, err := atomicQuery(fmt.Sprintf(`Set(%d, a=4) Set(%d, v=1000)`, col, col)); errors.Cause(err) != pilosa.ErrBSIGroupValueTooHigh {
+			t.Fatalf("unexpected error: %v", err)
+		} else if _, err := atomicQuery(fmt.Sprintf(`Set(%d, a=4) Set(%d, nope=1)`, col, col)); err == nil || !strings.Contains(err.Error(), "field not found") {
+			t.Fatalf("unexpected error: %v", err)
+		} else if _, err := atomicQuery(fmt.Sprintf(`Set(%d, a=4) Row(a=4)`, col)); errors.Cause(err) != pilosa.ErrAtomicCallNotAllowed {
+			t.Fatalf("unexpected error: %v", err)
+		} else if _, err := atomicQuery(fmt.Sprintf(`Set(%d, a=4) Clear(%d, v=1)`, col, col)); err == nil {
+			t.Fatal("expected error clearing int field")
+		}
+		if got := replicaHasBit(t, "a", 4, col); !reflect.DeepEqual(got, []bool{false, false}) {
+			t.Fatalf("unexpected a=4: %v", got)
+		}
+	})
+
+	t.Run("Rollback", func(t *testing.T) {
+		col := uint64(4*ShardWidth + 5)
+		if _, err := atomicQuery(fmt.Sprintf(`Set(%d, a=5) Set(%d, b=1)`, col, col)); err != nil {
+			t.Fatal(err)
+		}
+
+		// Drop a field from the second owner only, so that the batch fails
+		// there after the primary has staged it.
+		if err := owners(t, col)[1].Server.Holder().Index("i").DeleteField("x"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := atomicQuery(fmt.Sprintf(`Set(%d, a=6) Clear(%d, a=5) Set(%d, b=2) Set(%d, x=1)`, col, col, col, col)); err == nil || !strings.Contains(err.Error(), "field not found") {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// Nothing was written on the primary.
+		for _, tt := range []struct {
+			field string
+			row   uint64
+			exp   bool
+		}{{"a", 5, true}, {"a", 6, false}, {"b", 1, true}, {"b", 2, false}} {
+			if got := replicaHasBit(t, tt.field, tt.row, col); got[0] != tt.exp {
+				t.Fatalf("%s=%d: expected %v on primary, got %v", tt.field, tt.row, tt.exp, got[0])
+			}
+		}
+	})
+}
+
 func benchmarkExistence(nn bool, b *testing.B) {
 	c := test.MustNewCluster(b, 1)
 	var err error
@@ -0,0 +1,718 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pilosa
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pilosa/pilosa/v2/encoding/parquet"
+	"github.com/pilosa/pilosa/v2/tracing"
+	"github.com/pkg/errors"
+)
+
+// ExportFormat is the encoding of an index export.
+type ExportFormat string
+
+// Export formats.
+const (
+	// CSV with a header row. A column with several rows in a field spans
+	// several lines, the first of which holds its single-valued fields.
+	ExportFormatCSV ExportFormat = "csv"
+
+	// One JSON object per column.
+	ExportFormatNDJSON ExportFormat = "ndjson"
+
+	// Parquet, with a row group per shard.
+	ExportFormatParquet ExportFormat = "parquet"
+)
+
+// exportIDColumn is the name of the exported column holding column IDs or
+// keys. Field names can't begin with an underscore, so it can't clash.
+const exportIDColumn = "_id"
+
+// exportTimestampSuffix is appended to the name of a time field to name the
+// exported column holding the timestamps of its rows.
+const exportTimestampSuffix = "_timestamp"
+
+// ExportColumn holds the values of a column in each field of an index, before
+// keys are translated.
+type ExportColumn struct {
+	ID uint64 `json:"id"`
+
+	// Rows by field, for set, mutex, bool and time fields.
+	Rows map[string][]uint64 `json:"rows,omitempty"`
+
+	// Timestamps in nanoseconds of the rows of time fields, or zero for rows
+	// which were set without a timestamp.
+	Timestamps map[string][]int64 `json:"timestamps,omitempty"`
+
+	// Values by field, for int fields.
+	Values map[string]int64 `json:"values,omitempty"`
+}
+
+// ExportShard returns, in order, the columns of a shard which exist or have a
+// value in any field of the index.
+func (api *API) ExportShard(ctx context.Context, indexName string, shard uint64) ([]ExportColumn, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.ExportShard")
+	defer span.Finish()
+
+	if err := api.validate(apiExportShard); err != nil {
+		return nil, errors.Wrap(err, "validating api method")
+	}
+
+	// Validate that this handler owns the shard.
+	if !api.cluster.ownsShard(api.Node().ID, indexName, shard) {
+		api.server.logger.Printf("node %s does not own shard %d of index %s", api.Node().ID, shard, indexName)
+		return nil, ErrClusterDoesNotOwnShard
+	}
+
+	index := api.holder.Index(indexName)
+	if index == nil {
+		return nil, newNotFoundError(ErrIndexNotFound, indexName)
+	}
+	return exportShard(index, shard)
+}
+
+// ExportIndex writes every column of an index, with its values in each field
+// and keys translated, to w. Each shard is read from a node which owns it.
+func (api *API) ExportIndex(ctx context.Context, indexName string, format ExportFormat, w io.Writer) error {
+	span, ctx := tracing.StartSpanFromContext(ctx, "API.ExportIndex")
+	defer span.Finish()
+
+	if err := api.validate(apiExportIndex); err != nil {
+		return errors.Wrap(err, "validating api method")
+	}
+
+	index := api.holder.Index(indexName)
+	if index == nil {
+		return newNotFoundError(ErrIndexNotFound, indexName)
+	}
+
+	table := newExportTable(index)
+	var ew exportWriter
+	switch format {
+	case ExportFormatCSV:
+		ew = newCSVExportWriter(w, table)
+	case ExportFormatNDJSON:
+		ew = newNDJSONExportWriter(w, table)
+	case ExportFormatParquet:
+		pw, err := parquet.NewWriter(w, table.parquetColumns())
+		if err != nil {
+			return errors.Wrap(err, "creating parquet writer")
+		}
+		ew = &parquetExportWriter{w: pw}
+	default:
+		return NewBadRequestError(errors.Errorf("unknown export format: %q", format))
+	}
+
+	var n int
+	for _, shard := range index.AvailableShards().Slice() {
+		columns, err := api.exportShardFromOwner(ctx, indexName, shard)
+		if err != nil {
+			return errors.Wrapf(err, "exporting shard %d", shard)
+		}
+		records, err := table.records(columns)
+		if err != nil {
+			return errors.Wrapf(err, "translating shard %d", shard)
+		}
+		if err := ew.writeShard(records); err != nil {
+			return errors.Wrapf(err, "writing shard %d", shard)
+		}
+		n += len(records)
+	}
+	span.LogKV("n", n)
+
+	return errors.Wrap(ew.close(), "closing export")
+}
+
+// exportShardFromOwner exports a shard locally if this node owns it, and
+// otherwise from the first owner to respond, in random order.
+func (api *API) exportShardFromOwner(ctx context.Context, indexName string, shard uint64) ([]ExportColumn, error) {
+	nodes := api.cluster.shardNodes(indexName, shard)
+	for _, node := range nodes {
+		if node.ID == api.Node().ID {
+			index := api.holder.Index(indexName)
+			if index == nil {
+				return nil, newNotFoundError(ErrIndexNotFound, indexName)
+			}
+			return exportShard(index, shard)
+		}
+	}
+
+	err := errors.New("no nodes own shard")
+	for _, i := range rand.Perm(len(nodes)) {
+		var columns []ExportColumn
+		if columns, err = api.server.defaultClient.ExportShard(ctx, &nodes[i].URI, indexName, shard); err == nil {
+			return columns, nil
+		}
+		api.server.logger.Printf("exporting shard %d of index %s from node %s: %v", shard, indexName, nodes[i].ID, err)
+	}
+	return nil, err
+}
+
+// exportShard reads the columns of a shard from the local fragments of each
+// field of an index.
+func exportShard(index *Index, shard uint64) ([]ExportColumn, error) {
+	columns := make(map[uint64]*ExportColumn)
+	column := func(id uint64) *ExportColumn {
+		c, ok := columns[id]
+		if !ok {
+			c = &ExportColumn{ID: id}
+			columns[id] = c
+		}
+		return c
+	}
+
+	// Columns which exist are exported even if they have no values.
+	if f := index.existenceField(); f != nil {
+		if frag := exportFragment(f, viewStandard, shard); frag != nil {
+			if err := frag.forEachBit(func(_, columnID uint64) error {
+				column(columnID)
+				return nil
+			}); err != nil {
+				return nil, errors.Wrap(err, "reading existence")
+			}
+		}
+	}
+
+	for _, f := range index.Fields() {
+		var err error
+		switch {
+		case f.Name() == existenceFieldName:
+			continue
+		case f.Type() == FieldTypeInt:
+			err = exportValues(f, shard, column)
+		case f.Type() == FieldTypeTime && f.TimeQuantum() != "":
+			err = exportTimes(f, shard, column)
+		default:
+			err = exportRows(f, shard, column)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading field %s", f.Name())
+		}
+	}
+
+	a := make([]ExportColumn, 0, len(columns))
+	for _, c := range columns {
+		a = append(a, *c)
+	}
+	sort.Slice(a, func(i, j int) bool { return a[i].ID < a[j].ID })
+	return a, nil
+}
+
+// exportFragment returns the fragment of a field's view, if it exists.
+func exportFragment(f *Field, view string, shard uint64) *fragment {
+	if v := f.view(view); v != nil {
+		return v.Fragment(shard)
+	}
+	return nil
+}
+
+// exportRows reads the rows of a field's standard view.
+func exportRows(f *Field, shard uint64, column func(uint64) *ExportColumn) error {
+	frag := exportFragment(f, viewStandard, shard)
+	if frag == nil {
+		return nil
+	}
+	return frag.forEachBit(func(rowID, columnID uint64) error {
+		c := column(columnID)
+		if c.Rows == nil {
+			c.Rows = make(map[string][]uint64)
+		}
+		c.Rows[f.Name()] = append(c.Rows[f.Name()], rowID)
+		return nil
+	})
+}
+
+// exportValues reads the values of an int field.
+func exportValues(f *Field, shard uint64, column func(uint64) *ExportColumn) error {
+	values, err := intValues(f, shard)
+	if err != nil {
+		return err
+	}
+	for _, v := range values {
+		c := column(v.columnID)
+		if c.Values == nil {
+			c.Values = make(map[string]int64)
+		}
+		c.Values[f.Name()] = v.value
+	}
+	return nil
+}
+
+// intValue is the value of an int field in a column.
+type intValue struct {
+	columnID uint64
+	value    int64
+}
+
+// intValues returns the values of a shard of an int field, ordered by
+// column, decoding every column's value in one pass over the bits of the
+// fragment.
+func intValues(f *Field, shard uint64) ([]intValue, error) {
+	bsig := f.bsiGroup(f.Name())
+	if bsig == nil {
+		return nil, ErrBSIGroupNotFound
+	}
+	frag := exportFragment(f, ViewBSIGroupPrefix+f.Name(), shard)
+	if frag == nil {
+		return nil, nil
+	}
+
+	type bsiValue struct {
+		exists, negative bool
+		magnitude        uint64
+	}
+	bsiValues := make(map[uint64]*bsiValue)
+	if err := frag.forEachBit(func(rowID, columnID uint64) error {
+		v, ok := bsiValues[columnID]
+		if !ok {
+			v = &bsiValue{}
+			bsiValues[columnID] = v
+		}
+		switch rowID {
+		case bsiExistsBit:
+			v.exists = true
+		case bsiSignBit:
+			v.negative = true
+		default:
+			v.magnitude |= 1 << (rowID - BSIOffsetBit)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	values := make([]intValue, 0, len(bsiValues))
+	for columnID, v := range bsiValues {
+		if !v.exists {
+			continue
+		}
+		value := int64(v.magnitude)
+		if v.negative {
+			value = -value
+		}
+		values = append(values, intValue{columnID: columnID, value: value + bsig.Base})
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i].columnID < values[j].columnID })
+	return values, nil
+}
+
+// exportTimes reads the rows of a time field with their timestamps.
+func exportTimes(f *Field, shard uint64, column func(uint64) *ExportColumn) error {
+	bits, err := timeBits(f, shard)
+	if err != nil {
+		return err
+	}
+	for _, bit := range bits {
+		c := column(bit.columnID)
+		if c.Rows == nil {
+			c.Rows = make(map[string][]uint64)
+		}
+		if c.Timestamps == nil {
+			c.Timestamps = make(map[string][]int64)
+		}
+		c.Rows[f.Name()] = append(c.Rows[f.Name()], bit.rowID)
+		c.Timestamps[f.Name()] = append(c.Timestamps[f.Name()], bit.timestamp)
+	}
+	return nil
+}
+
+// timeBit is a bit of a time field, with the timestamp in nanoseconds it was
+// set with, or zero if it was set without one.
+type timeBit struct {
+	rowID, columnID uint64
+	timestamp       int64
+}
+
+// timeBits returns the bits of a shard of a time field, ordered by row,
+// column and timestamp. Bits set with a timestamp are in every view of the
+// field's quantum, so timestamps are read from the views of its finest unit.
+// Bits which are only in the standard view were set without a timestamp.
+func timeBits(f *Field, shard uint64) ([]timeBit, error) {
+	q := f.TimeQuantum()
+	unit := rune(q[len(q)-1])
+	finest := len(viewTimePart(viewByTimeUnit(viewStandard, time.Time{}, unit)))
+
+	var bits []timeBit
+	timed := make(map[[2]uint64]struct{})
+	for _, v := range f.views() {
+		if v.name == viewStandard || len(v.name) != len(viewStandard)+1+finest {
+			continue
+		}
+		t, err := timeOfView(v.name, false)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing view %s", v.name)
+		}
+		frag := v.Fragment(shard)
+		if frag == nil {
+			continue
+		}
+		if err := frag.forEachBit(func(rowID, columnID uint64) error {
+			bits = append(bits, timeBit{rowID, columnID, t.UnixNano()})
+			timed[[2]uint64{rowID, columnID}] = struct{}{}
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+	if frag := exportFragment(f, viewStandard, shard); frag != nil {
+		if err := frag.forEachBit(func(rowID, columnID uint64) error {
+			if _, ok := timed[[2]uint64{rowID, columnID}]; !ok {
+				bits = append(bits, timeBit{rowID, columnID, 0})
+			}
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Slice(bits, func(i, j int) bool {
+		if bits[i].rowID != bits[j].rowID {
+			return bits[i].rowID < bits[j].rowID
+		} else if bits[i].columnID != bits[j].columnID {
+			return bits[i].columnID < bits[j].columnID
+		}
+		return bits[i].timestamp < bits[j].timestamp
+	})
+	return bits, nil
+}
+
+// exportTable describes the columns of an index export, other than the
+// column holding IDs or keys.
+type exportTable struct {
+	index   *Index
+	columns []exportTableColumn
+}
+
+// exportTableColumn is an exported column, holding the values of a field or
+// the timestamps of a time field.
+type exportTableColumn struct {
+	name       string
+	field      *Field
+	timestamps bool
+}
+
+// newExportTable returns the columns exported from each field of an index.
+func newExportTable(index *Index) *exportTable {
+	t := &exportTable{index: index}
+	for _, f := range index.Fields() {
+		if f.Name() == existenceFieldName {
+			continue
+		}
+		t.columns = append(t.columns, exportTableColumn{name: f.Name(), field: f})
+		if f.Type() == FieldTypeTime && f.TimeQuantum() != "" {
+			t.columns = append(t.columns, exportTableColumn{name: f.Name() + exportTimestampSuffix, field: f, timestamps: true})
+		}
+	}
+	return t
+}
+
+// exportRecord is an exported column with its keys translated. The ID is a
+// uint64 or a string key. Values are indexed like exportTable.columns and
+// are nil if a column has no value, or else:
+//
+//   - set and time fields: a []interface{} of uint64 row IDs or string keys
+//   - mutex fields: a row ID or key
+//   - bool fields: a bool
+//   - int fields: an int64
+//   - timestamps: a []interface{} of time.Time, or nil for none
+type exportRecord struct {
+	id     interface{}
+	values []interface{}
+}
+
+// records translates the keys of exported columns.
+func (t *exportTable) records(columns []ExportColumn) ([]exportRecord, error) {
+	records := make([]exportRecord, len(columns))
+	if t.index.Keys() {
+		ids := make([]uint64, len(columns))
+		for i, c := range columns {
+			ids[i] = c.ID
+		}
+		keys, err := t.index.translateStore.TranslateIDs(ids)
+		if err != nil {
+			return nil, errors.Wrap(err, "translating columns")
+		}
+		for i := range records {
+			records[i].id = keys[i]
+		}
+	} else {
+		for i, c := range columns {
+			records[i].id = c.ID
+		}
+	}
+
+	for i := range records {
+		records[i].values = make([]interface{}, len(t.columns))
+	}
+	for j, tc := range t.columns {
+		f := tc.field
+		name := f.Name()
+
+		// Translate the keys of every row of the field at once.
+		var rowKeys map[uint64]string
+		if f.keys() && !tc.timestamps {
+			var ids []uint64
+			seen := make(map[uint64]struct{})
+			for _, c := range columns {
+				for _, id := range c.Rows[name] {
+					if _, ok := seen[id]; !ok {
+						seen[id] = struct{}{}
+						ids = append(ids, id)
+					}
+				}
+			}
+			keys, err := f.translateStore.TranslateIDs(ids)
+			if err != nil {
+				return nil, errors.Wrapf(err, "translating rows of field %s", name)
+			}
+			rowKeys = make(map[uint64]string, len(ids))
+			for i, id := range ids {
+				rowKeys[id] = keys[i]
+			}
+		}
+		row := func(id uint64) interface{} {
+			if rowKeys != nil {
+				return rowKeys[id]
+			}
+			return id
+		}
+
+		for i, c := range columns {
+			switch {
+			case tc.timestamps:
+				if timestamps := c.Timestamps[name]; len(timestamps) > 0 {
+					a := make([]interface{}, len(timestamps))
+					for k, ts := range timestamps {
+						if ts != 0 {
+							a[k] = time.Unix(0, ts).UTC()
+						}
+					}
+					records[i].values[j] = a
+				}
+			case f.Type() == FieldTypeInt:
+				if v, ok := c.Values[name]; ok {
+					records[i].values[j] = v
+				}
+			case f.Type() == FieldTypeBool:
+				if rows := c.Rows[name]; len(rows) > 0 {
+					records[i].values[j] = rows[0] == trueRowID
+				}
+			case f.Type() == FieldTypeMutex:
+				if rows := c.Rows[name]; len(rows) > 0 {
+					records[i].values[j] = row(rows[0])
+				}
+			default:
+				if rows := c.Rows[name]; len(rows) > 0 {
+					a := make([]interface{}, len(rows))
+					for k, id := range rows {
+						a[k] = row(id)
+					}
+					records[i].values[j] = a
+				}
+			}
+		}
+	}
+	return records, nil
+}
+
+// parquetColumns returns the Parquet schema of the table. Set and time
+// fields are repeated columns; as repeated values can't be null, timestamps
+// of rows set without one are written as the Unix epoch.
+func (t *exportTable) parquetColumns() []parquet.Column {
+	keyType := func(keys bool) parquet.Type {
+		if keys {
+			return parquet.String
+		}
+		return parquet.Uint64
+	}
+
+	columns := []parquet.Column{{Name: exportIDColumn, Type: keyType(t.index.Keys()), Repetition: parquet.Required}}
+	for _, tc := range t.columns {
+		col := parquet.Column{Name: tc.name, Type: keyType(tc.field.keys()), Repetition: parquet.Optional}
+		switch {
+		case tc.timestamps:
+			col.Type, col.Repetition = parquet.Timestamp, parquet.Repeated
+		case tc.field.Type() == FieldTypeInt:
+			col.Type = parquet.Int64
+		case tc.field.Type() == FieldTypeBool:
+			col.Type = parquet.Bool
+		case tc.field.Type() == FieldTypeSet, tc.field.Type() == FieldTypeTime:
+			col.Repetition = parquet.Repeated
+		}
+		columns = append(columns, col)
+	}
+	return columns
+}
+
+// exportWriter writes exported records in some format.
+type exportWriter interface {
+	writeShard(records []exportRecord) error
+	close() error
+}
+
+// csvExportWriter writes records as CSV. Line i of a record holds the i-th
+// value of each multi-valued column, and single values are on its first line.
+type csvExportWriter struct {
+	w      *csv.Writer
+	table  *exportTable
+	header bool
+}
+
+func newCSVExportWriter(w io.Writer, table *exportTable) *csvExportWriter {
+	return &csvExportWriter{w: csv.NewWriter(w), table: table}
+}
+
+func (w *csvExportWriter) writeShard(records []exportRecord) error {
+	if !w.header {
+		header := []string{exportIDColumn}
+		for _, tc := range w.table.columns {
+			header = append(header, tc.name)
+		}
+		if err := w.w.Write(header); err != nil {
+			return err
+		}
+		w.header = true
+	}
+
+	line := make([]string, len(w.table.columns)+1)
+	for _, rec := range records {
+		lines := 1
+		for _, v := range rec.values {
+			if a, ok := v.([]interface{}); ok && len(a) > lines {
+				lines = len(a)
+			}
+		}
+
+		for i := 0; i < lines; i++ {
+			line[0] = exportString(rec.id)
+			for j, v := range rec.values {
+				line[j+1] = ""
+				if a, ok := v.([]interface{}); ok {
+					if i < len(a) {
+						line[j+1] = exportString(a[i])
+					}
+				} else if i == 0 {
+					line[j+1] = exportString(v)
+				}
+			}
+			if err := w.w.Write(line); err != nil {
+				return err
+			}
+		}
+	}
+	w.w.Flush()
+	return w.w.Error()
+}
+
+func (w *csvExportWriter) close() error {
+	// Write the header of an empty export.
+	return w.writeShard(nil)
+}
+
+// ndjsonExportWriter writes a JSON object per record, leaving out columns
+// without a value.
+type ndjsonExportWriter struct {
+	enc   *json.Encoder
+	table *exportTable
+}
+
+func newNDJSONExportWriter(w io.Writer, table *exportTable) *ndjsonExportWriter {
+	return &ndjsonExportWriter{enc: json.NewEncoder(w), table: table}
+}
+
+func (w *ndjsonExportWriter) writeShard(records []exportRecord) error {
+	for _, rec := range records {
+		obj := map[string]interface{}{exportIDColumn: rec.id}
+		for j, v := range rec.values {
+			if v == nil {
+				continue
+			}
+			if a, ok := v.([]interface{}); ok && w.table.columns[j].timestamps {
+				strs := make([]interface{}, len(a))
+				for k, t := range a {
+					if t != nil {
+						strs[k] = exportString(t)
+					}
+				}
+				v = strs
+			}
+			obj[w.table.columns[j].name] = v
+		}
+		if err := w.enc.Encode(obj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *ndjsonExportWriter) close() error { return nil }
+
+// parquetExportWriter writes a row group per shard.
+type parquetExportWriter struct {
+	w *parquet.Writer
+}
+
+func (w *parquetExportWriter) writeShard(records []exportRecord) error {
+	epoch := time.Unix(0, 0).UTC()
+	row := make([]interface{}, 0)
+	for _, rec := range records {
+		row = append(row[:0], rec.id)
+		for _, v := range rec.values {
+			if a, ok := v.([]interface{}); ok {
+				for k, t := range a {
+					if t == nil {
+						a[k] = epoch
+					}
+				}
+			}
+			row = append(row, v)
+		}
+		if err := w.w.WriteRow(row); err != nil {
+			return err
+		}
+	}
+	return w.w.Flush()
+}
+
+func (w *parquetExportWriter) close() error { return w.w.Close() }
+
+// exportString formats an exported value as text.
+func exportString(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.Format(TimeFormat)
+	default:
+		panic("unexpected export value")
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"os"
 	"path/filepath"
 	"sort"
@@ -76,6 +77,9 @@ type Field struct {
 	// Key/ID translation store.
 	translateStore TranslateStore
 
+	// Outcomes of imports made with an idempotency token.
+	importTokens *importTokenStore
+
 	broadcaster broadcaster
 	Stats       stats.StatsClient
 
@@ -90,6 +94,8 @@ type Field struct {
 	logger logger.Logger
 
 	snapshotQueue chan *fragment
+	quarantine    *fragmentQuarantine
+	storageOpt    StorageOptions
 
 	// Instantiates new translation store on open.
 	OpenTranslateStore OpenTranslateStoreFunc
@@ -230,6 +236,8 @@ func newField(path, index, name string, opts FieldOption) (*Field, error) {
 
 		rowAttrStore: nopStore,
 
+		importTokens: newImportTokenStore(filepath.Join(path, "tokens"), defaultImportTokenN),
+
 		broadcaster: NopBroadcaster,
 		Stats:       stats.NopStatsClient,
 
@@ -795,7 +803,7 @@ func (f *Field) RowTime(rowID uint64, time time.Time, quantum string) (*Row, err
 	if view == nil {
 		return nil, errors.Errorf("view with quantum %v not found.", quantum)
 	}
-	return view.row(rowID), nil
+	return view.row(rowID)
 }
 
 // viewPath returns the path to a view in the field.
@@ -825,10 +833,13 @@ func (f *Field) views() []*view {
 }
 
 // recalculateCaches recalculates caches on every view in the field.
-func (f *Field) recalculateCaches() {
+func (f *Field) recalculateCaches() error {
 	for _, view := range f.views() {
-		view.recalculateCaches()
+		if err := view.recalculateCaches(); err != nil {
+			return errors.Wrapf(err, "view %s", view.name)
+		}
 	}
+	return nil
 }
 
 // createViewIfNotExists returns the named view, creating it if necessary.
@@ -882,6 +893,8 @@ func (f *Field) newView(path, name string) *view {
 	view.stats = f.Stats
 	view.broadcaster = f.broadcaster
 	view.snapshotQueue = f.snapshotQueue
+	view.quarantine = f.quarantine
+	view.storageOpt = f.storageOpt
 	return view
 }
 
@@ -920,7 +933,7 @@ func (f *Field) Row(rowID uint64) (*Row, error) {
 	if view == nil {
 		return nil, ErrInvalidView
 	}
-	return view.row(rowID), nil
+	return view.row(rowID)
 }
 
 // SetBit sets a bit on a view within the field.
@@ -1057,7 +1070,7 @@ func (f *Field) Value(columnID uint64) (value int64, exists bool, err error) {
 	}
 
 	// Fetch target view.
-	view := f.view(viewBSIGroupPrefix + f.name)
+	view := f.view(ViewBSIGroupPrefix + f.name)
 	if view == nil {
 		return 0, false, nil
 	}
@@ -1108,7 +1121,7 @@ func (f *Field) SetValue(columnID uint64, value int64) (changed bool, err error)
 	}
 
 	// Fetch target view.
-	view, err := f.createViewIfNotExists(viewBSIGroupPrefix + f.name)
+	view, err := f.createViewIfNotExists(ViewBSIGroupPrefix + f.name)
 	if err != nil {
 		return false, errors.Wrap(err, "creating view")
 	}
@@ -1116,6 +1129,118 @@ func (f *Field) SetValue(columnID uint64, value int64) (changed bool, err error)
 	return view.setValue(columnID, bsig.BitDepth, baseValue)
 }
 
+// SetValues sets the value of every column in filter to value, within a
+// single shard.
+func (f *Field) SetValues(shard uint64, filter *Row, value int64) (changed bool, err error) {
+	return f.updateValues(shard, filter, func(int64, bool) (int64, bool, error) {
+		return value, true, nil
+	})
+}
+
+// IncrementValues adds delta to the value of every column in filter, within
+// a single shard. Columns without a value are left unset.
+func (f *Field) IncrementValues(shard uint64, filter *Row, delta int64) (changed bool, err error) {
+	return f.updateValues(shard, filter, func(value int64, exists bool) (int64, bool, error) {
+		return value + delta, exists, nil
+	})
+}
+
+// AddValue adds delta to a column's value, treating a column without a
+// value as zero, and returns the new value. The read and write happen under
+// the fragment lock so concurrent calls can't lose updates.
+func (f *Field) AddValue(columnID uint64, delta int64) (int64, error) {
+	value, _, err := f.updateValue(columnID, func(value int64, exists bool) (int64, bool, error) {
+		if (delta > 0 && value > math.MaxInt64-delta) || (delta < 0 && value < math.MinInt64-delta) {
+			return 0, false, ErrBSIGroupValueTooHigh
+		}
+		return value + delta, true, nil
+	})
+	return value, err
+}
+
+// CompareAndSetValue sets a column's value to value if its current value is
+// expect, or if expect is nil and the column has no value. It returns the
+// column's value afterwards and whether it was set.
+func (f *Field) CompareAndSetValue(columnID uint64, expect *int64, value int64) (int64, bool, error) {
+	return f.updateValue(columnID, func(current int64, exists bool) (int64, bool, error) {
+		if expect == nil && !exists || expect != nil && exists && *expect == current {
+			return value, true, nil
+		}
+		return current, false, nil
+	})
+}
+
+// updateValue replaces a column's value with the value returned by fn.
+func (f *Field) updateValue(columnID uint64, fn func(value int64, exists bool) (int64, bool, error)) (value int64, ok bool, err error) {
+	bsig := f.bsiGroup(f.name)
+	if bsig == nil {
+		return 0, false, ErrBSIGroupNotFound
+	}
+
+	view, err := f.createViewIfNotExists(ViewBSIGroupPrefix + f.name)
+	if err != nil {
+		return 0, false, errors.Wrap(err, "creating view")
+	}
+	frag, err := view.CreateFragmentIfNotExists(columnID / ShardWidth)
+	if err != nil {
+		return 0, false, errors.Wrap(err, "creating fragment")
+	}
+
+	baseFn := bsig.baseValueFunc(fn)
+	for {
+		bitDepth := bsig.BitDepth
+		value, ok, requiredDepth, err := frag.updateValue(columnID, bitDepth, baseFn)
+		if err != nil {
+			return 0, false, err
+		} else if requiredDepth <= bitDepth {
+			return value + bsig.Base, ok, nil
+		} else if err := f.growBitDepth(bsig, requiredDepth); err != nil {
+			return 0, false, err
+		}
+	}
+}
+
+// updateValues rewrites the values of the columns in filter, within a single
+// shard, to the values returned by fn.
+func (f *Field) updateValues(shard uint64, filter *Row, fn func(value int64, exists bool) (int64, bool, error)) (changed bool, err error) {
+	bsig := f.bsiGroup(f.name)
+	if bsig == nil {
+		return false, ErrBSIGroupNotFound
+	}
+
+	view, err := f.createViewIfNotExists(ViewBSIGroupPrefix + f.name)
+	if err != nil {
+		return false, errors.Wrap(err, "creating view")
+	}
+	frag, err := view.CreateFragmentIfNotExists(shard)
+	if err != nil {
+		return false, errors.Wrap(err, "creating fragment")
+	}
+
+	baseFn := bsig.baseValueFunc(fn)
+	for {
+		bitDepth := bsig.BitDepth
+		changed, requiredDepth, err := frag.updateValues(filter, bitDepth, baseFn)
+		if err != nil || requiredDepth <= bitDepth {
+			return changed, err
+		} else if err := f.growBitDepth(bsig, requiredDepth); err != nil {
+			return false, err
+		}
+	}
+}
+
+// growBitDepth increases the bit depth of bsig to depth, if it is smaller.
+func (f *Field) growBitDepth(bsig *bsiGroup, depth uint) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if depth <= bsig.BitDepth {
+		return nil
+	}
+	bsig.BitDepth = depth
+	f.options.BitDepth = depth
+	return errors.Wrap(f.saveMeta(), "increasing bsi bit depth")
+}
+
 // Sum returns the sum and count for a field.
 // An optional filtering row can be provided.
 func (f *Field) Sum(filter *Row, name string) (sum, count int64, err error) {
@@ -1124,7 +1249,7 @@ func (f *Field) Sum(filter *Row, name string) (sum, count int64, err error) {
 		return 0, 0, ErrBSIGroupNotFound
 	}
 
-	view := f.view(viewBSIGroupPrefix + name)
+	view := f.view(ViewBSIGroupPrefix + name)
 	if view == nil {
 		return 0, 0, nil
 	}
@@ -1144,7 +1269,7 @@ func (f *Field) Min(filter *Row, name string) (min, count int64, err error) {
 		return 0, 0, ErrBSIGroupNotFound
 	}
 
-	view := f.view(viewBSIGroupPrefix + name)
+	view := f.view(ViewBSIGroupPrefix + name)
 	if view == nil {
 		return 0, 0, nil
 	}
@@ -1164,7 +1289,7 @@ func (f *Field) Max(filter *Row, name string) (max, count int64, err error) {
 		return 0, 0, ErrBSIGroupNotFound
 	}
 
-	view := f.view(viewBSIGroupPrefix + name)
+	view := f.view(ViewBSIGroupPrefix + name)
 	if view == nil {
 		return 0, 0, nil
 	}
@@ -1187,7 +1312,7 @@ func (f *Field) Range(name string, op pql.Token, predicate int64) (*Row, error)
 	}
 
 	// Retrieve bsiGroup's view.
-	view := f.view(viewBSIGroupPrefix + name)
+	view := f.view(ViewBSIGroupPrefix + name)
 	if view == nil {
 		return nil, nil
 	}
@@ -1221,6 +1346,9 @@ func (f *Field) Import(rowIDs, columnIDs []uint64, timestamps []*time.Time, opts
 			return errors.New("import clear is not supported with timestamps")
 		}
 	}
+	if options.Clear && options.Upsert {
+		return errors.New("import clear is not supported with upsert")
+	}
 
 	fieldType := f.Type()
 
@@ -1283,7 +1411,7 @@ func (f *Field) Import(rowIDs, columnIDs []uint64, timestamps []*time.Time, opts
 
 // importValue bulk imports range-encoded value data.
 func (f *Field) importValue(columnIDs []uint64, values []int64, options *ImportOptions) error {
-	viewName := viewBSIGroupPrefix + f.name
+	viewName := ViewBSIGroupPrefix + f.name
 	// Get the bsiGroup so we know bitDepth.
 	bsig := f.bsiGroup(f.name)
 	if bsig == nil {
@@ -1623,6 +1751,27 @@ func (b *bsiGroup) baseValueBetween(lo, hi int64) (baseValueLo, baseValueHi int6
 	return lo - b.Base, hi - b.Base, false
 }
 
+// baseValueFunc wraps fn, which operates on values, to operate on base
+// values instead. A column without a value is passed to fn as zero. The
+// values returned by fn are validated against min & max.
+func (b *bsiGroup) baseValueFunc(fn func(value int64, exists bool) (int64, bool, error)) func(baseValue int64, exists bool) (int64, bool, error) {
+	return func(baseValue int64, exists bool) (int64, bool, error) {
+		var value int64
+		if exists {
+			value = baseValue + b.Base
+		}
+		value, ok, err := fn(value, exists)
+		if err != nil || !ok {
+			return value - b.Base, ok, err
+		} else if value < b.Min {
+			return 0, false, ErrBSIGroupValueTooLow
+		} else if value > b.Max {
+			return 0, false, ErrBSIGroupValueTooHigh
+		}
+		return value - b.Base, true, nil
+	}
+}
+
 func (b *bsiGroup) validate() error {
 	if b.Name == "" {
 		return ErrBSIGroupNameRequired
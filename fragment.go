@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"container/heap"
 	"context"
+	cryptorand "crypto/rand"
 	"encoding/binary"
 	"fmt"
 	"hash"
@@ -30,6 +31,7 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 	"unsafe"
@@ -90,7 +92,9 @@ const (
 	// BSI bits used to check existence & sign.
 	bsiExistsBit = 0
 	bsiSignBit   = 1
-	bsiOffsetBit = 2
+
+	// BSIOffsetBit is the row of the lowest bit of the values in a BSI view.
+	BSIOffsetBit = 2
 
 	// Roaring bitmap flags.
 	roaringFlagBSIv2 = 0x01 // indicates version using low bit for existence
@@ -156,6 +160,19 @@ type fragment struct {
 	stats stats.StatsClient
 
 	snapshotQueue chan *fragment
+	storageOpt    StorageOptions
+	cold          bool // data file is compressed
+	unloaded      bool  // storage of a cold data file hasn't been read yet
+	loadErr       error // returned by load instead of reading the data file
+
+	// version changes whenever the fragment's data changes. It is read
+	// and written atomically so that it can be checked without the lock.
+	version uint64
+
+	// generation changes whenever the data file is replaced or reopened, so
+	// that data appended to the file since a backup can be told apart from a
+	// rewritten file. It is random, so it isn't reused across restarts.
+	generation uint64
 }
 
 // newFragment returns a new instance of Fragment.
@@ -272,7 +289,12 @@ func (f *fragment) Open() error {
 	return nil
 }
 
+// reopen prepares the fragment for a write, opening its data file if it was
+// closed, and loading the storage of a cold fragment.
 func (f *fragment) reopen() (mustClose bool, err error) {
+	if err := f.load(); err != nil {
+		return false, errors.Wrap(err, "loading")
+	}
 	if f.file == nil {
 		// Open the data file to be mmap'd and used as an ops log.
 		f.file, mustClose, err = syswrap.OpenFile(f.path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
@@ -322,6 +344,10 @@ func (f *fragment) openStorage(unmarshalData bool) error {
 		// unmarshal this data in order to have any.
 		unmarshalData = true
 	}
+	// Any data we unmarshal may differ from what we had before.
+	if unmarshalData {
+		f.bumpVersion()
+	}
 	// Open the data file to be mmap'd and used as an ops log.
 	file, mustClose, err := syswrap.OpenFile(f.path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
 	if err != nil {
@@ -331,6 +357,9 @@ func (f *fragment) openStorage(unmarshalData bool) error {
 	if mustClose {
 		defer f.safeClose()
 	}
+	if f.generation, err = newFragmentGeneration(); err != nil {
+		return errors.Wrap(err, "generating generation")
+	}
 
 	// Lock the underlying file.
 	if err := syscall.Flock(int(f.file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
@@ -354,7 +383,7 @@ func (f *fragment) openStorage(unmarshalData bool) error {
 	} else if fi.Size() == 0 {
 		bi := bufio.NewWriter(f.file)
 		var err error
-		if _, err = f.storage.WriteTo(bi); err != nil {
+		if _, err = f.writeStorage(f.storage, bi); err != nil {
 			return fmt.Errorf("init storage file: %s", err)
 		}
 		bi.Flush()
@@ -383,6 +412,32 @@ func (f *fragment) openStorage(unmarshalData bool) error {
 		}
 	}
 
+	// A cold data file is compressed. Its storage isn't read until the
+	// fragment is first used, by load, which decompresses it into memory
+	// rather than mapping it. Ops are appended to it as usual until the
+	// next snapshot, which writes it uncompressed.
+	f.cold = roaring.Compressed(data)
+	f.unloaded = false
+	if f.cold {
+		var flags byte
+		var flagsErr error
+		if unmarshalData {
+			flags, flagsErr = roaring.CompressedFlags(data)
+		}
+		if newStorageData != nil {
+			if err := syswrap.Munmap(newStorageData); err != nil {
+				return fmt.Errorf("unmapping compressed storage data: %s", err)
+			}
+			newStorageData = nil
+		}
+		if flagsErr != nil {
+			return &fragmentCorruptError{path: f.file.Name(), err: flagsErr}
+		}
+		if unmarshalData {
+			return f.unload(oldStorageData, flags)
+		}
+	}
+
 	if unmarshalData {
 		f.storageData = newStorageData
 		// We're about to either re-read the bitmap, or fail to do so
@@ -409,7 +464,7 @@ func (f *fragment) openStorage(unmarshalData bool) error {
 			if e2 != nil {
 				return fmt.Errorf("unmarshal storage: file=%s, err=%s, clearing old mapping also failed: %v", f.file.Name(), err, e2)
 			}
-			return fmt.Errorf("unmarshal storage: file=%s, err=%s", f.file.Name(), err)
+			return &fragmentCorruptError{path: f.file.Name(), err: err}
 		}
 		f.rowCache = &simpleCache{make(map[uint64]*Row)}
 		f.ops, f.opN = f.storage.Ops()
@@ -457,6 +512,79 @@ func (f *fragment) openStorage(unmarshalData bool) error {
 	return lastError
 }
 
+// unload replaces the storage with an empty bitmap holding flags, until the
+// cold data file is read by load. The old storage is moved off
+// oldStorageData, if it was mapped, since rows read from it may still be
+// in use.
+func (f *fragment) unload(oldStorageData []byte, flags byte) error {
+	if oldStorageData != nil {
+		if _, err := f.storage.RemapRoaringStorage(nil); err != nil {
+			return errors.Wrap(err, "unmapping old storage")
+		} else if err := syswrap.Munmap(oldStorageData); err != nil {
+			return fmt.Errorf("unmapping old storage data: %s", err)
+		}
+		f.storageData = nil
+	}
+	f.storage = roaring.NewFileBitmap()
+	f.storage.Flags = flags
+	f.storage.OpWriter = f.file
+	f.unloaded = true
+	f.rowCache = &simpleCache{make(map[uint64]*Row)}
+	f.ops, f.opN = 0, 0
+	return nil
+}
+
+// load reads the storage of a cold fragment, if it hasn't been read since
+// the fragment was opened, decompressing it into memory. f.mu must be held
+// for writing.
+func (f *fragment) load() error {
+	if !f.unloaded {
+		return nil
+	} else if f.loadErr != nil {
+		return f.loadErr
+	}
+	start := time.Now()
+	data, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return errors.Wrap(err, "reading data file")
+	}
+	f.storage.PreferMapping(false)
+	if err := f.storage.UnmarshalBinary(data); err != nil {
+		return &fragmentCorruptError{path: f.path, err: err}
+	}
+	f.unloaded = false
+	f.ops, f.opN = f.storage.Ops()
+	f.maxRowID = f.storage.Max() / ShardWidth
+	f.stats.Gauge("rows", float64(f.maxRowID), 1.0)
+	if err := f.openCache(); err != nil {
+		return errors.Wrap(err, "opening cache")
+	}
+	f.stats.Histogram("coldFragmentLoad", time.Since(start).Seconds(), 1.0)
+	return nil
+}
+
+// loadForRow is load for row, which can't return an error. Reads which can
+// report errors call ensureLoaded before reading rows, so this only loads
+// a fragment which was unloaded again since.
+func (f *fragment) loadForRow() {
+	if err := f.load(); err != nil {
+		f.Logger.Printf("fragment: error loading cold storage: err=%s, path=%s", err, f.path)
+	}
+}
+
+// ensureLoaded is load for reads which don't hold f.mu.
+func (f *fragment) ensureLoaded() error {
+	f.mu.RLock()
+	unloaded := f.unloaded
+	f.mu.RUnlock()
+	if !unloaded {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.load()
+}
+
 // openCache initializes the cache from row ids persisted to disk.
 func (f *fragment) openCache() error {
 	// Determine cache type from field name.
@@ -472,6 +600,11 @@ func (f *fragment) openCache() error {
 		return ErrInvalidCacheType
 	}
 
+	// The counts of the cached rows are read once the storage is loaded.
+	if f.unloaded {
+		return nil
+	}
+
 	// Read cache data from disk.
 	path := f.cachePath()
 	buf, err := ioutil.ReadFile(path)
@@ -598,13 +731,25 @@ func (f *fragment) closeStorage(includeMap bool) error {
 	return nil
 }
 
-// row returns a row by ID.
+// row returns a row by ID. Callers which can return an error should call
+// ensureLoaded first, so that a cold fragment which can't be read isn't
+// read as empty.
 func (f *fragment) row(rowID uint64) *Row {
 	f.mu.Lock()
 	defer f.mu.Unlock()
+	f.loadForRow()
 	return f.unprotectedRow(rowID)
 }
 
+// readRow is row for callers which can return an error. It returns the error
+// if the storage of a cold fragment can't be loaded.
+func (f *fragment) readRow(rowID uint64) (*Row, error) {
+	if err := f.ensureLoaded(); err != nil {
+		return nil, err
+	}
+	return f.row(rowID), nil
+}
+
 // unprotectedRow returns a row from the row cache if available or from storage
 // (updating the cache).
 func (f *fragment) unprotectedRow(rowID uint64) *Row {
@@ -801,6 +946,7 @@ func (f *fragment) unprotectedSetRow(row *Row, rowID uint64) (changed bool, err
 	// of this is worth having `changed`.
 	// For now we will assume changed is always true.
 	changed = true
+	f.bumpVersion()
 
 	// First container of the row in storage.
 	headContainerKey := rowID << shardVsContainerExponent
@@ -872,6 +1018,10 @@ func (f *fragment) unprotectedClearRow(rowID uint64) (changed bool, err error) {
 		}
 	}
 
+	if changed {
+		f.bumpVersion()
+	}
+
 	// Clear the row in cache.
 	f.cache.Add(rowID, 0)
 	f.rowCache.Add(rowID, nil)
@@ -884,6 +1034,53 @@ func (f *fragment) unprotectedClearRow(rowID uint64) (changed bool, err error) {
 	return changed, nil
 }
 
+// clearColumns clears every bit in the given columns, across all rows.
+// All columns must belong to the fragment's shard.
+func (f *fragment) clearColumns(columnIDs []uint64) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	mustClose, err := f.reopen()
+	if err != nil {
+		return false, errors.Wrap(err, "reopening")
+	}
+	if mustClose {
+		defer f.safeClose()
+	}
+
+	// Group the columns by their container within a row, so that each
+	// existing container is visited once instead of looking up every
+	// row/column pair.
+	byKey := make(map[uint64][]uint16)
+	for _, columnID := range columnIDs {
+		pos, err := f.pos(0, columnID)
+		if err != nil {
+			return false, err
+		}
+		byKey[pos>>16] = append(byKey[pos>>16], uint16(pos&0xFFFF))
+	}
+
+	rowSet := make(map[uint64]struct{})
+	var positions []uint64
+	itr, _ := f.storage.Containers.Iterator(0)
+	for itr.Next() {
+		key, c := itr.Value()
+		for _, v := range byKey[key&(1<<shardVsContainerExponent-1)] {
+			if c.Contains(v) {
+				positions = append(positions, key<<16|uint64(v))
+				rowSet[key>>shardVsContainerExponent] = struct{}{}
+			}
+		}
+	}
+	if len(positions) == 0 {
+		return false, nil
+	}
+
+	if err := f.importPositions(nil, positions, rowSet); err != nil {
+		return false, errors.Wrap(err, "clearing positions")
+	}
+	return true, nil
+}
+
 func (f *fragment) bit(rowID, columnID uint64) (bool, error) {
 	pos, err := f.pos(rowID, columnID)
 	if err != nil {
@@ -896,7 +1093,14 @@ func (f *fragment) bit(rowID, columnID uint64) (bool, error) {
 func (f *fragment) value(columnID uint64, bitDepth uint) (value int64, exists bool, err error) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
+	if err := f.load(); err != nil {
+		return 0, false, errors.Wrap(err, "loading")
+	}
+	return f.unprotectedValue(columnID, bitDepth)
+}
 
+// unprotectedValue reads a multi-bit value without grabbing the mutex.
+func (f *fragment) unprotectedValue(columnID uint64, bitDepth uint) (value int64, exists bool, err error) {
 	// If existence bit is unset then ignore remaining bits.
 	if v, err := f.bit(bsiExistsBit, columnID); err != nil {
 		return 0, false, errors.Wrap(err, "getting existence bit")
@@ -906,7 +1110,7 @@ func (f *fragment) value(columnID uint64, bitDepth uint) (value int64, exists bo
 
 	// Compute other bits into a value.
 	for i := uint(0); i < bitDepth; i++ {
-		if v, err := f.bit(uint64(bsiOffsetBit+i), columnID); err != nil {
+		if v, err := f.bit(uint64(BSIOffsetBit+i), columnID); err != nil {
 			return 0, false, errors.Wrapf(err, "getting value bit %d", i)
 		} else if v {
 			value |= (1 << i)
@@ -959,7 +1163,7 @@ func (f *fragment) positionsForValue(columnID uint64, bitDepth uint, value int64
 	}
 
 	for i := uint(0); i < bitDepth; i++ {
-		bit, err := f.pos(uint64(bsiOffsetBit+i), columnID)
+		bit, err := f.pos(uint64(BSIOffsetBit+i), columnID)
 		if err != nil {
 			return toSet, toClear, errors.Wrap(err, "getting pos")
 		}
@@ -984,7 +1188,51 @@ func (f *fragment) setValueBase(columnID uint64, bitDepth uint, value int64, cle
 	if mustClose {
 		defer f.safeClose()
 	}
+	return f.unprotectedSetValueBase(columnID, bitDepth, value, clear)
+}
 
+// updateValue reads a column's value and replaces it with the value returned
+// by fn, all under the fragment lock, so that concurrent updates to the same
+// column can't interleave. fn is passed the current value, and whether it
+// exists, and returns the new value and whether it should be written.
+//
+// The value returned by fn is returned, along with whether it was written.
+// If the new value needs more than bitDepth bits then nothing is written and
+// the required bit depth is returned so the caller can grow the field and
+// try again.
+func (f *fragment) updateValue(columnID uint64, bitDepth uint, fn func(value int64, exists bool) (int64, bool, error)) (value int64, ok bool, requiredDepth uint, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	mustClose, err := f.reopen()
+	if err != nil {
+		return 0, false, 0, errors.Wrap(err, "reopening")
+	}
+	if mustClose {
+		defer f.safeClose()
+	}
+
+	current, exists, err := f.unprotectedValue(columnID, bitDepth)
+	if err != nil {
+		return 0, false, 0, errors.Wrap(err, "reading value")
+	}
+	value, ok, err = fn(current, exists)
+	if err != nil {
+		return 0, false, 0, err
+	} else if !ok {
+		return value, false, bitDepth, nil
+	} else if depth := bitDepthInt64(value); depth > bitDepth {
+		return 0, false, depth, nil
+	}
+
+	if _, err := f.unprotectedSetValueBase(columnID, bitDepth, value, false); err != nil {
+		return 0, false, 0, errors.Wrap(err, "setting value")
+	}
+	return value, true, bitDepth, nil
+}
+
+// unprotectedSetValueBase sets or clears a multi-bit value without grabbing
+// the mutex.
+func (f *fragment) unprotectedSetValueBase(columnID uint64, bitDepth uint, value int64, clear bool) (changed bool, err error) {
 	// Convert value to an unsigned representation.
 	uvalue := uint64(value)
 	if value < 0 {
@@ -993,13 +1241,13 @@ func (f *fragment) setValueBase(columnID uint64, bitDepth uint, value int64, cle
 
 	for i := uint(0); i < bitDepth; i++ {
 		if uvalue&(1<<i) != 0 {
-			if c, err := f.unprotectedSetBit(uint64(bsiOffsetBit+i), columnID); err != nil {
+			if c, err := f.unprotectedSetBit(uint64(BSIOffsetBit+i), columnID); err != nil {
 				return changed, err
 			} else if c {
 				changed = true
 			}
 		} else {
-			if c, err := f.unprotectedClearBit(uint64(bsiOffsetBit+i), columnID); err != nil {
+			if c, err := f.unprotectedClearBit(uint64(BSIOffsetBit+i), columnID); err != nil {
 				return changed, err
 			} else if c {
 				changed = true
@@ -1049,7 +1297,7 @@ func (f *fragment) importSetValue(columnID uint64, bitDepth uint, value int64, c
 	}
 
 	for i := uint(0); i < bitDepth; i++ {
-		bit, err := f.pos(uint64(bsiOffsetBit+i), columnID)
+		bit, err := f.pos(uint64(BSIOffsetBit+i), columnID)
 		if err != nil {
 			return changed, errors.Wrap(err, "getting pos")
 		}
@@ -1109,6 +1357,10 @@ func (f *fragment) importSetValue(columnID uint64, bitDepth uint, value int64, c
 // sum returns the sum of a given bsiGroup as well as the number of columns involved.
 // A bitmap can be passed in to optionally filter the computed columns.
 func (f *fragment) sum(filter *Row, bitDepth uint) (sum int64, count uint64, err error) {
+	if err := f.ensureLoaded(); err != nil {
+		return 0, 0, err
+	}
+
 	// Compute count based on the existence row.
 	consider := f.row(bsiExistsBit)
 	if filter != nil {
@@ -1130,7 +1382,7 @@ func (f *fragment) sum(filter *Row, bitDepth uint) (sum int64, count uint64, err
 	// Execute once for positive numbers and once for negative. Subtract the
 	// negative sum from the positive sum.
 	for i := uint(0); i < bitDepth; i++ {
-		row := f.row(uint64(bsiOffsetBit + i))
+		row := f.row(uint64(BSIOffsetBit + i))
 
 		psum := int64((1 << i) * row.intersectionCount(prow))
 		nsum := int64((1 << i) * row.intersectionCount(nrow))
@@ -1145,6 +1397,9 @@ func (f *fragment) sum(filter *Row, bitDepth uint) (sum int64, count uint64, err
 // min returns the min of a given bsiGroup as well as the number of columns involved.
 // A bitmap can be passed in to optionally filter the computed columns.
 func (f *fragment) min(filter *Row, bitDepth uint) (min int64, count uint64, err error) {
+	if err := f.ensureLoaded(); err != nil {
+		return 0, 0, err
+	}
 	consider := f.row(bsiExistsBit)
 	if filter != nil {
 		consider = consider.Intersect(filter)
@@ -1172,7 +1427,7 @@ func (f *fragment) min(filter *Row, bitDepth uint) (min int64, count uint64, err
 // minUnsigned the lowest value without considering the sign bit. Filter is required.
 func (f *fragment) minUnsigned(filter *Row, bitDepth uint) (min int64, count uint64) {
 	for i := int(bitDepth - 1); i >= 0; i-- {
-		row := filter.Difference(f.row(uint64(bsiOffsetBit + i)))
+		row := filter.Difference(f.row(uint64(BSIOffsetBit + i)))
 		count = row.Count()
 		if count > 0 {
 			filter = row
@@ -1189,6 +1444,9 @@ func (f *fragment) minUnsigned(filter *Row, bitDepth uint) (min int64, count uin
 // max returns the max of a given bsiGroup as well as the number of columns involved.
 // A bitmap can be passed in to optionally filter the computed columns.
 func (f *fragment) max(filter *Row, bitDepth uint) (max int64, count uint64, err error) {
+	if err := f.ensureLoaded(); err != nil {
+		return 0, 0, err
+	}
 	consider := f.row(bsiExistsBit)
 	if filter != nil {
 		consider = consider.Intersect(filter)
@@ -1214,7 +1472,7 @@ func (f *fragment) max(filter *Row, bitDepth uint) (max int64, count uint64, err
 // maxUnsigned the highest value without considering the sign bit. Filter is required.
 func (f *fragment) maxUnsigned(filter *Row, bitDepth uint) (max int64, count uint64) {
 	for i := int(bitDepth - 1); i >= 0; i-- {
-		row := f.row(uint64(bsiOffsetBit + i)).Intersect(filter)
+		row := f.row(uint64(BSIOffsetBit + i)).Intersect(filter)
 		count = row.Count()
 		if count > 0 {
 			max += (1 << uint(i))
@@ -1229,32 +1487,38 @@ func (f *fragment) maxUnsigned(filter *Row, bitDepth uint) (max int64, count uin
 // minRow returns minRowID of the rows in the filter and its count.
 // if filter is nil, it returns fragment.minRowID, 1
 // if fragment has no rows, it returns 0, 0
-func (f *fragment) minRow(filter *Row) (uint64, uint64) {
+func (f *fragment) minRow(filter *Row) (uint64, uint64, error) {
+	if err := f.ensureLoaded(); err != nil {
+		return 0, 0, err
+	}
 	minRowID, hasRowID := f.minRowID()
 	if hasRowID {
 		if filter == nil {
-			return minRowID, 1
+			return minRowID, 1, nil
 		}
 		// iterate from min row ID and return the first that intersects with filter.
 		for i := minRowID; i <= f.maxRowID; i++ {
 			row := f.row(i).Intersect(filter)
 			count := row.Count()
 			if count > 0 {
-				return i, count
+				return i, count, nil
 			}
 		}
 	}
-	return 0, 0
+	return 0, 0, nil
 }
 
 // maxRow returns maxRowID of the rows in the filter and its count.
 // if filter is nil, it returns fragment.maxRowID, 1
 // if fragment has no rows, it returns 0, 0
-func (f *fragment) maxRow(filter *Row) (uint64, uint64) {
+func (f *fragment) maxRow(filter *Row) (uint64, uint64, error) {
+	if err := f.ensureLoaded(); err != nil {
+		return 0, 0, err
+	}
 	minRowID, hasRowID := f.minRowID()
 	if hasRowID {
 		if filter == nil {
-			return f.maxRowID, 1
+			return f.maxRowID, 1, nil
 		}
 		// iterate back from max row ID and return the first that intersects with filter.
 		// TODO: implement reverse container iteration to improve performance here for sparse data. --Jaffee
@@ -1262,15 +1526,18 @@ func (f *fragment) maxRow(filter *Row) (uint64, uint64) {
 			row := f.row(i).Intersect(filter)
 			count := row.Count()
 			if count > 0 {
-				return i, count
+				return i, count, nil
 			}
 		}
 	}
-	return 0, 0
+	return 0, 0, nil
 }
 
 // rangeOp returns bitmaps with a bsiGroup value encoding matching the predicate.
 func (f *fragment) rangeOp(op pql.Token, bitDepth uint, predicate int64) (*Row, error) {
+	if err := f.ensureLoaded(); err != nil {
+		return nil, err
+	}
 	switch op {
 	case pql.EQ:
 		return f.rangeEQ(bitDepth, predicate)
@@ -1300,7 +1567,7 @@ func (f *fragment) rangeEQ(bitDepth uint, predicate int64) (*Row, error) {
 
 	// Filter any bits that don't match the current bit value.
 	for i := int(bitDepth - 1); i >= 0; i-- {
-		row := f.row(uint64(bsiOffsetBit + i))
+		row := f.row(uint64(BSIOffsetBit + i))
 		bit := (upredicate >> uint(i)) & 1
 
 		if bit == 1 {
@@ -1360,7 +1627,7 @@ func (f *fragment) rangeLTUnsigned(filter *Row, bitDepth uint, predicate uint64,
 	// Filter any bits that don't match the current bit value.
 	leadingZeros := true
 	for i := int(bitDepth - 1); i >= 0; i-- {
-		row := f.row(uint64(bsiOffsetBit + i))
+		row := f.row(uint64(BSIOffsetBit + i))
 		bit := (predicate >> uint(i)) & 1
 
 		// Remove any columns with higher bits set.
@@ -1427,7 +1694,7 @@ func (f *fragment) rangeGTUnsigned(filter *Row, bitDepth uint, predicate uint64,
 
 	// Filter any bits that don't match the current bit value.
 	for i := int(bitDepth - 1); i >= 0; i-- {
-		row := f.row(uint64(bsiOffsetBit + i))
+		row := f.row(uint64(BSIOffsetBit + i))
 		bit := (predicate >> uint(i)) & 1
 
 		// Handle last bit differently.
@@ -1458,11 +1725,17 @@ func (f *fragment) rangeGTUnsigned(filter *Row, bitDepth uint, predicate uint64,
 
 // notNull returns the exists row.
 func (f *fragment) notNull() (*Row, error) {
+	if err := f.ensureLoaded(); err != nil {
+		return nil, err
+	}
 	return f.row(bsiExistsBit), nil
 }
 
 // rangeBetween returns bitmaps with a bsiGroup value encoding matching any value between predicateMin and predicateMax.
 func (f *fragment) rangeBetween(bitDepth uint, predicateMin, predicateMax int64) (*Row, error) {
+	if err := f.ensureLoaded(); err != nil {
+		return nil, err
+	}
 	b := f.row(bsiExistsBit)
 
 	// Convert predicates to unsigned values.
@@ -1503,7 +1776,7 @@ func (f *fragment) rangeBetweenUnsigned(filter *Row, bitDepth uint, predicateMin
 
 	// Filter any bits that don't match the current bit value.
 	for i := int(bitDepth - 1); i >= 0; i-- {
-		row := f.row(uint64(bsiOffsetBit + i))
+		row := f.row(uint64(BSIOffsetBit + i))
 		bit1 := (predicateMin >> uint(i)) & 1
 		bit2 := (predicateMax >> uint(i)) & 1
 
@@ -1550,6 +1823,9 @@ func (f *fragment) pos(rowID, columnID uint64) (uint64, error) {
 func (f *fragment) forEachBit(fn func(rowID, columnID uint64) error) error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
+	if err := f.load(); err != nil {
+		return errors.Wrap(err, "loading")
+	}
 
 	var err error
 	f.storage.ForEach(func(i uint64) {
@@ -1569,7 +1845,10 @@ func (f *fragment) forEachBit(fn func(rowID, columnID uint64) error) error {
 // If opt.FilterValues exist then the row attribute specified by field is matched.
 func (f *fragment) top(opt topOptions) ([]Pair, error) {
 	// Retrieve pairs. If no row ids specified then return from cache.
-	pairs := f.topBitmapPairs(opt.RowIDs)
+	pairs, err := f.topBitmapPairs(opt.RowIDs)
+	if err != nil {
+		return nil, err
+	}
 
 	// If row ids are provided, we don't want to truncate the result set
 	if len(opt.RowIDs) > 0 {
@@ -1701,17 +1980,20 @@ func (f *fragment) top(opt topOptions) ([]Pair, error) {
 	return r, nil
 }
 
-func (f *fragment) topBitmapPairs(rowIDs []uint64) []bitmapPair {
+func (f *fragment) topBitmapPairs(rowIDs []uint64) ([]bitmapPair, error) {
 	// Don't retrieve from storage if CacheTypeNone.
 	if f.CacheType == CacheTypeNone {
-		return f.cache.Top()
+		return f.cache.Top(), nil
+	}
+	if err := f.ensureLoaded(); err != nil {
+		return nil, err
 	}
 	// If no specific rows are requested, retrieve top rows.
 	if len(rowIDs) == 0 {
 		f.mu.Lock()
 		defer f.mu.Unlock()
-		f.cache.Invalidate()
-		return f.cache.Top()
+		f.invalidateCache()
+		return f.cache.Top(), nil
 	}
 
 	// Otherwise retrieve specific rows.
@@ -1736,7 +2018,7 @@ func (f *fragment) topBitmapPairs(rowIDs []uint64) []bitmapPair {
 		}
 	}
 	sort.Sort(bitmapPairs(pairs))
-	return pairs
+	return pairs, nil
 }
 
 // topOptions represents options passed into the Top() function.
@@ -1759,12 +2041,16 @@ type topOptions struct {
 
 // Checksum returns a checksum for the entire fragment.
 // If two fragments have the same checksum then they have the same data.
-func (f *fragment) Checksum() []byte {
+func (f *fragment) Checksum() ([]byte, error) {
+	blocks, err := f.Blocks()
+	if err != nil {
+		return nil, err
+	}
 	h := xxhash.New()
-	for _, block := range f.Blocks() {
+	for _, block := range blocks {
 		_, _ = h.Write(block.Checksum)
 	}
-	return h.Sum(nil)
+	return h.Sum(nil), nil
 }
 
 // InvalidateChecksums clears all cached block checksums.
@@ -1775,9 +2061,12 @@ func (f *fragment) InvalidateChecksums() {
 }
 
 // Blocks returns info for all blocks containing data.
-func (f *fragment) Blocks() []FragmentBlock {
+func (f *fragment) Blocks() ([]FragmentBlock, error) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
+	if err := f.load(); err != nil {
+		return nil, err
+	}
 
 	var a []FragmentBlock
 
@@ -1791,7 +2080,7 @@ func (f *fragment) Blocks() []FragmentBlock {
 	// Iterate over each value in the fragment.
 	v, eof := itr.Next()
 	if eof {
-		return nil
+		return nil, nil
 	}
 	blockID := int(v / (HashBlockSize * ShardWidth))
 	for {
@@ -1837,7 +2126,7 @@ func (f *fragment) Blocks() []FragmentBlock {
 		}
 	}
 
-	return a
+	return a, nil
 }
 
 // readContiguousChecksums appends multiple checksums in a row and returns the count added.
@@ -1856,14 +2145,17 @@ func (f *fragment) readContiguousChecksums(a *[]FragmentBlock, blockID int) (n i
 }
 
 // blockData returns bits in a block as row & column ID pairs.
-func (f *fragment) blockData(id int) (rowIDs, columnIDs []uint64) {
+func (f *fragment) blockData(id int) (rowIDs, columnIDs []uint64, err error) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
+	if err := f.load(); err != nil {
+		return nil, nil, err
+	}
 	f.storage.ForEachRange(uint64(id)*HashBlockSize*ShardWidth, (uint64(id)+1)*HashBlockSize*ShardWidth, func(i uint64) {
 		rowIDs = append(rowIDs, i/ShardWidth)
 		columnIDs = append(columnIDs, i%ShardWidth)
 	})
-	return rowIDs, columnIDs
+	return rowIDs, columnIDs, nil
 }
 
 // mergeBlock compares the block's bits and computes a diff with another set of block bits.
@@ -1882,6 +2174,9 @@ func (f *fragment) mergeBlock(id int, data []pairSet) (sets, clears []pairSet, e
 
 	f.mu.Lock()
 	defer f.mu.Unlock()
+	if err := f.load(); err != nil {
+		return nil, nil, errors.Wrap(err, "loading")
+	}
 
 	// Track sets and clears for all blocks (including local).
 	sets = make([]pairSet, len(data)+1)
@@ -2008,6 +2303,10 @@ func (f *fragment) bulkImport(rowIDs, columnIDs []uint64, options *ImportOptions
 
 // bulkImportStandard performs a bulk import on a standard fragment. May mutate
 // its rowIDs and columnIDs arguments.
+//
+// If options.Upsert is set and this is the standard view, the existing bits
+// of each imported column are cleared, so that the column is only set in the
+// imported rows.
 func (f *fragment) bulkImportStandard(rowIDs, columnIDs []uint64, options *ImportOptions) (err error) {
 	// rowSet maintains the set of rowIDs present in this import. It allows the
 	// cache to be updated once per row, instead of once per bit. TODO: consider
@@ -2036,12 +2335,65 @@ func (f *fragment) bulkImportStandard(rowIDs, columnIDs []uint64, options *Impor
 	defer f.mu.Unlock()
 	if options.Clear {
 		err = f.importPositions(nil, positions, rowSet)
+	} else if options.Upsert && f.view == viewStandard {
+		err = f.importPositions(positions, f.upsertClearPositions(positions, rowSet), rowSet)
 	} else {
 		err = f.importPositions(positions, nil, rowSet)
 	}
 	return errors.Wrap(err, "bulkImportStandard")
 }
 
+// upsertClearPositions returns the positions of the bits which must be
+// cleared so that the columns of positions are only set in the rows being
+// set. The rows of the returned positions are added to rowSet. It is
+// unprotected (f.mu must be locked when calling it).
+//
+// A column is held by the same container of each row, so for each row only
+// the containers holding imported columns are read, and only the bits of
+// those columns are checked.
+func (f *fragment) upsertClearPositions(positions []uint64, rowSet map[uint64]struct{}) []uint64 {
+	set := roaring.NewBitmap()
+	columns := roaring.NewBitmap()
+	for _, pos := range positions {
+		set.DirectAdd(pos)
+		columns.DirectAdd(pos % ShardWidth)
+	}
+
+	// Group the columns by the container of a row which holds them.
+	const rowContainers = 1 << shardVsContainerExponent
+	var lows [rowContainers][]uint16
+	for _, columnID := range columns.Slice() {
+		lows[columnID>>16] = append(lows[columnID>>16], uint16(columnID))
+	}
+
+	var clear []uint64
+	itr, _ := f.storage.Containers.Iterator(0)
+	for itr.Next() {
+		key, _ := itr.Value()
+		rowID := key >> shardVsContainerExponent
+		for i := range lows {
+			if len(lows[i]) == 0 {
+				continue
+			}
+			k := rowID*rowContainers + uint64(i)
+			c := f.storage.Containers.Get(k)
+			if c == nil {
+				continue
+			}
+			for _, low := range lows[i] {
+				if pos := k<<16 | uint64(low); c.Contains(low) && !set.Contains(pos) {
+					clear = append(clear, pos)
+					rowSet[rowID] = struct{}{}
+				}
+			}
+		}
+
+		// Skip the rest of the row's containers.
+		itr, _ = f.storage.Containers.Iterator((rowID + 1) * rowContainers)
+	}
+	return clear
+}
+
 // importPositions takes slices of positions within the fragment to set and
 // clear in storage. One must also pass in the set of unique rows which are
 // affected by the set and clear operations. It is unprotected (f.mu must be
@@ -2106,6 +2458,9 @@ func (f *fragment) importPositions(set, clear []uint64, rowSet map[uint64]struct
 func (f *fragment) bulkImportMutex(rowIDs, columnIDs []uint64) error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
+	if err := f.load(); err != nil {
+		return errors.Wrap(err, "loading")
+	}
 
 	rowSet := make(map[uint64]struct{})
 	// we have to maintain which columns are getting bits set as a map so that
@@ -2205,7 +2560,14 @@ func (f *fragment) importValueSmallWrite(columnIDs []uint64, values []int64, bit
 func (f *fragment) importValue(columnIDs []uint64, values []int64, bitDepth uint, clear bool) error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
+	if err := f.load(); err != nil {
+		return errors.Wrap(err, "loading")
+	}
+	return f.unprotectedImportValue(columnIDs, values, bitDepth, clear)
+}
 
+// unprotectedImportValue imports values without grabbing the mutex.
+func (f *fragment) unprotectedImportValue(columnIDs []uint64, values []int64, bitDepth uint, clear bool) error {
 	// Verify that there are an equal number of column ids and values.
 	if len(columnIDs) != len(values) {
 		return fmt.Errorf("mismatch of column/value len: %d != %d", len(columnIDs), len(values))
@@ -2249,6 +2611,70 @@ func (f *fragment) importValue(columnIDs []uint64, values []int64, bitDepth uint
 	return nil
 }
 
+// updateValues rewrites the range-encoded values of every column in filter
+// in a single write. fn is passed each column's current value, and whether
+// it has one, and returns the new value and whether it should be written.
+// Columns whose value is unchanged are skipped.
+//
+// If a new value needs more than bitDepth bits then nothing is written and
+// the required bit depth is returned so the caller can grow the field and
+// try again.
+func (f *fragment) updateValues(filter *Row, bitDepth uint, fn func(value int64, exists bool) (int64, bool, error)) (changed bool, requiredDepth uint, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	mustClose, err := f.reopen()
+	if err != nil {
+		return false, 0, errors.Wrap(err, "reopening")
+	}
+	if mustClose {
+		defer f.safeClose()
+	}
+
+	// Read the current values from each bit plane.
+	values := make(map[uint64]int64)
+	for _, columnID := range f.unprotectedRow(bsiExistsBit).Intersect(filter).Columns() {
+		values[columnID] = 0
+	}
+	for i := uint(0); i < bitDepth; i++ {
+		for _, columnID := range f.unprotectedRow(uint64(BSIOffsetBit + i)).Intersect(filter).Columns() {
+			if _, ok := values[columnID]; ok {
+				values[columnID] |= 1 << i
+			}
+		}
+	}
+	for _, columnID := range f.unprotectedRow(bsiSignBit).Intersect(filter).Columns() {
+		if _, ok := values[columnID]; ok {
+			values[columnID] = -values[columnID]
+		}
+	}
+
+	var columnIDs []uint64
+	var newValues []int64
+	requiredDepth = bitDepth
+	for _, columnID := range filter.Columns() {
+		value, exists := values[columnID]
+		newValue, ok, err := fn(value, exists)
+		if err != nil {
+			return false, 0, errors.Wrapf(err, "columnID=%d", columnID)
+		} else if !ok || (exists && newValue == value) {
+			continue
+		}
+		if depth := bitDepthInt64(newValue); depth > requiredDepth {
+			requiredDepth = depth
+		}
+		columnIDs = append(columnIDs, columnID)
+		newValues = append(newValues, newValue)
+	}
+
+	if requiredDepth > bitDepth || len(columnIDs) == 0 {
+		return false, requiredDepth, nil
+	}
+	if err := f.unprotectedImportValue(columnIDs, newValues, bitDepth, false); err != nil {
+		return false, 0, errors.Wrap(err, "importing values")
+	}
+	return true, bitDepth, nil
+}
+
 // importRoaring imports from the official roaring data format defined at
 // https://github.com/RoaringBitmap/RoaringFormatSpec or from pilosa's version
 // of the roaring format. The cache is updated to reflect the new data.
@@ -2260,6 +2686,9 @@ func (f *fragment) importRoaring(ctx context.Context, data []byte, clear bool) e
 	f.mu.Lock()
 	defer f.mu.Unlock()
 	span.Finish()
+	if err := f.load(); err != nil {
+		return errors.Wrap(err, "loading")
+	}
 	span, ctx = tracing.StartSpanFromContext(ctx, "importRoaring.ImportRoaringBits")
 	changed, rowSet, err := f.storage.ImportRoaringBits(data, clear, true, rowSize)
 	span.Finish()
@@ -2297,6 +2726,7 @@ func (f *fragment) incrementOpN(changed int) {
 	if changed <= 0 {
 		return
 	}
+	f.bumpVersion()
 	f.opN += changed
 	f.ops++
 	if f.opN > f.MaxOpN {
@@ -2304,6 +2734,38 @@ func (f *fragment) incrementOpN(changed int) {
 	}
 }
 
+// bumpVersion assigns the fragment a new version, invalidating any cached
+// results computed from it.
+func (f *fragment) bumpVersion() {
+	atomic.StoreUint64(&f.version, nextFragmentVersion())
+}
+
+// fragmentCorruptError is returned when the data file of a fragment can't be
+// unmarshalled.
+type fragmentCorruptError struct {
+	path string
+	err  error
+}
+
+// Error returns the error message.
+func (e *fragmentCorruptError) Error() string {
+	return fmt.Sprintf("unmarshal storage: file=%s, err=%s", e.path, e.err)
+}
+
+// newFragmentGeneration returns a random data file generation.
+func newFragmentGeneration() (uint64, error) {
+	var buf [8]byte
+	if _, err := cryptorand.Read(buf[:]); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(buf[:]), nil
+}
+
+// currentVersion returns the fragment's version.
+func (f *fragment) currentVersion() uint64 {
+	return atomic.LoadUint64(&f.version)
+}
+
 // Snapshot writes the storage bitmap to disk and reopens it. This may
 // coexist with existing background-queue snapshotting; it does not remove
 // things from the queue. You probably don't want to do this; use
@@ -2335,6 +2797,9 @@ func (f *fragment) protectedSnapshot(fromQueue bool) error {
 // snapshot does the actual snapshot operation. it does not check or care
 // about f.snapshotting.
 func (f *fragment) snapshot() error {
+	if err := f.load(); err != nil {
+		return errors.Wrap(err, "loading")
+	}
 	f.totalOpN += int64(f.opN)
 	f.totalOps += int64(f.ops)
 	f.snapshotsTaken++
@@ -2342,6 +2807,27 @@ func (f *fragment) snapshot() error {
 	return err
 }
 
+// StorageOptions are options for the data files of fragments.
+type StorageOptions struct {
+	// Checksums writes data files in the checksummed variant of the roaring
+	// format, whose container checksums are verified when they're opened.
+	// Data files written without checksums can still be read.
+	Checksums bool
+
+	// ColdAfter compresses the data files of fragments which haven't been
+	// written to for this long. They're decompressed into memory when
+	// they're first used after being opened. Zero disables compression.
+	ColdAfter time.Duration
+}
+
+// writeStorage writes bm to w in the format of the fragment's data file.
+func (f *fragment) writeStorage(bm *roaring.Bitmap, w io.Writer) (int64, error) {
+	if f.storageOpt.Checksums {
+		return bm.WriteChecksummedTo(w)
+	}
+	return bm.WriteTo(w)
+}
+
 // unprotectedWriteToFragment writes the fragment f with bm as the data. It is unprotected, and
 // f.mu must be locked when calling it.
 func unprotectedWriteToFragment(f *fragment, bm *roaring.Bitmap) (n int64, err error) { // nolint: interfacer
@@ -2359,7 +2845,7 @@ func unprotectedWriteToFragment(f *fragment, bm *roaring.Bitmap) (n int64, err e
 
 	// Write storage to snapshot.
 	bw := bufio.NewWriter(file)
-	if n, err = bm.WriteTo(bw); err != nil {
+	if n, err = f.writeStorage(bm, bw); err != nil {
 		return n, fmt.Errorf("snapshot write to: %s", err)
 	}
 
@@ -2392,11 +2878,100 @@ func unprotectedWriteToFragment(f *fragment, bm *roaring.Bitmap) (n int64, err e
 	return n, nil
 }
 
+// compressIfCold compresses the data file of the fragment if it hasn't been
+// written to for storageOpt.ColdAfter. The storage is then dropped from
+// memory, until the fragment is next used.
+func (f *fragment) compressIfCold() (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// A queued snapshot would rewrite the data file uncompressed.
+	if f.storageOpt.ColdAfter <= 0 || f.cold || f.snapshotting || !f.storage.Any() {
+		return false, nil
+	}
+	fi, err := os.Stat(f.path)
+	if err != nil {
+		return false, errors.Wrap(err, "statting data file")
+	} else if time.Since(fi.ModTime()) < f.storageOpt.ColdAfter {
+		return false, nil
+	}
+
+	var data bytes.Buffer
+	if _, err := f.writeStorage(f.storage, &data); err != nil {
+		return false, errors.Wrap(err, "writing storage")
+	}
+
+	// Write the compressed data file beside the current one.
+	path := f.path + snapshotExt
+	file, err := os.Create(path)
+	if err != nil {
+		return false, errors.Wrap(err, "creating compressed file")
+	}
+	n, err := roaring.Compress(file, data.Bytes())
+	if e := file.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		os.Remove(path)
+		return false, errors.Wrap(err, "compressing")
+	}
+
+	// The cache is read again when the storage is loaded.
+	if err := f.flushCache(); err != nil {
+		os.Remove(path)
+		return false, errors.Wrap(err, "flushing cache")
+	}
+
+	// Close current storage, leaving it mapped until it's unloaded.
+	f.totalOpN += int64(f.opN)
+	f.totalOps += int64(f.ops)
+	if err := f.closeStorage(false); err != nil {
+		return false, errors.Wrap(err, "closing storage")
+	}
+	if err := os.Rename(path, f.path); err != nil {
+		return false, errors.Wrap(err, "renaming compressed file")
+	}
+	if err := f.openStorage(true); err != nil {
+		return false, errors.Wrap(err, "opening storage")
+	}
+
+	f.stats.Count("coldFragments", 1, 1.0)
+	f.stats.Histogram("coldCompressionRatio", float64(data.Len())/float64(n), 1.0)
+	f.Logger.Printf("fragment: compressed cold %s/%s/%s/%d from %d to %d bytes", f.index, f.field, f.view, f.shard, data.Len(), n)
+	return true, nil
+}
+
 // RecalculateCache rebuilds the cache regardless of invalidate time delay.
-func (f *fragment) RecalculateCache() {
+func (f *fragment) RecalculateCache() error {
 	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.load(); err != nil {
+		return err
+	}
 	f.cache.Recalculate()
-	f.mu.Unlock()
+	f.bumpVersion()
+	return nil
+}
+
+// invalidateCache asks the cache to rebuild its rankings. If it does, the
+// fragment's version changes since results computed from the old rankings,
+// such as TopN, are no longer valid.
+func (f *fragment) invalidateCache() {
+	if !f.cache.Stale() {
+		return
+	}
+	f.cache.Invalidate()
+	if !f.cache.Stale() {
+		f.bumpVersion()
+	}
+}
+
+// cacheStale reports whether the fragment's cache has counts which are not
+// yet reflected in its rankings.
+func (f *fragment) cacheStale() bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.cache.Stale()
 }
 
 // FlushCache writes the cache data to disk.
@@ -2407,7 +2982,9 @@ func (f *fragment) FlushCache() error {
 }
 
 func (f *fragment) flushCache() error {
-	if f.cache == nil {
+	// The cache of a fragment whose storage isn't loaded is empty, and the
+	// cache file is left as it was.
+	if f.cache == nil || f.unloaded {
 		return nil
 	}
 
@@ -2523,6 +3100,99 @@ func (f *fragment) writeCacheToArchive(tw *tar.Writer) error {
 	return nil
 }
 
+// fragmentPin is the storage of a fragment at a point in time. The storage
+// format is append-only, so a hard link to the data file and its size keep
+// the pinned data while writes continue, even once a snapshot replaces the
+// file.
+type fragmentPin struct {
+	path       string // link to the data file
+	size       int64  // size of the data file when pinned
+	generation uint64 // generation of the data file when pinned
+	cache      []byte // cache file when pinned, if any
+
+	// offset is the start of the data written by WriteTo. When it isn't
+	// zero, only the ops appended to the data file since then are written.
+	offset int64
+}
+
+// unprotectedPin pins the storage of the fragment, linking its data file to
+// path. It is unprotected, and f.mu must be locked when calling it.
+func (f *fragment) unprotectedPin(path string) (*fragmentPin, error) {
+	if err := f.flushCache(); err != nil {
+		return nil, errors.Wrap(err, "flushing cache")
+	}
+	if err := os.Link(f.path, path); err != nil {
+		return nil, errors.Wrap(err, "linking data file")
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "statting")
+	}
+	cache, err := ioutil.ReadFile(f.cachePath())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, "reading cache")
+	}
+	return &fragmentPin{path: path, size: fi.Size(), generation: f.generation, cache: cache}, nil
+}
+
+// since returns the pin, writing only the ops appended to the data file
+// after offset.
+func (p *fragmentPin) since(offset int64) (*fragmentPin, error) {
+	if offset < 0 || offset > p.size {
+		return nil, errors.Errorf("offset %d out of range of pinned data file of %d bytes", offset, p.size)
+	}
+	other := *p
+	other.offset = offset
+	return &other, nil
+}
+
+// WriteTo writes the pinned storage to w, as an archive like the one written
+// by fragment.WriteTo. When the pin has an offset, the archive holds an "ops"
+// entry with the ops appended since, in place of the "data" entry.
+func (p *fragmentPin) WriteTo(w io.Writer) (n int64, err error) {
+	file, err := os.Open(p.path)
+	if err != nil {
+		return 0, errors.Wrap(err, "opening file")
+	}
+	defer file.Close()
+
+	name := "data"
+	if p.offset > 0 {
+		name = "ops"
+		if _, err := file.Seek(p.offset, io.SeekStart); err != nil {
+			return 0, errors.Wrap(err, "seeking")
+		}
+	}
+
+	tw := tar.NewWriter(w)
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0600,
+		Size:    p.size - p.offset,
+		ModTime: time.Now(),
+	}); err != nil {
+		return 0, errors.Wrap(err, "writing header")
+	}
+	if _, err := io.CopyN(tw, file, p.size-p.offset); err != nil {
+		return 0, errors.Wrap(err, "copying")
+	}
+
+	if p.cache != nil {
+		if err := tw.WriteHeader(&tar.Header{
+			Name:    "cache",
+			Mode:    0600,
+			Size:    int64(len(p.cache)),
+			ModTime: time.Now(),
+		}); err != nil {
+			return 0, errors.Wrap(err, "writing header")
+		}
+		if _, err := tw.Write(p.cache); err != nil {
+			return 0, errors.Wrap(err, "writing")
+		}
+	}
+	return 0, errors.Wrap(tw.Close(), "closing archive")
+}
+
 // ReadFrom reads a data file from r and loads it into the fragment.
 func (f *fragment) ReadFrom(r io.Reader) (n int64, err error) {
 	f.mu.Lock()
@@ -2544,6 +3214,10 @@ func (f *fragment) ReadFrom(r io.Reader) (n int64, err error) {
 			if err := f.readStorageFromArchive(tr); err != nil {
 				return 0, errors.Wrap(err, "reading storage")
 			}
+		case "ops":
+			if err := f.readOpsFromArchive(tr); err != nil {
+				return 0, errors.Wrap(err, "reading ops")
+			}
 		case "cache":
 			if err := f.readCacheFromArchive(tr); err != nil {
 				return 0, errors.Wrap(err, "reading cache")
@@ -2588,6 +3262,33 @@ func (f *fragment) readStorageFromArchive(r io.Reader) error {
 	return nil
 }
 
+// readOpsFromArchive appends ops, such as those of an incremental backup, to
+// the data file and reloads the storage.
+func (f *fragment) readOpsFromArchive(r io.Reader) error {
+	// Close current storage.
+	if err := f.closeStorage(true); err != nil {
+		return errors.Wrap(err, "closing")
+	}
+
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return errors.Wrap(err, "opening data file")
+	}
+	if _, err := io.Copy(file, r); err != nil {
+		file.Close()
+		return errors.Wrap(err, "appending")
+	} else if err := file.Close(); err != nil {
+		return errors.Wrap(err, "closing data file")
+	}
+
+	// Reopen storage.
+	if err := f.openStorage(true); err != nil {
+		return errors.Wrap(err, "opening")
+	}
+
+	return nil
+}
+
 func (f *fragment) readCacheFromArchive(r io.Reader) error {
 	// Slurp data from reader and write to disk.
 	buf, err := ioutil.ReadAll(r)
@@ -2677,10 +3378,13 @@ func filterWithRows(rows []uint64) rowFilter {
 // returning done == true will cause processing to stop after all filters for
 // this container have been processed. The rows accumulated up to this point
 // (including this row if all filters passed) will be returned.
-func (f *fragment) rows(start uint64, filters ...rowFilter) []uint64 {
+func (f *fragment) rows(start uint64, filters ...rowFilter) ([]uint64, error) {
+	if err := f.ensureLoaded(); err != nil {
+		return nil, err
+	}
 	f.mu.RLock()
 	defer f.mu.RUnlock()
-	return f.unprotectedRows(start, filters...)
+	return f.unprotectedRows(start, filters...), nil
 }
 
 // unprotectedRows calls rows without grabbing the mutex.
@@ -2734,19 +3438,25 @@ func upgradeRoaringBSIv2(f *fragment, bitDepth uint) (string, error) {
 
 	other := roaring.NewBitmap()
 	other.Flags = roaringFlagBSIv2
-	func() {
+	if err := func() error {
 		f.mu.Lock()
 		defer f.mu.Unlock()
+		if err := f.load(); err != nil {
+			return errors.Wrap(err, "loading")
+		}
 
 		f.storage.ForEach(func(i uint64) {
 			rowID, columnID := i/ShardWidth, (f.shard*ShardWidth)+(i%ShardWidth)
 			if rowID == uint64(bitDepth) {
 				_, _ = other.Add(pos(bsiExistsBit, columnID)) // move exists bit to beginning
 			} else {
-				_, _ = other.Add(pos(rowID+bsiOffsetBit, columnID)) // move other bits up
+				_, _ = other.Add(pos(rowID+BSIOffsetBit, columnID)) // move other bits up
 			}
 		})
-	}()
+		return nil
+	}(); err != nil {
+		return "", err
+	}
 
 	// Create temporary file next to existing file.
 	newPath := f.path + ".tmp"
@@ -2757,7 +3467,7 @@ func upgradeRoaringBSIv2(f *fragment, bitDepth uint) (string, error) {
 	defer file.Close()
 
 	// Write & flush to temporary file.
-	if _, err := other.WriteTo(file); err != nil {
+	if _, err := f.writeStorage(other, file); err != nil {
 		return "", err
 	} else if err := file.Sync(); err != nil {
 		return "", err
@@ -2775,12 +3485,16 @@ type rowIterator struct {
 	wrap   bool
 }
 
-func (f *fragment) rowIterator(wrap bool, filters ...rowFilter) *rowIterator {
+func (f *fragment) rowIterator(wrap bool, filters ...rowFilter) (*rowIterator, error) {
+	rowIDs, err := f.rows(0, filters...) // TODO: this may be memory intensive in high cardinality cases
+	if err != nil {
+		return nil, err
+	}
 	return &rowIterator{
 		f:      f,
-		rowIDs: f.rows(0, filters...), // TODO: this may be memory intensive in high cardinality cases
+		rowIDs: rowIDs,
 		wrap:   wrap,
-	}
+	}, nil
 }
 
 func (ri *rowIterator) Seek(rowID uint64) {
@@ -2873,7 +3587,10 @@ func (s *fragmentSyncer) syncFragment() error {
 	for _, node := range nodes {
 		// Read local blocks.
 		if node.ID == s.Node.ID {
-			b := s.Fragment.Blocks()
+			b, err := s.Fragment.Blocks()
+			if err != nil {
+				return errors.Wrap(err, "reading local blocks")
+			}
 			blockSets = append(blockSets, b)
 			continue
 		}
@@ -26,9 +26,11 @@ import (
 	"os"
 	"reflect"
 	"sort"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"testing/quick"
+	"time"
 
 	"golang.org/x/sync/errgroup"
 
@@ -369,6 +371,89 @@ func TestFragment_SetValue(t *testing.T) {
 	})
 }
 
+// Ensure values can be rewritten in bulk for a set of columns.
+func TestFragment_UpdateValues(t *testing.T) {
+	f := mustOpenFragment("i", "f", viewStandard, 0, "")
+	defer f.Clean(t)
+
+	if _, err := f.setValue(1, 4, 3); err != nil {
+		t.Fatal(err)
+	} else if _, err := f.setValue(2, 4, -5); err != nil {
+		t.Fatal(err)
+	}
+
+	// Negate existing values, leaving columns without a value unset.
+	negate := func(v int64, exists bool) (int64, bool, error) { return -v, exists, nil }
+	if changed, depth, err := f.updateValues(NewRow(1, 2, 3), 4, negate); err != nil {
+		t.Fatal(err)
+	} else if !changed || depth != 4 {
+		t.Fatalf("unexpected result: %v, %d", changed, depth)
+	}
+	for col, exp := range map[uint64]int64{1: -3, 2: 5} {
+		if value, exists, err := f.value(col, 4); err != nil {
+			t.Fatal(err)
+		} else if !exists || value != exp {
+			t.Fatalf("unexpected value for %d: %d, %v", col, value, exists)
+		}
+	}
+	if _, exists, err := f.value(3, 4); err != nil {
+		t.Fatal(err)
+	} else if exists {
+		t.Fatal("expected no value")
+	}
+
+	// Values which need a larger bit depth are not written.
+	set := func(int64, bool) (int64, bool, error) { return 100, true, nil }
+	if changed, depth, err := f.updateValues(NewRow(3), 4, set); err != nil {
+		t.Fatal(err)
+	} else if changed || depth != 7 {
+		t.Fatalf("unexpected result: %v, %d", changed, depth)
+	} else if changed, _, err := f.updateValues(NewRow(3), 7, set); err != nil {
+		t.Fatal(err)
+	} else if !changed {
+		t.Fatal("expected change")
+	} else if value, _, err := f.value(3, 7); err != nil {
+		t.Fatal(err)
+	} else if value != 100 {
+		t.Fatalf("unexpected value: %d", value)
+	}
+}
+
+// Ensure a value can be read and replaced atomically.
+func TestFragment_UpdateValue(t *testing.T) {
+	f := mustOpenFragment("i", "f", viewStandard, 0, "")
+	defer f.Clean(t)
+
+	add := func(v int64, exists bool) (int64, bool, error) { return v + 3, true, nil }
+	for _, exp := range []int64{3, 6} {
+		if value, ok, depth, err := f.updateValue(100, 4, add); err != nil {
+			t.Fatal(err)
+		} else if value != exp || !ok || depth != 4 {
+			t.Fatalf("unexpected result: %d, %v, %d", value, ok, depth)
+		}
+	}
+
+	// A rejected update returns the value from fn without writing it.
+	reject := func(v int64, exists bool) (int64, bool, error) { return v, false, nil }
+	if value, ok, _, err := f.updateValue(100, 4, reject); err != nil {
+		t.Fatal(err)
+	} else if value != 6 || ok {
+		t.Fatalf("unexpected result: %d, %v", value, ok)
+	}
+
+	// Values which need a larger bit depth are not written.
+	set := func(int64, bool) (int64, bool, error) { return 100, true, nil }
+	if _, ok, depth, err := f.updateValue(100, 4, set); err != nil {
+		t.Fatal(err)
+	} else if ok || depth != 7 {
+		t.Fatalf("unexpected result: %v, %d", ok, depth)
+	} else if value, _, err := f.value(100, 4); err != nil {
+		t.Fatal(err)
+	} else if value != 6 {
+		t.Fatalf("unexpected value: %d", value)
+	}
+}
+
 // Ensure a fragment can sum values.
 func TestFragment_Sum(t *testing.T) {
 	const bitDepth = 16
@@ -710,12 +795,12 @@ func BenchmarkFragment_SetValue(b *testing.B) {
 	depths := []uint{4, 8, 16}
 	for _, bitDepth := range depths {
 		name := fmt.Sprintf("Depth%d", bitDepth)
-		f := mustOpenFragment("i", "f", viewBSIGroupPrefix+"foo", 0, "none")
+		f := mustOpenFragment("i", "f", ViewBSIGroupPrefix+"foo", 0, "none")
 		b.Run(name+"_Sparse", func(b *testing.B) {
 			benchmarkSetValues(b, bitDepth, f, func(u uint64) uint64 { return (u + 70000) & (ShardWidth - 1) })
 		})
 		f.Clean(b)
-		f = mustOpenFragment("i", "f", viewBSIGroupPrefix+"foo", 0, "none")
+		f = mustOpenFragment("i", "f", ViewBSIGroupPrefix+"foo", 0, "none")
 		b.Run(name+"_Dense", func(b *testing.B) {
 			benchmarkSetValues(b, bitDepth, f, func(u uint64) uint64 { return (u + 1) & (ShardWidth - 1) })
 		})
@@ -747,12 +832,12 @@ func BenchmarkFragment_ImportValue(b *testing.B) {
 	depths := []uint{4, 8, 16}
 	for _, bitDepth := range depths {
 		name := fmt.Sprintf("Depth%d", bitDepth)
-		f := mustOpenBSIFragment("i", "f", viewBSIGroupPrefix+"foo", 0)
+		f := mustOpenBSIFragment("i", "f", ViewBSIGroupPrefix+"foo", 0)
 		b.Run(name+"_Sparse", func(b *testing.B) {
 			benchmarkImportValues(b, bitDepth, f, func(u uint64) uint64 { return (u + 70000) & (ShardWidth - 1) })
 		})
 		f.Clean(b)
-		f = mustOpenBSIFragment("i", "f", viewBSIGroupPrefix+"foo", 0)
+		f = mustOpenBSIFragment("i", "f", ViewBSIGroupPrefix+"foo", 0)
 		b.Run(name+"_Dense", func(b *testing.B) {
 			benchmarkImportValues(b, bitDepth, f, func(u uint64) uint64 { return (u + 1) & (ShardWidth - 1) })
 		})
@@ -871,7 +956,7 @@ func BenchmarkFragment_RepeatedSmallValueImports(b *testing.B) {
 				b.Run(fmt.Sprintf("Updates%dVals%dOpN%d", numUpdates, valsPerUpdate, opN), func(b *testing.B) {
 					for i := 0; i < b.N; i++ {
 						b.StopTimer()
-						f := mustOpenBSIFragment("i", "f", viewBSIGroupPrefix+"foo", 0)
+						f := mustOpenBSIFragment("i", "f", ViewBSIGroupPrefix+"foo", 0)
 						f.MaxOpN = opN
 						err := f.importValue(initialCols, initialVals, 21, false)
 						if err != nil {
@@ -926,6 +1011,185 @@ func TestFragment_Snapshot(t *testing.T) {
 	}
 }
 
+// Ensure a fragment written with checksums can be reopened, and that a
+// corrupted container is detected on open.
+func TestFragment_Snapshot_Checksums(t *testing.T) {
+	f := mustOpenFragment("i", "f", viewStandard, 0, "")
+	defer f.Clean(t)
+	f.storageOpt.Checksums = true
+
+	f.mustSetBits(1000, 1, 2, ShardWidth-1)
+	if err := f.Snapshot(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Append to the ops log of the checksummed file, then reopen.
+	if _, err := f.setBit(1000, 3); err != nil {
+		t.Fatal(err)
+	} else if err := f.Reopen(); err != nil {
+		t.Fatal(err)
+	} else if n := f.row(1000).Count(); n != 4 {
+		t.Fatalf("unexpected count (reopen): %d", n)
+	}
+
+	if err := f.Snapshot(); err != nil {
+		t.Fatal(err)
+	} else if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		t.Fatal(err)
+	} else if !roaring.Checksummed(data) {
+		t.Fatal("expected checksummed data file")
+	}
+
+	// Flip a bit in the last container's data.
+	corrupt := append([]byte(nil), data...)
+	corrupt[len(corrupt)-1] ^= 0x01
+	if err := ioutil.WriteFile(f.path, corrupt, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Open(); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+
+	// Restore the file so it can be cleaned up.
+	if err := ioutil.WriteFile(f.path, data, 0600); err != nil {
+		t.Fatal(err)
+	} else if err := f.Open(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Ensure a fragment which hasn't been written to is compressed, that its
+// storage isn't loaded until it's used, that it can be written to and
+// reopened, and that a snapshot decompresses it.
+func TestFragment_CompressIfCold(t *testing.T) {
+	f := mustOpenFragment("i", "f", viewStandard, 0, "")
+	defer f.Clean(t)
+	f.storageOpt.ColdAfter = time.Hour
+
+	f.mustSetBits(1000, 1, 2, ShardWidth-1)
+	f.mustSetBits(1001, 3)
+	if err := f.Snapshot(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The fragment was just written to, so it isn't cold yet.
+	if ok, err := f.compressIfCold(); err != nil {
+		t.Fatal(err)
+	} else if ok {
+		t.Fatal("expected recently written fragment not to be compressed")
+	}
+
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(f.path, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := f.compressIfCold(); err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Fatal("expected fragment to be compressed")
+	} else if data, err := ioutil.ReadFile(f.path); err != nil {
+		t.Fatal(err)
+	} else if !roaring.Compressed(data) {
+		t.Fatal("expected compressed data file")
+	} else if !f.unloaded || f.storage.Any() {
+		t.Fatal("expected compressed storage not to be loaded")
+	} else if pairs, err := f.top(topOptions{N: 2}); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(pairs, []Pair{{ID: 1000, Count: 3}, {ID: 1001, Count: 1}}) {
+		t.Fatalf("unexpected pairs: %+v", pairs)
+	} else if f.unloaded {
+		t.Fatal("expected storage to be loaded once read")
+	}
+
+	// A cold fragment isn't loaded when it's opened. Ops are appended to
+	// the compressed data file.
+	if err := f.Reopen(); err != nil {
+		t.Fatal(err)
+	} else if !f.cold || !f.unloaded {
+		t.Fatal("expected reopened fragment to be cold and not loaded")
+	} else if _, err := f.setBit(1000, 4); err != nil {
+		t.Fatal(err)
+	} else if err := f.Reopen(); err != nil {
+		t.Fatal(err)
+	} else if !f.cold || !f.unloaded {
+		t.Fatal("expected reopened fragment to be cold and not loaded")
+	} else if n := f.row(1000).Count(); n != 4 {
+		t.Fatalf("unexpected count (reopen): %d", n)
+	} else if n := f.row(1001).Count(); n != 1 {
+		t.Fatalf("unexpected count (reopen): %d", n)
+	}
+
+	if err := f.Snapshot(); err != nil {
+		t.Fatal(err)
+	} else if data, err := ioutil.ReadFile(f.path); err != nil {
+		t.Fatal(err)
+	} else if roaring.Compressed(data) || f.cold {
+		t.Fatal("expected snapshot to decompress data file")
+	} else if n := f.row(1000).Count(); n != 4 {
+		t.Fatalf("unexpected count (snapshot): %d", n)
+	}
+}
+
+// Ensure reads of a cold fragment whose data file can't be loaded return an
+// error rather than reading it as empty.
+func TestFragment_CompressIfCold_Unreadable(t *testing.T) {
+	f := mustOpenFragment("i", "f", viewStandard, 0, "")
+	defer f.Clean(t)
+	f.storageOpt.ColdAfter = time.Hour
+
+	f.mustSetBits(1000, 1, 2)
+	if err := f.Snapshot(); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(f.path, old, old); err != nil {
+		t.Fatal(err)
+	} else if ok, err := f.compressIfCold(); err != nil || !ok {
+		t.Fatalf("expected fragment to be compressed: %v", err)
+	}
+
+	data, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		t.Fatal(err)
+	} else if err := ioutil.WriteFile(f.path, data[:len(data)/2], 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := f.readRow(1000); err == nil {
+		t.Fatal("expected row error")
+	} else if _, err := f.rows(0); err == nil {
+		t.Fatal("expected rows error")
+	} else if _, err := f.Blocks(); err == nil {
+		t.Fatal("expected blocks error")
+	} else if _, err := f.top(topOptions{N: 1}); err == nil {
+		t.Fatal("expected top error")
+	} else if _, _, err := f.minRow(nil); err == nil {
+		t.Fatal("expected min row error")
+	} else if err := f.RecalculateCache(); err == nil {
+		t.Fatal("expected recalculate cache error")
+	}
+
+	f.mu.Lock()
+	other := f.unprotectedFrozenCopy()
+	f.mu.Unlock()
+	if _, err := other.readRow(1000); err == nil {
+		t.Fatal("expected snapshot row error")
+	}
+
+	// Reads succeed once the data file is readable again.
+	if err := ioutil.WriteFile(f.path, data, 0666); err != nil {
+		t.Fatal(err)
+	} else if row, err := f.readRow(1000); err != nil {
+		t.Fatal(err)
+	} else if n := row.Count(); n != 2 {
+		t.Fatalf("unexpected count: %d", n)
+	}
+}
+
 // Ensure a fragment can iterate over all bits in order.
 func TestFragment_ForEachBit(t *testing.T) {
 	f := mustOpenFragment("i", "f", viewStandard, 0, "")
@@ -1200,7 +1464,10 @@ func TestFragment_Checksum(t *testing.T) {
 	defer f.Clean(t)
 
 	// Retrieve checksum and set bits.
-	orig := f.Checksum()
+	orig, err := f.Checksum()
+	if err != nil {
+		t.Fatal(err)
+	}
 	if _, err := f.setBit(1, 200); err != nil {
 		t.Fatal(err)
 	} else if _, err := f.setBit(HashBlockSize*2, 200); err != nil {
@@ -1208,7 +1475,9 @@ func TestFragment_Checksum(t *testing.T) {
 	}
 
 	// Ensure new checksum is different.
-	if chksum := f.Checksum(); bytes.Equal(chksum, orig) {
+	if chksum, err := f.Checksum(); err != nil {
+		t.Fatal(err)
+	} else if bytes.Equal(chksum, orig) {
 		t.Fatalf("expected checksum to change: %x - %x", chksum, orig)
 	}
 }
@@ -1225,8 +1494,10 @@ func TestFragment_Blocks(t *testing.T) {
 	if _, err := f.setBit(0, 0); err != nil {
 		t.Fatal(err)
 	}
-	blocks := f.Blocks()
-	if blocks[0].Checksum == nil {
+	blocks, err := f.Blocks()
+	if err != nil {
+		t.Fatal(err)
+	} else if blocks[0].Checksum == nil {
 		t.Fatalf("expected checksum: %x", blocks[0].Checksum)
 	}
 	prev = blocks
@@ -1235,8 +1506,9 @@ func TestFragment_Blocks(t *testing.T) {
 	if _, err := f.setBit(20, 0); err != nil {
 		t.Fatal(err)
 	}
-	blocks = f.Blocks()
-	if bytes.Equal(blocks[0].Checksum, prev[0].Checksum) {
+	if blocks, err = f.Blocks(); err != nil {
+		t.Fatal(err)
+	} else if bytes.Equal(blocks[0].Checksum, prev[0].Checksum) {
 		t.Fatalf("expected checksum to change: %x", blocks[0].Checksum)
 	}
 	prev = blocks
@@ -1245,8 +1517,9 @@ func TestFragment_Blocks(t *testing.T) {
 	if _, err := f.setBit(20, 100); err != nil {
 		t.Fatal(err)
 	}
-	blocks = f.Blocks()
-	if bytes.Equal(blocks[0].Checksum, prev[0].Checksum) {
+	if blocks, err = f.Blocks(); err != nil {
+		t.Fatal(err)
+	} else if bytes.Equal(blocks[0].Checksum, prev[0].Checksum) {
 		t.Fatalf("expected checksum to change: %x", blocks[0].Checksum)
 	}
 }
@@ -1262,7 +1535,9 @@ func TestFragment_Blocks_Empty(t *testing.T) {
 	}
 
 	// Ensure checksum for block 1 is blank.
-	if blocks := f.Blocks(); len(blocks) != 1 {
+	if blocks, err := f.Blocks(); err != nil {
+		t.Fatal(err)
+	} else if len(blocks) != 1 {
 		t.Fatalf("unexpected block count: %d", len(blocks))
 	} else if blocks[0].ID != 1 {
 		t.Fatalf("unexpected block id: %d", blocks[0].ID)
@@ -1423,7 +1698,9 @@ func BenchmarkFragment_Blocks(b *testing.B) {
 	// Reset timer and execute benchmark.
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		if a := f.Blocks(); len(a) == 0 {
+		if a, err := f.Blocks(); err != nil {
+			b.Fatal(err)
+		} else if len(a) == 0 {
 			b.Fatal("no blocks in fragment")
 		}
 	}
@@ -1699,6 +1976,55 @@ func TestFragment_ConcurrentImport(t *testing.T) {
 	})
 }
 
+// Ensure an upsert import replaces the rows of each imported column.
+func TestFragment_ImportUpsert(t *testing.T) {
+	t.Run("Standard", func(t *testing.T) {
+		f := mustOpenFragment("i", "f", viewStandard, 0, "")
+		defer f.Clean(t)
+
+		if err := f.bulkImport([]uint64{1, 1, 1, 2, 5, 6, 6, 7}, []uint64{1, 2, 3, 2, ShardWidth - 1, 70000, 70001, 70001}, &ImportOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.bulkImport([]uint64{3, 1, 4, 8}, []uint64{2, 3, 2, 70000}, &ImportOptions{Upsert: true}); err != nil {
+			t.Fatal(err)
+		}
+
+		for rowID, exp := range map[uint64][]uint64{
+			1: {1, 3},
+			2: {},
+			3: {2},
+			4: {2},
+			5: {ShardWidth - 1},
+			6: {70001},
+			7: {70001},
+			8: {70000},
+		} {
+			if cols := f.row(rowID).Columns(); !reflect.DeepEqual(cols, exp) {
+				t.Fatalf("row %d: expected %v, got %v", rowID, exp, cols)
+			} else if n := f.cache.Get(rowID); n != uint64(len(exp)) {
+				t.Fatalf("row %d: unexpected cache count: %d", rowID, n)
+			}
+		}
+	})
+
+	// Only the standard view is replaced, so that time views keep history.
+	t.Run("TimeView", func(t *testing.T) {
+		f := mustOpenFragment("i", "f", viewStandard+"_2019", 0, "")
+		defer f.Clean(t)
+
+		if err := f.bulkImport([]uint64{1}, []uint64{1}, &ImportOptions{}); err != nil {
+			t.Fatal(err)
+		} else if err := f.bulkImport([]uint64{2}, []uint64{1}, &ImportOptions{Upsert: true}); err != nil {
+			t.Fatal(err)
+		}
+		if cols := f.row(1).Columns(); !reflect.DeepEqual(cols, []uint64{1}) {
+			t.Fatalf("unexpected row 1: %v", cols)
+		} else if cols := f.row(2).Columns(); !reflect.DeepEqual(cols, []uint64{1}) {
+			t.Fatalf("unexpected row 2: %v", cols)
+		}
+	})
+}
+
 // Ensure a fragment can import mutually exclusive values.
 func TestFragment_ImportMutex(t *testing.T) {
 	tests := []struct {
@@ -2359,8 +2685,8 @@ func TestGetZipfRowsSliceRoaring(t *testing.T) {
 	if err != nil {
 		t.Fatalf("importing roaring: %v", err)
 	}
-	if !reflect.DeepEqual(f.rows(0), []uint64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}) {
-		t.Fatalf("unexpected rows: %v", f.rows(0))
+	if !reflect.DeepEqual(f.mustRows(0), []uint64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}) {
+		t.Fatalf("unexpected rows: %v", f.mustRows(0))
 	}
 	for i := uint64(1); i < 10; i++ {
 		if f.row(i).Count() >= f.row(i-1).Count() {
@@ -2622,6 +2948,22 @@ func (f *fragment) mustSetBits(rowID uint64, columnIDs ...uint64) {
 	}
 }
 
+func (f *fragment) mustRows(start uint64, filters ...rowFilter) []uint64 {
+	rows, err := f.rows(start, filters...)
+	if err != nil {
+		panic(err)
+	}
+	return rows
+}
+
+func (f *fragment) mustRowIterator(wrap bool, filters ...rowFilter) *rowIterator {
+	ri, err := f.rowIterator(wrap, filters...)
+	if err != nil {
+		panic(err)
+	}
+	return ri
+}
+
 func addToBitmap(bm *roaring.Bitmap, rowID uint64, columnIDs ...uint64) {
 	// we'll reuse the columnIDs slice and fill it with positions for DirectAddN
 	for i, c := range columnIDs {
@@ -2648,12 +2990,12 @@ func TestFragment_RowsIteration(t *testing.T) {
 			}
 		}
 
-		ids := f.rows(0)
+		ids := f.mustRows(0)
 		if !reflect.DeepEqual(expectedAll, ids) {
 			t.Fatalf("Do not match %v %v", expectedAll, ids)
 		}
 
-		ids = f.rows(0, filterColumn(1))
+		ids = f.mustRows(0, filterColumn(1))
 		if !reflect.DeepEqual(expectedOdd, ids) {
 			t.Fatalf("Do not match %v %v", expectedOdd, ids)
 		}
@@ -2672,12 +3014,12 @@ func TestFragment_RowsIteration(t *testing.T) {
 			t.Fatal(err)
 		}
 
-		ids := f.rows(0)
+		ids := f.mustRows(0)
 		if !reflect.DeepEqual(expected, ids) {
 			t.Fatalf("Do not match %v %v", expected, ids)
 		}
 
-		ids = f.rows(0, filterColumn(66000))
+		ids = f.mustRows(0, filterColumn(66000))
 		if !reflect.DeepEqual(expected, ids) {
 			t.Fatalf("Do not match %v %v", expected, ids)
 		}
@@ -2695,11 +3037,11 @@ func TestFragment_RowsIteration(t *testing.T) {
 					t.Fatal(err)
 				}
 
-				ids := f.rows(0)
+				ids := f.mustRows(0)
 				if !reflect.DeepEqual(expectedRows, ids) {
 					t.Fatalf("Do not match %v %v", expectedRows, ids)
 				}
-				ids = f.rows(0, filterColumn(c))
+				ids = f.mustRows(0, filterColumn(c))
 				if !reflect.DeepEqual(expectedRows, ids) {
 					t.Fatalf("Do not match %v %v", expectedRows, ids)
 				}
@@ -2921,7 +3263,7 @@ func TestFragmentRowIterator(t *testing.T) {
 		f.mustSetBits(2, 0)
 		f.mustSetBits(3, 0)
 
-		iter := f.rowIterator(false)
+		iter := f.mustRowIterator(false)
 		for i := uint64(0); i < 4; i++ {
 			row, id, wrapped := iter.Next()
 			if id != i {
@@ -2954,7 +3296,7 @@ func TestFragmentRowIterator(t *testing.T) {
 		f.mustSetBits(5, 0)
 		f.mustSetBits(7, 0)
 
-		iter := f.rowIterator(false)
+		iter := f.mustRowIterator(false)
 		for i := uint64(1); i < 8; i += 2 {
 			row, id, wrapped := iter.Next()
 			if id != i {
@@ -2987,7 +3329,7 @@ func TestFragmentRowIterator(t *testing.T) {
 		f.mustSetBits(2, 0)
 		f.mustSetBits(3, 0)
 
-		iter := f.rowIterator(true)
+		iter := f.mustRowIterator(true)
 		for i := uint64(0); i < 5; i++ {
 			row, id, wrapped := iter.Next()
 			if id != i%4 {
@@ -3012,7 +3354,7 @@ func TestFragmentRowIterator(t *testing.T) {
 		f.mustSetBits(5, 0)
 		f.mustSetBits(7, 0)
 
-		iter := f.rowIterator(true)
+		iter := f.mustRowIterator(true)
 		for i := uint64(1); i < 10; i += 2 {
 			row, id, wrapped := iter.Next()
 			if id != i%8 {
@@ -3338,7 +3680,7 @@ func check(t *testing.T, f *fragment, exp map[uint64]map[uint64]struct{}) {
 }
 
 func TestImportValueConcurrent(t *testing.T) {
-	f := mustOpenBSIFragment("i", "f", viewBSIGroupPrefix+"foo", 0)
+	f := mustOpenBSIFragment("i", "f", ViewBSIGroupPrefix+"foo", 0)
 	eg := &errgroup.Group{}
 	for i := 0; i < 4; i++ {
 		i := i
@@ -3378,7 +3720,7 @@ func TestImportMultipleValues(t *testing.T) {
 	for i, test := range tests {
 		for _, maxOpN := range []int{0, 10000} { // test small/large write
 			t.Run(fmt.Sprintf("%dLowOpN", i), func(t *testing.T) {
-				f := mustOpenBSIFragment("i", "f", viewBSIGroupPrefix+"foo", 0)
+				f := mustOpenBSIFragment("i", "f", ViewBSIGroupPrefix+"foo", 0)
 				f.MaxOpN = maxOpN
 				defer f.Clean(t)
 				err := f.importValue(test.cols, test.vals, test.depth, false)
@@ -3435,7 +3777,7 @@ func TestImportValueRowCache(t *testing.T) {
 	for i, test := range tests {
 		for _, maxOpN := range []int{1, 10000} {
 			t.Run(fmt.Sprintf("%dMaxOpN%d", i, maxOpN), func(t *testing.T) {
-				f := mustOpenBSIFragment("i", "f", viewBSIGroupPrefix+"foo", 0)
+				f := mustOpenBSIFragment("i", "f", ViewBSIGroupPrefix+"foo", 0)
 				f.MaxOpN = maxOpN
 				defer f.Clean(t)
 
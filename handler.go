@@ -39,6 +39,13 @@ type QueryRequest struct {
 	// Do not return columns, if true.
 	ExcludeColumns bool
 
+	// Apply all Set() and Clear() calls together or not at all, if true.
+	Atomic bool
+
+	// Number of replicas which must acknowledge each write. If empty, the
+	// server default is used.
+	WriteConsistency WriteConsistency
+
 	// If true, indicates that query is part of a larger distributed query.
 	// If false, this request is on the originating node.
 	Remote bool
@@ -74,6 +81,29 @@ func (resp *QueryResponse) MarshalJSON() ([]byte, error) {
 	})
 }
 
+// QueryResultChunk is a piece of a streamed query response. The chunks for
+// each top-level call are sent in call order. Bitmap results are sent as any
+// number of column batches (in no particular order) followed by a final chunk
+// holding the row attributes. All other results are sent as a single chunk.
+type QueryResultChunk struct {
+	// Index of the top-level call which produced the chunk.
+	Index int `json:"index"`
+
+	// A batch of columns belonging to a bitmap result. Keys is used
+	// instead of Columns if the index uses keys.
+	Columns []uint64 `json:"columns,omitempty"`
+	Keys    []string `json:"keys,omitempty"`
+
+	// Row attributes of a bitmap result.
+	Attrs map[string]interface{} `json:"attrs,omitempty"`
+
+	// Result of a non-bitmap call.
+	Result interface{} `json:"result,omitempty"`
+
+	// Done is true for the last chunk of a result.
+	Done bool `json:"done,omitempty"`
+}
+
 // Handler is the interface for the data handler, a wrapper around
 // Pilosa's data store.
 type Handler interface {
@@ -123,6 +153,10 @@ type ImportRequest struct {
 type ImportRoaringRequest struct {
 	Clear bool
 	Views map[string][]byte
+
+	// Idempotency token of the import. It is sent as a URL argument since
+	// it isn't part of the encoded request.
+	Token string
 }
 
 // ImportResponse is the structured response of an import.
@@ -0,0 +1,381 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pilosa
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pilosa/pilosa/v2/logger"
+	"github.com/pilosa/pilosa/v2/pql"
+	"github.com/pkg/errors"
+)
+
+// WriteConsistency is the number of a shard's replicas which must
+// acknowledge a write before it is reported as successful.
+type WriteConsistency string
+
+// Write consistency levels.
+const (
+	WriteConsistencyOne    WriteConsistency = "one"
+	WriteConsistencyQuorum WriteConsistency = "quorum"
+	WriteConsistencyAll    WriteConsistency = "all"
+)
+
+// validate returns an error if c is not a known consistency level. The
+// empty level is valid and means the server default.
+func (c WriteConsistency) validate() error {
+	switch c {
+	case "", WriteConsistencyOne, WriteConsistencyQuorum, WriteConsistencyAll:
+		return nil
+	}
+	return ErrInvalidWriteConsistency
+}
+
+// required returns the number of acknowledgements needed out of n replicas.
+func (c WriteConsistency) required(n int) int {
+	switch c {
+	case WriteConsistencyOne:
+		return 1
+	case WriteConsistencyQuorum:
+		return n/2 + 1
+	default:
+		return n
+	}
+}
+
+// hint is a write which couldn't be delivered to a replica.
+type hint struct {
+	Index string `json:"index"`
+	Query string `json:"query"`
+}
+
+// hintedHandoff queues writes for replicas which couldn't be reached, and
+// replays them once the replicas recover. Hints are appended to a file per
+// node so that they survive a restart.
+type hintedHandoff struct {
+	mu      sync.Mutex
+	path    string
+	pending map[string]int // number of hints queued, by node ID
+
+	// Serializes replays to each node, by node ID.
+	replayMu map[string]*sync.Mutex
+
+	client InternalQueryClient
+	logger logger.Logger
+}
+
+// newHintedHandoff returns a hinted handoff queue stored in path.
+func newHintedHandoff(path string, client InternalQueryClient, logger logger.Logger) *hintedHandoff {
+	return &hintedHandoff{
+		path:     path,
+		pending:  make(map[string]int),
+		replayMu: make(map[string]*sync.Mutex),
+		client:   client,
+		logger:   logger,
+	}
+}
+
+// Open counts the hints left over from a previous run.
+func (h *hintedHandoff) Open() error {
+	if err := os.MkdirAll(h.path, 0777); err != nil {
+		return errors.Wrap(err, "creating hints directory")
+	}
+	fis, err := ioutil.ReadDir(h.path)
+	if err != nil {
+		return errors.Wrap(err, "reading hints directory")
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, fi := range fis {
+		if fi.IsDir() || filepath.Ext(fi.Name()) == ".tmp" {
+			continue
+		}
+		hints, err := h.readHints(fi.Name())
+		if err != nil {
+			return err
+		}
+		if len(hints) > 0 {
+			h.pending[fi.Name()] = len(hints)
+		}
+	}
+	return nil
+}
+
+// Pending returns the number of hints queued for a node.
+func (h *hintedHandoff) Pending(nodeID string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.pending[nodeID]
+}
+
+// Nodes returns the IDs of the nodes with queued hints.
+func (h *hintedHandoff) Nodes() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ids := make([]string, 0, len(h.pending))
+	for id := range h.pending {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Add queues a write for a node.
+func (h *hintedHandoff) Add(nodeID, index, query string) error {
+	buf, err := json.Marshal(hint{Index: index, Query: query})
+	if err != nil {
+		return errors.Wrap(err, "marshaling hint")
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	f, err := os.OpenFile(filepath.Join(h.path, nodeID), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return errors.Wrap(err, "opening hints file")
+	}
+	defer f.Close()
+	if _, err := f.Write(append(buf, '\n')); err != nil {
+		return errors.Wrap(err, "writing hint")
+	} else if err := f.Sync(); err != nil {
+		return errors.Wrap(err, "syncing hints file")
+	}
+	h.pending[nodeID]++
+	return nil
+}
+
+// Replay sends the hints queued for node in the order they were added. It
+// stops at the first hint which can't be delivered, leaving it and any
+// later hints queued. Hints which the node rejects are logged and dropped,
+// since sending them again would fail in the same way.
+func (h *hintedHandoff) Replay(ctx context.Context, node *Node) error {
+	h.mu.Lock()
+	mu := h.replayMu[node.ID]
+	if mu == nil {
+		mu = &sync.Mutex{}
+		h.replayMu[node.ID] = mu
+	}
+	h.mu.Unlock()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	h.mu.Lock()
+	hints, err := h.readHints(node.ID)
+	h.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	var n int
+	var sendErr error
+	for _, hint := range hints {
+		if _, err := h.client.QueryNode(ctx, &node.URI, hint.Index, &QueryRequest{
+			Query:  hint.Query,
+			Remote: true,
+		}); isUnreachable(err) {
+			sendErr = err
+			break
+		} else if err != nil {
+			h.logger.Printf("dropping hinted write to node %s: %s: %s", node.ID, hint.Query, err)
+		}
+		n++
+	}
+
+	// Drop the hints which were sent. Hints may have been added while
+	// replaying, so the file is read again.
+	if n > 0 {
+		h.mu.Lock()
+		err := h.truncateHints(node.ID, n)
+		h.mu.Unlock()
+		if err != nil {
+			return err
+		}
+		h.logger.Printf("replayed %d hinted writes to node %s", n, node.ID)
+	}
+	return errors.Wrap(sendErr, "replaying hint")
+}
+
+// readHints returns the hints queued for a node. h.mu must be held.
+func (h *hintedHandoff) readHints(nodeID string) ([]hint, error) {
+	buf, err := ioutil.ReadFile(filepath.Join(h.path, nodeID))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "reading hints file")
+	}
+
+	var hints []hint
+	scanner := bufio.NewScanner(bytes.NewReader(buf))
+	scanner.Buffer(nil, len(buf)+1)
+	for scanner.Scan() {
+		var hint hint
+		if err := json.Unmarshal(scanner.Bytes(), &hint); err != nil {
+			return nil, errors.Wrapf(err, "unmarshaling hint for node %s", nodeID)
+		}
+		hints = append(hints, hint)
+	}
+	return hints, errors.Wrap(scanner.Err(), "scanning hints file")
+}
+
+// truncateHints removes the first n hints queued for a node. h.mu must be
+// held.
+func (h *hintedHandoff) truncateHints(nodeID string, n int) error {
+	hints, err := h.readHints(nodeID)
+	if err != nil {
+		return err
+	}
+	hints = hints[n:]
+
+	path := filepath.Join(h.path, nodeID)
+	if len(hints) == 0 {
+		delete(h.pending, nodeID)
+		return errors.Wrap(os.Remove(path), "removing hints file")
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, hint := range hints {
+		if err := enc.Encode(hint); err != nil {
+			return errors.Wrap(err, "marshaling hint")
+		}
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, buf.Bytes(), 0666); err != nil {
+		return errors.Wrap(err, "writing hints file")
+	} else if err := os.Rename(tmp, path); err != nil {
+		return errors.Wrap(err, "renaming hints file")
+	}
+	h.pending[nodeID] = len(hints)
+	return nil
+}
+
+// isUnreachable returns true if err means that a node couldn't be reached,
+// as opposed to the node rejecting the request.
+func isUnreachable(err error) bool {
+	_, ok := errors.Cause(err).(net.Error)
+	return ok
+}
+
+// writeReplicas applies a write to every replica of shard. The local
+// replica is written by calling local; the call is forwarded to the others.
+//
+// Replicas with queued hints are presumed unreachable until the background
+// replay loop has delivered them, and writes to them are queued behind the
+// hints so that every replica applies writes in the same order. If too few
+// replicas remain to meet the consistency level, the write is rejected
+// with ErrWriteConsistencyNotMet before anything is written.
+//
+// Replicas found to be unreachable while writing are sent the write later
+// through the hinted handoff queue. If that leaves fewer acknowledgements
+// than the consistency level requires, ErrWriteNotReplicated is returned:
+// the write has been applied to the replicas which acknowledged it and will
+// reach the others once they recover.
+func (e *executor) writeReplicas(ctx context.Context, index string, shard uint64, c *pql.Call, opt *execOptions, local func() (bool, error)) (bool, error) {
+	nodes := e.Cluster.shardNodes(index, shard)
+
+	// Remote calls are only applied locally.
+	if opt.Remote {
+		for _, node := range nodes {
+			if node.ID == e.Node.ID {
+				return local()
+			}
+		}
+		return false, nil
+	}
+
+	consistency := opt.WriteConsistency
+	if consistency == "" {
+		consistency = e.WriteConsistency
+	}
+	required := consistency.required(len(nodes))
+
+	if e.handoff != nil {
+		var available int
+		for _, node := range nodes {
+			if node.ID == e.Node.ID || e.handoff.Pending(node.ID) == 0 {
+				available++
+			}
+		}
+		if available < required {
+			return false, errors.Wrapf(ErrWriteConsistencyNotMet, "%d of %d replicas available, %d required", available, len(nodes), required)
+		}
+	}
+
+	var ret bool
+	var acks int
+	var missed []string
+	for _, node := range nodes {
+		// Update locally if host matches.
+		if node.ID == e.Node.ID {
+			val, err := local()
+			if err != nil {
+				return false, err
+			}
+			ret = ret || val
+			acks++
+			continue
+		}
+
+		// Queue the write behind any hints already queued for the node.
+		if e.handoff != nil && e.handoff.Pending(node.ID) > 0 {
+			if err := e.handoff.Add(node.ID, index, c.String()); err != nil {
+				return false, errors.Wrap(err, "queueing hinted write")
+			}
+			missed = append(missed, node.ID)
+			continue
+		}
+
+		// Forward call to remote node otherwise.
+		res, err := e.remoteExec(ctx, node, index, &pql.Query{Calls: []*pql.Call{c}}, nil)
+		if err != nil {
+			if e.handoff == nil || !isUnreachable(err) {
+				return false, err
+			}
+			if err := e.handoff.Add(node.ID, index, c.String()); err != nil {
+				return false, errors.Wrap(err, "queueing hinted write")
+			}
+			missed = append(missed, node.ID)
+			continue
+		}
+		ret = ret || res[0].(bool)
+		acks++
+	}
+
+	if acks < required {
+		return false, errors.Wrapf(ErrWriteNotReplicated, "%d of %d replicas acknowledged the write, %d required (queued for: %v)", acks, len(nodes), required, missed)
+	}
+	return ret, nil
+}
+
+// replayHints replays the hints queued for every node which can be reached.
+func (e *executor) replayHints(ctx context.Context) {
+	for _, id := range e.handoff.Nodes() {
+		node := e.Cluster.nodeByID(id)
+		if node == nil {
+			continue
+		}
+		if err := e.handoff.Replay(ctx, node); err != nil {
+			e.Holder.Logger.Debugf("replaying hints to node %s: %s", id, err)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pilosa
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/pilosa/pilosa/v2/logger"
+	"github.com/pilosa/pilosa/v2/pql"
+	"github.com/pkg/errors"
+)
+
+// handoffTestClient records the queries sent to each node, failing those
+// sent to nodes which are down.
+type handoffTestClient struct {
+	mu      sync.Mutex
+	down    map[string]bool
+	reject  map[string]bool
+	queries map[string][]string
+}
+
+func (c *handoffTestClient) QueryNode(ctx context.Context, uri *URI, index string, req *QueryRequest) (*QueryResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.down[uri.Host] {
+		return nil, &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+	} else if c.reject[req.Query] {
+		return nil, errors.New("rejected")
+	}
+	c.queries[uri.Host] = append(c.queries[uri.Host], req.Query)
+	return &QueryResponse{Results: []interface{}{true}}, nil
+}
+
+func TestExecutor_WriteConsistency(t *testing.T) {
+	path, err := ioutil.TempDir(*TempDir, "pilosa-handoff-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+
+	client := &handoffTestClient{
+		down:    map[string]bool{"node1": true},
+		reject:  map[string]bool{},
+		queries: map[string][]string{},
+	}
+	e := newExecutor(optExecutorInternalQueryClient(client))
+	defer e.Close()
+	e.Holder = NewHolder()
+	e.Holder.Path = filepath.Join(path, "data")
+	if err := e.Holder.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer e.Holder.Close()
+	if _, err := e.Holder.CreateIndex("i", IndexOptions{}); err != nil {
+		t.Fatal(err)
+	} else if _, err := e.Holder.Index("i").CreateField("f"); err != nil {
+		t.Fatal(err)
+	}
+
+	e.Cluster = newCluster()
+	e.Cluster.ReplicaN = 3
+	for _, id := range []string{"node0", "node1", "node2"} {
+		e.Cluster.addNodeBasicSorted(&Node{ID: id, URI: URI{Scheme: "http", Host: id, Port: 10101}})
+	}
+	e.Node = e.Cluster.nodeByID("node0")
+	e.WriteConsistency = WriteConsistencyAll
+	e.handoff = newHintedHandoff(filepath.Join(path, "hints"), client, logger.NopLogger)
+	if err := e.handoff.Open(); err != nil {
+		t.Fatal(err)
+	}
+
+	write := func(query string, consistency WriteConsistency) error {
+		q, err := pql.ParseString(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = e.Execute(context.Background(), "i", q, nil, &execOptions{WriteConsistency: consistency})
+		return err
+	}
+
+	t.Run("Levels", func(t *testing.T) {
+		// Node 1 is found to be down while writing, so the write is only
+		// accepted.
+		if err := write(`Set(1, f=1)`, ""); errors.Cause(err) != ErrWriteNotReplicated {
+			t.Fatalf("expected not replicated error, got %v", err)
+		} else if err := write(`Set(2, f=1)`, WriteConsistencyQuorum); err != nil {
+			t.Fatal(err)
+		} else if err := write(`Clear(1, f=1)`, WriteConsistencyOne); err != nil {
+			t.Fatal(err)
+		}
+
+		// Node 1 gets no writes, but every write is queued for it.
+		if n := e.handoff.Pending("node1"); n != 3 {
+			t.Fatalf("unexpected pending hints: %d", n)
+		} else if q := client.queries["node2"]; len(q) != 3 {
+			t.Fatalf("unexpected node2 queries: %v", q)
+		}
+
+		// Node 1 has queued writes, so a write to every replica is rejected
+		// before anything is written.
+		if err := write(`Set(7, f=1)`, ""); errors.Cause(err) != ErrWriteConsistencyNotMet {
+			t.Fatalf("expected consistency error, got %v", err)
+		} else if n := e.handoff.Pending("node1"); n != 3 {
+			t.Fatalf("unexpected pending hints: %d", n)
+		} else if q := client.queries["node2"]; len(q) != 3 {
+			t.Fatalf("unexpected node2 queries: %v", q)
+		} else if row, err := e.Holder.Field("i", "f").Row(1); err != nil {
+			t.Fatal(err)
+		} else if cols := row.Columns(); !reflect.DeepEqual(cols, []uint64{2}) {
+			t.Fatalf("unexpected columns: %v", cols)
+		}
+
+		// Quorum isn't reached once node 2 is found to be down too.
+		client.down["node2"] = true
+		if err := write(`Set(3, f=1)`, WriteConsistencyQuorum); errors.Cause(err) != ErrWriteNotReplicated {
+			t.Fatalf("expected not replicated error, got %v", err)
+		} else if err := write(`Set(4, f=1)`, WriteConsistencyOne); err != nil {
+			t.Fatal(err)
+		}
+		client.down["node2"] = false
+	})
+
+	t.Run("Replay", func(t *testing.T) {
+		// Hints survive a restart.
+		e.handoff = newHintedHandoff(filepath.Join(path, "hints"), client, logger.NopLogger)
+		if err := e.handoff.Open(); err != nil {
+			t.Fatal(err)
+		} else if n := e.handoff.Pending("node1"); n != 5 {
+			t.Fatalf("unexpected pending hints: %d", n)
+		}
+
+		// Writes are queued behind the hints, even once node 1 recovers,
+		// rather than replaying them.
+		client.down["node1"] = false
+		client.reject["Set(_col=3, f=1)"] = true
+		if err := write(`Set(5, f=1)`, WriteConsistencyOne); err != nil {
+			t.Fatal(err)
+		} else if q := client.queries["node1"]; len(q) != 0 {
+			t.Fatalf("unexpected node1 queries: %v", q)
+		}
+
+		// The replay loop sends the queued writes in order.
+		e.replayHints(context.Background())
+		exp := []string{"Set(_col=1, f=1)", "Set(_col=2, f=1)", "Clear(_col=1, f=1)", "Set(_col=4, f=1)", "Set(_col=5, f=1)"}
+		if q := client.queries["node1"]; !reflect.DeepEqual(q, exp) {
+			t.Fatalf("unexpected node1 queries: %v", q)
+		} else if n := e.handoff.Pending("node1"); n != 0 {
+			t.Fatalf("unexpected pending hints: %d", n)
+		} else if n := e.handoff.Pending("node2"); n != 0 {
+			t.Fatalf("unexpected node2 pending hints: %d", n)
+		}
+
+		// Once replayed, writes reach every replica again.
+		if err := write(`Set(6, f=1)`, ""); err != nil {
+			t.Fatal(err)
+		}
+		exp = []string{"Set(_col=4, f=1)", "Set(_col=5, f=1)", "Set(_col=6, f=1)"}
+		if q := client.queries["node2"]; !reflect.DeepEqual(q[3:], exp) {
+			t.Fatalf("unexpected node2 queries: %v", q)
+		}
+	})
+
+	t.Run("InvalidLevel", func(t *testing.T) {
+		if err := WriteConsistency("most").validate(); err != ErrInvalidWriteConsistency {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+}
@@ -39,6 +39,10 @@ const (
 	// defaultCacheFlushInterval is the default value for Fragment.CacheFlushInterval.
 	defaultCacheFlushInterval = 1 * time.Minute
 
+	// defaultColdStorageInterval is the default interval at which fragments
+	// are checked for being cold.
+	defaultColdStorageInterval = 1 * time.Minute
+
 	// fileLimit is the maximum open file limit (ulimit -n) to automatically set.
 	fileLimit = 262144 // (512^2)
 
@@ -73,10 +77,21 @@ type Holder struct {
 	// The interval at which the cached row ids are persisted to disk.
 	cacheFlushInterval time.Duration
 
+	// The interval at which fragments are checked for being cold.
+	coldStorageInterval time.Duration
+
 	Logger logger.Logger
 
 	snapshotQueue chan *fragment
 
+	// Options for the data files of fragments.
+	Storage StorageOptions
+
+	// Moves fragments which can't be read aside on open, instead of
+	// failing to open.
+	QuarantineCorruptFragments bool
+	quarantine                 *fragmentQuarantine
+
 	// Manages replication from the primary node.
 	primaryTranslateNode     *Node
 	translateStoreReplicator *holderTranslateStoreReplicator
@@ -84,6 +99,13 @@ type Holder struct {
 	// Instantiates new translation stores for indexes & fields.
 	OpenTranslateStore  OpenTranslateStoreFunc  // local store
 	OpenTranslateReader OpenTranslateReaderFunc // replication
+
+	// Fragments pinned for backups, by backup ID.
+	backupMu sync.Mutex
+	backups  map[string]*backupPins
+
+	// Blocks writes while a backup pins fragments.
+	backupBarrier *backupBarrier
 }
 
 // lockedChan looks a little ridiculous admittedly, but exists for good reason.
@@ -117,6 +139,9 @@ func NewHolder() *Holder {
 	return &Holder{
 		indexes: make(map[string]*Index),
 		closing: make(chan struct{}),
+		backups: make(map[string]*backupPins),
+
+		backupBarrier: newBackupBarrier(),
 
 		opened: lockedChan{ch: make(chan struct{})},
 
@@ -125,7 +150,8 @@ func NewHolder() *Holder {
 
 		NewAttrStore: newNopAttrStore,
 
-		cacheFlushInterval: defaultCacheFlushInterval,
+		cacheFlushInterval:  defaultCacheFlushInterval,
+		coldStorageInterval: defaultColdStorageInterval,
 
 		Logger: logger.NopLogger,
 
@@ -145,6 +171,11 @@ func (h *Holder) Open() error {
 		return errors.Wrap(err, "creating directory")
 	}
 
+	// Backups don't survive a restart, so remove any fragments they pinned.
+	if err := os.RemoveAll(filepath.Join(h.Path, backupDir)); err != nil {
+		return errors.Wrap(err, "removing backup pins")
+	}
+
 	// Open path to read all index directories.
 	f, err := os.Open(h.Path)
 	if err != nil {
@@ -162,6 +193,11 @@ func (h *Holder) Open() error {
 	// is closed, so we should always close this channel when done.
 	h.snapshotQueue = newSnapshotQueue(100, 2, h.Logger)
 
+	h.quarantine = nil
+	if h.QuarantineCorruptFragments {
+		h.quarantine = &fragmentQuarantine{path: h.Path, logger: h.Logger}
+	}
+
 	for _, fi := range fis {
 		// Skip files or hidden directories.
 		if !fi.IsDir() || strings.HasPrefix(fi.Name(), ".") {
@@ -189,12 +225,21 @@ func (h *Holder) Open() error {
 		h.indexes[index.Name()] = index
 		h.mu.Unlock()
 	}
+	if err := h.markQuarantinedShards(); err != nil {
+		return errors.Wrap(err, "marking quarantined shards")
+	}
 	h.Logger.Printf("open holder: complete")
 
 	// Periodically flush cache.
 	h.wg.Add(1)
 	go func() { defer h.wg.Done(); h.monitorCacheFlush() }()
 
+	// Periodically compress cold fragments.
+	if h.Storage.ColdAfter > 0 {
+		h.wg.Add(1)
+		go func() { defer h.wg.Done(); h.monitorColdStorage() }()
+	}
+
 	h.Stats.Open()
 
 	h.opened.Close()
@@ -263,7 +308,7 @@ func (h *Holder) HasData() (bool, error) {
 	}
 
 	for _, fi := range fis {
-		if !fi.IsDir() {
+		if !fi.IsDir() || strings.HasPrefix(fi.Name(), ".") {
 			continue
 		}
 		return true, nil
@@ -442,6 +487,8 @@ func (h *Holder) newIndex(path, name string) (*Index, error) {
 	index.newAttrStore = h.NewAttrStore
 	index.columnAttrs = h.NewAttrStore(filepath.Join(index.path, ".data"))
 	index.snapshotQueue = h.snapshotQueue
+	index.quarantine = h.quarantine
+	index.storageOpt = h.Storage
 	index.holder = h
 	index.OpenTranslateStore = h.OpenTranslateStore
 	return index, nil
@@ -537,14 +584,53 @@ func (h *Holder) flushCaches() {
 	}
 }
 
+func (h *Holder) monitorColdStorage() {
+	ticker := time.NewTicker(h.coldStorageInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-h.closing:
+			return
+		case <-ticker.C:
+			h.compressColdFragments()
+		}
+	}
+}
+
+// compressColdFragments compresses the data files of fragments which haven't
+// been written to for Storage.ColdAfter.
+func (h *Holder) compressColdFragments() {
+	for _, index := range h.Indexes() {
+		for _, field := range index.Fields() {
+			for _, view := range field.views() {
+				for _, fragment := range view.allFragments() {
+					select {
+					case <-h.closing:
+						return
+					default:
+					}
+
+					if _, err := fragment.compressIfCold(); err != nil {
+						h.Logger.Printf("ERROR compressing cold fragment: err=%s, path=%s", err, fragment.path)
+					}
+				}
+			}
+		}
+	}
+}
+
 // recalculateCaches recalculates caches on every index in the holder. This is
 // probably not practical to call in real-world workloads, but makes writing
 // integration tests much eaiser, since one doesn't have to wait 10 seconds
 // after setting bits to get expected response.
-func (h *Holder) recalculateCaches() {
+func (h *Holder) recalculateCaches() error {
 	for _, index := range h.Indexes() {
-		index.recalculateCaches()
+		if err := index.recalculateCaches(); err != nil {
+			return errors.Wrapf(err, "index %s", index.Name())
+		}
 	}
+	return nil
 }
 
 // setFileLimit attempts to set the open file limit to the FileLimit constant defined above.
@@ -21,6 +21,7 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/pilosa/pilosa/v2/roaring"
 )
@@ -321,3 +322,61 @@ func TestHolderCleaner_Reopen(t *testing.T) {
 		t.Fatalf("couldn't close holder: %v", err)
 	}
 }
+
+// Ensure cold fragments aren't loaded when the holder is opened, including
+// those of int fields, and are loaded when they're first read.
+func TestHolder_ColdFragments(t *testing.T) {
+	h := newHolder()
+	defer h.Close()
+	h.Storage.ColdAfter = time.Hour
+
+	idx := h.MustCreateIndexIfNotExists("i", IndexOptions{})
+	fld, err := idx.CreateField("f", OptFieldTypeInt(-100, 100))
+	if err != nil {
+		t.Fatal(err)
+	} else if _, err := fld.SetValue(1, 10); err != nil {
+		t.Fatal(err)
+	} else if _, err := fld.SetValue(ShardWidth+2, -5); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	for _, frag := range fld.view(ViewBSIGroupPrefix + "f").allFragments() {
+		if err := frag.Snapshot(); err != nil {
+			t.Fatal(err)
+		} else if err := os.Chtimes(frag.path, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h.compressColdFragments()
+
+	if err := h.Holder.Close(); err != nil {
+		t.Fatal(err)
+	} else if err := h.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	fld = h.Index("i").Field("f")
+	frags := fld.view(ViewBSIGroupPrefix + "f").allFragments()
+	if len(frags) != 2 {
+		t.Fatalf("unexpected fragments: %d", len(frags))
+	}
+	for _, frag := range frags {
+		if !frag.cold || !frag.unloaded {
+			t.Fatalf("expected fragment %d to be cold and not loaded", frag.shard)
+		}
+	}
+
+	if v, exists, err := fld.Value(1); err != nil {
+		t.Fatal(err)
+	} else if !exists || v != 10 {
+		t.Fatalf("unexpected value: %d, %v", v, exists)
+	} else if v, exists, err := fld.Value(ShardWidth + 2); err != nil {
+		t.Fatal(err)
+	} else if !exists || v != -5 {
+		t.Fatalf("unexpected value: %d, %v", v, exists)
+	}
+	for _, frag := range frags {
+		if frag.unloaded {
+			t.Fatalf("expected fragment %d to be loaded once read", frag.shard)
+		}
+	}
+}
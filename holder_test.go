@@ -17,6 +17,7 @@ package pilosa_test
 import (
 	"bytes"
 	"context"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -197,6 +198,46 @@ func TestHolder_Open(t *testing.T) {
 			t.Fatalf("unexpected error: %s", err)
 		}
 	})
+	t.Run("QuarantineFragmentStorageCorrupt", func(t *testing.T) {
+		h := test.MustOpenHolder()
+		defer h.Close()
+
+		if idx, err := h.CreateIndex("foo", pilosa.IndexOptions{}); err != nil {
+			t.Fatal(err)
+		} else if field, err := idx.CreateField("bar", pilosa.OptFieldTypeDefault()); err != nil {
+			t.Fatal(err)
+		} else if _, err := field.SetBit(0, 0, nil); err != nil {
+			t.Fatal(err)
+		} else if _, err := field.SetBit(0, ShardWidth, nil); err != nil {
+			t.Fatal(err)
+		} else if err := h.Holder.Close(); err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(h.Path, "foo", "bar", "views", "standard", "fragments", "0")
+		if err := os.Truncate(path, 2); err != nil {
+			t.Fatal(err)
+		}
+
+		h.Holder.QuarantineCorruptFragments = true
+		if err := h.Reopen(); err != nil {
+			t.Fatal(err)
+		}
+		quarantined := h.QuarantinedFragments()
+		if len(quarantined) != 1 || quarantined[0].Index != "foo" || quarantined[0].Field != "bar" || quarantined[0].View != "standard" || quarantined[0].Shard != 0 {
+			t.Fatalf("unexpected quarantined fragments: %+v", quarantined)
+		} else if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatalf("expected fragment to be moved, got %v", err)
+		} else if _, err := os.Stat(quarantined[0].Path); err != nil {
+			t.Fatal(err)
+		}
+
+		// The shard is still available, and the other fragment is intact.
+		if shards := h.Field("foo", "bar").AvailableShards().Slice(); !reflect.DeepEqual(shards, []uint64{0, 1}) {
+			t.Fatalf("unexpected available shards: %v", shards)
+		} else if cols := h.Row("foo", "bar", 0).Columns(); !reflect.DeepEqual(cols, []uint64{ShardWidth}) {
+			t.Fatalf("unexpected columns: %v", cols)
+		}
+	})
 	// Try to re-create existing index
 	t.Run("CreateIndexIfNotExists", func(t *testing.T) {
 		h := test.MustOpenHolder()
@@ -249,6 +290,13 @@ func TestHolder_HasData(t *testing.T) {
 			t.Fatal("expected HasData to return false, no err, but", ok, err)
 		}
 
+		// Hidden directories, such as queued hints, aren't indexes.
+		if err := os.MkdirAll(filepath.Join(h.Path, ".hints"), 0777); err != nil {
+			t.Fatal(err)
+		} else if ok, err := h.HasData(); ok || err != nil {
+			t.Fatal("expected HasData to return false, no err, but", ok, err)
+		}
+
 		// Create an index directory to indicate data exists.
 		if err := os.Mkdir(h.IndexPath("test"), 0777); err != nil {
 			t.Fatal(err)
@@ -450,6 +498,52 @@ func TestHolderSyncer_BlockIteratorLimits(t *testing.T) {
 }
 
 // Ensure holder correctly handles clears during block sync.
+// Ensure anti-entropy fetches the data of a quarantined fragment from a
+// replica.
+func TestHolderSyncer_Quarantine(t *testing.T) {
+	c := test.MustNewCluster(t, 2)
+	for _, m := range c {
+		m.Config.Cluster.ReplicaN = 2
+		m.Config.AntiEntropy.Interval = 0
+	}
+	if err := c.Start(); err != nil {
+		t.Fatalf("starting cluster: %v", err)
+	}
+	defer c.Close()
+
+	c[0].MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	c[0].MustCreateField(t, "i", "f")
+	if _, err := c[0].Query("i", "", `Set(10, f=1) Set(20, f=1)`); err != nil {
+		t.Fatal(err)
+	}
+
+	// Corrupt the data file of node 1, and reopen its holder.
+	hldr1 := &test.Holder{Holder: c[1].Server.Holder()}
+	if err := hldr1.Holder.Close(); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(c[1].Config.DataDir, "i", "f", "views", "standard", "fragments", "0")
+	if err := ioutil.WriteFile(path, []byte("not a fragment"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	hldr1.QuarantineCorruptFragments = true
+	if err := hldr1.Open(); err != nil {
+		t.Fatal(err)
+	}
+
+	if quarantined := hldr1.QuarantinedFragments(); len(quarantined) != 1 {
+		t.Fatalf("unexpected quarantined fragments: %+v", quarantined)
+	} else if cols := hldr1.Row("i", "f", 1).Columns(); len(cols) != 0 {
+		t.Fatalf("unexpected columns before sync: %v", cols)
+	}
+	if err := c[1].Server.SyncData(); err != nil {
+		t.Fatalf("syncing node 1: %v", err)
+	}
+	if cols := hldr1.Row("i", "f", 1).Columns(); !reflect.DeepEqual(cols, []uint64{10, 20}) {
+		t.Fatalf("unexpected columns after sync: %v", cols)
+	}
+}
+
 func TestHolderSyncer_Clears(t *testing.T) {
 	c := test.MustNewCluster(t, 3)
 	c[0].Config.Cluster.ReplicaN = 3
@@ -315,6 +315,108 @@ func (c *InternalClient) QueryNode(ctx context.Context, uri *pilosa.URI, index s
 	return qresp, nil
 }
 
+// QueryStream executes query against the index, passing each chunk of the
+// streamed response to fn as it is read.
+func (c *InternalClient) QueryStream(ctx context.Context, index string, queryRequest *pilosa.QueryRequest, fn func(pilosa.QueryResultChunk) error) error {
+	span, ctx := tracing.StartSpanFromContext(ctx, "InternalClient.QueryStream")
+	defer span.Finish()
+	return c.QueryStreamNode(ctx, c.defaultURI, index, queryRequest, fn)
+}
+
+// TranslateKeysNode translates the keys of an index, or of one of its
+// fields, to IDs on the node specified, creating any which don't exist.
+func (c *InternalClient) TranslateKeysNode(ctx context.Context, uri *pilosa.URI, index, field string, keys []string) ([]uint64, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx, "InternalClient.TranslateKeysNode")
+	defer span.Finish()
+
+	buf, err := c.serializer.Marshal(&pilosa.TranslateKeysRequest{Index: index, Field: field, Keys: keys})
+	if err != nil {
+		return nil, errors.Wrap(err, "marshaling request")
+	}
+
+	u := uri.Path("/internal/translate/keys")
+	req, err := http.NewRequest("POST", u, bytes.NewReader(buf))
+	if err != nil {
+		return nil, errors.Wrap(err, "creating request")
+	}
+	req.Header.Set("Content-Length", strconv.Itoa(len(buf)))
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Accept", "application/x-protobuf")
+	req.Header.Set("User-Agent", "pilosa/"+pilosa.Version)
+
+	resp, err := c.executeRequest(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading response body")
+	}
+	var tresp pilosa.TranslateKeysResponse
+	if err := c.serializer.Unmarshal(body, &tresp); err != nil {
+		return nil, errors.Wrap(err, "unmarshaling response")
+	}
+	return tresp.IDs, nil
+}
+
+// QueryStreamNode executes query against the index, sending the request to
+// the node specified and passing each chunk of the streamed response to fn as
+// it is read. Non-bitmap results are decoded from JSON, with numbers
+// represented as json.Number.
+func (c *InternalClient) QueryStreamNode(ctx context.Context, uri *pilosa.URI, index string, queryRequest *pilosa.QueryRequest, fn func(pilosa.QueryResultChunk) error) error {
+	span, ctx := tracing.StartSpanFromContext(ctx, "QueryStreamNode")
+	defer span.Finish()
+
+	if index == "" {
+		return pilosa.ErrIndexRequired
+	} else if queryRequest.Query == "" {
+		return pilosa.ErrQueryRequired
+	}
+
+	buf, err := c.serializer.Marshal(queryRequest)
+	if err != nil {
+		return errors.Wrap(err, "marshaling queryRequest")
+	}
+
+	// Create HTTP request.
+	u := uri.Path(fmt.Sprintf("/index/%s/query", index))
+	req, err := http.NewRequest("POST", u, bytes.NewReader(buf))
+	if err != nil {
+		return errors.Wrap(err, "creating request")
+	}
+
+	req.Header.Set("Content-Length", strconv.Itoa(len(buf)))
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Accept", "application/x-ndjson")
+	req.Header.Set("User-Agent", "pilosa/"+pilosa.Version)
+
+	// Execute request against the host.
+	resp, err := c.executeRequest(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Decode one line at a time.
+	dec := json.NewDecoder(resp.Body)
+	dec.UseNumber()
+	for {
+		var line queryStreamLine
+		if err := dec.Decode(&line); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return errors.Wrap(err, "decoding chunk")
+		} else if line.Err != "" {
+			return errors.New(line.Err)
+		}
+		if err := fn(line.QueryResultChunk); err != nil {
+			return err
+		}
+	}
+}
+
 // Import bulk imports bits for a single shard to a host.
 func (c *InternalClient) Import(ctx context.Context, index, field string, shard uint64, bits []pilosa.Bit, opts ...pilosa.ImportOption) error {
 	span, ctx := tracing.StartSpanFromContext(ctx, "InternalClient.Import")
@@ -478,6 +580,12 @@ func (c *InternalClient) importNode(ctx context.Context, node *pilosa.Node, inde
 	if opts.IgnoreKeyCheck {
 		vals.Set("ignoreKeyCheck", "true")
 	}
+	if opts.Upsert {
+		vals.Set("upsert", "true")
+	}
+	if opts.Token != "" {
+		vals.Set("token", opts.Token)
+	}
 	url := fmt.Sprintf("%s?%s", u.String(), vals.Encode())
 
 	req, err := http.NewRequest("POST", url, bytes.NewReader(buf))
@@ -630,6 +738,9 @@ func (c *InternalClient) ImportRoaring(ctx context.Context, uri *pilosa.URI, ind
 
 	vals := url.Values{}
 	vals.Set("remote", strconv.FormatBool(remote))
+	if req.Token != "" {
+		vals.Set("token", req.Token)
+	}
 	url := fmt.Sprintf("%s/index/%s/field/%s/import-roaring/%d?%s", uri, index, field, shard, vals.Encode())
 
 	// Marshal data to protobuf.
@@ -775,6 +886,348 @@ func (c *InternalClient) RetrieveShardFromURI(ctx context.Context, index, field,
 	return resp.Body, nil
 }
 
+// ExportShard returns the columns of a shard of an index from a node which
+// owns it.
+func (c *InternalClient) ExportShard(ctx context.Context, uri *pilosa.URI, index string, shard uint64) ([]pilosa.ExportColumn, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx, "InternalClient.ExportShard")
+	defer span.Finish()
+
+	u := uriPathToURL(uri, fmt.Sprintf("/internal/index/%s/shard/%d/export", index, shard))
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating request")
+	}
+	req.Header.Set("User-Agent", "pilosa/"+pilosa.Version)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.executeRequest(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var columns []pilosa.ExportColumn
+	if err := json.NewDecoder(resp.Body).Decode(&columns); err != nil {
+		return nil, errors.Wrap(err, "decoding response body")
+	}
+	return columns, nil
+}
+
+// ExportIndex copies an export of every column of an index, in the given
+// format, to w.
+func (c *InternalClient) ExportIndex(ctx context.Context, index string, format pilosa.ExportFormat, w io.Writer) error {
+	span, ctx := tracing.StartSpanFromContext(ctx, "InternalClient.ExportIndex")
+	defer span.Finish()
+
+	if index == "" {
+		return pilosa.ErrIndexRequired
+	}
+
+	u := uriPathToURL(c.defaultURI, fmt.Sprintf("/index/%s/export", index))
+	u.RawQuery = url.Values{"format": {string(format)}}.Encode()
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return errors.Wrap(err, "creating request")
+	}
+	req.Header.Set("User-Agent", "pilosa/"+pilosa.Version)
+
+	resp, err := c.executeRequest(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return errors.Wrap(err, "copying export")
+	}
+	return nil
+}
+
+// Backup copies a backup of every index, made by the coordinator, to w. When
+// base is the manifest of an earlier backup, the backup is an incremental
+// backup holding only what changed since.
+func (c *InternalClient) Backup(ctx context.Context, base *pilosa.BackupManifest, w io.Writer) error {
+	span, ctx := tracing.StartSpanFromContext(ctx, "InternalClient.Backup")
+	defer span.Finish()
+
+	// Backups are made by the coordinator, which holds the primary
+	// translate stores.
+	uri, err := c.coordinatorURI(ctx)
+	if err != nil {
+		return err
+	}
+
+	var body io.Reader
+	if base != nil {
+		buf, err := json.Marshal(base)
+		if err != nil {
+			return errors.Wrap(err, "marshaling manifest")
+		}
+		body = bytes.NewReader(buf)
+	}
+
+	u := uriPathToURL(uri, "/backup")
+	req, err := http.NewRequest("POST", u.String(), body)
+	if err != nil {
+		return errors.Wrap(err, "creating request")
+	}
+	if base != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("User-Agent", "pilosa/"+pilosa.Version)
+
+	resp, err := c.executeRequest(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return errors.Wrap(err, "copying backup")
+	}
+	return nil
+}
+
+// Restore rebuilds the indexes of a backup, read from r, through the
+// coordinator.
+func (c *InternalClient) Restore(ctx context.Context, r io.Reader) error {
+	span, ctx := tracing.StartSpanFromContext(ctx, "InternalClient.Restore")
+	defer span.Finish()
+
+	// Backups are restored by the coordinator, which holds the primary
+	// translate stores.
+	uri, err := c.coordinatorURI(ctx)
+	if err != nil {
+		return err
+	}
+
+	u := uriPathToURL(uri, "/restore")
+	req, err := http.NewRequest("POST", u.String(), r)
+	if err != nil {
+		return errors.Wrap(err, "creating request")
+	}
+	req.Header.Set("Content-Type", "application/x-tar")
+	req.Header.Set("User-Agent", "pilosa/"+pilosa.Version)
+
+	resp, err := c.executeRequest(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// coordinatorURI returns the URI of the cluster's coordinator.
+func (c *InternalClient) coordinatorURI(ctx context.Context) (*pilosa.URI, error) {
+	nodes, err := c.Nodes(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting nodes")
+	}
+	for _, node := range nodes {
+		if node.IsCoordinator {
+			return &node.URI, nil
+		}
+	}
+	return c.defaultURI, nil
+}
+
+// PinBackup pins every fragment of a node for a backup.
+func (c *InternalClient) PinBackup(ctx context.Context, uri *pilosa.URI, id string) ([]pilosa.BackupFragmentInfo, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx, "InternalClient.PinBackup")
+	defer span.Finish()
+
+	u := uriPathToURL(uri, fmt.Sprintf("/internal/backup/%s", id))
+	req, err := http.NewRequest("POST", u.String(), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating request")
+	}
+	req.Header.Set("User-Agent", "pilosa/"+pilosa.Version)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.executeRequest(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var infos []pilosa.BackupFragmentInfo
+	if err := json.NewDecoder(resp.Body).Decode(&infos); err != nil {
+		return nil, errors.Wrap(err, "decoding response body")
+	}
+	return infos, nil
+}
+
+// BackupFragment returns a ReadCloser which contains the archive of a
+// fragment pinned for a backup on a node, holding only the ops appended to its
+// data file after offset when offset isn't zero. Caller *must* close the
+// returned ReadCloser.
+func (c *InternalClient) BackupFragment(ctx context.Context, uri *pilosa.URI, id string, bf pilosa.BackupFragment, offset int64) (io.ReadCloser, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx, "InternalClient.BackupFragment")
+	defer span.Finish()
+
+	u := uriPathToURL(uri, fmt.Sprintf("/internal/backup/%s/fragment", id))
+	u.RawQuery = url.Values{
+		"index":  {bf.Index},
+		"field":  {bf.Field},
+		"view":   {bf.View},
+		"shard":  {strconv.FormatUint(bf.Shard, 10)},
+		"offset": {strconv.FormatInt(offset, 10)},
+	}.Encode()
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating request")
+	}
+	req.Header.Set("User-Agent", "pilosa/"+pilosa.Version)
+
+	resp, err := c.executeRequest(req.WithContext(ctx))
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return nil, pilosa.ErrFragmentNotFound
+		}
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// BlockBackup blocks writes made through a node for a backup, and waits for
+// those in progress to end.
+func (c *InternalClient) BlockBackup(ctx context.Context, uri *pilosa.URI, id string) error {
+	span, ctx := tracing.StartSpanFromContext(ctx, "InternalClient.BlockBackup")
+	defer span.Finish()
+
+	u := uriPathToURL(uri, fmt.Sprintf("/internal/backup/%s/block", id))
+	req, err := http.NewRequest("POST", u.String(), nil)
+	if err != nil {
+		return errors.Wrap(err, "creating request")
+	}
+	req.Header.Set("User-Agent", "pilosa/"+pilosa.Version)
+
+	resp, err := c.executeRequest(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// UnblockBackup unblocks writes made through a node for a backup.
+func (c *InternalClient) UnblockBackup(ctx context.Context, uri *pilosa.URI, id string) error {
+	span, ctx := tracing.StartSpanFromContext(ctx, "InternalClient.UnblockBackup")
+	defer span.Finish()
+
+	u := uriPathToURL(uri, fmt.Sprintf("/internal/backup/%s/block", id))
+	req, err := http.NewRequest("DELETE", u.String(), nil)
+	if err != nil {
+		return errors.Wrap(err, "creating request")
+	}
+	req.Header.Set("User-Agent", "pilosa/"+pilosa.Version)
+
+	resp, err := c.executeRequest(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// ReleaseBackup removes the fragments of a node pinned for a backup.
+func (c *InternalClient) ReleaseBackup(ctx context.Context, uri *pilosa.URI, id string) error {
+	span, ctx := tracing.StartSpanFromContext(ctx, "InternalClient.ReleaseBackup")
+	defer span.Finish()
+
+	u := uriPathToURL(uri, fmt.Sprintf("/internal/backup/%s", id))
+	req, err := http.NewRequest("DELETE", u.String(), nil)
+	if err != nil {
+		return errors.Wrap(err, "creating request")
+	}
+	req.Header.Set("User-Agent", "pilosa/"+pilosa.Version)
+
+	resp, err := c.executeRequest(req.WithContext(ctx))
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return pilosa.ErrBackupNotFound
+		}
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// RestoreFragment replaces the data of a fragment on a node with an archive
+// read from r.
+func (c *InternalClient) RestoreFragment(ctx context.Context, uri *pilosa.URI, bf pilosa.BackupFragment, r io.Reader) error {
+	span, ctx := tracing.StartSpanFromContext(ctx, "InternalClient.RestoreFragment")
+	defer span.Finish()
+
+	u := uriPathToURL(uri, "/internal/restore/fragment")
+	u.RawQuery = url.Values{
+		"index": {bf.Index},
+		"field": {bf.Field},
+		"view":  {bf.View},
+		"shard": {strconv.FormatUint(bf.Shard, 10)},
+	}.Encode()
+	req, err := http.NewRequest("POST", u.String(), r)
+	if err != nil {
+		return errors.Wrap(err, "creating request")
+	}
+	req.Header.Set("Content-Type", "application/x-tar")
+	req.Header.Set("User-Agent", "pilosa/"+pilosa.Version)
+
+	resp, err := c.executeRequest(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// RestoreAttrs sets the column attributes of an index, or the row attributes
+// of one of its fields, on a node. The attributes are read from r as JSON.
+func (c *InternalClient) RestoreAttrs(ctx context.Context, uri *pilosa.URI, index, field string, r io.Reader) error {
+	span, ctx := tracing.StartSpanFromContext(ctx, "InternalClient.RestoreAttrs")
+	defer span.Finish()
+
+	u := uriPathToURL(uri, "/internal/restore/attrs")
+	q := url.Values{"index": {index}}
+	if field != "" {
+		q.Set("field", field)
+	}
+	u.RawQuery = q.Encode()
+	req, err := http.NewRequest("POST", u.String(), r)
+	if err != nil {
+		return errors.Wrap(err, "creating request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "pilosa/"+pilosa.Version)
+
+	resp, err := c.executeRequest(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// RestoreKeys sets the keys of an index, or of one of its fields, on the
+// primary translate node. The keys are read from r as JSON translate entries.
+func (c *InternalClient) RestoreKeys(ctx context.Context, uri *pilosa.URI, index, field string, r io.Reader) error {
+	span, ctx := tracing.StartSpanFromContext(ctx, "InternalClient.RestoreKeys")
+	defer span.Finish()
+
+	u := uriPathToURL(uri, "/internal/restore/keys")
+	q := url.Values{"index": {index}}
+	if field != "" {
+		q.Set("field", field)
+	}
+	u.RawQuery = q.Encode()
+	req, err := http.NewRequest("POST", u.String(), r)
+	if err != nil {
+		return errors.Wrap(err, "creating request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "pilosa/"+pilosa.Version)
+
+	resp, err := c.executeRequest(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
 func (c *InternalClient) CreateField(ctx context.Context, index, field string) error {
 	span, ctx := tracing.StartSpanFromContext(ctx, "InternalClient.CreateField")
 	defer span.Finish()
@@ -19,9 +19,11 @@ import (
 	"bytes"
 	"context"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	gohttp "net/http"
 	"reflect"
+	"sort"
 	"testing"
 	"time"
 
@@ -180,6 +182,109 @@ func TestClient_MultiNode(t *testing.T) {
 	}
 }
 
+// Ensure client can stream query results.
+func TestClient_QueryStream(t *testing.T) {
+	c := test.MustRunCluster(t, 2,
+		[]server.CommandOption{
+			server.OptCommandServerOptions(pilosa.OptServerNodeID("node0"), pilosa.OptServerClusterHasher(&test.ModHasher{}))},
+		[]server.CommandOption{
+			server.OptCommandServerOptions(pilosa.OptServerNodeID("node1"), pilosa.OptServerClusterHasher(&test.ModHasher{}))},
+	)
+	defer c.Close()
+
+	c.CreateField(t, "i", pilosa.IndexOptions{}, "f")
+	c.CreateField(t, "k", pilosa.IndexOptions{Keys: true}, "f")
+
+	var exp []uint64
+	for shard := uint64(0); shard < 4; shard++ {
+		for _, col := range []uint64{shard * pilosa.ShardWidth, shard*pilosa.ShardWidth + 5} {
+			c.Query(t, "i", fmt.Sprintf("Set(%d, f=1)", col))
+			exp = append(exp, col)
+		}
+	}
+	c.Query(t, "k", `Set("a", f=1) Set("b", f=1) Set("c", f=2)`)
+
+	client := MustNewClient(c[0].URL(), http.GetHTTPClient(nil))
+
+	// stream collects the chunks of each result.
+	stream := func(index, query string) [][]pilosa.QueryResultChunk {
+		t.Helper()
+		var results [][]pilosa.QueryResultChunk
+		if err := client.QueryStream(context.Background(), index, &pilosa.QueryRequest{Query: query}, func(chunk pilosa.QueryResultChunk) error {
+			if chunk.Index == len(results) {
+				results = append(results, nil)
+			} else if chunk.Index != len(results)-1 {
+				t.Fatalf("unexpected chunk index: %d", chunk.Index)
+			}
+			results[chunk.Index] = append(results[chunk.Index], chunk)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return results
+	}
+
+	t.Run("IDs", func(t *testing.T) {
+		results := stream("i", `Row(f=1) Count(Row(f=1))`)
+		if len(results) != 2 {
+			t.Fatalf("unexpected results: %s", spew.Sdump(results))
+		}
+
+		var columns []uint64
+		for _, chunk := range results[0] {
+			columns = append(columns, chunk.Columns...)
+		}
+		sort.Slice(columns, func(i, j int) bool { return columns[i] < columns[j] })
+		if !reflect.DeepEqual(columns, exp) {
+			t.Fatalf("unexpected columns: %v", columns)
+		} else if last := results[0][len(results[0])-1]; !last.Done {
+			t.Fatalf("expected last chunk to be done: %s", spew.Sdump(last))
+		}
+
+		if len(results[1]) != 1 || !results[1][0].Done {
+			t.Fatalf("unexpected count chunks: %s", spew.Sdump(results[1]))
+		} else if n := results[1][0].Result; n != json.Number("8") {
+			t.Fatalf("unexpected count: %v", n)
+		}
+	})
+
+	t.Run("Keys", func(t *testing.T) {
+		results := stream("k", `Row(f=1)`)
+		if len(results) != 1 {
+			t.Fatalf("unexpected results: %s", spew.Sdump(results))
+		}
+		var keys []string
+		for _, chunk := range results[0] {
+			keys = append(keys, chunk.Keys...)
+		}
+		sort.Strings(keys)
+		if !reflect.DeepEqual(keys, []string{"a", "b"}) {
+			t.Fatalf("unexpected keys: %v", keys)
+		}
+	})
+
+	t.Run("AtomicKeys", func(t *testing.T) {
+		var chunks []pilosa.QueryResultChunk
+		if err := client.QueryStream(context.Background(), "k", &pilosa.QueryRequest{Query: `Set("d", f=3)`, Atomic: true}, func(chunk pilosa.QueryResultChunk) error {
+			chunks = append(chunks, chunk)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		} else if len(chunks) != 1 || !chunks[0].Done || chunks[0].Result != true {
+			t.Fatalf("unexpected chunks: %s", spew.Sdump(chunks))
+		}
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		err := client.QueryStream(context.Background(), "i", &pilosa.QueryRequest{Query: `Row(nosuchfield=1)`}, func(pilosa.QueryResultChunk) error {
+			return nil
+		})
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	})
+}
+
 // Ensure client can export data.
 func TestClient_Export(t *testing.T) {
 	cluster := test.MustRunCluster(t, 1)
@@ -344,6 +449,62 @@ func TestClient_Export(t *testing.T) {
 			t.Fatalf("unexpected export data: %s", got)
 		}
 	})
+
+	t.Run("Export int", func(t *testing.T) {
+		cmd.MustCreateField(t, "unkeyed", "intf", pilosa.OptFieldTypeInt(-100, 100))
+		if _, err := c.Query(context.Background(), "unkeyed", &pilosa.QueryRequest{
+			Query: `Set(100, intf=-20) Set(101, intf=0) Set(200, intf=75)`,
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		buf := bytes.NewBuffer(nil)
+		if err := c.ExportCSV(context.Background(), "unkeyed", "intf", 0, buf); err != nil {
+			t.Fatal(err)
+		}
+
+		if got, exp := buf.String(), "100,-20\n101,0\n200,75\n"; got != exp {
+			t.Fatalf("unexpected export data: %s", got)
+		}
+	})
+
+	t.Run("Export bool", func(t *testing.T) {
+		cmd.MustCreateField(t, "keyed", "boolf", pilosa.OptFieldTypeBool())
+		if _, err := c.Query(context.Background(), "keyed", &pilosa.QueryRequest{
+			Query: `Set("col100", boolf=true) Set("col200", boolf=false)`,
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		buf := bytes.NewBuffer(nil)
+		if err := c.ExportCSV(context.Background(), "keyed", "boolf", 0, buf); err != nil {
+			t.Fatal(err)
+		}
+
+		// Columns are ordered by row, so false values come first.
+		if got, exp := buf.String(), "col200,false\ncol100,true\n"; got != exp {
+			t.Fatalf("unexpected export data: %s", got)
+		}
+	})
+
+	t.Run("Export time", func(t *testing.T) {
+		cmd.MustCreateField(t, "unkeyed", "timef", pilosa.OptFieldTypeTime("YMDH"))
+		if _, err := c.Query(context.Background(), "unkeyed", &pilosa.QueryRequest{
+			Query: `Set(100, timef=1, 2019-01-02T03:00) Set(100, timef=1, 2019-05-06T07:00) Set(101, timef=2)`,
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		buf := bytes.NewBuffer(nil)
+		if err := c.ExportCSV(context.Background(), "unkeyed", "timef", 0, buf); err != nil {
+			t.Fatal(err)
+		}
+
+		exp := "1,100,2019-01-02T03:00\n1,100,2019-05-06T07:00\n2,101,\n"
+		if got := buf.String(); got != exp {
+			t.Fatalf("unexpected export data: %s", got)
+		}
+	})
 }
 
 // Ensure client can bulk import data.
@@ -392,6 +553,67 @@ func TestClient_Import(t *testing.T) {
 	if a := hldr.Row("i", "f", 200).Columns(); !reflect.DeepEqual(a, []uint64{}) {
 		t.Fatalf("unexpected columns: %+v", a)
 	}
+
+	// Replace the row of column 1.
+	if err := c.Import(context.Background(), "i", "f", 0, []pilosa.Bit{
+		{RowID: 300, ColumnID: 1},
+	}, pilosa.OptImportOptionsUpsert(true)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Verify data.
+	if a := hldr.Row("i", "f", 0).Columns(); !reflect.DeepEqual(a, []uint64{}) {
+		t.Fatalf("unexpected columns: %+v", a)
+	}
+	if a := hldr.Row("i", "f", 300).Columns(); !reflect.DeepEqual(a, []uint64{1}) {
+		t.Fatalf("unexpected columns: %+v", a)
+	}
+}
+
+// Ensure that imports retried with the same token are only applied once.
+func TestClient_ImportToken(t *testing.T) {
+	cluster := test.MustRunCluster(t, 1)
+	defer cluster.Close()
+	cmd := cluster[0]
+	cmd.MustCreateIndex(t, "i", pilosa.IndexOptions{})
+	cmd.MustCreateField(t, "i", "f", pilosa.OptFieldTypeSet(pilosa.CacheTypeRanked, 100))
+
+	c := MustNewClient(cmd.URL(), http.GetHTTPClient(nil))
+	bits := []pilosa.Bit{{RowID: 0, ColumnID: 1}}
+	roaringReq := makeImportRoaringRequest(false, "3B3001000100000900010000000100010009000100")
+	roaringReq.Token = "roaring"
+	importAll := func() {
+		if err := c.Import(context.Background(), "i", "f", 0, bits, pilosa.OptImportOptionsToken("bits")); err != nil {
+			t.Fatal(err)
+		} else if err := c.ImportRoaring(context.Background(), nil, "i", "f", 0, false, roaringReq); err != nil {
+			t.Fatal(err)
+		}
+	}
+	importAll()
+
+	// Clear the imported data, then retry the imports.
+	if err := c.Import(context.Background(), "i", "f", 0, bits, pilosa.OptImportOptionsClear(true)); err != nil {
+		t.Fatal(err)
+	} else if err := c.ImportRoaring(context.Background(), nil, "i", "f", 0, false, makeImportRoaringRequest(true, "3B3001000100000900010000000100010009000100")); err != nil {
+		t.Fatal(err)
+	}
+	importAll()
+
+	hldr := test.Holder{Holder: cmd.Server.Holder()}
+	if a := hldr.Row("i", "f", 0).Columns(); !reflect.DeepEqual(a, []uint64{}) {
+		t.Fatalf("unexpected columns: %+v", a)
+	}
+
+	// Tokens are remembered across restarts.
+	if err := cmd.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	c = MustNewClient(cmd.URL(), http.GetHTTPClient(nil))
+	importAll()
+	hldr = test.Holder{Holder: cmd.Server.Holder()}
+	if a := hldr.Row("i", "f", 0).Columns(); !reflect.DeepEqual(a, []uint64{}) {
+		t.Fatalf("unexpected columns after reopen: %+v", a)
+	}
 }
 
 // Ensure client can bulk import data.
@@ -36,6 +36,8 @@ import (
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 	"github.com/pilosa/pilosa/v2"
+	"github.com/pilosa/pilosa/v2/encoding/arrow"
+	"github.com/pilosa/pilosa/v2/encoding/parquet"
 	"github.com/pilosa/pilosa/v2/logger"
 	"github.com/pilosa/pilosa/v2/tracing"
 	"github.com/pkg/errors"
@@ -173,25 +175,31 @@ func (h *Handler) Close() error {
 func (h *Handler) populateValidators() {
 	h.validators = map[string]*queryValidationSpec{}
 	h.validators["Home"] = queryValidationSpecRequired()
+	h.validators["PostBackup"] = queryValidationSpecRequired()
 	h.validators["PostClusterResizeAbort"] = queryValidationSpecRequired()
 	h.validators["PostClusterResizeRemoveNode"] = queryValidationSpecRequired()
 	h.validators["PostClusterResizeSetCoordinator"] = queryValidationSpecRequired()
 	h.validators["GetExport"] = queryValidationSpecRequired("index", "field", "shard")
 	h.validators["GetIndexes"] = queryValidationSpecRequired()
 	h.validators["GetIndex"] = queryValidationSpecRequired()
+	h.validators["GetIndexExport"] = queryValidationSpecRequired().Optional("format")
 	h.validators["PostIndex"] = queryValidationSpecRequired()
 	h.validators["DeleteIndex"] = queryValidationSpecRequired()
 	h.validators["GetTranslateData"] = queryValidationSpecRequired("offset")
 	h.validators["PostTranslateKeys"] = queryValidationSpecRequired()
 	h.validators["PostField"] = queryValidationSpecRequired()
 	h.validators["DeleteField"] = queryValidationSpecRequired()
-	h.validators["PostImport"] = queryValidationSpecRequired().Optional("clear", "ignoreKeyCheck")
-	h.validators["PostImportRoaring"] = queryValidationSpecRequired().Optional("remote", "clear")
-	h.validators["PostQuery"] = queryValidationSpecRequired().Optional("shards", "columnAttrs", "excludeRowAttrs", "excludeColumns")
+	h.validators["DeleteColumn"] = queryValidationSpecRequired().Optional("keys", "attrs")
+	h.validators["PostImport"] = queryValidationSpecRequired().Optional("clear", "ignoreKeyCheck", "upsert", "token")
+	h.validators["PostImportRoaring"] = queryValidationSpecRequired().Optional("remote", "clear", "token")
+	h.validators["PostIngest"] = queryValidationSpecRequired("column").Optional("set", "int", "time")
+	h.validators["PostQuery"] = queryValidationSpecRequired().Optional("shards", "columnAttrs", "excludeRowAttrs", "excludeColumns", "atomic", "writeConsistency")
+	h.validators["PostSQL"] = queryValidationSpecRequired()
 	h.validators["GetInfo"] = queryValidationSpecRequired()
 	h.validators["RecalculateCaches"] = queryValidationSpecRequired()
 	h.validators["GetSchema"] = queryValidationSpecRequired()
 	h.validators["PostSchema"] = queryValidationSpecRequired().Optional("remote")
+	h.validators["PostRestore"] = queryValidationSpecRequired()
 	h.validators["GetStatus"] = queryValidationSpecRequired()
 	h.validators["GetVersion"] = queryValidationSpecRequired()
 	h.validators["PostClusterMessage"] = queryValidationSpecRequired()
@@ -199,6 +207,15 @@ func (h *Handler) populateValidators() {
 	h.validators["GetFragmentBlocks"] = queryValidationSpecRequired("index", "field", "view", "shard")
 	h.validators["GetFragmentData"] = queryValidationSpecRequired("index", "field", "view", "shard")
 	h.validators["GetFragmentNodes"] = queryValidationSpecRequired("shard", "index")
+	h.validators["PostPinBackup"] = queryValidationSpecRequired()
+	h.validators["PostBlockBackup"] = queryValidationSpecRequired()
+	h.validators["DeleteBlockBackup"] = queryValidationSpecRequired()
+	h.validators["GetBackupFragment"] = queryValidationSpecRequired("index", "field", "view", "shard").Optional("offset")
+	h.validators["DeleteBackup"] = queryValidationSpecRequired()
+	h.validators["PostRestoreFragment"] = queryValidationSpecRequired("index", "field", "view", "shard")
+	h.validators["PostRestoreAttrs"] = queryValidationSpecRequired("index").Optional("field")
+	h.validators["PostRestoreKeys"] = queryValidationSpecRequired("index").Optional("field")
+	h.validators["GetShardExport"] = queryValidationSpecRequired()
 	h.validators["PostIndexAttrDiff"] = queryValidationSpecRequired()
 	h.validators["PostFieldAttrDiff"] = queryValidationSpecRequired()
 	h.validators["GetNodes"] = queryValidationSpecRequired()
@@ -274,6 +291,7 @@ func (h *Handler) collectStats(next http.Handler) http.Handler {
 func newRouter(handler *Handler) *mux.Router {
 	router := mux.NewRouter()
 	router.HandleFunc("/", handler.handleHome).Methods("GET").Name("Home")
+	router.HandleFunc("/backup", handler.handlePostBackup).Methods("POST").Name("PostBackup")
 	router.HandleFunc("/cluster/resize/abort", handler.handlePostClusterResizeAbort).Methods("POST").Name("PostClusterResizeAbort")
 	router.HandleFunc("/cluster/resize/remove-node", handler.handlePostClusterResizeRemoveNode).Methods("POST").Name("PostClusterResizeRemoveNode")
 	router.HandleFunc("/cluster/resize/set-coordinator", handler.handlePostClusterResizeSetCoordinator).Methods("POST").Name("PostClusterResizeSetCoordinator")
@@ -292,29 +310,43 @@ func newRouter(handler *Handler) *mux.Router {
 	router.HandleFunc("/index/{index}/field", handler.handlePostField).Methods("POST").Name("PostField")
 	router.HandleFunc("/index/{index}/field/", handler.handlePostField).Methods("POST").Name("PostField")
 	router.HandleFunc("/index/{index}/field/{field}", handler.handleDeleteField).Methods("DELETE").Name("DeleteField")
+	router.HandleFunc("/index/{index}/export", handler.handleGetIndexExport).Methods("GET").Name("GetIndexExport")
+	router.HandleFunc("/index/{index}/column/{column}", handler.handleDeleteColumn).Methods("DELETE").Name("DeleteColumn")
 	router.HandleFunc("/index/{index}/field/{field}/import", handler.handlePostImport).Methods("POST").Name("PostImport")
 	router.HandleFunc("/index/{index}/field/{field}/import-roaring/{shard}", handler.handlePostImportRoaring).Methods("POST").Name("PostImportRoaring")
+	router.HandleFunc("/index/{index}/ingest", handler.handlePostIngest).Methods("POST").Name("PostIngest")
 	router.HandleFunc("/index/{index}/query", handler.handlePostQuery).Methods("POST").Name("PostQuery")
 	router.HandleFunc("/info", handler.handleGetInfo).Methods("GET").Name("GetInfo")
 	router.HandleFunc("/recalculate-caches", handler.handleRecalculateCaches).Methods("POST").Name("RecalculateCaches")
+	router.HandleFunc("/restore", handler.handlePostRestore).Methods("POST").Name("PostRestore")
 	router.HandleFunc("/schema", handler.handleGetSchema).Methods("GET").Name("GetSchema")
+	router.HandleFunc("/sql", handler.handlePostSQL).Methods("POST").Name("PostSQL")
 	router.HandleFunc("/schema", handler.handlePostSchema).Methods("POST").Name("PostSchema")
 	router.HandleFunc("/status", handler.handleGetStatus).Methods("GET").Name("GetStatus")
 	router.HandleFunc("/version", handler.handleGetVersion).Methods("GET").Name("GetVersion")
 
 	// /internal endpoints are for internal use only; they may change at any time.
 	// DO NOT rely on these for external applications!
+	router.HandleFunc("/internal/backup/{id}", handler.handlePostPinBackup).Methods("POST").Name("PostPinBackup")
+	router.HandleFunc("/internal/backup/{id}", handler.handleDeleteBackup).Methods("DELETE").Name("DeleteBackup")
+	router.HandleFunc("/internal/backup/{id}/block", handler.handlePostBlockBackup).Methods("POST").Name("PostBlockBackup")
+	router.HandleFunc("/internal/backup/{id}/block", handler.handleDeleteBlockBackup).Methods("DELETE").Name("DeleteBlockBackup")
+	router.HandleFunc("/internal/backup/{id}/fragment", handler.handleGetBackupFragment).Methods("GET").Name("GetBackupFragment")
 	router.HandleFunc("/internal/cluster/message", handler.handlePostClusterMessage).Methods("POST").Name("PostClusterMessage")
 	router.HandleFunc("/internal/fragment/block/data", handler.handleGetFragmentBlockData).Methods("GET").Name("GetFragmentBlockData")
 	router.HandleFunc("/internal/fragment/blocks", handler.handleGetFragmentBlocks).Methods("GET").Name("GetFragmentBlocks")
 	router.HandleFunc("/internal/fragment/data", handler.handleGetFragmentData).Methods("GET").Name("GetFragmentData")
 	router.HandleFunc("/internal/fragment/nodes", handler.handleGetFragmentNodes).Methods("GET").Name("GetFragmentNodes")
 	router.HandleFunc("/internal/index/{index}/attr/diff", handler.handlePostIndexAttrDiff).Methods("POST").Name("PostIndexAttrDiff")
+	router.HandleFunc("/internal/index/{index}/shard/{shard}/export", handler.handleGetShardExport).Methods("GET").Name("GetShardExport")
 	router.HandleFunc("/internal/translate/data", handler.handlePostTranslateData).Methods("POST").Name("PostTranslateData")
 	router.HandleFunc("/internal/translate/keys", handler.handlePostTranslateKeys).Methods("POST").Name("PostTranslateKeys")
 	router.HandleFunc("/internal/index/{index}/field/{field}/attr/diff", handler.handlePostFieldAttrDiff).Methods("POST").Name("PostFieldAttrDiff")
 	router.HandleFunc("/internal/index/{index}/field/{field}/remote-available-shards/{shardID}", handler.handleDeleteRemoteAvailableShard).Methods("DELETE")
 	router.HandleFunc("/internal/nodes", handler.handleGetNodes).Methods("GET").Name("GetNodes")
+	router.HandleFunc("/internal/restore/attrs", handler.handlePostRestoreAttrs).Methods("POST").Name("PostRestoreAttrs")
+	router.HandleFunc("/internal/restore/fragment", handler.handlePostRestoreFragment).Methods("POST").Name("PostRestoreFragment")
+	router.HandleFunc("/internal/restore/keys", handler.handlePostRestoreKeys).Methods("POST").Name("PostRestoreKeys")
 	router.HandleFunc("/internal/shards/max", handler.handleGetShardsMax).Methods("GET").Name("GetShardsMax") // TODO: deprecate, but it's being used by the client
 
 	router.Use(handler.queryArgValidator)
@@ -327,6 +359,11 @@ func newRouter(handler *Handler) *mux.Router {
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	defer func() {
 		if err := recover(); err != nil {
+			// Aborted responses are left to net/http, which closes the
+			// connection.
+			if err == http.ErrAbortHandler {
+				panic(err)
+			}
 			w.WriteHeader(http.StatusInternalServerError)
 			stack := debug.Stack()
 			msg := "PANIC: %s\n%s"
@@ -424,6 +461,28 @@ func validHeaderAcceptJSON(header http.Header) bool {
 	return true
 }
 
+// validHeaderAcceptNDJSON returns true if the request explicitly accepts
+// newline delimited JSON.
+func validHeaderAcceptNDJSON(header http.Header) bool {
+	for _, v := range header["Accept"] {
+		if v == "application/x-ndjson" {
+			return true
+		}
+	}
+	return false
+}
+
+// validHeaderAcceptArrow returns true if the request explicitly accepts an
+// Arrow stream.
+func validHeaderAcceptArrow(header http.Header) bool {
+	for _, v := range header["Accept"] {
+		if v == arrow.ContentType {
+			return true
+		}
+	}
+	return false
+}
+
 // handleGetSchema handles GET /schema requests.
 func (h *Handler) handleGetSchema(w http.ResponseWriter, r *http.Request) {
 	if !validHeaderAcceptJSON(r.Header) {
@@ -510,11 +569,20 @@ func (h *Handler) handlePostQuery(w http.ResponseWriter, r *http.Request) {
 	// TODO: Remove
 	req.Index = mux.Vars(r)["index"]
 
+	if validHeaderAcceptNDJSON(r.Header) {
+		h.handlePostQueryStream(w, r, req)
+		return
+	}
+
 	resp, err := h.api.Query(r.Context(), req)
 	if err != nil {
 		switch errors.Cause(err) {
 		case pilosa.ErrTooManyWrites:
 			w.WriteHeader(http.StatusRequestEntityTooLarge)
+		case pilosa.ErrWriteConsistencyNotMet:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case pilosa.ErrWriteNotReplicated:
+			w.WriteHeader(http.StatusAccepted)
 		case pilosa.ErrTranslateStoreReadOnly:
 			u := h.api.PrimaryReplicaNodeURL()
 			u.Path, u.RawQuery = r.URL.Path, r.URL.RawQuery
@@ -548,6 +616,90 @@ func (h *Handler) handlePostQuery(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handlePostQueryStream executes a query, writing each result (or batch of
+// columns, for bitmap results) as a line of NDJSON as soon as it's available.
+func (h *Handler) handlePostQueryStream(w http.ResponseWriter, r *http.Request, req *pilosa.QueryRequest) {
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+
+	// Nothing is written until the first chunk arrives so that errors
+	// which occur before execution begins get a proper status code.
+	var started bool
+	err := h.api.QueryStream(r.Context(), req, func(chunk pilosa.QueryResultChunk) error {
+		if !started {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			started = true
+		}
+		if err := enc.Encode(queryStreamLine{QueryResultChunk: chunk}); err != nil {
+			return errors.Wrap(err, "encoding chunk")
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	})
+	if err == nil {
+		return
+	}
+
+	if !started {
+		switch errors.Cause(err) {
+		case pilosa.ErrTranslateStoreReadOnly:
+			u := h.api.PrimaryReplicaNodeURL()
+			u.Path, u.RawQuery = r.URL.Path, r.URL.RawQuery
+			http.Redirect(w, r, u.String(), http.StatusFound)
+			return
+		case pilosa.ErrTooManyWrites:
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+		case pilosa.ErrWriteConsistencyNotMet:
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case pilosa.ErrWriteNotReplicated:
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}
+	if e := enc.Encode(queryStreamLine{Err: err.Error()}); e != nil {
+		h.logger.Printf("write query stream error: %v (while trying to write another error: %v)", e, err)
+	}
+}
+
+// queryStreamLine is a single line of a streamed query response. It holds
+// either a result chunk or an error which ended the stream.
+type queryStreamLine struct {
+	pilosa.QueryResultChunk
+	Err string `json:"error,omitempty"`
+}
+
+// handlePostSQL handles POST /sql requests. The body holds a single SQL
+// statement, and the result is returned as a JSON table.
+func (h *Handler) handlePostSQL(w http.ResponseWriter, r *http.Request) {
+	if !validHeaderAcceptJSON(r.Header) {
+		http.Error(w, "JSON only acceptable response", http.StatusNotAcceptable)
+		return
+	}
+	resp := successResponse{h: h}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		resp.write(w, pilosa.NewBadRequestError(errors.Wrap(err, "reading body")))
+		return
+	}
+
+	result, err := h.api.SQL(r.Context(), string(body))
+	if err != nil {
+		resp.write(w, err)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		h.logger.Printf("write sql response error: %s", err)
+	}
+}
+
 // handleGetShardsMax handles GET /internal/shards/max requests.
 func (h *Handler) handleGetShardsMax(w http.ResponseWriter, r *http.Request) {
 	if !validHeaderAcceptJSON(r.Header) {
@@ -937,6 +1089,29 @@ func (h *Handler) handleDeleteField(w http.ResponseWriter, r *http.Request) {
 	resp.write(w, err)
 }
 
+// handleDeleteColumn handles DELETE /index/{index}/column/{column} request.
+func (h *Handler) handleDeleteColumn(w http.ResponseWriter, r *http.Request) {
+	if !validHeaderAcceptJSON(r.Header) {
+		http.Error(w, "JSON only acceptable response", http.StatusNotAcceptable)
+		return
+	}
+
+	indexName := mux.Vars(r)["index"]
+	column := mux.Vars(r)["column"]
+	keys := r.URL.Query().Get("keys") == "true"
+	attrs := r.URL.Query().Get("attrs") == "true"
+
+	_, err := h.api.DeleteColumn(r.Context(), indexName, column, keys, attrs)
+	if errors.Cause(err) == pilosa.ErrTranslateStoreReadOnly {
+		u := h.api.PrimaryReplicaNodeURL()
+		u.Path, u.RawQuery = r.URL.Path, r.URL.RawQuery
+		http.Redirect(w, r, u.String(), http.StatusTemporaryRedirect)
+		return
+	}
+	resp := successResponse{h: h}
+	resp.write(w, err)
+}
+
 // handleDeleteRemoteAvailableShard handles DELETE /field/{field}/available-shards/{shardID} request.
 func (h *Handler) handleDeleteRemoteAvailableShard(w http.ResponseWriter, r *http.Request) {
 	if !validHeaderAcceptJSON(r.Header) {
@@ -1019,6 +1194,10 @@ func (h *Handler) readProtobufQueryRequest(r *http.Request) (*pilosa.QueryReques
 	if err != nil {
 		return nil, errors.Wrap(err, "unmarshalling query request")
 	}
+	// The protobuf message has no atomic or write consistency fields, so
+	// take them from the URL.
+	qreq.Atomic = r.URL.Query().Get("atomic") == "true"
+	qreq.WriteConsistency = pilosa.WriteConsistency(r.URL.Query().Get("writeConsistency"))
 	return qreq, nil
 }
 
@@ -1040,16 +1219,22 @@ func (h *Handler) readURLQueryRequest(r *http.Request) (*pilosa.QueryRequest, er
 	}
 
 	return &pilosa.QueryRequest{
-		Query:           query,
-		Shards:          shards,
-		ColumnAttrs:     q.Get("columnAttrs") == "true",
-		ExcludeRowAttrs: q.Get("excludeRowAttrs") == "true",
-		ExcludeColumns:  q.Get("excludeColumns") == "true",
+		Query:            query,
+		Shards:           shards,
+		ColumnAttrs:      q.Get("columnAttrs") == "true",
+		ExcludeRowAttrs:  q.Get("excludeRowAttrs") == "true",
+		ExcludeColumns:   q.Get("excludeColumns") == "true",
+		Atomic:           q.Get("atomic") == "true",
+		WriteConsistency: pilosa.WriteConsistency(q.Get("writeConsistency")),
 	}, nil
 }
 
 // writeQueryResponse writes the response from the executor to w.
 func (h *Handler) writeQueryResponse(w http.ResponseWriter, r *http.Request, resp *pilosa.QueryResponse) error {
+	// Errors can't be represented in Arrow so they fall through to JSON.
+	if validHeaderAcceptArrow(r.Header) && resp.Err == nil {
+		return h.writeArrowQueryResponse(w, resp)
+	}
 	if !validHeaderAcceptJSON(r.Header) {
 		w.Header().Set("Content-Type", "application/protobuf")
 		return h.writeProtobufQueryResponse(w, resp)
@@ -1068,11 +1253,77 @@ func (h *Handler) writeProtobufQueryResponse(w io.Writer, resp *pilosa.QueryResp
 	return nil
 }
 
+// writeArrowQueryResponse writes the response from the executor to w as an
+// Arrow stream.
+func (h *Handler) writeArrowQueryResponse(w http.ResponseWriter, resp *pilosa.QueryResponse) error {
+	buf, err := arrow.Serializer{}.Marshal(resp)
+	if err == arrow.ErrUnsupportedResult {
+		http.Error(w, err.Error(), http.StatusNotAcceptable)
+		return nil
+	} else if err != nil {
+		return errors.Wrap(err, "marshalling")
+	}
+	w.Header().Set("Content-Type", arrow.ContentType)
+	if _, err := w.Write(buf); err != nil {
+		return errors.Wrap(err, "writing")
+	}
+	return nil
+}
+
 // writeJSONQueryResponse writes the response from the executor to w as JSON.
 func (h *Handler) writeJSONQueryResponse(w io.Writer, resp *pilosa.QueryResponse) error {
 	return json.NewEncoder(w).Encode(resp)
 }
 
+// handlePostIngest handles POST /index/{index}/ingest requests. The body
+// holds NDJSON or CSV records, and the URL arguments map record values to
+// fields.
+func (h *Handler) handlePostIngest(w http.ResponseWriter, r *http.Request) {
+	if !validHeaderAcceptJSON(r.Header) {
+		http.Error(w, "JSON only acceptable response", http.StatusNotAcceptable)
+		return
+	}
+
+	var format pilosa.IngestFormat
+	switch strings.TrimSpace(strings.Split(r.Header.Get("Content-Type"), ";")[0]) {
+	case "application/x-ndjson", "application/json":
+		format = pilosa.IngestFormatNDJSON
+	case "text/csv":
+		format = pilosa.IngestFormatCSV
+	default:
+		http.Error(w, "Unsupported media type", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	resp := successResponse{h: h}
+	q := r.URL.Query()
+	mapping := &pilosa.IngestMapping{
+		Column: q.Get("column"),
+		Set:    splitIngestList(q.Get("set")),
+		Int:    splitIngestList(q.Get("int")),
+		Time:   make(map[string]string),
+	}
+	for _, item := range splitIngestList(q.Get("time")) {
+		parts := strings.SplitN(item, ":", 2)
+		if len(parts) != 2 {
+			resp.write(w, pilosa.NewBadRequestError(errors.Errorf("time field must be given as <field>:<timestamp>: %s", item)))
+			return
+		}
+		mapping.Time[parts[0]] = parts[1]
+	}
+
+	err := h.api.Ingest(r.Context(), mux.Vars(r)["index"], format, mapping, r.Body)
+	resp.write(w, err)
+}
+
+// splitIngestList splits a comma separated list of names.
+func splitIngestList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
 // handlePostImport handles /import requests.
 func (h *Handler) handlePostImport(w http.ResponseWriter, r *http.Request) {
 	// Verify that request is only communicating over protobufs.
@@ -1090,10 +1341,13 @@ func (h *Handler) handlePostImport(w http.ResponseWriter, r *http.Request) {
 	q := r.URL.Query()
 	doClear := q.Get("clear") == "true"
 	doIgnoreKeyCheck := q.Get("ignoreKeyCheck") == "true"
+	doUpsert := q.Get("upsert") == "true"
 
 	opts := []pilosa.ImportOption{
 		pilosa.OptImportOptionsClear(doClear),
 		pilosa.OptImportOptionsIgnoreKeyCheck(doIgnoreKeyCheck),
+		pilosa.OptImportOptionsUpsert(doUpsert),
+		pilosa.OptImportOptionsToken(q.Get("token")),
 	}
 
 	// Get index and field type to determine how to handle the
@@ -1205,6 +1459,68 @@ func (h *Handler) handleGetExportCSV(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleGetIndexExport handles GET /index/{index}/export requests, which
+// stream every column of the index in the format given by the format URL
+// argument, CSV by default.
+func (h *Handler) handleGetIndexExport(w http.ResponseWriter, r *http.Request) {
+	format := pilosa.ExportFormat(r.URL.Query().Get("format"))
+	switch format {
+	case "", pilosa.ExportFormatCSV:
+		format = pilosa.ExportFormatCSV
+		w.Header().Set("Content-Type", "text/csv")
+	case pilosa.ExportFormatNDJSON:
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	case pilosa.ExportFormatParquet:
+		w.Header().Set("Content-Type", parquet.ContentType)
+	default:
+		http.Error(w, "unknown export format", http.StatusBadRequest)
+		return
+	}
+
+	sw := &streamResponseWriter{ResponseWriter: w}
+	if err := h.api.ExportIndex(r.Context(), mux.Vars(r)["index"], format, sw); err != nil {
+		h.logger.Printf("exporting index: %v", err)
+		cause := errors.Cause(err)
+		if _, ok := cause.(pilosa.BadRequestError); ok {
+			sw.Error(err, http.StatusBadRequest)
+		} else if cause == pilosa.ErrIndexNotFound {
+			sw.Error(err, http.StatusNotFound)
+		} else {
+			sw.Error(err, http.StatusInternalServerError)
+		}
+	}
+}
+
+// handleGetShardExport handles GET /internal/index/{index}/shard/{shard}/export
+// requests, returning the columns of a shard before keys are translated.
+func (h *Handler) handleGetShardExport(w http.ResponseWriter, r *http.Request) {
+	if !validHeaderAcceptJSON(r.Header) {
+		http.Error(w, "JSON only acceptable response", http.StatusNotAcceptable)
+		return
+	}
+	shard, err := strconv.ParseUint(mux.Vars(r)["shard"], 10, 64)
+	if err != nil {
+		http.Error(w, "shard should be an unsigned integer", http.StatusBadRequest)
+		return
+	}
+
+	columns, err := h.api.ExportShard(r.Context(), mux.Vars(r)["index"], shard)
+	if errors.Cause(err) == pilosa.ErrIndexNotFound {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	} else if errors.Cause(err) == pilosa.ErrClusterDoesNotOwnShard {
+		http.Error(w, err.Error(), http.StatusPreconditionFailed)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(columns); err != nil {
+		h.logger.Printf("write shard export response error: %s", err)
+	}
+}
+
 // handleGetFragmentNodes handles /internal/fragment/nodes requests.
 func (h *Handler) handleGetFragmentNodes(w http.ResponseWriter, r *http.Request) {
 	if !validHeaderAcceptJSON(r.Header) {
@@ -1309,6 +1625,196 @@ type getFragmentBlocksResponse struct {
 	Blocks []pilosa.FragmentBlock `json:"blocks"`
 }
 
+// handlePostBackup handles POST /backup requests, returning a backup of
+// every index as a tar archive. The request body may hold the manifest of an
+// earlier backup, for an incremental backup.
+func (h *Handler) handlePostBackup(w http.ResponseWriter, r *http.Request) {
+	var base *pilosa.BackupManifest
+	if err := json.NewDecoder(r.Body).Decode(&base); err != nil && err != io.EOF {
+		http.Error(w, "decoding manifest: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-tar")
+	sw := &streamResponseWriter{ResponseWriter: w}
+	if err := h.api.Backup(r.Context(), base, sw); err != nil {
+		h.logger.Printf("backing up: %v", err)
+		switch errors.Cause(err) {
+		case pilosa.ErrNodeNotCoordinator:
+			sw.Error(err, http.StatusBadRequest)
+		default:
+			sw.Error(err, http.StatusInternalServerError)
+		}
+	}
+}
+
+// streamResponseWriter records whether a streamed response has started.
+type streamResponseWriter struct {
+	http.ResponseWriter
+	started bool
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (w *streamResponseWriter) WriteHeader(code int) {
+	w.started = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write implements io.Writer.
+func (w *streamResponseWriter) Write(p []byte) (int, error) {
+	w.started = true
+	return w.ResponseWriter.Write(p)
+}
+
+// Error reports an error which ended the response. If nothing has been
+// sent yet, the error is sent with code as the status. Otherwise the status
+// has already been sent, so the connection is aborted instead, and the
+// client sees a truncated response rather than one which looks complete.
+func (w *streamResponseWriter) Error(err error, code int) {
+	if !w.started {
+		http.Error(w.ResponseWriter, err.Error(), code)
+		return
+	}
+	panic(http.ErrAbortHandler)
+}
+
+// handlePostBlockBackup handles POST /internal/backup/{id}/block requests,
+// blocking writes made through this node for a backup.
+func (h *Handler) handlePostBlockBackup(w http.ResponseWriter, r *http.Request) {
+	if err := h.api.BlockBackup(r.Context(), mux.Vars(r)["id"]); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// handleDeleteBlockBackup handles DELETE /internal/backup/{id}/block
+// requests, unblocking writes made through this node for a backup.
+func (h *Handler) handleDeleteBlockBackup(w http.ResponseWriter, r *http.Request) {
+	if err := h.api.UnblockBackup(r.Context(), mux.Vars(r)["id"]); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// handlePostPinBackup handles POST /internal/backup/{id} requests, pinning
+// the fragments of this node for a backup.
+func (h *Handler) handlePostPinBackup(w http.ResponseWriter, r *http.Request) {
+	if !validHeaderAcceptJSON(r.Header) {
+		http.Error(w, "JSON only acceptable response", http.StatusNotAcceptable)
+		return
+	}
+	infos, err := h.api.PinBackup(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(infos); err != nil {
+		h.logger.Printf("write pin backup response error: %s", err)
+	}
+}
+
+// handleGetBackupFragment handles GET /internal/backup/{id}/fragment
+// requests, returning a fragment pinned for a backup.
+func (h *Handler) handleGetBackupFragment(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	shard, err := strconv.ParseUint(q.Get("shard"), 10, 64)
+	if err != nil {
+		http.Error(w, "shard should be an unsigned integer", http.StatusBadRequest)
+		return
+	}
+	var offset int64
+	if s := q.Get("offset"); s != "" {
+		if offset, err = strconv.ParseInt(s, 10, 64); err != nil {
+			http.Error(w, "offset should be an integer", http.StatusBadRequest)
+			return
+		}
+	}
+	bf := pilosa.BackupFragment{Index: q.Get("index"), Field: q.Get("field"), View: q.Get("view"), Shard: shard}
+	p, err := h.api.BackupFragment(r.Context(), mux.Vars(r)["id"], bf, offset)
+	if cause := errors.Cause(err); cause == pilosa.ErrBackupNotFound || cause == pilosa.ErrFragmentNotFound {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := p.WriteTo(w); err != nil {
+		h.logger.Printf("error streaming backup fragment: %s", err)
+	}
+}
+
+// handleDeleteBackup handles DELETE /internal/backup/{id} requests,
+// releasing the fragments of this node pinned for a backup.
+func (h *Handler) handleDeleteBackup(w http.ResponseWriter, r *http.Request) {
+	err := h.api.ReleaseBackup(r.Context(), mux.Vars(r)["id"])
+	if errors.Cause(err) == pilosa.ErrBackupNotFound {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// handlePostRestore handles POST /restore requests, rebuilding the indexes
+// of a backup read from the request body.
+func (h *Handler) handlePostRestore(w http.ResponseWriter, r *http.Request) {
+	if err := h.api.Restore(r.Context(), r.Body); err != nil {
+		h.logger.Printf("restoring: %v", err)
+		http.Error(w, err.Error(), restoreErrorStatus(err))
+	}
+}
+
+// handlePostRestoreFragment handles POST /internal/restore/fragment requests,
+// replacing the data of a fragment with the archive in the request body.
+func (h *Handler) handlePostRestoreFragment(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	shard, err := strconv.ParseUint(q.Get("shard"), 10, 64)
+	if err != nil {
+		http.Error(w, "shard should be an unsigned integer", http.StatusBadRequest)
+		return
+	}
+	bf := pilosa.BackupFragment{Index: q.Get("index"), Field: q.Get("field"), View: q.Get("view"), Shard: shard}
+	if err := h.api.RestoreFragment(r.Context(), bf, r.Body); err != nil {
+		http.Error(w, err.Error(), restoreErrorStatus(err))
+	}
+}
+
+// handlePostRestoreAttrs handles POST /internal/restore/attrs requests,
+// setting the attributes in the request body.
+func (h *Handler) handlePostRestoreAttrs(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if err := h.api.RestoreAttrs(r.Context(), q.Get("index"), q.Get("field"), r.Body); err != nil {
+		http.Error(w, err.Error(), restoreErrorStatus(err))
+	}
+}
+
+// handlePostRestoreKeys handles POST /internal/restore/keys requests,
+// setting the translate keys in the request body.
+func (h *Handler) handlePostRestoreKeys(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if err := h.api.RestoreKeys(r.Context(), q.Get("index"), q.Get("field"), r.Body); err != nil {
+		http.Error(w, err.Error(), restoreErrorStatus(err))
+	}
+}
+
+// restoreErrorStatus returns the HTTP status of an error restoring a backup.
+func restoreErrorStatus(err error) int {
+	cause := errors.Cause(err)
+	switch cause.(type) {
+	case pilosa.BadRequestError:
+		return http.StatusBadRequest
+	case pilosa.ConflictError:
+		return http.StatusConflict
+	}
+	switch cause {
+	case pilosa.ErrIndexNotFound, pilosa.ErrFieldNotFound:
+		return http.StatusNotFound
+	case pilosa.ErrNodeNotCoordinator:
+		return http.StatusBadRequest
+	case pilosa.ErrClusterDoesNotOwnShard, pilosa.ErrTranslateStoreReadOnly:
+		return http.StatusPreconditionFailed
+	}
+	return http.StatusInternalServerError
+}
+
 // handleGetFragmentData handles GET /internal/fragment/data requests.
 func (h *Handler) handleGetFragmentData(w http.ResponseWriter, r *http.Request) {
 	// Read shard parameter.
@@ -1660,6 +2166,7 @@ func (h *Handler) handlePostImportRoaring(w http.ResponseWriter, r *http.Request
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	req.Token = q.Get("token")
 
 	urlVars := mux.Vars(r)
 	shard, err := strconv.ParseUint(urlVars["shard"], 10, 64)
@@ -1708,6 +2215,7 @@ func (h *Handler) handlePostTranslateKeys(w http.ResponseWriter, r *http.Request
 	buf, err := h.api.TranslateKeys(r.Body)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("translate keys: %v", err), http.StatusInternalServerError)
+		return
 	}
 
 	// Write response.
@@ -17,6 +17,9 @@ package http
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"strings"
 	"testing"
@@ -178,3 +181,25 @@ func TestFieldOptionValidation(t *testing.T) {
 		}
 	}
 }
+
+// Ensure errors in a streamed response are only sent before it has started.
+func TestStreamResponseWriter_Error(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := &streamResponseWriter{ResponseWriter: rec}
+	w.Error(errors.New("marker"), http.StatusNotFound)
+	if rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), "marker") {
+		t.Fatalf("unexpected response: %d %s", rec.Code, rec.Body.String())
+	}
+
+	w = &streamResponseWriter{ResponseWriter: httptest.NewRecorder()}
+	if _, err := w.Write([]byte("partial")); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if r := recover(); r != http.ErrAbortHandler {
+			t.Fatalf("unexpected panic: %v", r)
+		}
+	}()
+	w.Error(errors.New("marker"), http.StatusInternalServerError)
+	t.Fatal("expected response to be aborted")
+}
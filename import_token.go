@@ -0,0 +1,210 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pilosa
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// defaultImportTokenN is the number of import tokens remembered per shard.
+const defaultImportTokenN = 1000
+
+// importToken is the record of a successful import made with a token.
+type importToken struct {
+	Token string `json:"token"`
+}
+
+// importTokenStore records the tokens of successful imports, so that an
+// import which is retried after it succeeded isn't applied again. Failed
+// imports aren't recorded, so retrying them runs the import again. The most
+// recent tokens of each shard are kept in a file next to the field's views,
+// so that they survive a restart.
+type importTokenStore struct {
+	mu     sync.Mutex
+	path   string
+	maxN   int
+	shards map[uint64]*shardImportTokens
+}
+
+// shardImportTokens holds the tokens seen by a shard.
+type shardImportTokens struct {
+	order  []string            // tokens, oldest first
+	tokens map[string]struct{} // set of remembered tokens
+	lines  int                 // number of tokens in the file
+
+	// Imports which haven't finished, by token.
+	inflight map[string]chan struct{}
+}
+
+// newImportTokenStore returns a token store which keeps maxN tokens per shard
+// in path.
+func newImportTokenStore(path string, maxN int) *importTokenStore {
+	return &importTokenStore{
+		path:   path,
+		maxN:   maxN,
+		shards: make(map[uint64]*shardImportTokens),
+	}
+}
+
+// do calls fn unless an import with token was already made successfully to
+// shard, in which case nil is returned. If an import with token is still
+// running, do waits for it, and calls fn if that import failed.
+func (s *importTokenStore) do(shard uint64, token string, fn func() error) error {
+	s.mu.Lock()
+	st, err := s.shard(shard)
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	for {
+		if _, ok := st.tokens[token]; ok {
+			s.mu.Unlock()
+			return nil
+		}
+		ch, ok := st.inflight[token]
+		if !ok {
+			break
+		}
+		s.mu.Unlock()
+		<-ch
+		s.mu.Lock()
+	}
+	ch := make(chan struct{})
+	st.inflight[token] = ch
+	s.mu.Unlock()
+
+	importErr := fn()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(st.inflight, token)
+	close(ch)
+
+	// A failed import isn't recorded, whether or not the failure would
+	// happen again, so that a retry runs the import again.
+	if importErr != nil {
+		return importErr
+	}
+	if err := s.record(shard, st, token); err != nil {
+		return errors.Wrap(err, "recording import token")
+	}
+	return nil
+}
+
+// shard returns the tokens of a shard, reading them from disk the first
+// time. s.mu must be held.
+func (s *importTokenStore) shard(shard uint64) (*shardImportTokens, error) {
+	if st, ok := s.shards[shard]; ok {
+		return st, nil
+	}
+
+	st := &shardImportTokens{
+		tokens:   make(map[string]struct{}),
+		inflight: make(map[string]chan struct{}),
+	}
+	buf, err := ioutil.ReadFile(s.shardPath(shard))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, "reading import tokens")
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(buf))
+	scanner.Buffer(nil, len(buf)+1)
+	for scanner.Scan() {
+		var it importToken
+		if err := json.Unmarshal(scanner.Bytes(), &it); err != nil {
+			return nil, errors.Wrapf(err, "unmarshaling import token for shard %d", shard)
+		}
+		st.add(it.Token, s.maxN)
+		st.lines++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "scanning import tokens")
+	}
+	s.shards[shard] = st
+	return st, nil
+}
+
+// record appends a token to the shard's file. Once the file holds twice as
+// many tokens as are remembered, it is rewritten with only the remembered
+// ones. s.mu must be held.
+func (s *importTokenStore) record(shard uint64, st *shardImportTokens, token string) error {
+	st.add(token, s.maxN)
+
+	if err := os.MkdirAll(s.path, 0777); err != nil {
+		return errors.Wrap(err, "creating import tokens directory")
+	}
+	path := s.shardPath(shard)
+
+	if st.lines+1 > 2*s.maxN {
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		for _, token := range st.order {
+			if err := enc.Encode(importToken{Token: token}); err != nil {
+				return errors.Wrap(err, "marshaling import token")
+			}
+		}
+		tmp := path + ".tmp"
+		if err := ioutil.WriteFile(tmp, buf.Bytes(), 0666); err != nil {
+			return errors.Wrap(err, "writing import tokens")
+		} else if err := os.Rename(tmp, path); err != nil {
+			return errors.Wrap(err, "renaming import tokens")
+		}
+		st.lines = len(st.order)
+		return nil
+	}
+
+	buf, err := json.Marshal(importToken{Token: token})
+	if err != nil {
+		return errors.Wrap(err, "marshaling import token")
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return errors.Wrap(err, "opening import tokens")
+	}
+	defer f.Close()
+	if _, err := f.Write(append(buf, '\n')); err != nil {
+		return errors.Wrap(err, "writing import token")
+	} else if err := f.Sync(); err != nil {
+		return errors.Wrap(err, "syncing import tokens")
+	}
+	st.lines++
+	return nil
+}
+
+// shardPath returns the path of the file holding a shard's tokens.
+func (s *importTokenStore) shardPath(shard uint64) string {
+	return filepath.Join(s.path, strconv.FormatUint(shard, 10))
+}
+
+// add remembers a token, forgetting the oldest token if more than maxN are
+// remembered.
+func (st *shardImportTokens) add(token string, maxN int) {
+	if _, ok := st.tokens[token]; !ok {
+		st.order = append(st.order, token)
+	}
+	st.tokens[token] = struct{}{}
+	for len(st.order) > maxN {
+		delete(st.tokens, st.order[0])
+		st.order = st.order[1:]
+	}
+}
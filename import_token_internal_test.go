@@ -0,0 +1,136 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pilosa
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestImportTokenStore(t *testing.T) {
+	path, err := ioutil.TempDir(*TempDir, "pilosa-tokens-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+	path = filepath.Join(path, "tokens")
+
+	var calls int
+	fn := func(err error) func() error {
+		return func() error {
+			calls++
+			return err
+		}
+	}
+
+	s := newImportTokenStore(path, 2)
+	t.Run("Retry", func(t *testing.T) {
+		if err := s.do(0, "a", fn(nil)); err != nil {
+			t.Fatal(err)
+		} else if err := s.do(0, "b", fn(NewBadRequestError(errors.New("marker")))); errors.Cause(err).Error() != "marker" {
+			t.Fatalf("unexpected error: %v", err)
+		} else if _, ok := err.(BadRequestError); !ok {
+			t.Fatalf("unexpected error type: %T", err)
+		}
+
+		// Retrying a successful import returns success without importing.
+		if err := s.do(0, "a", fn(errors.New("retried"))); err != nil {
+			t.Fatal(err)
+		} else if calls != 2 {
+			t.Fatalf("unexpected calls: %d", calls)
+		}
+
+		// Retrying a failed import runs it again.
+		if err := s.do(0, "b", fn(errors.New("transient"))); err == nil || err.Error() != "transient" {
+			t.Fatalf("unexpected error: %v", err)
+		} else if err := s.do(0, "b", fn(nil)); err != nil {
+			t.Fatal(err)
+		} else if err := s.do(0, "b", fn(errors.New("retried"))); err != nil {
+			t.Fatal(err)
+		} else if calls != 4 {
+			t.Fatalf("unexpected calls: %d", calls)
+		}
+
+		// Tokens are per shard.
+		if err := s.do(1, "a", fn(nil)); err != nil {
+			t.Fatal(err)
+		} else if calls != 5 {
+			t.Fatalf("unexpected calls: %d", calls)
+		}
+	})
+
+	t.Run("Reopen", func(t *testing.T) {
+		s = newImportTokenStore(path, 2)
+		if err := s.do(0, "a", fn(errors.New("retried"))); err != nil {
+			t.Fatal(err)
+		} else if err := s.do(0, "b", fn(errors.New("retried"))); err != nil {
+			t.Fatal(err)
+		} else if calls != 5 {
+			t.Fatalf("unexpected calls: %d", calls)
+		}
+	})
+
+	t.Run("Evict", func(t *testing.T) {
+		// Only the two most recent tokens are remembered, including after
+		// the file is rewritten.
+		for _, token := range []string{"c", "d", "e"} {
+			if err := s.do(0, token, fn(nil)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		s = newImportTokenStore(path, 2)
+		if err := s.do(0, "e", fn(nil)); err != nil {
+			t.Fatal(err)
+		} else if calls != 8 {
+			t.Fatalf("unexpected calls: %d", calls)
+		} else if err := s.do(0, "c", fn(nil)); err != nil {
+			t.Fatal(err)
+		} else if calls != 9 {
+			t.Fatalf("unexpected calls: %d", calls)
+		}
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		var mu sync.Mutex
+		var n int
+		start := make(chan struct{})
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+				if err := s.do(2, "f", func() error {
+					mu.Lock()
+					n++
+					mu.Unlock()
+					return nil
+				}); err != nil {
+					t.Error(err)
+				}
+			}()
+		}
+		close(start)
+		wg.Wait()
+		if n != 1 {
+			t.Fatalf("unexpected imports: %d", n)
+		}
+	})
+}
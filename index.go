@@ -59,6 +59,8 @@ type Index struct {
 
 	logger        logger.Logger
 	snapshotQueue chan *fragment
+	quarantine    *fragmentQuarantine
+	storageOpt    StorageOptions
 
 	// Used for notifying holder when a field is added.
 	holder *Holder
@@ -341,10 +343,13 @@ func (i *Index) existenceField() *Field {
 }
 
 // recalculateCaches recalculates caches on every field in the index.
-func (i *Index) recalculateCaches() {
+func (i *Index) recalculateCaches() error {
 	for _, field := range i.Fields() {
-		field.recalculateCaches()
+		if err := field.recalculateCaches(); err != nil {
+			return errors.Wrapf(err, "field %s", field.Name())
+		}
 	}
+	return nil
 }
 
 // CreateField creates a field.
@@ -463,6 +468,8 @@ func (i *Index) newField(path, name string) (*Field, error) {
 	f.broadcaster = i.broadcaster
 	f.rowAttrStore = i.newAttrStore(filepath.Join(f.path, ".data"))
 	f.snapshotQueue = i.snapshotQueue
+	f.quarantine = i.quarantine
+	f.storageOpt = i.storageOpt
 	f.OpenTranslateStore = i.OpenTranslateStore
 	return f, nil
 }
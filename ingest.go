@@ -0,0 +1,432 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pilosa
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/pilosa/pilosa/v2/tracing"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+)
+
+// IngestFormat is the encoding of the records passed to API.Ingest.
+type IngestFormat string
+
+// Ingest formats.
+const (
+	// One JSON object per record.
+	IngestFormatNDJSON IngestFormat = "ndjson"
+
+	// One row per record, after a header row naming the values.
+	IngestFormatCSV IngestFormat = "csv"
+)
+
+// ingestBatchSize is the number of records imported at once.
+const ingestBatchSize = 10000
+
+// IngestMapping declares how the values of ingested records are imported.
+// Values are imported into the field of the same name.
+type IngestMapping struct {
+	// Name of the value holding the record's column ID, or its column key
+	// if the index uses keys.
+	Column string
+
+	// Names of the set, mutex and bool fields to import into. In NDJSON
+	// records, a value may be a list of rows.
+	Set []string
+
+	// Names of the int fields to import into.
+	Int []string
+
+	// Names of the time fields to import into, mapped to the name of the
+	// value holding the record's timestamp. Timestamps are RFC 3339 or
+	// TimeFormat strings. Records without a timestamp are only imported
+	// into the standard view.
+	Time map[string]string
+}
+
+// ingestRecord is a decoded record, by value name.
+type ingestRecord map[string]interface{}
+
+// Ingest imports records, each of which may set values in several fields.
+// Records are imported in batches: the column and row keys of each batch
+// are translated together, and its bits and values are sent with one
+// import per field and shard.
+func (api *API) Ingest(ctx context.Context, indexName string, format IngestFormat, mapping *IngestMapping, r io.Reader) error {
+	span, ctx := tracing.StartSpanFromContext(ctx, "API.Ingest")
+	defer span.Finish()
+
+	if err := api.validate(apiIngest); err != nil {
+		return errors.Wrap(err, "validating api method")
+	}
+
+	index := api.holder.Index(indexName)
+	if index == nil {
+		return newNotFoundError(ErrIndexNotFound, indexName)
+	}
+	if err := validateIngestMapping(index, mapping); err != nil {
+		return err
+	}
+
+	var next func() (ingestRecord, error)
+	switch format {
+	case IngestFormatNDJSON:
+		next = ndjsonIngestDecoder(r)
+	case IngestFormatCSV:
+		next = csvIngestDecoder(r)
+	default:
+		return NewBadRequestError(errors.Errorf("unknown ingest format: %q", format))
+	}
+
+	var n int
+	records := make([]ingestRecord, 0, ingestBatchSize)
+	for {
+		rec, err := next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return NewBadRequestError(errors.Wrapf(err, "decoding record %d", n+len(records)))
+		}
+		records = append(records, rec)
+
+		if len(records) == ingestBatchSize {
+			if err := api.ingestBatch(ctx, index, mapping, records, n); err != nil {
+				return err
+			}
+			n += len(records)
+			records = records[:0]
+		}
+	}
+	return api.ingestBatch(ctx, index, mapping, records, n)
+}
+
+// validateIngestMapping returns an error if mapping refers to fields which
+// don't exist or are of the wrong type.
+func validateIngestMapping(index *Index, mapping *IngestMapping) error {
+	if mapping.Column == "" {
+		return NewBadRequestError(errors.New("ingest mapping requires a column"))
+	}
+
+	// Each field is imported from a single mapping, so that one can't
+	// replace the bits of another.
+	seen := make(map[string]struct{})
+	check := func(name string, types ...string) error {
+		if _, ok := seen[name]; ok {
+			return NewBadRequestError(errors.Errorf("field %s appears more than once in ingest mapping", name))
+		}
+		seen[name] = struct{}{}
+
+		f := index.Field(name)
+		if f == nil {
+			return newNotFoundError(ErrFieldNotFound, name)
+		}
+		for _, typ := range types {
+			if f.Type() == typ {
+				return nil
+			}
+		}
+		return NewBadRequestError(errors.Errorf("cannot ingest into %s field %s as %s", f.Type(), name, types[0]))
+	}
+	for _, name := range mapping.Set {
+		if err := check(name, FieldTypeSet, FieldTypeMutex, FieldTypeBool, FieldTypeTime); err != nil {
+			return err
+		}
+	}
+	for _, name := range mapping.Int {
+		if err := check(name, FieldTypeInt); err != nil {
+			return err
+		}
+	}
+	for name := range mapping.Time {
+		if err := check(name, FieldTypeTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ingestBatch imports a batch of records. offset is the number of records
+// before the batch, and is only used in error messages.
+func (api *API) ingestBatch(ctx context.Context, index *Index, mapping *IngestMapping, records []ingestRecord, offset int) error {
+	if len(records) == 0 {
+		return nil
+	}
+	recordErr := func(i int, err error) error {
+		return NewBadRequestError(errors.Wrapf(err, "record %d", offset+i))
+	}
+
+	// Determine the column of each record.
+	columnIDs := make([]uint64, len(records))
+	if index.Keys() {
+		keys := make([]string, len(records))
+		for i, rec := range records {
+			key, err := ingestKey(rec[mapping.Column])
+			if err != nil {
+				return recordErr(i, errors.Wrap(err, "column"))
+			}
+			keys[i] = key
+		}
+		ids, err := api.translateKeys(ctx, index.Name(), "", keys)
+		if err != nil {
+			return errors.Wrap(err, "translating columns")
+		}
+		columnIDs = ids
+	} else {
+		for i, rec := range records {
+			id, err := ingestID(rec[mapping.Column])
+			if err != nil {
+				return recordErr(i, errors.Wrap(err, "column"))
+			}
+			columnIDs[i] = id
+		}
+	}
+
+	bits := make(map[string]map[uint64][]Bit)
+	addBits := func(name, timestampName string) error {
+		f := index.Field(name)
+		var fieldBits []Bit
+		var rowKeys []string
+		for i, rec := range records {
+			var timestamp int64
+			if timestampName != "" {
+				if v, ok := rec[timestampName]; ok && v != nil {
+					t, err := ingestTime(v)
+					if err != nil {
+						return recordErr(i, errors.Wrapf(err, "timestamp %s", timestampName))
+					}
+					timestamp = t.UnixNano()
+				}
+			}
+
+			for _, v := range ingestList(rec[name]) {
+				bit := Bit{ColumnID: columnIDs[i], Timestamp: timestamp}
+				if f.keys() {
+					key, err := ingestKey(v)
+					if err != nil {
+						return recordErr(i, errors.Wrapf(err, "field %s", name))
+					}
+					rowKeys = append(rowKeys, key)
+				} else if f.Type() == FieldTypeBool {
+					b, err := ingestBool(v)
+					if err != nil {
+						return recordErr(i, errors.Wrapf(err, "field %s", name))
+					} else if b {
+						bit.RowID = trueRowID
+					} else {
+						bit.RowID = falseRowID
+					}
+				} else {
+					id, err := ingestID(v)
+					if err != nil {
+						return recordErr(i, errors.Wrapf(err, "field %s", name))
+					}
+					bit.RowID = id
+				}
+				fieldBits = append(fieldBits, bit)
+			}
+		}
+
+		if len(rowKeys) > 0 {
+			ids, err := api.translateKeys(ctx, index.Name(), name, rowKeys)
+			if err != nil {
+				return errors.Wrapf(err, "translating rows of field %s", name)
+			}
+			for i := range fieldBits {
+				fieldBits[i].RowID = ids[i]
+			}
+		}
+
+		byShard := make(map[uint64][]Bit)
+		for _, bit := range fieldBits {
+			shard := bit.ColumnID / ShardWidth
+			byShard[shard] = append(byShard[shard], bit)
+		}
+		bits[name] = byShard
+		return nil
+	}
+	for _, name := range mapping.Set {
+		if err := addBits(name, ""); err != nil {
+			return err
+		}
+	}
+	for name, timestampName := range mapping.Time {
+		if err := addBits(name, timestampName); err != nil {
+			return err
+		}
+	}
+
+	values := make(map[string]map[uint64][]FieldValue)
+	for _, name := range mapping.Int {
+		byShard := make(map[uint64][]FieldValue)
+		for i, rec := range records {
+			v, ok := rec[name]
+			if !ok || v == nil {
+				continue
+			}
+			value, err := ingestInt(v)
+			if err != nil {
+				return recordErr(i, errors.Wrapf(err, "field %s", name))
+			}
+			shard := columnIDs[i] / ShardWidth
+			byShard[shard] = append(byShard[shard], FieldValue{ColumnID: columnIDs[i], Value: value})
+		}
+		values[name] = byShard
+	}
+
+	// Keys have been translated, so the receiving nodes needn't check them.
+	// Each batch holds back backups until it's imported on every node.
+	opt := OptImportOptionsIgnoreKeyCheck(true)
+	api.holder.backupBarrier.enter()
+	defer api.holder.backupBarrier.exit()
+	var eg errgroup.Group
+	for name, byShard := range bits {
+		for shard, shardBits := range byShard {
+			name, shard, shardBits := name, shard, shardBits
+			eg.Go(func() error {
+				return api.server.defaultClient.Import(ctx, index.Name(), name, shard, shardBits, opt)
+			})
+		}
+	}
+	for name, byShard := range values {
+		for shard, shardValues := range byShard {
+			name, shard, shardValues := name, shard, shardValues
+			eg.Go(func() error {
+				return api.server.defaultClient.ImportValue(ctx, index.Name(), name, shard, shardValues, opt)
+			})
+		}
+	}
+	return errors.Wrap(eg.Wait(), "importing")
+}
+
+// ndjsonIngestDecoder returns a function which decodes the next record of
+// r, a stream of JSON objects. It returns io.EOF after the last record.
+func ndjsonIngestDecoder(r io.Reader) func() (ingestRecord, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	return func() (ingestRecord, error) {
+		var rec ingestRecord
+		if err := dec.Decode(&rec); err != nil {
+			return nil, err
+		}
+		return rec, nil
+	}
+}
+
+// csvIngestDecoder returns a function which decodes the next record of r,
+// which is CSV with a header row naming the values. Empty values are left
+// out of the record. It returns io.EOF after the last record.
+func csvIngestDecoder(r io.Reader) func() (ingestRecord, error) {
+	cr := csv.NewReader(r)
+	var header []string
+	return func() (ingestRecord, error) {
+		if header == nil {
+			var err error
+			if header, err = cr.Read(); err != nil {
+				return nil, err
+			}
+		}
+		row, err := cr.Read()
+		if err != nil {
+			return nil, err
+		}
+		rec := make(ingestRecord, len(row))
+		for i, v := range row {
+			if v != "" {
+				rec[header[i]] = v
+			}
+		}
+		return rec, nil
+	}
+}
+
+// ingestList returns the values of v, which may be a single value or a
+// list of them.
+func ingestList(v interface{}) []interface{} {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		return v
+	default:
+		return []interface{}{v}
+	}
+}
+
+// ingestKey returns v as a key.
+func ingestKey(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case nil:
+		return "", errors.New("missing value")
+	default:
+		return "", fmt.Errorf("invalid key: %v", v)
+	}
+}
+
+// ingestID returns v as an ID.
+func ingestID(v interface{}) (uint64, error) {
+	s, err := ingestKey(v)
+	if err != nil {
+		return 0, err
+	}
+	id, err := strconv.ParseUint(s, 10, 64)
+	return id, errors.Wrap(err, "parsing id")
+}
+
+// ingestInt returns v as an integer value.
+func ingestInt(v interface{}) (int64, error) {
+	s, err := ingestKey(v)
+	if err != nil {
+		return 0, err
+	}
+	value, err := strconv.ParseInt(s, 10, 64)
+	return value, errors.Wrap(err, "parsing int")
+}
+
+// ingestBool returns v as a bool.
+func ingestBool(v interface{}) (bool, error) {
+	switch v := v.(type) {
+	case bool:
+		return v, nil
+	case string:
+		b, err := strconv.ParseBool(v)
+		return b, errors.Wrap(err, "parsing bool")
+	default:
+		return false, fmt.Errorf("invalid bool: %v", v)
+	}
+}
+
+// ingestTime returns v as a timestamp.
+func ingestTime(v interface{}) (time.Time, error) {
+	s, ok := v.(string)
+	if !ok {
+		return time.Time{}, fmt.Errorf("invalid timestamp: %v", v)
+	}
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(TimeFormat, s)
+	return t, errors.Wrap(err, "parsing timestamp")
+}
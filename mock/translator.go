@@ -29,9 +29,11 @@ type TranslateStore struct {
 	SetReadOnlyFunc   func(v bool)
 	TranslateKeyFunc  func(key string) (uint64, error)
 	TranslateKeysFunc func(keys []string) ([]uint64, error)
+	FindKeysFunc      func(keys []string) ([]uint64, error)
 	TranslateIDFunc   func(id uint64) (string, error)
 	TranslateIDsFunc  func(ids []uint64) ([]string, error)
 	ForceSetFunc      func(id uint64, key string) error
+	DeleteIDsFunc     func(ids []uint64) error
 	EntryReaderFunc   func(ctx context.Context, offset uint64) (pilosa.TranslateEntryReader, error)
 }
 
@@ -59,6 +61,10 @@ func (s *TranslateStore) TranslateKeys(keys []string) ([]uint64, error) {
 	return s.TranslateKeysFunc(keys)
 }
 
+func (s *TranslateStore) FindKeys(keys []string) ([]uint64, error) {
+	return s.FindKeysFunc(keys)
+}
+
 func (s *TranslateStore) TranslateID(id uint64) (string, error) {
 	return s.TranslateIDFunc(id)
 }
@@ -71,6 +77,10 @@ func (s *TranslateStore) ForceSet(id uint64, key string) error {
 	return s.ForceSetFunc(id, key)
 }
 
+func (s *TranslateStore) DeleteIDs(ids []uint64) error {
+	return s.DeleteIDsFunc(ids)
+}
+
 func (s *TranslateStore) EntryReader(ctx context.Context, offset uint64) (pilosa.TranslateEntryReader, error) {
 	return s.EntryReaderFunc(ctx, offset)
 }
@@ -58,6 +58,12 @@ var (
 	ErrQueryTimeout     = errors.New("query timeout")
 	ErrTooManyWrites    = errors.New("too many write commands")
 
+	ErrAtomicCallNotAllowed = errors.New("atomic queries may only contain Set() and Clear() calls")
+
+	ErrInvalidWriteConsistency = errors.New("invalid write consistency, must be one, quorum, or all")
+	ErrWriteConsistencyNotMet  = errors.New("write consistency not met")
+	ErrWriteNotReplicated      = errors.New("write accepted but not yet replicated")
+
 	// TODO(2.0) poorly named - used when a *node* doesn't own a shard. Probably
 	// we won't need this error at all by 2.0 though.
 	ErrClusterDoesNotOwnShard = errors.New("node does not own shard")
@@ -66,6 +72,8 @@ var (
 	ErrNodeNotCoordinator = errors.New("node is not the coordinator")
 	ErrResizeNotRunning   = errors.New("no resize job currently running")
 
+	ErrBackupNotFound = errors.New("backup not found")
+
 	ErrNotImplemented            = errors.New("not implemented")
 	ErrFieldsArgumentRequired    = errors.New("fields argument required")
 	ErrExpectedFieldListArgument = errors.New("expected field list argument")
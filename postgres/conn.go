@@ -0,0 +1,516 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/pilosa/pilosa/v2"
+	"github.com/pkg/errors"
+)
+
+// Startup request codes.
+const (
+	protocolVersion3 = 3 << 16
+	sslRequestCode   = 80877103
+	gssEncRequest    = 80877104
+	cancelRequest    = 80877102
+)
+
+// maxMessageSize bounds the size of a single client message.
+const maxMessageSize = 64 << 20
+
+// Type OIDs of result columns.
+const (
+	oidBool = 16
+	oidInt8 = 20
+	oidText = 25
+)
+
+// SQLSTATE error codes.
+const (
+	codeSyntaxOrAccess     = "42000"
+	codeUndefinedTable     = "42P01"
+	codeUndefinedColumn    = "42703"
+	codeInvalidAuth        = "28000"
+	codeFeatureUnsupported = "0A000"
+	codeProtocolViolation  = "08P01"
+	codeInternal           = "XX000"
+)
+
+// conn is a single client connection.
+type conn struct {
+	nc  net.Conn
+	api *pilosa.API
+	r   *bufio.Reader
+	w   *bufio.Writer
+
+	// tlsConfig is used to encrypt the connection at the client's request.
+	// If set, unencrypted connections are refused.
+	tlsConfig *tls.Config
+
+	// database is the index PQL queries are executed against.
+	database string
+}
+
+func newConn(nc net.Conn, api *pilosa.API, tlsConfig *tls.Config) *conn {
+	return &conn{
+		nc:        nc,
+		api:       api,
+		r:         bufio.NewReader(nc),
+		w:         bufio.NewWriter(nc),
+		tlsConfig: tlsConfig,
+	}
+}
+
+// serve runs the startup handshake and then handles messages until the
+// client terminates the connection.
+func (c *conn) serve(ctx context.Context) error {
+	if ok, err := c.startup(); err != nil {
+		return errors.Wrap(err, "startup")
+	} else if !ok {
+		return nil
+	}
+
+	// Set when an extended query protocol message is rejected; messages are
+	// then discarded until the next Sync.
+	var skipUntilSync bool
+
+	for {
+		typ, body, err := c.readMessage()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return errors.Wrap(err, "reading message")
+		}
+
+		switch typ {
+		case 'Q':
+			query := string(bytes.TrimRight(body, "\x00"))
+			c.query(ctx, query)
+			c.readyForQuery()
+
+		case 'X':
+			return nil
+
+		case 'S':
+			skipUntilSync = false
+			c.readyForQuery()
+
+		case 'P', 'B', 'D', 'E', 'C', 'H':
+			if !skipUntilSync {
+				c.error(codeFeatureUnsupported, "the extended query protocol is not supported; use simple queries")
+				skipUntilSync = true
+			}
+
+		default:
+			c.error(codeProtocolViolation, fmt.Sprintf("unsupported message type %q", typ))
+			c.readyForQuery()
+		}
+
+		if err := c.w.Flush(); err != nil {
+			return errors.Wrap(err, "flushing")
+		}
+	}
+}
+
+// startup reads the startup message and completes the handshake. It returns
+// false if the connection should be closed without error.
+func (c *conn) startup() (bool, error) {
+	var params []string
+	for params == nil {
+		var n int32
+		if err := binary.Read(c.r, binary.BigEndian, &n); err != nil {
+			return false, errors.Wrap(err, "reading length")
+		} else if n < 8 || n > maxMessageSize {
+			return false, errors.Errorf("invalid startup message length: %d", n)
+		}
+		body := make([]byte, n-4)
+		if _, err := io.ReadFull(c.r, body); err != nil {
+			return false, errors.Wrap(err, "reading startup message")
+		}
+
+		switch code := binary.BigEndian.Uint32(body); code {
+		case sslRequestCode:
+			if c.tlsConfig == nil || c.encrypted() {
+				if _, err := c.nc.Write([]byte{'N'}); err != nil {
+					return false, errors.Wrap(err, "declining encryption")
+				}
+				continue
+			}
+			if err := c.startTLS(); err != nil {
+				return false, errors.Wrap(err, "starting tls")
+			}
+		case gssEncRequest:
+			// GSSAPI encryption isn't supported; the client may request
+			// SSL instead.
+			if _, err := c.nc.Write([]byte{'N'}); err != nil {
+				return false, errors.Wrap(err, "declining encryption")
+			}
+		case cancelRequest:
+			return false, nil
+		case protocolVersion3:
+			if c.tlsConfig != nil && !c.encrypted() {
+				c.error(codeInvalidAuth, "SSL is required")
+				return false, c.w.Flush()
+			}
+			params = strings.Split(string(body[4:]), "\x00")
+		default:
+			c.error(codeProtocolViolation, fmt.Sprintf("unsupported protocol version %d.%d", code>>16, code&0xFFFF))
+			return false, c.w.Flush()
+		}
+	}
+	for i := 0; i+1 < len(params); i += 2 {
+		if params[i] == "database" {
+			c.database = params[i+1]
+		}
+	}
+
+	c.writeMessage('R', uint32Bytes(0)) // AuthenticationOk
+	for _, kv := range [][2]string{
+		{"server_version", "9.6.0"},
+		{"server_encoding", "UTF8"},
+		{"client_encoding", "UTF8"},
+		{"DateStyle", "ISO, MDY"},
+		{"integer_datetimes", "on"},
+		{"standard_conforming_strings", "on"},
+	} {
+		c.writeMessage('S', cstrings(kv[0], kv[1]))
+	}
+	c.readyForQuery()
+	return true, c.w.Flush()
+}
+
+// startTLS accepts a client's SSL request and performs the TLS handshake.
+func (c *conn) startTLS() error {
+	// Anything the client sent after its request would otherwise be read
+	// as if it had been encrypted.
+	if c.r.Buffered() > 0 {
+		return errors.New("received unencrypted data after ssl request")
+	}
+	if _, err := c.nc.Write([]byte{'S'}); err != nil {
+		return errors.Wrap(err, "accepting encryption")
+	}
+	tc := tls.Server(c.nc, c.tlsConfig)
+	if err := tc.Handshake(); err != nil {
+		return errors.Wrap(err, "handshake")
+	}
+	c.nc = tc
+	c.r = bufio.NewReader(tc)
+	c.w = bufio.NewWriter(tc)
+	return nil
+}
+
+// encrypted returns true if the connection is using TLS.
+func (c *conn) encrypted() bool {
+	_, ok := c.nc.(*tls.Conn)
+	return ok
+}
+
+// readMessage reads a message type and body.
+func (c *conn) readMessage() (byte, []byte, error) {
+	typ, err := c.r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	var n int32
+	if err := binary.Read(c.r, binary.BigEndian, &n); err != nil {
+		return 0, nil, errors.Wrap(err, "reading length")
+	} else if n < 4 || n > maxMessageSize {
+		return 0, nil, errors.Errorf("invalid message length: %d", n)
+	}
+	body := make([]byte, n-4)
+	if _, err := io.ReadFull(c.r, body); err != nil {
+		return 0, nil, errors.Wrap(err, "reading body")
+	}
+	return typ, body, nil
+}
+
+// query executes a simple query and writes its results.
+func (c *conn) query(ctx context.Context, query string) {
+	query = strings.TrimSpace(query)
+	query = strings.TrimSpace(strings.TrimSuffix(query, ";"))
+	if query == "" {
+		c.writeMessage('I', nil) // EmptyQueryResponse
+		return
+	}
+
+	keyword := strings.ToUpper(strings.Fields(query)[0])
+	switch keyword {
+	case "SELECT":
+		res, err := c.api.SQL(ctx, query)
+		if err != nil {
+			c.apiError(err)
+			return
+		}
+		c.writeTable(res.Columns, res.Rows)
+
+	case "SET":
+		// Clients commonly configure session parameters on connect.
+		// There are none to configure, so these are accepted and ignored.
+		c.writeMessage('C', cstrings("SET"))
+
+	default:
+		if c.database == "" {
+			c.error(codeSyntaxOrAccess, "PQL queries require connecting to an index as the database")
+			return
+		}
+		resp, err := c.api.Query(ctx, &pilosa.QueryRequest{Index: c.database, Query: query})
+		if err != nil {
+			c.apiError(err)
+			return
+		}
+		for _, result := range resp.Results {
+			columns, rows := resultTable(result)
+			c.writeTable(columns, rows)
+		}
+	}
+}
+
+// resultTable converts the result of a PQL call into a table.
+func resultTable(result interface{}) ([]string, [][]interface{}) {
+	switch r := result.(type) {
+	case *pilosa.Row:
+		var rows [][]interface{}
+		if len(r.Keys) > 0 {
+			for _, key := range r.Keys {
+				rows = append(rows, []interface{}{key})
+			}
+		} else {
+			for _, id := range r.Columns() {
+				rows = append(rows, []interface{}{id})
+			}
+		}
+		return []string{"_id"}, rows
+
+	case uint64:
+		return []string{"count"}, [][]interface{}{{r}}
+
+	case bool:
+		return []string{"changed"}, [][]interface{}{{r}}
+
+	case pilosa.ValCount:
+		return []string{"value", "count"}, [][]interface{}{{r.Val, r.Count}}
+
+	case []pilosa.Pair:
+		rows := make([][]interface{}, len(r))
+		for i, p := range r {
+			rows[i] = []interface{}{pairID(p), p.Count}
+		}
+		return []string{"row", "count"}, rows
+
+	case pilosa.Pair:
+		return []string{"row", "count"}, [][]interface{}{{pairID(r), r.Count}}
+
+	case pilosa.RowIdentifiers:
+		var rows [][]interface{}
+		if len(r.Keys) > 0 {
+			for _, key := range r.Keys {
+				rows = append(rows, []interface{}{key})
+			}
+		} else {
+			for _, id := range r.Rows {
+				rows = append(rows, []interface{}{id})
+			}
+		}
+		return []string{"row"}, rows
+
+	case []pilosa.GroupCount:
+		var columns []string
+		if len(r) > 0 {
+			for _, fr := range r[0].Group {
+				columns = append(columns, fr.Field)
+			}
+		}
+		columns = append(columns, "count")
+		rows := make([][]interface{}, len(r))
+		for i, gc := range r {
+			for _, fr := range gc.Group {
+				if fr.RowKey != "" {
+					rows[i] = append(rows[i], fr.RowKey)
+				} else {
+					rows[i] = append(rows[i], fr.RowID)
+				}
+			}
+			rows[i] = append(rows[i], gc.Count)
+		}
+		return columns, rows
+
+	case nil:
+		return []string{"result"}, [][]interface{}{{nil}}
+
+	default:
+		// Results without a natural tabular form are returned as JSON.
+		buf, err := json.Marshal(r)
+		if err != nil {
+			return []string{"result"}, [][]interface{}{{fmt.Sprint(r)}}
+		}
+		return []string{"result"}, [][]interface{}{{string(buf)}}
+	}
+}
+
+func pairID(p pilosa.Pair) interface{} {
+	if p.Key != "" {
+		return p.Key
+	}
+	return p.ID
+}
+
+// writeTable writes a RowDescription, a DataRow per row, and a
+// CommandComplete message. Column types are taken from the first non-null
+// value of each column.
+func (c *conn) writeTable(columns []string, rows [][]interface{}) {
+	var buf bytes.Buffer
+	buf.Write(uint16Bytes(uint16(len(columns))))
+	for i, name := range columns {
+		oid, size := columnType(rows, i)
+		buf.Write(cstrings(name))
+		buf.Write(uint32Bytes(0))                 // table OID
+		buf.Write(uint16Bytes(0))                 // attribute number
+		buf.Write(uint32Bytes(oid))               // type OID
+		buf.Write(uint16Bytes(uint16(size)))      // type size
+		buf.Write(uint32Bytes(uint32(1<<32 - 1))) // type modifier (-1)
+		buf.Write(uint16Bytes(0))                 // text format
+	}
+	c.writeMessage('T', buf.Bytes())
+
+	for _, row := range rows {
+		buf.Reset()
+		buf.Write(uint16Bytes(uint16(len(row))))
+		for _, v := range row {
+			if v == nil {
+				buf.Write(uint32Bytes(uint32(1<<32 - 1))) // NULL (-1)
+				continue
+			}
+			s := formatValue(v)
+			buf.Write(uint32Bytes(uint32(len(s))))
+			buf.WriteString(s)
+		}
+		c.writeMessage('D', buf.Bytes())
+	}
+
+	c.writeMessage('C', cstrings(fmt.Sprintf("SELECT %d", len(rows))))
+}
+
+// columnType returns the type OID and size of column i.
+func columnType(rows [][]interface{}, i int) (oid uint32, size int16) {
+	for _, row := range rows {
+		switch row[i].(type) {
+		case nil:
+			continue
+		case bool:
+			return oidBool, 1
+		case int64, uint64:
+			return oidInt8, 8
+		default:
+			return oidText, -1
+		}
+	}
+	return oidText, -1
+}
+
+// formatValue returns the text format of v.
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case bool:
+		if v {
+			return "t"
+		}
+		return "f"
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// apiError writes err as an ErrorResponse with an appropriate code.
+func (c *conn) apiError(err error) {
+	code := codeInternal
+	cause := errors.Cause(err)
+	switch {
+	case cause == pilosa.ErrIndexNotFound:
+		code = codeUndefinedTable
+	case cause == pilosa.ErrFieldNotFound:
+		code = codeUndefinedColumn
+	default:
+		if _, ok := cause.(pilosa.BadRequestError); ok {
+			code = codeSyntaxOrAccess
+		}
+	}
+	c.error(code, err.Error())
+}
+
+// error writes an ErrorResponse.
+func (c *conn) error(code, msg string) {
+	var buf bytes.Buffer
+	buf.WriteByte('S')
+	buf.Write(cstrings("ERROR"))
+	buf.WriteByte('V')
+	buf.Write(cstrings("ERROR"))
+	buf.WriteByte('C')
+	buf.Write(cstrings(code))
+	buf.WriteByte('M')
+	buf.Write(cstrings(msg))
+	buf.WriteByte(0)
+	c.writeMessage('E', buf.Bytes())
+}
+
+// readyForQuery writes a ReadyForQuery message in the idle state.
+func (c *conn) readyForQuery() {
+	c.writeMessage('Z', []byte{'I'})
+}
+
+// writeMessage buffers a message. Write errors are reported when the buffer
+// is flushed.
+func (c *conn) writeMessage(typ byte, body []byte) {
+	c.w.WriteByte(typ)
+	c.w.Write(uint32Bytes(uint32(len(body) + 4)))
+	c.w.Write(body)
+}
+
+func uint16Bytes(v uint16) []byte {
+	p := make([]byte, 2)
+	binary.BigEndian.PutUint16(p, v)
+	return p
+}
+
+func uint32Bytes(v uint32) []byte {
+	p := make([]byte, 4)
+	binary.BigEndian.PutUint32(p, v)
+	return p
+}
+
+// cstrings returns each string followed by a null terminator.
+func cstrings(a ...string) []byte {
+	var buf bytes.Buffer
+	for _, s := range a {
+		buf.WriteString(s)
+		buf.WriteByte(0)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,168 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package postgres implements enough of the PostgreSQL v3 wire protocol for
+// psql-compatible clients to query Pilosa.
+//
+// Only the simple query protocol is supported. SELECT statements are
+// executed as SQL; any other statement is executed as PQL against the index
+// named by the connection's database parameter.
+package postgres
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"sync"
+
+	"github.com/pilosa/pilosa/v2"
+	"github.com/pilosa/pilosa/v2/logger"
+	"github.com/pkg/errors"
+)
+
+// Server accepts PostgreSQL client connections.
+type Server struct {
+	api       *pilosa.API
+	logger    logger.Logger
+	ln        net.Listener
+	tlsConfig *tls.Config
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu     sync.Mutex
+	conns  map[net.Conn]struct{}
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// serverOption is a functional option type for Server.
+type serverOption func(s *Server) error
+
+// OptServerAPI sets the API used to execute queries.
+func OptServerAPI(api *pilosa.API) serverOption {
+	return func(s *Server) error {
+		s.api = api
+		return nil
+	}
+}
+
+// OptServerLogger sets the logger.
+func OptServerLogger(logger logger.Logger) serverOption {
+	return func(s *Server) error {
+		s.logger = logger
+		return nil
+	}
+}
+
+// OptServerListener sets the listener on which connections are accepted.
+func OptServerListener(ln net.Listener) serverOption {
+	return func(s *Server) error {
+		s.ln = ln
+		return nil
+	}
+}
+
+// OptServerTLSConfig sets the TLS configuration used to encrypt
+// connections. If set, clients must request SSL.
+func OptServerTLSConfig(config *tls.Config) serverOption {
+	return func(s *Server) error {
+		s.tlsConfig = config
+		return nil
+	}
+}
+
+// NewServer returns a new instance of Server.
+func NewServer(opts ...serverOption) (*Server, error) {
+	s := &Server{
+		logger: logger.NopLogger,
+		conns:  make(map[net.Conn]struct{}),
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, errors.Wrap(err, "applying option")
+		}
+	}
+
+	if s.api == nil {
+		return nil, errors.New("must pass OptServerAPI")
+	} else if s.ln == nil {
+		return nil, errors.New("must pass OptServerListener")
+	}
+	return s, nil
+}
+
+// Addr returns the address of the listener.
+func (s *Server) Addr() net.Addr { return s.ln.Addr() }
+
+// Serve accepts connections until the server is closed.
+func (s *Server) Serve() error {
+	for {
+		nc, err := s.ln.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return nil
+			}
+			return errors.Wrap(err, "accepting connection")
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			nc.Close()
+			return nil
+		}
+		s.conns[nc] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go func() {
+			defer s.wg.Done()
+			defer func() {
+				s.mu.Lock()
+				delete(s.conns, nc)
+				s.mu.Unlock()
+				nc.Close()
+			}()
+
+			c := newConn(nc, s.api, s.tlsConfig)
+			if err := c.serve(s.ctx); err != nil && !s.isClosed() {
+				s.logger.Printf("postgres connection from %s: %v", nc.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+// Close stops accepting connections and closes open ones.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	err := s.ln.Close()
+	for nc := range s.conns {
+		nc.Close()
+	}
+	s.mu.Unlock()
+
+	s.cancel()
+	s.wg.Wait()
+	return errors.Wrap(err, "closing listener")
+}
+
+func (s *Server) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
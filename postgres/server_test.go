@@ -0,0 +1,340 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres_test
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pilosa/pilosa/v2"
+	"github.com/pilosa/pilosa/v2/postgres"
+	"github.com/pilosa/pilosa/v2/test"
+)
+
+func TestServer(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	c.CreateField(t, "i", pilosa.IndexOptions{TrackExistence: true}, "f")
+	c.CreateField(t, "i", pilosa.IndexOptions{TrackExistence: true}, "v", pilosa.OptFieldTypeInt(0, 100))
+	c.Query(t, "i", "Set(1, f=10) Set(2, f=10) Set(3, f=20) Set(1, v=5) Set(2, v=7)")
+
+	ln, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := postgres.NewServer(postgres.OptServerAPI(c[0].API), postgres.OptServerListener(ln))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		if err := s.Serve(); err != nil {
+			t.Error(err)
+		}
+	}()
+	defer s.Close()
+
+	client := mustConnect(t, s.Addr().String(), "i")
+	defer client.close()
+
+	t.Run("SQL", func(t *testing.T) {
+		results, err := client.query(t, "SELECT f, COUNT(*) FROM i WHERE v > 0 GROUP BY f;")
+		if err != nil {
+			t.Fatal(err)
+		}
+		exp := []result{{
+			columns: []string{"f", "count(*)"},
+			rows:    [][]string{{"10", "2"}},
+			tag:     "SELECT 1",
+		}}
+		if !reflect.DeepEqual(results, exp) {
+			t.Fatalf("unexpected results: %+v", results)
+		}
+	})
+
+	t.Run("SQLNull", func(t *testing.T) {
+		results, err := client.query(t, "SELECT SUM(v) FROM i WHERE f = 20")
+		if err != nil {
+			t.Fatal(err)
+		} else if rows := results[0].rows; len(rows) != 1 || rows[0][0] != "<NULL>" {
+			t.Fatalf("unexpected rows: %v", rows)
+		}
+	})
+
+	t.Run("PQL", func(t *testing.T) {
+		results, err := client.query(t, "Row(f=10) Count(Row(f=20))")
+		if err != nil {
+			t.Fatal(err)
+		}
+		exp := []result{
+			{columns: []string{"_id"}, rows: [][]string{{"1"}, {"2"}}, tag: "SELECT 2"},
+			{columns: []string{"count"}, rows: [][]string{{"1"}}, tag: "SELECT 1"},
+		}
+		if !reflect.DeepEqual(results, exp) {
+			t.Fatalf("unexpected results: %+v", results)
+		}
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		if _, err := client.query(t, "SELECT f FROM i ORDER BY f"); err == nil || !strings.Contains(err.Error(), "ORDER BY is not supported") {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := client.query(t, "SELECT COUNT(*) FROM nope"); err == nil || !strings.Contains(err.Error(), "42P01") {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// The connection remains usable after an error.
+		if _, err := client.query(t, "SELECT COUNT(*) FROM i"); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		if results, err := client.query(t, " ; "); err != nil {
+			t.Fatal(err)
+		} else if len(results) != 0 {
+			t.Fatalf("unexpected results: %+v", results)
+		}
+	})
+}
+
+func TestServer_TLS(t *testing.T) {
+	c := test.MustRunCluster(t, 1)
+	defer c.Close()
+	c.CreateField(t, "i", pilosa.IndexOptions{TrackExistence: true}, "f")
+	c.Query(t, "i", "Set(1, f=10)")
+
+	cert, err := tls.LoadX509KeyPair("../server/testdata/certs/localhost.crt", "../server/testdata/certs/localhost.key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := postgres.NewServer(
+		postgres.OptServerAPI(c[0].API),
+		postgres.OptServerListener(ln),
+		postgres.OptServerTLSConfig(&tls.Config{Certificates: []tls.Certificate{cert}}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		if err := s.Serve(); err != nil {
+			t.Error(err)
+		}
+	}()
+	defer s.Close()
+
+	t.Run("Encrypted", func(t *testing.T) {
+		// The test certificate has no subject alternative names, so it
+		// can't be verified.
+		client := mustConnectTLS(t, s.Addr().String(), "i", &tls.Config{InsecureSkipVerify: true})
+		defer client.close()
+
+		results, err := client.query(t, "SELECT COUNT(*) FROM i")
+		if err != nil {
+			t.Fatal(err)
+		} else if rows := results[0].rows; !reflect.DeepEqual(rows, [][]string{{"1"}}) {
+			t.Fatalf("unexpected rows: %v", rows)
+		}
+	})
+
+	// Clients which don't request SSL are refused.
+	t.Run("Unencrypted", func(t *testing.T) {
+		client := mustDial(t, s.Addr().String())
+		defer client.conn.Close()
+		if err := client.startup(t, "i"); err == nil || !strings.Contains(err.Error(), "28000") {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+}
+
+// testClient is a minimal PostgreSQL wire protocol client.
+type testClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+type result struct {
+	columns []string
+	rows    [][]string
+	tag     string
+}
+
+type pgError string
+
+func (e pgError) Error() string { return string(e) }
+
+func mustConnect(t *testing.T, addr, database string) *testClient {
+	t.Helper()
+	return mustConnectTLS(t, addr, database, nil)
+}
+
+// mustConnectTLS connects and requests SSL, which the server should accept
+// if config is set and decline otherwise.
+func mustConnectTLS(t *testing.T, addr, database string, config *tls.Config) *testClient {
+	t.Helper()
+	c := mustDial(t, addr)
+
+	c.write(t, 0, []byte{0x04, 0xd2, 0x16, 0x2f})
+	b, err := c.r.ReadByte()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config == nil {
+		if b != 'N' {
+			t.Fatalf("unexpected ssl response: %q", b)
+		}
+	} else {
+		if b != 'S' {
+			t.Fatalf("unexpected ssl response: %q", b)
+		}
+		tc := tls.Client(c.conn, config)
+		if err := tc.Handshake(); err != nil {
+			t.Fatal(err)
+		}
+		c.conn, c.r = tc, bufio.NewReader(tc)
+	}
+
+	if err := c.startup(t, database); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func mustDial(t *testing.T, addr string) *testClient {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testClient{conn: conn, r: bufio.NewReader(conn)}
+}
+
+// startup sends the startup message and returns the error reported by the
+// server, if any.
+func (c *testClient) startup(t *testing.T, database string) error {
+	t.Helper()
+	var body bytes.Buffer
+	body.Write([]byte{0, 3, 0, 0})
+	body.WriteString("user\x00test\x00database\x00" + database + "\x00\x00")
+	c.write(t, 0, body.Bytes())
+
+	for {
+		typ, body := c.read(t)
+		switch typ {
+		case 'Z':
+			return nil
+		case 'E':
+			return pgError(strings.Replace(string(body), "\x00", " ", -1))
+		case 'R', 'S', 'K':
+		default:
+			t.Fatalf("unexpected startup message: %q", typ)
+		}
+	}
+}
+
+// query sends a simple query and returns its results, or the error
+// reported by the server.
+func (c *testClient) query(t *testing.T, q string) ([]result, error) {
+	t.Helper()
+	c.write(t, 'Q', append([]byte(q), 0))
+
+	var results []result
+	var cur result
+	var err error
+	for {
+		typ, body := c.read(t)
+		switch typ {
+		case 'T':
+			n := int(binary.BigEndian.Uint16(body))
+			body = body[2:]
+			cur = result{columns: []string{}, rows: [][]string{}}
+			for i := 0; i < n; i++ {
+				j := bytes.IndexByte(body, 0)
+				cur.columns = append(cur.columns, string(body[:j]))
+				body = body[j+1+18:]
+			}
+		case 'D':
+			n := int(binary.BigEndian.Uint16(body))
+			body = body[2:]
+			row := make([]string, n)
+			for i := range row {
+				l := int32(binary.BigEndian.Uint32(body))
+				body = body[4:]
+				if l < 0 {
+					row[i] = "<NULL>"
+					continue
+				}
+				row[i], body = string(body[:l]), body[l:]
+			}
+			cur.rows = append(cur.rows, row)
+		case 'C':
+			cur.tag = string(bytes.TrimRight(body, "\x00"))
+			results = append(results, cur)
+		case 'I':
+		case 'E':
+			err = pgError(strings.Replace(string(body), "\x00", " ", -1))
+		case 'Z':
+			return results, err
+		default:
+			t.Fatalf("unexpected message: %q", typ)
+		}
+	}
+}
+
+func (c *testClient) close() {
+	c.conn.Write([]byte{'X', 0, 0, 0, 4})
+	c.conn.Close()
+}
+
+func (c *testClient) write(t *testing.T, typ byte, body []byte) {
+	t.Helper()
+	var buf bytes.Buffer
+	if typ != 0 {
+		buf.WriteByte(typ)
+	}
+	binary.Write(&buf, binary.BigEndian, int32(len(body)+4))
+	buf.Write(body)
+	if _, err := c.conn.Write(buf.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (c *testClient) read(t *testing.T) (byte, []byte) {
+	t.Helper()
+	typ, err := c.r.ReadByte()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int32
+	if err := binary.Read(c.r, binary.BigEndian, &n); err != nil {
+		t.Fatal(err)
+	}
+	body := make([]byte, n-4)
+	if _, err := io.ReadFull(c.r, body); err != nil {
+		t.Fatal(err)
+	}
+	return typ, body
+}
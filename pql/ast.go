@@ -516,6 +516,8 @@ func (cond *Condition) IntSliceValue() ([]int64, error) {
 
 func formatValue(v interface{}) string {
 	switch v := v.(type) {
+	case nil:
+		return "null"
 	case string:
 		return fmt.Sprintf("%q", v)
 	case []interface{}:
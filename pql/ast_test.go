@@ -41,6 +41,12 @@ func TestCall_String(t *testing.T) {
 			t.Fatalf("unexpected string: %s", s)
 		}
 	})
+	t.Run("Null Arg", func(t *testing.T) {
+		c := &pql.Call{Name: "CompareAndSet", Args: map[string]interface{}{"expect": nil}}
+		if s := c.String(); s != `CompareAndSet(expect=null)` {
+			t.Fatalf("unexpected string: %s", s)
+		}
+	})
 }
 
 // Ensure condition can handle values for BETWEEN operator.
@@ -70,11 +70,11 @@ const (
 	ruleAction19
 	ruleAction20
 	ruleAction21
-	rulePegText
 	ruleAction22
 	ruleAction23
 	ruleAction24
 	ruleAction25
+	rulePegText
 	ruleAction26
 	ruleAction27
 	ruleAction28
@@ -107,6 +107,10 @@ const (
 	ruleAction55
 	ruleAction56
 	ruleAction57
+	ruleAction58
+	ruleAction59
+	ruleAction60
+	ruleAction61
 )
 
 var rul3s = [...]string{
@@ -165,11 +169,11 @@ var rul3s = [...]string{
 	"Action19",
 	"Action20",
 	"Action21",
-	"PegText",
 	"Action22",
 	"Action23",
 	"Action24",
 	"Action25",
+	"PegText",
 	"Action26",
 	"Action27",
 	"Action28",
@@ -202,6 +206,10 @@ var rul3s = [...]string{
 	"Action55",
 	"Action56",
 	"Action57",
+	"Action58",
+	"Action59",
+	"Action60",
+	"Action61",
 }
 
 type token32 struct {
@@ -318,7 +326,7 @@ type PQL struct {
 
 	Buffer string
 	buffer []rune
-	rules  [92]func() bool
+	rules  [96]func() bool
 	parse  func(rule ...int) error
 	reset  func()
 	Pretty bool
@@ -455,77 +463,85 @@ func (p *PQL) Execute() {
 		case ruleAction21:
 			p.endCall()
 		case ruleAction22:
-			p.startCall(buffer[begin:end])
+			p.startCall("Add")
 		case ruleAction23:
 			p.endCall()
 		case ruleAction24:
-			p.addBTWN()
+			p.startCall("CompareAndSet")
 		case ruleAction25:
-			p.addLTE()
+			p.endCall()
 		case ruleAction26:
-			p.addGTE()
+			p.startCall(buffer[begin:end])
 		case ruleAction27:
-			p.addEQ()
+			p.endCall()
 		case ruleAction28:
-			p.addNEQ()
+			p.addBTWN()
 		case ruleAction29:
-			p.addLT()
+			p.addLTE()
 		case ruleAction30:
-			p.addGT()
+			p.addGTE()
 		case ruleAction31:
-			p.startConditional()
+			p.addEQ()
 		case ruleAction32:
-			p.endConditional()
+			p.addNEQ()
 		case ruleAction33:
-			p.condAdd(buffer[begin:end])
+			p.addLT()
 		case ruleAction34:
-			p.condAdd(buffer[begin:end])
+			p.addGT()
 		case ruleAction35:
-			p.condAdd(buffer[begin:end])
+			p.startConditional()
 		case ruleAction36:
-			p.startList()
+			p.endConditional()
 		case ruleAction37:
-			p.endList()
+			p.condAdd(buffer[begin:end])
 		case ruleAction38:
-			p.addVal(nil)
+			p.condAdd(buffer[begin:end])
 		case ruleAction39:
-			p.addVal(true)
+			p.condAdd(buffer[begin:end])
 		case ruleAction40:
-			p.addVal(false)
+			p.startList()
 		case ruleAction41:
-			p.addVal(buffer[begin:end])
+			p.endList()
 		case ruleAction42:
-			p.addNumVal(buffer[begin:end])
+			p.addVal(nil)
 		case ruleAction43:
-			p.addNumVal(buffer[begin:end])
+			p.addVal(true)
 		case ruleAction44:
-			p.startCall(buffer[begin:end])
+			p.addVal(false)
 		case ruleAction45:
-			p.addVal(p.endCall())
-		case ruleAction46:
 			p.addVal(buffer[begin:end])
+		case ruleAction46:
+			p.addNumVal(buffer[begin:end])
 		case ruleAction47:
+			p.addNumVal(buffer[begin:end])
+		case ruleAction48:
+			p.startCall(buffer[begin:end])
+		case ruleAction49:
+			p.addVal(p.endCall())
+		case ruleAction50:
+			p.addVal(buffer[begin:end])
+		case ruleAction51:
 			s, _ := strconv.Unquote(buffer[begin:end])
 			p.addVal(s)
-		case ruleAction48:
+		case ruleAction52:
 			p.addVal(buffer[begin:end])
-		case ruleAction49:
+		case ruleAction53:
 			p.addField(buffer[begin:end])
-		case ruleAction50:
+		case ruleAction54:
 			p.addPosStr("_field", buffer[begin:end])
-		case ruleAction51:
+		case ruleAction55:
 			p.addPosNum("_col", buffer[begin:end])
-		case ruleAction52:
+		case ruleAction56:
 			p.addPosStr("_col", buffer[begin:end])
-		case ruleAction53:
+		case ruleAction57:
 			p.addPosStr("_col", buffer[begin:end])
-		case ruleAction54:
+		case ruleAction58:
 			p.addPosNum("_row", buffer[begin:end])
-		case ruleAction55:
+		case ruleAction59:
 			p.addPosStr("_row", buffer[begin:end])
-		case ruleAction56:
+		case ruleAction60:
 			p.addPosStr("_row", buffer[begin:end])
-		case ruleAction57:
+		case ruleAction61:
 			p.addPosStr("_timestamp", buffer[begin:end])
 
 		}
@@ -638,7 +654,7 @@ func (p *PQL) Init() {
 			position, tokenIndex = position0, tokenIndex0
 			return false
 		},
-		/* 1 Call <- <(('S' 'e' 't' Action0 open col comma args (comma timestamp)? close Action1) / ('S' 'e' 't' 'R' 'o' 'w' 'A' 't' 't' 'r' 's' Action2 open posfield comma row comma args close Action3) / ('S' 'e' 't' 'C' 'o' 'l' 'u' 'm' 'n' 'A' 't' 't' 'r' 's' Action4 open col comma args close Action5) / ('C' 'l' 'e' 'a' 'r' Action6 open col comma args close Action7) / ('C' 'l' 'e' 'a' 'r' 'R' 'o' 'w' Action8 open arg close Action9) / ('S' 't' 'o' 'r' 'e' Action10 open Call comma arg close Action11) / ('T' 'o' 'p' 'N' Action12 open posfield (comma allargs)? close Action13) / ('R' 'o' 'w' 's' Action14 open posfield (comma allargs)? close Action15) / ('R' 'a' 'n' 'g' 'e' Action16 open field sp '=' sp value comma ('f' 'r' 'o' 'm' '=')? Action17 timestampfmt Action18 comma ('t' 'o' '=')? sp Action19 timestampfmt Action20 close Action21) / (<IDENT> Action22 open allargs comma? close Action23))> */
+		/* 1 Call <- <(('S' 'e' 't' Action0 open col comma args (comma timestamp)? close Action1) / ('S' 'e' 't' 'R' 'o' 'w' 'A' 't' 't' 'r' 's' Action2 open posfield comma row comma args close Action3) / ('S' 'e' 't' 'C' 'o' 'l' 'u' 'm' 'n' 'A' 't' 't' 'r' 's' Action4 open col comma args close Action5) / ('C' 'l' 'e' 'a' 'r' Action6 open col comma args close Action7) / ('C' 'l' 'e' 'a' 'r' 'R' 'o' 'w' Action8 open arg close Action9) / ('S' 't' 'o' 'r' 'e' Action10 open Call comma arg close Action11) / ('T' 'o' 'p' 'N' Action12 open posfield (comma allargs)? close Action13) / ('R' 'o' 'w' 's' Action14 open posfield (comma allargs)? close Action15) / ('R' 'a' 'n' 'g' 'e' Action16 open field sp '=' sp value comma ('f' 'r' 'o' 'm' '=')? Action17 timestampfmt Action18 comma ('t' 'o' '=')? sp Action19 timestampfmt Action20 close Action21) / ('A' 'd' 'd' Action22 open col comma args close Action23) / ('C' 'o' 'm' 'p' 'a' 'r' 'e' 'A' 'n' 'd' 'S' 'e' 't' Action24 open col comma args close Action25) / (<IDENT> Action26 open allargs comma? close Action27))> */
 		func() bool {
 			position5, tokenIndex5 := position, tokenIndex
 			{
@@ -687,7 +703,7 @@ func (p *PQL) Init() {
 								add(rulePegText, position13)
 							}
 							{
-								add(ruleAction57, position)
+								add(ruleAction61, position)
 							}
 							add(ruletimestamp, position12)
 						}
@@ -773,7 +789,7 @@ func (p *PQL) Init() {
 								add(rulePegText, position21)
 							}
 							{
-								add(ruleAction54, position)
+								add(ruleAction58, position)
 							}
 							goto l19
 						l20:
@@ -794,7 +810,7 @@ func (p *PQL) Init() {
 							}
 							position++
 							{
-								add(ruleAction55, position)
+								add(ruleAction59, position)
 							}
 							goto l19
 						l23:
@@ -815,7 +831,7 @@ func (p *PQL) Init() {
 							}
 							position++
 							{
-								add(ruleAction56, position)
+								add(ruleAction60, position)
 							}
 						}
 					l19:
@@ -1271,6 +1287,118 @@ func (p *PQL) Init() {
 					}
 					goto l7
 				l51:
+					position, tokenIndex = position7, tokenIndex7
+					if buffer[position] != rune('A') {
+						goto l300
+					}
+					position++
+					if buffer[position] != rune('d') {
+						goto l300
+					}
+					position++
+					if buffer[position] != rune('d') {
+						goto l300
+					}
+					position++
+					{
+						add(ruleAction22, position)
+					}
+					if !_rules[ruleopen]() {
+						goto l300
+					}
+					if !_rules[rulecol]() {
+						goto l300
+					}
+					if !_rules[rulecomma]() {
+						goto l300
+					}
+					if !_rules[ruleargs]() {
+						goto l300
+					}
+					if !_rules[ruleclose]() {
+						goto l300
+					}
+					{
+						add(ruleAction23, position)
+					}
+					goto l7
+				l300:
+					position, tokenIndex = position7, tokenIndex7
+					if buffer[position] != rune('C') {
+						goto l301
+					}
+					position++
+					if buffer[position] != rune('o') {
+						goto l301
+					}
+					position++
+					if buffer[position] != rune('m') {
+						goto l301
+					}
+					position++
+					if buffer[position] != rune('p') {
+						goto l301
+					}
+					position++
+					if buffer[position] != rune('a') {
+						goto l301
+					}
+					position++
+					if buffer[position] != rune('r') {
+						goto l301
+					}
+					position++
+					if buffer[position] != rune('e') {
+						goto l301
+					}
+					position++
+					if buffer[position] != rune('A') {
+						goto l301
+					}
+					position++
+					if buffer[position] != rune('n') {
+						goto l301
+					}
+					position++
+					if buffer[position] != rune('d') {
+						goto l301
+					}
+					position++
+					if buffer[position] != rune('S') {
+						goto l301
+					}
+					position++
+					if buffer[position] != rune('e') {
+						goto l301
+					}
+					position++
+					if buffer[position] != rune('t') {
+						goto l301
+					}
+					position++
+					{
+						add(ruleAction24, position)
+					}
+					if !_rules[ruleopen]() {
+						goto l301
+					}
+					if !_rules[rulecol]() {
+						goto l301
+					}
+					if !_rules[rulecomma]() {
+						goto l301
+					}
+					if !_rules[ruleargs]() {
+						goto l301
+					}
+					if !_rules[ruleclose]() {
+						goto l301
+					}
+					{
+						add(ruleAction25, position)
+					}
+					goto l7
+				l301:
 					position, tokenIndex = position7, tokenIndex7
 					{
 						position62 := position
@@ -1280,7 +1408,7 @@ func (p *PQL) Init() {
 						add(rulePegText, position62)
 					}
 					{
-						add(ruleAction22, position)
+						add(ruleAction26, position)
 					}
 					if !_rules[ruleopen]() {
 						goto l5
@@ -1302,7 +1430,7 @@ func (p *PQL) Init() {
 						goto l5
 					}
 					{
-						add(ruleAction23, position)
+						add(ruleAction27, position)
 					}
 				}
 			l7:
@@ -1446,7 +1574,7 @@ func (p *PQL) Init() {
 							}
 							position++
 							{
-								add(ruleAction24, position)
+								add(ruleAction28, position)
 							}
 							goto l86
 						l87:
@@ -1460,7 +1588,7 @@ func (p *PQL) Init() {
 							}
 							position++
 							{
-								add(ruleAction25, position)
+								add(ruleAction29, position)
 							}
 							goto l86
 						l89:
@@ -1474,7 +1602,7 @@ func (p *PQL) Init() {
 							}
 							position++
 							{
-								add(ruleAction26, position)
+								add(ruleAction30, position)
 							}
 							goto l86
 						l91:
@@ -1488,7 +1616,7 @@ func (p *PQL) Init() {
 							}
 							position++
 							{
-								add(ruleAction27, position)
+								add(ruleAction31, position)
 							}
 							goto l86
 						l93:
@@ -1502,7 +1630,7 @@ func (p *PQL) Init() {
 							}
 							position++
 							{
-								add(ruleAction28, position)
+								add(ruleAction32, position)
 							}
 							goto l86
 						l95:
@@ -1512,7 +1640,7 @@ func (p *PQL) Init() {
 							}
 							position++
 							{
-								add(ruleAction29, position)
+								add(ruleAction33, position)
 							}
 							goto l86
 						l97:
@@ -1522,7 +1650,7 @@ func (p *PQL) Init() {
 							}
 							position++
 							{
-								add(ruleAction30, position)
+								add(ruleAction34, position)
 							}
 						}
 					l86:
@@ -1540,7 +1668,7 @@ func (p *PQL) Init() {
 					{
 						position100 := position
 						{
-							add(ruleAction31, position)
+							add(ruleAction35, position)
 						}
 						if !_rules[rulecondint]() {
 							goto l80
@@ -1561,7 +1689,7 @@ func (p *PQL) Init() {
 								goto l80
 							}
 							{
-								add(ruleAction35, position)
+								add(ruleAction39, position)
 							}
 							add(rulecondfield, position102)
 						}
@@ -1572,7 +1700,7 @@ func (p *PQL) Init() {
 							goto l80
 						}
 						{
-							add(ruleAction32, position)
+							add(ruleAction36, position)
 						}
 						add(ruleconditional, position100)
 					}
@@ -1585,11 +1713,11 @@ func (p *PQL) Init() {
 			position, tokenIndex = position80, tokenIndex80
 			return false
 		},
-		/* 5 COND <- <(('>' '<' Action24) / ('<' '=' Action25) / ('>' '=' Action26) / ('=' '=' Action27) / ('!' '=' Action28) / ('<' Action29) / ('>' Action30))> */
+		/* 5 COND <- <(('>' '<' Action28) / ('<' '=' Action29) / ('>' '=' Action30) / ('=' '=' Action31) / ('!' '=' Action32) / ('<' Action33) / ('>' Action34))> */
 		nil,
-		/* 6 conditional <- <(Action31 condint condLT condfield condLT condint Action32)> */
+		/* 6 conditional <- <(Action35 condint condLT condfield condLT condint Action36)> */
 		nil,
-		/* 7 condint <- <(<(('-'? [1-9] [0-9]*) / '0')> sp Action33)> */
+		/* 7 condint <- <(<(('-'? [1-9] [0-9]*) / '0')> sp Action37)> */
 		func() bool {
 			position108, tokenIndex108 := position, tokenIndex
 			{
@@ -1639,7 +1767,7 @@ func (p *PQL) Init() {
 					goto l108
 				}
 				{
-					add(ruleAction33, position)
+					add(ruleAction37, position)
 				}
 				add(rulecondint, position109)
 			}
@@ -1648,7 +1776,7 @@ func (p *PQL) Init() {
 			position, tokenIndex = position108, tokenIndex108
 			return false
 		},
-		/* 8 condLT <- <(<(('<' '=') / '<')> sp Action34)> */
+		/* 8 condLT <- <(<(('<' '=') / '<')> sp Action38)> */
 		func() bool {
 			position118, tokenIndex118 := position, tokenIndex
 			{
@@ -1680,7 +1808,7 @@ func (p *PQL) Init() {
 					goto l118
 				}
 				{
-					add(ruleAction34, position)
+					add(ruleAction38, position)
 				}
 				add(rulecondLT, position119)
 			}
@@ -1689,9 +1817,9 @@ func (p *PQL) Init() {
 			position, tokenIndex = position118, tokenIndex118
 			return false
 		},
-		/* 9 condfield <- <(<fieldExpr> sp Action35)> */
+		/* 9 condfield <- <(<fieldExpr> sp Action39)> */
 		nil,
-		/* 10 value <- <(item / (lbrack Action36 list rbrack Action37))> */
+		/* 10 value <- <(item / (lbrack Action40 list rbrack Action41))> */
 		func() bool {
 			position125, tokenIndex125 := position, tokenIndex
 			{
@@ -1716,7 +1844,7 @@ func (p *PQL) Init() {
 						add(rulelbrack, position129)
 					}
 					{
-						add(ruleAction36, position)
+						add(ruleAction40, position)
 					}
 					if !_rules[rulelist]() {
 						goto l125
@@ -1736,7 +1864,7 @@ func (p *PQL) Init() {
 						add(rulerbrack, position131)
 					}
 					{
-						add(ruleAction37, position)
+						add(ruleAction41, position)
 					}
 				}
 			l127:
@@ -1775,7 +1903,7 @@ func (p *PQL) Init() {
 			position, tokenIndex = position133, tokenIndex133
 			return false
 		},
-		/* 12 item <- <(('n' 'u' 'l' 'l' &(comma / (sp close)) Action38) / ('t' 'r' 'u' 'e' &(comma / (sp close)) Action39) / ('f' 'a' 'l' 's' 'e' &(comma / (sp close)) Action40) / (timestampfmt Action41) / (<('-'? [0-9]+ ('.' [0-9]*)?)> Action42) / (<('-'? '.' [0-9]+)> Action43) / (<IDENT> Action44 open allargs comma? close Action45) / (<([a-z] / [A-Z] / [0-9] / '-' / '_' / ':')+> Action46) / (<('"' doublequotedstring '"')> Action47) / ('\'' <singlequotedstring> '\'' Action48))> */
+		/* 12 item <- <(('n' 'u' 'l' 'l' &(comma / (sp close)) Action42) / ('t' 'r' 'u' 'e' &(comma / (sp close)) Action43) / ('f' 'a' 'l' 's' 'e' &(comma / (sp close)) Action44) / (timestampfmt Action45) / (<('-'? [0-9]+ ('.' [0-9]*)?)> Action46) / (<('-'? '.' [0-9]+)> Action47) / (<IDENT> Action48 open allargs comma? close Action49) / (<([a-z] / [A-Z] / [0-9] / '-' / '_' / ':')+> Action50) / (<('"' doublequotedstring '"')> Action51) / ('\'' <singlequotedstring> '\'' Action52))> */
 		func() bool {
 			position137, tokenIndex137 := position, tokenIndex
 			{
@@ -1819,7 +1947,7 @@ func (p *PQL) Init() {
 						position, tokenIndex = position141, tokenIndex141
 					}
 					{
-						add(ruleAction38, position)
+						add(ruleAction42, position)
 					}
 					goto l139
 				l140:
@@ -1861,7 +1989,7 @@ func (p *PQL) Init() {
 						position, tokenIndex = position146, tokenIndex146
 					}
 					{
-						add(ruleAction39, position)
+						add(ruleAction43, position)
 					}
 					goto l139
 				l145:
@@ -1907,7 +2035,7 @@ func (p *PQL) Init() {
 						position, tokenIndex = position151, tokenIndex151
 					}
 					{
-						add(ruleAction40, position)
+						add(ruleAction44, position)
 					}
 					goto l139
 				l150:
@@ -1916,7 +2044,7 @@ func (p *PQL) Init() {
 						goto l155
 					}
 					{
-						add(ruleAction41, position)
+						add(ruleAction45, position)
 					}
 					goto l139
 				l155:
@@ -1974,7 +2102,7 @@ func (p *PQL) Init() {
 						add(rulePegText, position158)
 					}
 					{
-						add(ruleAction42, position)
+						add(ruleAction46, position)
 					}
 					goto l139
 				l157:
@@ -2014,7 +2142,7 @@ func (p *PQL) Init() {
 						add(rulePegText, position169)
 					}
 					{
-						add(ruleAction43, position)
+						add(ruleAction47, position)
 					}
 					goto l139
 				l168:
@@ -2027,7 +2155,7 @@ func (p *PQL) Init() {
 						add(rulePegText, position176)
 					}
 					{
-						add(ruleAction44, position)
+						add(ruleAction48, position)
 					}
 					if !_rules[ruleopen]() {
 						goto l175
@@ -2049,7 +2177,7 @@ func (p *PQL) Init() {
 						goto l175
 					}
 					{
-						add(ruleAction45, position)
+						add(ruleAction49, position)
 					}
 					goto l139
 				l175:
@@ -2152,7 +2280,7 @@ func (p *PQL) Init() {
 						add(rulePegText, position182)
 					}
 					{
-						add(ruleAction46, position)
+						add(ruleAction50, position)
 					}
 					goto l139
 				l181:
@@ -2173,7 +2301,7 @@ func (p *PQL) Init() {
 						add(rulePegText, position199)
 					}
 					{
-						add(ruleAction47, position)
+						add(ruleAction51, position)
 					}
 					goto l139
 				l198:
@@ -2194,7 +2322,7 @@ func (p *PQL) Init() {
 					}
 					position++
 					{
-						add(ruleAction48, position)
+						add(ruleAction52, position)
 					}
 				}
 			l139:
@@ -2383,7 +2511,7 @@ func (p *PQL) Init() {
 			position, tokenIndex = position219, tokenIndex219
 			return false
 		},
-		/* 16 field <- <(<(fieldExpr / reserved)> Action49)> */
+		/* 16 field <- <(<(fieldExpr / reserved)> Action53)> */
 		func() bool {
 			position230, tokenIndex230 := position, tokenIndex
 			{
@@ -2562,7 +2690,7 @@ func (p *PQL) Init() {
 					add(rulePegText, position232)
 				}
 				{
-					add(ruleAction49, position)
+					add(ruleAction53, position)
 				}
 				add(rulefield, position231)
 			}
@@ -2573,7 +2701,7 @@ func (p *PQL) Init() {
 		},
 		/* 17 reserved <- <(('_' 'r' 'o' 'w') / ('_' 'c' 'o' 'l') / ('_' 's' 't' 'a' 'r' 't') / ('_' 'e' 'n' 'd') / ('_' 't' 'i' 'm' 'e' 's' 't' 'a' 'm' 'p') / ('_' 'f' 'i' 'e' 'l' 'd'))> */
 		nil,
-		/* 18 posfield <- <(<fieldExpr> Action50)> */
+		/* 18 posfield <- <(<fieldExpr> Action54)> */
 		func() bool {
 			position244, tokenIndex244 := position, tokenIndex
 			{
@@ -2586,7 +2714,7 @@ func (p *PQL) Init() {
 					add(rulePegText, position246)
 				}
 				{
-					add(ruleAction50, position)
+					add(ruleAction54, position)
 				}
 				add(ruleposfield, position245)
 			}
@@ -2633,7 +2761,7 @@ func (p *PQL) Init() {
 			position, tokenIndex = position248, tokenIndex248
 			return false
 		},
-		/* 20 col <- <((<uint> Action51) / ('\'' <singlequotedstring> '\'' Action52) / ('"' <doublequotedstring> '"' Action53))> */
+		/* 20 col <- <((<uint> Action55) / ('\'' <singlequotedstring> '\'' Action56) / ('"' <doublequotedstring> '"' Action57))> */
 		func() bool {
 			position254, tokenIndex254 := position, tokenIndex
 			{
@@ -2648,7 +2776,7 @@ func (p *PQL) Init() {
 						add(rulePegText, position258)
 					}
 					{
-						add(ruleAction51, position)
+						add(ruleAction55, position)
 					}
 					goto l256
 				l257:
@@ -2669,7 +2797,7 @@ func (p *PQL) Init() {
 					}
 					position++
 					{
-						add(ruleAction52, position)
+						add(ruleAction56, position)
 					}
 					goto l256
 				l260:
@@ -2690,7 +2818,7 @@ func (p *PQL) Init() {
 					}
 					position++
 					{
-						add(ruleAction53, position)
+						add(ruleAction57, position)
 					}
 				}
 			l256:
@@ -2701,7 +2829,7 @@ func (p *PQL) Init() {
 			position, tokenIndex = position254, tokenIndex254
 			return false
 		},
-		/* 21 row <- <((<uint> Action54) / ('\'' <singlequotedstring> '\'' Action55) / ('"' <doublequotedstring> '"' Action56))> */
+		/* 21 row <- <((<uint> Action58) / ('\'' <singlequotedstring> '\'' Action59) / ('"' <doublequotedstring> '"' Action60))> */
 		nil,
 		/* 22 open <- <('(' sp)> */
 		func() bool {
@@ -3006,7 +3134,7 @@ func (p *PQL) Init() {
 			position, tokenIndex = position294, tokenIndex294
 			return false
 		},
-		/* 31 timestamp <- <(<timestampfmt> Action57)> */
+		/* 31 timestamp <- <(<timestampfmt> Action61)> */
 		nil,
 		/* 33 Action0 <- <{p.startCall("Set")}> */
 		nil,
@@ -3052,78 +3180,86 @@ func (p *PQL) Init() {
 		nil,
 		/* 54 Action21 <- <{p.endCall()}> */
 		nil,
+		/* 55 Action22 <- <{p.startCall("Add")}> */
+		nil,
+		/* 56 Action23 <- <{p.endCall()}> */
+		nil,
+		/* 57 Action24 <- <{p.startCall("CompareAndSet")}> */
+		nil,
+		/* 58 Action25 <- <{p.endCall()}> */
+		nil,
 		nil,
-		/* 56 Action22 <- <{ p.startCall(buffer[begin:end] ) }> */
+		/* 60 Action26 <- <{ p.startCall(buffer[begin:end] ) }> */
 		nil,
-		/* 57 Action23 <- <{ p.endCall() }> */
+		/* 61 Action27 <- <{ p.endCall() }> */
 		nil,
-		/* 58 Action24 <- <{ p.addBTWN() }> */
+		/* 62 Action28 <- <{ p.addBTWN() }> */
 		nil,
-		/* 59 Action25 <- <{ p.addLTE() }> */
+		/* 63 Action29 <- <{ p.addLTE() }> */
 		nil,
-		/* 60 Action26 <- <{ p.addGTE() }> */
+		/* 64 Action30 <- <{ p.addGTE() }> */
 		nil,
-		/* 61 Action27 <- <{ p.addEQ() }> */
+		/* 65 Action31 <- <{ p.addEQ() }> */
 		nil,
-		/* 62 Action28 <- <{ p.addNEQ() }> */
+		/* 66 Action32 <- <{ p.addNEQ() }> */
 		nil,
-		/* 63 Action29 <- <{ p.addLT() }> */
+		/* 67 Action33 <- <{ p.addLT() }> */
 		nil,
-		/* 64 Action30 <- <{ p.addGT() }> */
+		/* 68 Action34 <- <{ p.addGT() }> */
 		nil,
-		/* 65 Action31 <- <{p.startConditional()}> */
+		/* 69 Action35 <- <{p.startConditional()}> */
 		nil,
-		/* 66 Action32 <- <{p.endConditional()}> */
+		/* 70 Action36 <- <{p.endConditional()}> */
 		nil,
-		/* 67 Action33 <- <{p.condAdd(buffer[begin:end])}> */
+		/* 71 Action37 <- <{p.condAdd(buffer[begin:end])}> */
 		nil,
-		/* 68 Action34 <- <{p.condAdd(buffer[begin:end])}> */
+		/* 72 Action38 <- <{p.condAdd(buffer[begin:end])}> */
 		nil,
-		/* 69 Action35 <- <{p.condAdd(buffer[begin:end])}> */
+		/* 73 Action39 <- <{p.condAdd(buffer[begin:end])}> */
 		nil,
-		/* 70 Action36 <- <{ p.startList() }> */
+		/* 74 Action40 <- <{ p.startList() }> */
 		nil,
-		/* 71 Action37 <- <{ p.endList() }> */
+		/* 75 Action41 <- <{ p.endList() }> */
 		nil,
-		/* 72 Action38 <- <{ p.addVal(nil) }> */
+		/* 76 Action42 <- <{ p.addVal(nil) }> */
 		nil,
-		/* 73 Action39 <- <{ p.addVal(true) }> */
+		/* 77 Action43 <- <{ p.addVal(true) }> */
 		nil,
-		/* 74 Action40 <- <{ p.addVal(false) }> */
+		/* 78 Action44 <- <{ p.addVal(false) }> */
 		nil,
-		/* 75 Action41 <- <{ p.addVal(buffer[begin:end]) }> */
+		/* 79 Action45 <- <{ p.addVal(buffer[begin:end]) }> */
 		nil,
-		/* 76 Action42 <- <{ p.addNumVal(buffer[begin:end]) }> */
+		/* 80 Action46 <- <{ p.addNumVal(buffer[begin:end]) }> */
 		nil,
-		/* 77 Action43 <- <{ p.addNumVal(buffer[begin:end]) }> */
+		/* 81 Action47 <- <{ p.addNumVal(buffer[begin:end]) }> */
 		nil,
-		/* 78 Action44 <- <{ p.startCall(buffer[begin:end]) }> */
+		/* 82 Action48 <- <{ p.startCall(buffer[begin:end]) }> */
 		nil,
-		/* 79 Action45 <- <{ p.addVal(p.endCall()) }> */
+		/* 83 Action49 <- <{ p.addVal(p.endCall()) }> */
 		nil,
-		/* 80 Action46 <- <{ p.addVal(buffer[begin:end]) }> */
+		/* 84 Action50 <- <{ p.addVal(buffer[begin:end]) }> */
 		nil,
-		/* 81 Action47 <- <{ s, _ := strconv.Unquote(buffer[begin:end]); p.addVal(s) }> */
+		/* 85 Action51 <- <{ s, _ := strconv.Unquote(buffer[begin:end]); p.addVal(s) }> */
 		nil,
-		/* 82 Action48 <- <{ p.addVal(buffer[begin:end]) }> */
+		/* 86 Action52 <- <{ p.addVal(buffer[begin:end]) }> */
 		nil,
-		/* 83 Action49 <- <{ p.addField(buffer[begin:end]) }> */
+		/* 87 Action53 <- <{ p.addField(buffer[begin:end]) }> */
 		nil,
-		/* 84 Action50 <- <{ p.addPosStr("_field", buffer[begin:end]) }> */
+		/* 88 Action54 <- <{ p.addPosStr("_field", buffer[begin:end]) }> */
 		nil,
-		/* 85 Action51 <- <{p.addPosNum("_col", buffer[begin:end])}> */
+		/* 89 Action55 <- <{p.addPosNum("_col", buffer[begin:end])}> */
 		nil,
-		/* 86 Action52 <- <{p.addPosStr("_col", buffer[begin:end])}> */
+		/* 90 Action56 <- <{p.addPosStr("_col", buffer[begin:end])}> */
 		nil,
-		/* 87 Action53 <- <{p.addPosStr("_col", buffer[begin:end])}> */
+		/* 91 Action57 <- <{p.addPosStr("_col", buffer[begin:end])}> */
 		nil,
-		/* 88 Action54 <- <{p.addPosNum("_row", buffer[begin:end])}> */
+		/* 92 Action58 <- <{p.addPosNum("_row", buffer[begin:end])}> */
 		nil,
-		/* 89 Action55 <- <{p.addPosStr("_row", buffer[begin:end])}> */
+		/* 93 Action59 <- <{p.addPosStr("_row", buffer[begin:end])}> */
 		nil,
-		/* 90 Action56 <- <{p.addPosStr("_row", buffer[begin:end])}> */
+		/* 94 Action60 <- <{p.addPosStr("_row", buffer[begin:end])}> */
 		nil,
-		/* 91 Action57 <- <{p.addPosStr("_timestamp", buffer[begin:end])}> */
+		/* 95 Action61 <- <{p.addPosStr("_timestamp", buffer[begin:end])}> */
 		nil,
 	}
 	p.rules = _rules
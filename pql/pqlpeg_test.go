@@ -207,6 +207,22 @@ func TestPEGWorking(t *testing.T) {
 			name:   "Clear2args",
 			input:  "Clear(1, a=53, b=33)",
 			ncalls: 1},
+		{
+			name:   "Add",
+			input:  "Add(1, field=n, by=-3)",
+			ncalls: 1},
+		{
+			name:   "AddColKey",
+			input:  `Add("foo", field=n, by=5)`,
+			ncalls: 1},
+		{
+			name:   "AddNamedCol",
+			input:  "Add(col=1, field=n, by=5)",
+			ncalls: 1},
+		{
+			name:   "CompareAndSet",
+			input:  "CompareAndSet(1, field=n, expect=null, value=4)",
+			ncalls: 1},
 		{
 			name:   "TopN",
 			input:  "TopN(myfield, n=44)",
@@ -0,0 +1,133 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pilosa
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pilosa/pilosa/v2/logger"
+	"github.com/pilosa/pilosa/v2/roaring"
+	"github.com/pkg/errors"
+)
+
+// QuarantineDir is the directory, within a data directory, to which fragment
+// files that can't be read are moved. Like other hidden directories, it's
+// skipped when the holder is opened.
+const QuarantineDir = ".quarantine"
+
+// QuarantineFragment moves the data file of a fragment at path, and its cache
+// file, out of the data directory dir into its quarantine directory. They
+// keep their path within dir, under a directory named for the time, so that
+// later quarantines of the same fragment don't replace them. It returns the
+// path to which the data file was moved.
+func QuarantineFragment(dir, path string) (string, error) {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return "", errors.Wrap(err, "finding path within data directory")
+	}
+	dst := filepath.Join(dir, QuarantineDir, time.Now().UTC().Format("20060102T150405Z"), rel)
+	if err := os.MkdirAll(filepath.Dir(dst), 0777); err != nil {
+		return "", errors.Wrap(err, "creating quarantine directory")
+	}
+	if err := os.Rename(path, dst); err != nil {
+		return "", errors.Wrap(err, "moving data file")
+	}
+	if err := os.Rename(path+cacheExt, dst+cacheExt); err != nil && !os.IsNotExist(err) {
+		return "", errors.Wrap(err, "moving cache file")
+	}
+	return dst, nil
+}
+
+// QuarantinedFragment is a fragment which was quarantined when the holder
+// was opened, as its data file couldn't be read.
+type QuarantinedFragment struct {
+	Index string `json:"index"`
+	Field string `json:"field"`
+	View  string `json:"view"`
+	Shard uint64 `json:"shard"`
+
+	// Path is where the data file was moved, and Err why it couldn't be read.
+	Path string `json:"path"`
+	Err  string `json:"error"`
+}
+
+// fragmentQuarantine quarantines the fragments which can't be read as the
+// holder is opened.
+type fragmentQuarantine struct {
+	path   string
+	logger logger.Logger
+
+	mu        sync.Mutex
+	fragments []QuarantinedFragment
+}
+
+// add quarantines a fragment which failed to open with err.
+func (q *fragmentQuarantine) add(frag *fragment, err error) error {
+	dst, qerr := QuarantineFragment(q.path, frag.path)
+	if qerr != nil {
+		return errors.Wrapf(qerr, "quarantining fragment: shard=%d, err=%s", frag.shard, err)
+	}
+	q.logger.Printf("QUARANTINED fragment %s/%s/%s/%d, moved to %s: %s", frag.index, frag.field, frag.view, frag.shard, dst, err)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.fragments = append(q.fragments, QuarantinedFragment{
+		Index: frag.index,
+		Field: frag.field,
+		View:  frag.view,
+		Shard: frag.shard,
+		Path:  dst,
+		Err:   err.Error(),
+	})
+	return nil
+}
+
+// QuarantinedFragments returns the fragments quarantined when the holder was
+// last opened.
+func (h *Holder) QuarantinedFragments() []QuarantinedFragment {
+	if h.quarantine == nil {
+		return nil
+	}
+	h.quarantine.mu.Lock()
+	defer h.quarantine.mu.Unlock()
+	return append([]QuarantinedFragment(nil), h.quarantine.fragments...)
+}
+
+// markQuarantinedShards marks the shards of quarantined fragments as
+// available, though missing on this node, so that anti-entropy fetches their
+// data from the other owners of the shards.
+func (h *Holder) markQuarantinedShards() error {
+	if h.quarantine == nil {
+		return nil
+	}
+	fragments := h.QuarantinedFragments()
+	for _, qf := range fragments {
+		field := h.Field(qf.Index, qf.Field)
+		if field == nil {
+			continue
+		}
+		if err := field.AddRemoteAvailableShards(roaring.NewBitmap(qf.Shard)); err != nil {
+			return errors.Wrapf(err, "marking shard %d of %s/%s", qf.Shard, qf.Index, qf.Field)
+		}
+	}
+	if len(fragments) > 0 {
+		h.Logger.Printf("quarantined %d fragments, their data will be fetched from replicas by anti-entropy, or must be restored from a backup", len(fragments))
+	}
+	h.Stats.Gauge("quarantinedFragments", float64(len(fragments)), 1.0)
+	return nil
+}
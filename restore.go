@@ -0,0 +1,347 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pilosa
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pilosa/pilosa/v2/tracing"
+	"github.com/pkg/errors"
+)
+
+// Restore rebuilds the indexes of a backup, read from r, in this cluster,
+// which may be of a different size than the one backed up. It must be
+// called on the coordinator.
+//
+// The schema is applied to every node, keys are sent to the primary
+// translate node, from which the other nodes replicate them, attributes
+// are restored to every node, and each fragment is sent to the owners of
+// its shard. None of the backed up indexes may exist, unless the backup is
+// an incremental backup, which is replayed on top of its base backup once
+// that is restored.
+func (api *API) Restore(ctx context.Context, r io.Reader) error {
+	span, ctx := tracing.StartSpanFromContext(ctx, "API.Restore")
+	defer span.Finish()
+
+	if err := api.validate(apiRestore); err != nil {
+		return errors.Wrap(err, "validating api method")
+	}
+	if !api.cluster.isCoordinator() {
+		return ErrNodeNotCoordinator
+	}
+
+	var manifest *BackupManifest
+	var schema *Schema
+	var index, shard string
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return NewBadRequestError(errors.Wrap(err, "reading backup"))
+		}
+
+		// The manifest and the schema come first, so that indexes and fields
+		// exist before their contents are restored. Backups made before
+		// manifests were added start with the schema.
+		if manifest == nil && schema == nil && hdr.Name == backupManifestName {
+			manifest = &BackupManifest{}
+			if err := json.NewDecoder(tr).Decode(manifest); err != nil {
+				return NewBadRequestError(errors.Wrap(err, "decoding manifest"))
+			}
+			continue
+		}
+		if schema == nil {
+			if hdr.Name != backupSchemaName {
+				return NewBadRequestError(errors.Errorf("backup must start with %s", backupSchemaName))
+			}
+			incremental := manifest != nil && manifest.Base != ""
+			if schema, err = api.restoreSchema(ctx, tr, incremental); err != nil {
+				return err
+			}
+			continue
+		}
+
+		parts := strings.Split(hdr.Name, "/")
+		if parts[0] != index {
+			index, shard = parts[0], ""
+			api.server.logger.Printf("restoring index %s", index)
+		}
+		switch {
+		case len(parts) == 2 && parts[1] == backupKeysName:
+			err = api.restoreKeys(ctx, index, "", tr)
+		case len(parts) == 2 && parts[1] == backupAttrsName:
+			err = api.restoreAttrs(ctx, index, "", tr)
+		case len(parts) == 3 && parts[2] == backupKeysName:
+			err = api.restoreKeys(ctx, index, parts[1], tr)
+		case len(parts) == 3 && parts[2] == backupAttrsName:
+			err = api.restoreAttrs(ctx, index, parts[1], tr)
+		case len(parts) == 6 && parts[2] == "views" && parts[4] == "fragments" && strings.HasSuffix(parts[5], ".tar"):
+			if s := strings.TrimSuffix(parts[5], ".tar"); s != shard {
+				shard = s
+				api.server.logger.Printf("restoring index %s shard %s", index, shard)
+			}
+			var n uint64
+			if n, err = strconv.ParseUint(shard, 10, 64); err != nil {
+				return NewBadRequestError(errors.Wrapf(err, "parsing shard of %s", hdr.Name))
+			}
+			err = api.restoreFragment(ctx, BackupFragment{Index: index, Field: parts[1], View: parts[3], Shard: n}, tr)
+		default:
+			return NewBadRequestError(errors.Errorf("unexpected backup entry: %s", hdr.Name))
+		}
+		if err != nil {
+			return errors.Wrapf(err, "restoring %s", hdr.Name)
+		}
+	}
+	if schema == nil {
+		return NewBadRequestError(errors.New("backup is empty"))
+	}
+	return nil
+}
+
+// restoreSchema applies the schema of a backup to every node. The indexes of
+// an incremental backup may already exist, and only those indexes and fields
+// created since its base are added.
+func (api *API) restoreSchema(ctx context.Context, r io.Reader, incremental bool) (*Schema, error) {
+	var schema Schema
+	if err := json.NewDecoder(r).Decode(&schema); err != nil {
+		return nil, NewBadRequestError(errors.Wrap(err, "decoding schema"))
+	}
+	for _, index := range schema.Indexes {
+		if !incremental && api.holder.Index(index.Name) != nil {
+			return nil, newConflictError(errors.Wrap(ErrIndexExists, index.Name))
+		}
+	}
+	if err := api.ApplySchema(ctx, &schema, false); err != nil {
+		return nil, errors.Wrap(err, "applying schema")
+	}
+	return &schema, nil
+}
+
+// restoreKeys restores the keys of an index, or of one of its fields, to
+// the primary translate node, as only its translate stores are writable.
+func (api *API) restoreKeys(ctx context.Context, indexName, fieldName string, r io.Reader) error {
+	node := api.cluster.translatePrimaryNode()
+	if node == nil || node.ID == api.Node().ID {
+		return api.RestoreKeys(ctx, indexName, fieldName, r)
+	}
+	return errors.Wrapf(api.server.defaultClient.RestoreKeys(ctx, &node.URI, indexName, fieldName, r), "restoring to node %s", node.ID)
+}
+
+// RestoreKeys sets the keys of an index, or of one of its fields when
+// fieldName is given, in the translate store of this node, which must be
+// the primary translate node. The keys are read from r as a stream of JSON
+// translate entries.
+func (api *API) RestoreKeys(ctx context.Context, indexName, fieldName string, r io.Reader) error {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.RestoreKeys")
+	defer span.Finish()
+
+	if err := api.validate(apiRestoreKeys); err != nil {
+		return errors.Wrap(err, "validating api method")
+	}
+
+	store, err := api.restoreTranslateStore(indexName, fieldName)
+	if err != nil {
+		return err
+	} else if store.ReadOnly() {
+		return ErrTranslateStoreReadOnly
+	}
+
+	dec := json.NewDecoder(r)
+	for {
+		var entry TranslateEntry
+		if err := dec.Decode(&entry); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return NewBadRequestError(errors.Wrap(err, "decoding key"))
+		}
+		if err := store.ForceSet(entry.ID, entry.Key); err != nil {
+			return errors.Wrap(err, "setting key")
+		}
+	}
+}
+
+// restoreTranslateStore returns the translate store of an index, or of one
+// of its fields.
+func (api *API) restoreTranslateStore(indexName, fieldName string) (TranslateStore, error) {
+	index := api.holder.Index(indexName)
+	if index == nil {
+		return nil, newNotFoundError(ErrIndexNotFound, indexName)
+	} else if fieldName == "" {
+		return index.TranslateStore(), nil
+	}
+	field := index.Field(fieldName)
+	if field == nil {
+		return nil, newNotFoundError(ErrFieldNotFound, fieldName)
+	}
+	return field.TranslateStore(), nil
+}
+
+// restoreAttrs restores the column attributes of an index, or the row
+// attributes of one of its fields, to every node.
+func (api *API) restoreAttrs(ctx context.Context, indexName, fieldName string, r io.Reader) error {
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		return errors.Wrap(err, "reading attributes")
+	}
+
+	for _, node := range api.cluster.Nodes() {
+		if node.ID == api.Node().ID {
+			err = api.RestoreAttrs(ctx, indexName, fieldName, bytes.NewReader(buf))
+		} else {
+			err = api.server.defaultClient.RestoreAttrs(ctx, &node.URI, indexName, fieldName, bytes.NewReader(buf))
+		}
+		if err != nil {
+			return errors.Wrapf(err, "restoring to node %s", node.ID)
+		}
+	}
+	return nil
+}
+
+// restoreFragment sends a fragment archive to each owner of its shard. The
+// archive is first written to a temporary file, as it's read once for each
+// owner.
+func (api *API) restoreFragment(ctx context.Context, bf BackupFragment, r io.Reader) error {
+	file, err := ioutil.TempFile(api.holder.Path, ".restore")
+	if err != nil {
+		return errors.Wrap(err, "creating temporary file")
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	if _, err := io.Copy(file, r); err != nil {
+		return errors.Wrap(err, "copying fragment")
+	}
+
+	for _, node := range api.cluster.shardNodes(bf.Index, bf.Shard) {
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return errors.Wrap(err, "seeking")
+		}
+		if node.ID == api.Node().ID {
+			err = api.RestoreFragment(ctx, bf, file)
+		} else {
+			err = api.server.defaultClient.RestoreFragment(ctx, &node.URI, bf, file)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "restoring to node %s", node.ID)
+		}
+	}
+	return nil
+}
+
+// RestoreFragment replaces the data of a fragment of this node with an
+// archive read from r, growing the bit depth of an int field to fit the
+// restored values.
+func (api *API) RestoreFragment(ctx context.Context, bf BackupFragment, r io.Reader) error {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.RestoreFragment")
+	defer span.Finish()
+
+	if err := api.validate(apiRestoreFragment); err != nil {
+		return errors.Wrap(err, "validating api method")
+	}
+	if err := api.validateShardOwnership(bf.Index, bf.Shard); err != nil {
+		return errors.Wrap(err, "validating shard ownership")
+	}
+
+	field := api.holder.Field(bf.Index, bf.Field)
+	if field == nil {
+		return newNotFoundError(ErrFieldNotFound, bf.Field)
+	}
+	v, err := field.createViewIfNotExists(bf.View)
+	if err != nil {
+		return errors.Wrap(err, "creating view")
+	}
+	frag, err := v.CreateFragmentIfNotExists(bf.Shard)
+	if err != nil {
+		return errors.Wrap(err, "creating fragment")
+	}
+	if _, err := frag.ReadFrom(r); err != nil {
+		return errors.Wrap(err, "reading fragment")
+	}
+
+	// Bit depths grow separately on each node as values are set, so the
+	// fragment may hold deeper values than this node has seen.
+	if !strings.HasPrefix(bf.View, ViewBSIGroupPrefix) {
+		return nil
+	}
+	bsig := field.bsiGroup(strings.TrimPrefix(bf.View, ViewBSIGroupPrefix))
+	if bsig == nil {
+		return nil
+	}
+	rows, err := frag.rows(0)
+	if err != nil {
+		return errors.Wrap(err, "reading rows")
+	} else if len(rows) == 0 || rows[len(rows)-1] < BSIOffsetBit {
+		return nil
+	}
+	depth := uint(rows[len(rows)-1]-BSIOffsetBit) + 1
+	return errors.Wrap(field.growBitDepth(bsig, depth), "growing bit depth")
+}
+
+// RestoreAttrs sets the column attributes of an index, or the row attributes
+// of one of its fields when fieldName is given, on this node. The attributes
+// are read from r as a JSON object of attributes by ID. Whole numbers are
+// restored as integers.
+func (api *API) RestoreAttrs(ctx context.Context, indexName, fieldName string, r io.Reader) error {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.RestoreAttrs")
+	defer span.Finish()
+
+	if err := api.validate(apiRestoreAttrs); err != nil {
+		return errors.Wrap(err, "validating api method")
+	}
+
+	index := api.holder.Index(indexName)
+	if index == nil {
+		return newNotFoundError(ErrIndexNotFound, indexName)
+	}
+	store := index.ColumnAttrStore()
+	if fieldName != "" {
+		field := index.Field(fieldName)
+		if field == nil {
+			return newNotFoundError(ErrFieldNotFound, fieldName)
+		}
+		store = field.RowAttrStore()
+	}
+
+	var attrs map[uint64]map[string]interface{}
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	if err := dec.Decode(&attrs); err != nil {
+		return NewBadRequestError(errors.Wrap(err, "decoding attributes"))
+	}
+	for _, m := range attrs {
+		for k, v := range m {
+			n, ok := v.(json.Number)
+			if !ok {
+				continue
+			}
+			if i, err := n.Int64(); err == nil {
+				m[k] = i
+			} else if m[k], err = n.Float64(); err != nil {
+				return NewBadRequestError(errors.Wrapf(err, "decoding attribute %s", k))
+			}
+		}
+	}
+	return errors.Wrap(store.SetBulkAttrs(attrs), "setting attributes")
+}
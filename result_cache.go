@@ -0,0 +1,248 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pilosa
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pilosa/pilosa/v2/lru"
+	"github.com/pilosa/pilosa/v2/pql"
+)
+
+// fragmentVersionSeq is the source of fragment versions. Versions are drawn
+// from a single sequence so that a fragment which is deleted and recreated
+// never reuses a version seen by the result cache.
+var fragmentVersionSeq uint64
+
+// nextFragmentVersion returns a new, never before used, fragment version.
+func nextFragmentVersion() uint64 {
+	return atomic.AddUint64(&fragmentVersionSeq, 1)
+}
+
+// resultCache holds per-shard results of read-only calls (Count, TopN,
+// GroupBy). Each entry records the versions of the fragments which were
+// consulted to compute it; an entry is only returned if none of those
+// fragments have changed since.
+type resultCache struct {
+	mu  sync.Mutex
+	lru *lru.Cache
+
+	// maxResultSize is the largest number of items (pairs or group counts)
+	// a single result may contain and still be cached.
+	maxResultSize int
+}
+
+// resultCacheEntry is a cached shard result along with the fragment
+// versions it was computed from.
+type resultCacheEntry struct {
+	versions []uint64
+	result   interface{}
+}
+
+// newResultCache returns a new instance of resultCache. It holds at most
+// maxEntries shard results.
+func newResultCache(maxEntries, maxResultSize int) *resultCache {
+	return &resultCache{
+		lru:           lru.New(maxEntries),
+		maxResultSize: maxResultSize,
+	}
+}
+
+// get returns the cached result for key if it was computed from the given
+// fragment versions.
+func (c *resultCache) get(key string, versions []uint64) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	v, ok := c.lru.Get(key)
+	if !ok {
+		return nil, false
+	}
+	entry := v.(*resultCacheEntry)
+	if !uint64SlicesEqual(entry.versions, versions) {
+		return nil, false
+	}
+	return copyShardResult(entry.result), true
+}
+
+// add caches result for key. Results which exceed the maximum result size
+// are ignored.
+func (c *resultCache) add(key string, versions []uint64, result interface{}) {
+	switch r := result.(type) {
+	case []Pair:
+		if c.maxResultSize > 0 && len(r) > c.maxResultSize {
+			return
+		}
+	case []GroupCount:
+		if c.maxResultSize > 0 && len(r) > c.maxResultSize {
+			return
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lru.Add(key, &resultCacheEntry{
+		versions: versions,
+		result:   copyShardResult(result),
+	})
+}
+
+// copyShardResult returns a copy of a shard result which can be handed to a
+// reduce function without risk of the cached value being modified.
+func copyShardResult(result interface{}) interface{} {
+	switch r := result.(type) {
+	case []Pair:
+		other := make([]Pair, len(r))
+		copy(other, r)
+		return other
+	case []GroupCount:
+		other := make([]GroupCount, len(r))
+		copy(other, r)
+		return other
+	default:
+		return result
+	}
+}
+
+// cachedMapFn wraps mapFn so that its results are served from, and stored in,
+// the executor's result cache. extra is appended to the cache key and must
+// describe any input to mapFn that is not part of the call itself.
+func (e *executor) cachedMapFn(ctx context.Context, index string, c *pql.Call, extra string, mapFn mapFunc) mapFunc {
+	if e.resultCache == nil {
+		return mapFn
+	}
+
+	// Snapshot reads may be older than the live fragment versions.
+	if querySnapshotFromContext(ctx) != nil {
+		return mapFn
+	}
+
+	// Results which depend on row attributes can't be invalidated by
+	// fragment versions.
+	if attrName, _ := c.Args["attrName"].(string); attrName != "" {
+		return mapFn
+	}
+
+	idx := e.Holder.Index(index)
+	if idx == nil {
+		return mapFn
+	}
+
+	// Determine every field the call may read from. The existence field is
+	// always included since calls such as Not() read from it implicitly.
+	names := map[string]struct{}{existenceFieldName: {}}
+	callFieldNames(c, names)
+	fields := make([]*Field, 0, len(names))
+	for name := range names {
+		if f := idx.Field(name); f != nil {
+			fields = append(fields, f)
+		}
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Name() < fields[j].Name() })
+
+	prefix := index + "/" + c.String() + extra + "/"
+	return func(shard uint64) (interface{}, error) {
+		key := prefix + strconv.FormatUint(shard, 10)
+		versions := shardVersions(fields, shard)
+
+		if result, ok := e.resultCache.get(key, versions); ok {
+			e.Holder.Stats.Count("resultCacheHit", 1, 1.0)
+			return result, nil
+		}
+		e.Holder.Stats.Count("resultCacheMiss", 1, 1.0)
+
+		result, err := mapFn(shard)
+		if err != nil {
+			return result, err
+		}
+
+		// TopN reads rankings which are rebuilt at most every few seconds.
+		// Rankings which are behind the fragment's counts are rebuilt by a
+		// later TopN, so the result is only cached once they are current.
+		if c.Name == "TopN" && shardCachesStale(fields, shard) {
+			return result, nil
+		}
+		e.resultCache.add(key, versions, result)
+		return result, nil
+	}
+}
+
+// shardVersions returns the versions of every fragment of fields in shard.
+// Views are visited in name order and missing fragments are reported as
+// zero so that the creation of a view or fragment changes the result.
+func shardVersions(fields []*Field, shard uint64) []uint64 {
+	var versions []uint64
+	for _, f := range fields {
+		views := f.views()
+		sort.Slice(views, func(i, j int) bool { return views[i].name < views[j].name })
+		for _, view := range views {
+			var version uint64
+			if frag := view.Fragment(shard); frag != nil {
+				version = frag.currentVersion()
+			}
+			versions = append(versions, version)
+		}
+		// Separate fields so that views moving between them can't collide.
+		versions = append(versions, 0)
+	}
+	return versions
+}
+
+// shardCachesStale reports whether any fragment of fields in shard has a
+// cache whose rankings don't reflect its latest counts.
+func shardCachesStale(fields []*Field, shard uint64) bool {
+	for _, f := range fields {
+		for _, view := range f.views() {
+			if frag := view.Fragment(shard); frag != nil && frag.cacheStale() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// callFieldNames adds to names every argument key and string argument value
+// in c and its children. Not all of these are field names, but every field
+// name referenced by the call will be among them.
+func callFieldNames(c *pql.Call, names map[string]struct{}) {
+	for key, arg := range c.Args {
+		names[key] = struct{}{}
+		switch v := arg.(type) {
+		case string:
+			names[v] = struct{}{}
+		case *pql.Call:
+			callFieldNames(v, names)
+		}
+	}
+	for _, child := range c.Children {
+		callFieldNames(child, names)
+	}
+}
+
+func uint64SlicesEqual(a, b []uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,81 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pilosa
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pilosa/pilosa/v2/pql"
+)
+
+// Ensure TopN results computed from rankings which are behind the fragment's
+// counts are not cached, so they are replaced once the rankings are rebuilt.
+func TestExecutor_ResultCache_StaleTopN(t *testing.T) {
+	h := newHolder()
+	if err := h.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	e := newExecutor(optExecutorResultCache(100, 0))
+	defer e.Close()
+	e.Holder = h.Holder
+
+	idx := h.MustCreateIndexIfNotExists("i", IndexOptions{})
+	f, err := idx.CreateField("f")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query, err := pql.ParseString(`TopN(f)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := query.Calls[0]
+	ctx := context.Background()
+	mapFn := e.cachedMapFn(ctx, "i", c, "", func(shard uint64) (interface{}, error) {
+		return e.executeTopNShard(ctx, "i", c, shard)
+	})
+	check := func(exp []Pair) {
+		t.Helper()
+		for i := 0; i < 2; i++ {
+			if res, err := mapFn(0); err != nil {
+				t.Fatal(err)
+			} else if !reflect.DeepEqual(res, exp) {
+				t.Fatalf("unexpected result: %v", res)
+			}
+		}
+	}
+
+	// The first count is ranked immediately.
+	if _, err := f.SetBit(10, 1, nil); err != nil {
+		t.Fatal(err)
+	}
+	check([]Pair{{ID: 10, Count: 1}})
+
+	// Later counts are only ranked once the rankings are rebuilt.
+	if _, err := f.SetBit(10, 2, nil); err != nil {
+		t.Fatal(err)
+	}
+	check([]Pair{{ID: 10, Count: 1}})
+
+	rc := f.view(viewStandard).Fragment(0).cache.(*rankCache)
+	rc.mu.Lock()
+	rc.updateTime = time.Time{}
+	rc.mu.Unlock()
+	check([]Pair{{ID: 10, Count: 2}})
+}
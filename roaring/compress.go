@@ -0,0 +1,141 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package roaring
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// storageVersionCompressed indicates the compressed variant of the
+	// storage format. The header is followed by the DEFLATE compressed
+	// Pilosa roaring data, and then by any ops appended since.
+	storageVersionCompressed = uint32(2)
+
+	// compressedHeaderSize is the size in bytes of the cookie, a reserved
+	// byte, and the uncompressed and compressed sizes of the data.
+	compressedHeaderSize = 3 + 1 + 8 + 8
+
+	// maxCompressionRatio is the largest ratio DEFLATE can achieve, used to
+	// reject headers claiming implausibly large uncompressed data.
+	maxCompressionRatio = 1032
+)
+
+// Compressed reports whether data is in the compressed variant of the
+// Pilosa roaring format.
+func Compressed(data []byte) bool {
+	if len(data) < headerBaseSize {
+		return false
+	}
+	return uint32(binary.LittleEndian.Uint16(data[0:2])) == MagicNumber && uint32(data[2]) == storageVersionCompressed
+}
+
+// Compress writes data, which must be in the Pilosa roaring format, to w in
+// the compressed variant of the format. Ops can be appended to the written
+// data, as with the uncompressed format.
+func Compress(w io.Writer, data []byte) (int64, error) {
+	var buf bytes.Buffer
+	buf.Write(make([]byte, compressedHeaderSize))
+	zw, err := flate.NewWriter(&buf, flate.DefaultCompression)
+	if err != nil {
+		return 0, errors.Wrap(err, "creating compressor")
+	}
+	if _, err := zw.Write(data); err != nil {
+		return 0, errors.Wrap(err, "compressing")
+	} else if err := zw.Close(); err != nil {
+		return 0, errors.Wrap(err, "flushing compressor")
+	}
+
+	header := buf.Bytes()[:compressedHeaderSize]
+	binary.LittleEndian.PutUint32(header[0:4], MagicNumber|storageVersionCompressed<<16)
+	binary.LittleEndian.PutUint64(header[4:12], uint64(len(data)))
+	binary.LittleEndian.PutUint64(header[12:20], uint64(buf.Len()-compressedHeaderSize))
+	return buf.WriteTo(w)
+}
+
+// Decompress returns the Pilosa roaring data held by data, which is in the
+// compressed variant of the format, followed by the ops appended to it.
+func Decompress(data []byte) ([]byte, error) {
+	_, out, err := decompress(data)
+	return out, err
+}
+
+// CompressedFlags returns the flags of the Pilosa roaring data held by data,
+// which is in the compressed variant of the format. Only the start of the
+// data is decompressed.
+func CompressedFlags(data []byte) (byte, error) {
+	if !Compressed(data) {
+		return 0, errors.New("data is not compressed")
+	} else if len(data) < compressedHeaderSize {
+		return 0, errors.New("data too small")
+	}
+	var header [headerBaseSize]byte
+	zr := flate.NewReader(bytes.NewReader(data[compressedHeaderSize:]))
+	defer zr.Close()
+	if _, err := io.ReadFull(zr, header[:]); err != nil {
+		return 0, errors.Wrap(err, "decompressing header")
+	}
+	return header[3], nil
+}
+
+// decompress also returns the offset in data of the first appended op.
+func decompress(data []byte) (int64, []byte, error) {
+	if !Compressed(data) {
+		return 0, nil, errors.New("data is not compressed")
+	} else if len(data) < compressedHeaderSize {
+		return 0, nil, errors.New("data too small")
+	}
+	size := binary.LittleEndian.Uint64(data[4:12])
+	compressedSize := binary.LittleEndian.Uint64(data[12:20])
+	if compressedSize > uint64(len(data)-compressedHeaderSize) {
+		return 0, nil, fmt.Errorf("insufficient data for %d compressed bytes", compressedSize)
+	} else if size > (compressedSize+1)*maxCompressionRatio {
+		return 0, nil, fmt.Errorf("implausible size %d for %d compressed bytes", size, compressedSize)
+	}
+	opsOffset := int64(compressedHeaderSize + compressedSize)
+	ops := data[opsOffset:]
+
+	out := make([]byte, int(size)+len(ops))
+	zr := flate.NewReader(bytes.NewReader(data[compressedHeaderSize:opsOffset]))
+	defer zr.Close()
+	if _, err := io.ReadFull(zr, out[:size]); err != nil {
+		return 0, nil, errors.Wrap(err, "decompressing")
+	}
+	copy(out[size:], ops)
+	return opsOffset, out, nil
+}
+
+// unmarshalCompressed decompresses data and unmarshals it. The offset of an
+// OpError refers to data, not to the decompressed data.
+func (b *Bitmap) unmarshalCompressed(data []byte) error {
+	opsOffset, out, err := decompress(data)
+	if err != nil {
+		return err
+	}
+	err = b.unmarshalPilosaRoaring(out)
+	if opErr, ok := err.(*OpError); ok {
+		// The ops follow the decompressed data in out, and the compressed
+		// data in data.
+		size := int64(len(out)) - (int64(len(data)) - opsOffset)
+		opErr.Offset += opsOffset - size
+	}
+	return err
+}
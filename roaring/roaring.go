@@ -18,6 +18,7 @@ package roaring
 import (
 	"encoding/binary"
 	"fmt"
+	"hash/crc32"
 	"hash/fnv"
 	"io"
 	"math/bits"
@@ -34,6 +35,11 @@ const (
 	// storageVersion indicates the storage version, in byte 2.
 	storageVersion = uint32(0)
 
+	// storageVersionChecksummed indicates the checksummed variant of the
+	// storage format, in which the container offsets are followed by a
+	// checksum of each container.
+	storageVersionChecksummed = uint32(1)
+
 	// NOTE: byte 3 stores user-defined flags.
 
 	// cookie is the first 3 bytes in a roaring bitmap file,
@@ -1045,18 +1051,34 @@ func (ew *errWriter) WriteUint64(b []byte, v uint64) {
 // WriteTo writes b to w.
 func (b *Bitmap) WriteTo(w io.Writer) (n int64, err error) {
 	b.Optimize()
-	return b.writeToUnoptimized(w)
+	return b.writeToUnoptimized(w, false)
+}
+
+// WriteChecksummedTo writes b to w in the checksummed variant of the Pilosa
+// roaring format, whose container checksums are verified when it's read.
+func (b *Bitmap) WriteChecksummedTo(w io.Writer) (n int64, err error) {
+	b.Optimize()
+	return b.writeToUnoptimized(w, true)
 }
 
+// checksumTable is the table of the container checksums of the checksummed
+// storage format, which are CRC-32C, as it's computed in hardware on most
+// platforms.
+var checksumTable = crc32.MakeTable(crc32.Castagnoli)
+
 // writeToUnoptimized is a WriteTo without the Optimize path. We need
 // this because otherwise we can't do some of our marshal/unmarshal tests
 // safely.
-func (b *Bitmap) writeToUnoptimized(w io.Writer) (n int64, err error) {
+func (b *Bitmap) writeToUnoptimized(w io.Writer, checksums bool) (n int64, err error) {
 	// Remove empty containers before persisting.
 	//b.removeEmptyContainers()
 
 	containerCount := b.Containers.Size() - b.countEmptyContainers()
 	headerSize := headerBaseSize
+	version, checksumSize := storageVersion, 0
+	if checksums {
+		version, checksumSize = storageVersionChecksummed, 4
+	}
 	byte2 := make([]byte, 2)
 	byte4 := make([]byte, 4)
 	byte8 := make([]byte, 8)
@@ -1070,7 +1092,7 @@ func (b *Bitmap) writeToUnoptimized(w io.Writer) (n int64, err error) {
 		n: 0,
 	}
 
-	ew.WriteUint32(byte4, cookie|(uint32(b.Flags)<<24))
+	ew.WriteUint32(byte4, MagicNumber|version<<16|(uint32(b.Flags)<<24))
 	ew.WriteUint32(byte4, uint32(containerCount))
 
 	// Descriptive header section: encode keys and cardinality.
@@ -1092,7 +1114,7 @@ func (b *Bitmap) writeToUnoptimized(w io.Writer) (n int64, err error) {
 
 	// Offset header section: write the offset for each container block.
 	// 4 bytes per container.
-	offset := uint32(headerSize + (containerCount * (8 + 2 + 2 + 4)))
+	offset := uint32(headerSize + (containerCount * (8 + 2 + 2 + 4 + checksumSize)))
 	citer, _ = b.Containers.Iterator(0)
 	for citer.Next() {
 		_, c := citer.Value()
@@ -1102,11 +1124,30 @@ func (b *Bitmap) writeToUnoptimized(w io.Writer) (n int64, err error) {
 		}
 
 	}
+
+	// Checksum section: write the checksum of each container's header and
+	// data, 4 bytes per container.
+	if checksums {
+		header := make([]byte, 12)
+		citer, _ = b.Containers.Iterator(0)
+		for citer.Next() {
+			key, c := citer.Value()
+			if c.N() > 0 {
+				binary.LittleEndian.PutUint64(header[0:8], key)
+				binary.LittleEndian.PutUint16(header[8:10], uint16(c.typ()))
+				binary.LittleEndian.PutUint16(header[10:12], uint16(c.N()-1))
+				h := crc32.New(checksumTable)
+				_, _ = h.Write(header)
+				_, _ = c.WriteTo(h)
+				ew.WriteUint32(byte4, h.Sum32())
+			}
+		}
+	}
 	if ew.err != nil {
 		return int64(ew.n), ew.err
 	}
 
-	n = int64(headerSize + (containerCount * (8 + 2 + 2 + 4)))
+	n = int64(headerSize + (containerCount * (8 + 2 + 2 + 4 + checksumSize)))
 
 	// Container storage section: write each container block.
 	citer, _ = b.Containers.Iterator(0)
@@ -1169,6 +1210,7 @@ func (b *baseRoaringIterator) SilenceLint() {
 
 type pilosaRoaringIterator struct {
 	baseRoaringIterator
+	checksums []byte
 }
 
 type officialRoaringIterator struct {
@@ -1218,8 +1260,8 @@ func newOfficialRoaringIterator(data []byte) (*officialRoaringIterator, error) {
 
 func newPilosaRoaringIterator(data []byte) (*pilosaRoaringIterator, error) {
 	fileVersion := uint32(data[2])
-	if fileVersion != storageVersion {
-		return nil, fmt.Errorf("wrong roaring version, file is v%d, server requires v%d", fileVersion, storageVersion)
+	if fileVersion != storageVersion && fileVersion != storageVersionChecksummed {
+		return nil, fmt.Errorf("wrong roaring version, file is v%d, server requires v%d or v%d", fileVersion, storageVersion, storageVersionChecksummed)
 	}
 	r := &pilosaRoaringIterator{}
 	r.data = data
@@ -1248,6 +1290,14 @@ func newPilosaRoaringIterator(data []byte) (*pilosaRoaringIterator, error) {
 	offsetEnd := offsetStart + (r.keys * 4)
 	r.headers = data[headerStart:headerEnd]
 	r.offsets = data[offsetStart:offsetEnd]
+	if fileVersion == storageVersionChecksummed {
+		if int64(len(data)) < offsetEnd+(r.keys*4) {
+			return nil, fmt.Errorf("insufficient data for checksums: want %d bytes, got %d",
+				offsetEnd+(r.keys*4), len(data))
+		}
+		r.checksums = data[offsetEnd : offsetEnd+(r.keys*4)]
+		offsetEnd += r.keys * 4
+	}
 	// if there's no containers, we want to act as though data started at the end
 	// of the list of offsets, which was also empty, so we don't think the entire thing
 	// is actually a malformed op
@@ -1318,6 +1368,13 @@ func (r *pilosaRoaringIterator) Next() (key uint64, cType byte, n int, length in
 	}
 	r.prevOffset32 = offset32
 	r.currentDataOffset = r.chunkOffset + uint64(offset32)
+	if r.checksums != nil {
+		err := verifyContainerChecksum(r.data, headerBaseSize, int(r.currentIdx), int64(r.currentDataOffset), r.currentType, r.checksums)
+		if err != nil {
+			r.Done(err)
+			return r.Current()
+		}
+	}
 
 	// a run container keeps its data after an initial 2 byte length header
 	var runCount uint16
@@ -1960,7 +1960,7 @@ func TestWriteReadFullBitmap(t *testing.T) {
 	bb.Containers.Put(0, cb)
 	bb2 := NewFileBitmap()
 	var buf bytes.Buffer
-	_, err := bb.writeToUnoptimized(&buf)
+	_, err := bb.writeToUnoptimized(&buf, false)
 	if err != nil {
 		t.Fatalf("error writing: %v", err)
 	}
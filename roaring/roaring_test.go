@@ -21,6 +21,7 @@ import (
 	"math/rand"
 	"reflect"
 	"sort"
+	"strings"
 	"testing"
 	"testing/quick"
 	"time"
@@ -28,6 +29,7 @@ import (
 	"github.com/pilosa/pilosa/v2"
 	"github.com/pilosa/pilosa/v2/roaring"
 	_ "github.com/pilosa/pilosa/v2/test"
+	"github.com/pkg/errors"
 )
 
 func TestContainerCount(t *testing.T) {
@@ -1207,6 +1209,138 @@ func TestBitmap_Marshal_Quick_Bitmap_Sorted(t *testing.T) {
 	testBitmapMarshalQuick(t, 10000, 0, 10000, true)
 }
 
+// Ensure a bitmap written in the checksummed format can be read, with its
+// ops log, and that corrupt containers are detected.
+func TestBitmap_WriteChecksummedTo(t *testing.T) {
+	bm := roaring.NewFileBitmap(1, 2, 3, 70000)
+	bm.Flags = 1
+	for v := uint64(1 << 20); v < (1<<20)+5000; v += 2 {
+		bm.DirectAdd(v) // bitmap container
+	}
+	for v := uint64(1 << 24); v < (1<<24)+3000; v++ {
+		bm.DirectAdd(v) // run container
+	}
+	exp := bm.Slice()
+
+	var buf bytes.Buffer
+	if n, err := bm.WriteChecksummedTo(&buf); err != nil {
+		t.Fatal(err)
+	} else if n != int64(buf.Len()) {
+		t.Fatalf("size mismatch: %d != %d", n, buf.Len())
+	}
+	bm.OpWriter = &buf
+	if _, err := bm.Add(5); err != nil {
+		t.Fatal(err)
+	}
+	exp = append([]uint64{1, 2, 3, 5}, exp[3:]...)
+
+	bm2 := roaring.NewFileBitmap()
+	if err := bm2.UnmarshalBinary(buf.Bytes()); err != nil {
+		t.Fatal(err)
+	} else if got := bm2.Slice(); !reflect.DeepEqual(got, exp) {
+		t.Fatalf("unexpected values: %s", diff(exp, got))
+	}
+
+	// Flip a bit in the last container, and in its header.
+	data := buf.Bytes()
+	for _, i := range []int{len(data) - 13 - 100, 8 + 2*12 + 3} {
+		corrupt := append([]byte(nil), data...)
+		corrupt[i] ^= 0x10
+		if err := roaring.NewFileBitmap().UnmarshalBinary(corrupt); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+			t.Fatalf("expected checksum mismatch at %d, got %v", i, err)
+		}
+	}
+}
+
+// Ensure an incomplete op at the end of the ops log is reported with its
+// offset, and that the data before it can be read.
+func TestBitmap_UnmarshalBinary_TruncatedOp(t *testing.T) {
+	bm := roaring.NewFileBitmap(1, 2, 3)
+	var buf bytes.Buffer
+	if _, err := bm.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	bm.OpWriter = &buf
+	if _, err := bm.Add(4); err != nil {
+		t.Fatal(err)
+	}
+	offset := int64(buf.Len())
+	if _, err := bm.Add(5); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()[:buf.Len()-3]
+
+	err := roaring.NewFileBitmap().UnmarshalBinary(data)
+	if opErr, ok := errors.Cause(err).(*roaring.OpError); !ok {
+		t.Fatalf("expected op error, got %v", err)
+	} else if opErr.Offset != offset {
+		t.Fatalf("expected offset %d, got %d", offset, opErr.Offset)
+	}
+
+	bm2 := roaring.NewFileBitmap()
+	if err := bm2.UnmarshalBinary(data[:offset]); err != nil {
+		t.Fatal(err)
+	} else if got := bm2.Slice(); !reflect.DeepEqual(got, []uint64{1, 2, 3, 4}) {
+		t.Fatalf("unexpected values: %v", got)
+	}
+}
+
+// Ensure a compressed bitmap can be read, with ops appended to it, and that
+// the offset of an incomplete op refers to the compressed data.
+func TestBitmap_Compress(t *testing.T) {
+	bm := roaring.NewFileBitmap(1, 2, 3, 70000)
+	for v := uint64(1 << 20); v < (1<<20)+5000; v += 2 {
+		bm.DirectAdd(v)
+	}
+	exp := bm.Slice()
+
+	var raw, buf bytes.Buffer
+	if _, err := bm.WriteChecksummedTo(&raw); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := roaring.Compress(&buf, raw.Bytes()); err != nil {
+		t.Fatal(err)
+	} else if n != int64(buf.Len()) {
+		t.Fatalf("size mismatch: %d != %d", n, buf.Len())
+	} else if n >= int64(raw.Len()) {
+		t.Fatalf("expected compressed size %d to be less than %d", n, raw.Len())
+	} else if !roaring.Compressed(buf.Bytes()) || roaring.Compressed(raw.Bytes()) {
+		t.Fatal("unexpected result from Compressed")
+	} else if flags, err := roaring.CompressedFlags(buf.Bytes()); err != nil {
+		t.Fatal(err)
+	} else if flags != bm.Flags {
+		t.Fatalf("unexpected flags: %d", flags)
+	}
+
+	bm.OpWriter = &buf
+	if _, err := bm.Add(5); err != nil {
+		t.Fatal(err)
+	}
+	exp = append([]uint64{1, 2, 3, 5}, exp[3:]...)
+	offset := int64(buf.Len())
+	if _, err := bm.Add(6); err != nil {
+		t.Fatal(err)
+	}
+
+	bm2 := roaring.NewFileBitmap()
+	if err := bm2.UnmarshalBinary(buf.Bytes()[:offset]); err != nil {
+		t.Fatal(err)
+	} else if got := bm2.Slice(); !reflect.DeepEqual(got, exp) {
+		t.Fatalf("unexpected values: %s", diff(exp, got))
+	}
+
+	err := roaring.NewFileBitmap().UnmarshalBinary(buf.Bytes()[:buf.Len()-3])
+	if opErr, ok := errors.Cause(err).(*roaring.OpError); !ok {
+		t.Fatalf("expected op error, got %v", err)
+	} else if opErr.Offset != offset {
+		t.Fatalf("expected offset %d, got %d", offset, opErr.Offset)
+	}
+
+	if _, err := roaring.Decompress(buf.Bytes()[:30]); err == nil {
+		t.Fatal("expected error decompressing truncated data")
+	}
+}
+
 // TODO update for RLE
 
 // Ensure a bitmap can be marshaled and unmarshaled.
@@ -17,13 +17,56 @@ package roaring
 import (
 	"encoding/binary"
 	"fmt"
+	"hash/crc32"
 	"unsafe"
 
 	"github.com/pkg/errors"
 )
 
+// verifyContainerChecksum verifies the checksum of the ith container of data,
+// of type typ, whose data starts at offset.
+func verifyContainerChecksum(data []byte, headerSize, i int, offset int64, typ byte, checksums []byte) error {
+	header := data[headerSize+i*12 : headerSize+(i+1)*12]
+	size := int64(0)
+	switch typ {
+	case containerRun:
+		if offset+runCountHeaderSize > int64(len(data)) {
+			return fmt.Errorf("container %d out of bounds: off=%d, len=%d", i, offset, len(data))
+		}
+		size = runCountHeaderSize + int64(binary.LittleEndian.Uint16(data[offset:offset+runCountHeaderSize]))*interval16Size
+	case containerArray:
+		size = int64(binary.LittleEndian.Uint16(header[10:12])+1) * 2
+	case containerBitmap:
+		size = bitmapN * 8
+	}
+	if offset+size > int64(len(data)) {
+		return fmt.Errorf("container %d out of bounds: off=%d, size=%d, len=%d", i, offset, size, len(data))
+	}
+
+	chk := crc32.Update(crc32.Checksum(header, checksumTable), checksumTable, data[offset:offset+size])
+	if exp := binary.LittleEndian.Uint32(checksums[i*4:]); chk != exp {
+		return fmt.Errorf("container checksum mismatch: container %d, key %d, exp=%08x, got=%08x", i, binary.LittleEndian.Uint64(header[0:8]), exp, chk)
+	}
+	return nil
+}
+
+// OpError is returned when the ops log of Pilosa roaring data holds an op
+// that can't be read, such as one only partly written before a crash. The
+// ops before it have been applied, and the data can be truncated to Offset
+// to drop it and anything after it.
+type OpError struct {
+	Offset int64
+	Err    error
+}
+
+// Error returns the error message.
+func (e *OpError) Error() string {
+	return fmt.Sprintf("reading op at offset %d: %s", e.Offset, e.Err)
+}
+
 // UnmarshalBinary decodes b from a binary-encoded byte slice. data can be in
-// either official roaring format or Pilosa's roaring format.
+// either official roaring format or Pilosa's roaring format, including its
+// checksummed and compressed variants.
 func (b *Bitmap) UnmarshalBinary(data []byte) error {
 	if data == nil {
 		// Nothing to unmarshal
@@ -33,6 +76,9 @@ func (b *Bitmap) UnmarshalBinary(data []byte) error {
 	b.opN = 0 // reset opN since we're reading new data.
 	fileMagic := uint32(binary.LittleEndian.Uint16(data[0:2]))
 	if fileMagic == MagicNumber { // if pilosa roaring
+		if Compressed(data) {
+			return errors.Wrap(b.unmarshalCompressed(data), "unmarshaling as compressed pilosa roaring")
+		}
 		return errors.Wrap(b.unmarshalPilosaRoaring(data), "unmarshaling as pilosa roaring")
 	}
 
@@ -128,6 +174,15 @@ func readWithRuns(b *Bitmap, data []byte, pos int, keyN uint32) error {
 	return nil
 }
 
+// Checksummed reports whether data is in the checksummed variant of the
+// Pilosa roaring format.
+func Checksummed(data []byte) bool {
+	if len(data) < headerBaseSize {
+		return false
+	}
+	return uint32(binary.LittleEndian.Uint16(data[0:2])) == MagicNumber && uint32(data[2]) == storageVersionChecksummed
+}
+
 func (b *Bitmap) unmarshalPilosaRoaring(data []byte) error {
 	if len(data) < headerBaseSize {
 		return errors.New("data too small")
@@ -141,8 +196,8 @@ func (b *Bitmap) unmarshalPilosaRoaring(data []byte) error {
 		return fmt.Errorf("invalid roaring file, magic number %v is incorrect", fileMagic)
 	}
 
-	if fileVersion != storageVersion {
-		return fmt.Errorf("wrong roaring version, file is v%d, server requires v%d", fileVersion, storageVersion)
+	if fileVersion != storageVersion && fileVersion != storageVersionChecksummed {
+		return fmt.Errorf("wrong roaring version, file is v%d, server requires v%d or v%d", fileVersion, storageVersion, storageVersionChecksummed)
 	}
 
 	// Read key count in bytes sizeof(cookie)+sizeof(flag):(sizeof(cookie)+sizeof(uint32)).
@@ -151,6 +206,17 @@ func (b *Bitmap) unmarshalPilosaRoaring(data []byte) error {
 		return fmt.Errorf("insufficient data for header + offsets: key-cardinality not provided for %d containers", keyN)
 	}
 
+	// The checksummed format has a checksum for each container after the
+	// offsets.
+	var checksums []byte
+	if fileVersion == storageVersionChecksummed {
+		start := int64(headerBaseSize) + int64(keyN)*(12+4)
+		if int64(len(data)) < start+int64(keyN)*4 {
+			return fmt.Errorf("insufficient data for checksums: %d containers", keyN)
+		}
+		checksums = data[start : start+int64(keyN)*4]
+	}
+
 	headerSize := headerBaseSize
 	b.Containers.ResetN(int(keyN))
 	// Descriptive header section: Read container keys and cardinalities.
@@ -189,6 +255,11 @@ func (b *Bitmap) unmarshalPilosaRoaring(data []byte) error {
 		if c == nil {
 			continue
 		}
+		if checksums != nil {
+			if err := verifyContainerChecksum(data, headerSize, i, offset, c.typ(), checksums); err != nil {
+				return err
+			}
+		}
 		switch c.typ() {
 		case containerRun:
 			runCount := binary.LittleEndian.Uint16(data[offset : offset+runCountHeaderSize])
@@ -214,8 +285,7 @@ func (b *Bitmap) unmarshalPilosaRoaring(data []byte) error {
 		// Unmarshal the op and apply it.
 		var opr op
 		if err := opr.UnmarshalBinary(buf); err != nil {
-			// FIXME(benbjohnson): return error with position so file can be trimmed.
-			return err
+			return &OpError{Offset: opsOffset, Err: err}
 		}
 		opr.apply(b)
 		// Increase the op count.
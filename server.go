@@ -74,6 +74,12 @@ type Server struct { // nolint: maligned
 
 	defaultClient InternalClient
 	dataDir       string
+
+	resultCacheMaxEntries    int
+	resultCacheMaxResultSize int
+
+	writeConsistency      WriteConsistency
+	hintedHandoffInterval time.Duration
 }
 
 // Holder returns the holder for server.
@@ -149,6 +155,35 @@ func OptServerMaxWritesPerRequest(n int) ServerOption {
 	}
 }
 
+// OptServerQuarantineCorruptFragments is a functional option on Server
+// used to quarantine fragments which can't be read on startup, rather than
+// failing to start.
+func OptServerQuarantineCorruptFragments(enabled bool) ServerOption {
+	return func(s *Server) error {
+		s.holder.QuarantineCorruptFragments = enabled
+		return nil
+	}
+}
+
+// OptServerStorageChecksums is a functional option on Server
+// used to write fragment data files with per-container checksums.
+func OptServerStorageChecksums(enabled bool) ServerOption {
+	return func(s *Server) error {
+		s.holder.Storage.Checksums = enabled
+		return nil
+	}
+}
+
+// OptServerStorageColdAfter is a functional option on Server
+// used to compress fragment data files which haven't been written to
+// for dur.
+func OptServerStorageColdAfter(dur time.Duration) ServerOption {
+	return func(s *Server) error {
+		s.holder.Storage.ColdAfter = dur
+		return nil
+	}
+}
+
 // OptServerMetricInterval is a functional option on Server
 // used to set the interval between metric samples.
 func OptServerMetricInterval(dur time.Duration) ServerOption {
@@ -193,6 +228,42 @@ func OptServerExecutorPoolSize(size int) ServerOption {
 	}
 }
 
+// OptServerResultCache is a functional option on Server
+// used to enable caching of per-shard Count, TopN, and GroupBy results.
+// A maxEntries of zero disables the cache. Results containing more than
+// maxResultSize items are not cached; zero means no limit.
+func OptServerResultCache(maxEntries, maxResultSize int) ServerOption {
+	return func(s *Server) error {
+		s.resultCacheMaxEntries = maxEntries
+		s.resultCacheMaxResultSize = maxResultSize
+		return nil
+	}
+}
+
+// OptServerWriteConsistency is a functional option on Server
+// used to set the default number of replicas which must acknowledge
+// a write.
+func OptServerWriteConsistency(c WriteConsistency) ServerOption {
+	return func(s *Server) error {
+		if err := c.validate(); err != nil {
+			return err
+		}
+		s.writeConsistency = c
+		return nil
+	}
+}
+
+// OptServerHintedHandoffInterval is a functional option on Server
+// used to set how often writes queued for unreachable replicas are
+// replayed. Zero disables hinted handoff, so that unreachable replicas
+// fail the write instead.
+func OptServerHintedHandoffInterval(interval time.Duration) ServerOption {
+	return func(s *Server) error {
+		s.hintedHandoffInterval = interval
+		return nil
+	}
+}
+
 // OptServerPrimaryTranslateStore has been deprecated.
 func OptServerPrimaryTranslateStore(store TranslateStore) ServerOption {
 	return func(s *Server) error {
@@ -309,6 +380,9 @@ func NewServer(opts ...ServerOption) (*Server, error) {
 		metricInterval:      0,
 		diagnosticInterval:  0,
 
+		writeConsistency:      WriteConsistencyAll,
+		hintedHandoffInterval: 10 * time.Second,
+
 		logger: logger.NopLogger,
 	}
 	s.cluster.InternalClient = s.defaultClient
@@ -327,6 +401,9 @@ func NewServer(opts ...ServerOption) (*Server, error) {
 	if s.executorPoolSize > 0 {
 		executorOpts = append(executorOpts, optExecutorWorkerPoolSize(s.executorPoolSize))
 	}
+	if s.resultCacheMaxEntries > 0 {
+		executorOpts = append(executorOpts, optExecutorResultCache(s.resultCacheMaxEntries, s.resultCacheMaxResultSize))
+	}
 	s.executor = newExecutor(executorOpts...)
 
 	// s.holder.translateFile.logger = s.logger
@@ -373,6 +450,10 @@ func NewServer(opts ...ServerOption) (*Server, error) {
 	s.executor.Node = node
 	s.executor.Cluster = s.cluster
 	s.executor.MaxWritesPerRequest = s.maxWritesPerRequest
+	s.executor.WriteConsistency = s.writeConsistency
+	if s.hintedHandoffInterval > 0 {
+		s.executor.handoff = newHintedHandoff(filepath.Join(path, ".hints"), s.defaultClient, s.logger)
+	}
 	s.cluster.broadcaster = s
 	s.cluster.maxWritesPerRequest = s.maxWritesPerRequest
 	s.holder.broadcaster = s
@@ -432,6 +513,11 @@ func (s *Server) Open() error {
 	if err := s.holder.Open(); err != nil {
 		return errors.Wrap(err, "opening Holder")
 	}
+	if s.executor.handoff != nil {
+		if err := s.executor.handoff.Open(); err != nil {
+			return errors.Wrap(err, "opening hinted handoff")
+		}
+	}
 	if err := s.cluster.setNodeState(nodeStateReady); err != nil {
 		return errors.Wrap(err, "setting nodeState")
 	}
@@ -450,8 +536,9 @@ func (s *Server) Open() error {
 	s.syncer.Stats = s.holder.Stats.WithTags("HolderSyncer")
 
 	// Start background monitoring.
-	s.wg.Add(3)
+	s.wg.Add(4)
 	go func() { defer s.wg.Done(); s.monitorAntiEntropy() }()
+	go func() { defer s.wg.Done(); s.monitorHintedHandoff() }()
 	go func() { defer s.wg.Done(); s.monitorRuntime() }()
 	go func() { defer s.wg.Done(); s.monitorDiagnostics() }()
 
@@ -511,6 +598,26 @@ func (s *Server) SyncData() error {
 	return errors.Wrap(s.syncer.SyncHolder(), "syncing holder")
 }
 
+// monitorHintedHandoff periodically replays the writes queued for replicas
+// which couldn't be reached.
+func (s *Server) monitorHintedHandoff() {
+	if s.executor.handoff == nil {
+		return
+	}
+
+	ticker := time.NewTicker(s.hintedHandoffInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.closing:
+			return
+		case <-ticker.C:
+		}
+		s.executor.replayHints(context.Background())
+	}
+}
+
 func (s *Server) monitorAntiEntropy() {
 	if s.antiEntropyInterval == 0 || s.cluster.ReplicaN <= 1 {
 		return // anti entropy disabled
@@ -649,7 +756,9 @@ func (s *Server) receiveMessage(m Message) error {
 			return err
 		}
 	case *RecalculateCaches:
-		s.holder.recalculateCaches()
+		if err := s.holder.recalculateCaches(); err != nil {
+			return errors.Wrap(err, "recalculating caches")
+		}
 	case *NodeEvent:
 		err := s.cluster.ReceiveEvent(obj)
 		if err != nil {
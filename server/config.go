@@ -87,6 +87,11 @@ type Config struct {
 	// lots of fragments.
 	MaxFileCount uint64 `toml:"max-file-count"`
 
+	// QuarantineCorruptFragments moves fragments whose data files can't be
+	// read aside on startup, rather than failing to start. Their data is
+	// fetched from the other owners of their shards by anti-entropy.
+	QuarantineCorruptFragments bool `toml:"quarantine-corrupt-fragments"`
+
 	// TLS
 	TLS TLSConfig `toml:"tls"`
 
@@ -112,6 +117,9 @@ type Config struct {
 		Hosts       []string `toml:"hosts"`
 		// TODO(2.0) move this out of cluster. (why is it here??)
 		LongQueryTime toml.Duration `toml:"long-query-time"`
+		// WriteConsistency is the default number of replicas which must
+		// acknowledge a write: one, quorum, or all.
+		WriteConsistency string `toml:"write-consistency"`
 	} `toml:"cluster"`
 
 	// Gossip config is based around memberlist.Config.
@@ -127,6 +135,39 @@ type Config struct {
 		Interval toml.Duration `toml:"interval"`
 	} `toml:"anti-entropy"`
 
+	HintedHandoff struct {
+		// Interval is how often writes queued for unreachable replicas are
+		// replayed. Zero disables hinted handoff.
+		Interval toml.Duration `toml:"interval"`
+	} `toml:"hinted-handoff"`
+
+	ResultCache struct {
+		// MaxEntries is the number of per-shard query results to cache.
+		// Zero disables the result cache.
+		MaxEntries int `toml:"max-entries"`
+		// MaxResultSize is the largest number of items (TopN pairs or
+		// GroupBy groups) a cached result may contain. Zero means no limit.
+		MaxResultSize int `toml:"max-result-size"`
+	} `toml:"result-cache"`
+
+	Storage struct {
+		// Checksums writes fragment data files with a checksum for each
+		// container, which is verified when the file is opened.
+		Checksums bool `toml:"checksums"`
+		// ColdAfter compresses fragment data files which haven't been
+		// written to for this long. Zero disables compression.
+		ColdAfter toml.Duration `toml:"cold-after"`
+	} `toml:"storage"`
+
+	Postgres struct {
+		// Bind is the host:port on which to accept PostgreSQL wire protocol
+		// connections. Empty disables the listener.
+		Bind string `toml:"bind"`
+		// Insecure allows the listener to accept unencrypted connections
+		// when the server isn't configured to serve TLS.
+		Insecure bool `toml:"insecure"`
+	} `toml:"postgres"`
+
 	Metric struct {
 		// Service can be statsd, expvar, or none.
 		Service string `toml:"service"`
@@ -182,6 +223,7 @@ func NewConfig() *Config {
 	c.Cluster.ReplicaN = 1
 	c.Cluster.Hosts = []string{}
 	c.Cluster.LongQueryTime = toml.Duration(time.Minute)
+	c.Cluster.WriteConsistency = "all"
 
 	// Gossip config.
 	c.Gossip.Port = "14000"
@@ -197,6 +239,13 @@ func NewConfig() *Config {
 	// AntiEntropy config.
 	c.AntiEntropy.Interval = toml.Duration(10 * time.Minute)
 
+	// HintedHandoff config.
+	c.HintedHandoff.Interval = toml.Duration(10 * time.Second)
+
+	// ResultCache config.
+	c.ResultCache.MaxEntries = 0
+	c.ResultCache.MaxResultSize = 1000
+
 	// Metric config.
 	c.Metric.Service = "none"
 	c.Metric.PollInterval = toml.Duration(0 * time.Minute)
@@ -32,12 +32,57 @@ import (
 
 	"github.com/pilosa/pilosa/v2"
 	"github.com/pilosa/pilosa/v2/boltdb"
+	"github.com/pilosa/pilosa/v2/encoding/arrow"
 	"github.com/pilosa/pilosa/v2/encoding/proto"
 	"github.com/pilosa/pilosa/v2/http"
 	"github.com/pilosa/pilosa/v2/server"
 	"github.com/pilosa/pilosa/v2/test"
 )
 
+// Ensure keyed records can be ingested through a node whose translate
+// stores are read only.
+func TestHandler_PostIngestCluster(t *testing.T) {
+	cluster := test.MustRunCluster(t, 3)
+	defer cluster.Close()
+	cluster[0].MustCreateIndex(t, "i", pilosa.IndexOptions{Keys: true})
+	cluster[0].MustCreateField(t, "i", "color", pilosa.OptFieldKeys())
+
+	var primary, replica *test.Command
+	for _, cmd := range cluster {
+		idx, err := cmd.API.Index(context.Background(), "i")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if idx.TranslateStore().ReadOnly() {
+			replica = cmd
+		} else {
+			primary = cmd
+		}
+	}
+	if primary == nil || replica == nil {
+		t.Fatal("expected primary and replica translate stores")
+	}
+
+	w := httptest.NewRecorder()
+	req := test.MustNewHTTPRequest("POST", "/index/i/ingest?column=id&set=color", strings.NewReader(`
+		{"id": "a", "color": "red"}
+		{"id": "b", "color": "red"}
+		{"id": "c", "color": "blue"}
+	`))
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	replica.Handler.(*http.Handler).Handler.ServeHTTP(w, req)
+	if w.Code != gohttp.StatusOK {
+		t.Fatalf("unexpected status code: %d %s", w.Code, w.Body.String())
+	}
+
+	res, err := primary.API.Query(context.Background(), &pilosa.QueryRequest{Index: "i", Query: `Row(color="red")`})
+	if err != nil {
+		t.Fatal(err)
+	} else if keys := res.Results[0].(*pilosa.Row).Keys; !reflect.DeepEqual(keys, []string{"a", "b"}) {
+		t.Fatalf("unexpected red columns: %v", keys)
+	}
+}
+
 func TestHandler_PostSchemaCluster(t *testing.T) {
 	cluster := test.MustRunCluster(t, 3)
 	defer cluster.Close()
@@ -77,6 +122,69 @@ func TestHandler_PostSchemaCluster(t *testing.T) {
 	})
 }
 
+// Ensure an index is exported from the nodes which own each shard.
+func TestHandler_IndexExportCluster(t *testing.T) {
+	cluster := test.MustRunCluster(t, 3)
+	defer cluster.Close()
+	cmd := cluster[0]
+	h := cmd.Handler.(*http.Handler).Handler
+
+	cmd.MustCreateIndex(t, "i-export", pilosa.IndexOptions{TrackExistence: true})
+	cmd.MustCreateField(t, "i-export", "color", pilosa.OptFieldKeys())
+	cmd.MustCreateField(t, "i-export", "tags")
+	cmd.MustCreateField(t, "i-export", "age", pilosa.OptFieldTypeInt(-100, 100))
+	cmd.MustCreateField(t, "i-export", "active", pilosa.OptFieldTypeBool())
+	cmd.MustCreateField(t, "i-export", "size", pilosa.OptFieldTypeMutex(pilosa.CacheTypeRanked, 100))
+	cmd.MustCreateField(t, "i-export", "visit", pilosa.OptFieldTypeTime("YMD"))
+	cmd.MustQuery(t, &pilosa.QueryRequest{Index: "i-export", Query: fmt.Sprintf(`
+		Set(1, color="red") Set(1, tags=1) Set(1, tags=2) Set(1, age=-5)
+		Set(1, visit=7, 2019-01-02T00:00) Set(1, visit=8)
+		Set(%d, active=true) Set(%d, size=4) Set(%d, age=30)
+	`, pilosa.ShardWidth+1, 2*pilosa.ShardWidth+3, 3*pilosa.ShardWidth)})
+
+	export := func(format string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, test.MustNewHTTPRequest("GET", "/index/i-export/export?format="+format, nil))
+		if w.Code != gohttp.StatusOK {
+			t.Fatalf("unexpected status code: %d %s", w.Code, w.Body.String())
+		}
+		return w
+	}
+
+	if body := export("csv").Body.String(); body != fmt.Sprintf(`_id,active,age,color,size,tags,visit,visit_timestamp
+1,,-5,red,,1,7,2019-01-02T00:00
+1,,,,,2,8,
+%d,true,,,,,,
+%d,,,,4,,,
+%d,,30,,,,,
+`, pilosa.ShardWidth+1, 2*pilosa.ShardWidth+3, 3*pilosa.ShardWidth) {
+		t.Fatalf("unexpected csv: %s", body)
+	}
+
+	if body := export("ndjson").Body.String(); body != fmt.Sprintf(`{"_id":1,"age":-5,"color":["red"],"tags":[1,2],"visit":[7,8],"visit_timestamp":["2019-01-02T00:00",null]}
+{"_id":%d,"active":true}
+{"_id":%d,"size":4}
+{"_id":%d,"age":30}
+`, pilosa.ShardWidth+1, 2*pilosa.ShardWidth+3, 3*pilosa.ShardWidth) {
+		t.Fatalf("unexpected ndjson: %s", body)
+	}
+
+	if body := export("parquet").Body.Bytes(); !bytes.HasPrefix(body, []byte("PAR1")) || !bytes.HasSuffix(body, []byte("PAR1")) {
+		t.Fatalf("unexpected parquet: %x", body)
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, test.MustNewHTTPRequest("GET", "/index/i-export/export?format=xml", nil))
+	if w.Code != gohttp.StatusBadRequest {
+		t.Fatalf("unexpected status code: %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, test.MustNewHTTPRequest("GET", "/index/missing/export", nil))
+	if w.Code != gohttp.StatusNotFound {
+		t.Fatalf("unexpected status code: %d", w.Code)
+	}
+}
+
 func TestHandler_Endpoints(t *testing.T) {
 	cluster := test.MustRunCluster(t, 1)
 	defer cluster.Close()
@@ -434,6 +542,136 @@ func TestHandler_Endpoints(t *testing.T) {
 		}
 	})
 
+	t.Run("Row arrow", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := test.MustNewHTTPRequest("POST", "/index/i0/query", strings.NewReader("Row(f0=30)"))
+		r.Header.Set("Accept", arrow.ContentType)
+		h.ServeHTTP(w, r)
+		if w.Code != gohttp.StatusOK {
+			t.Fatalf("unexpected status code: %d", w.Code)
+		} else if ct := w.Header().Get("Content-Type"); ct != arrow.ContentType {
+			t.Fatalf("unexpected content type: %s", ct)
+		}
+
+		// The golden file was verified with the Apache Arrow Go reader.
+		exp, err := ioutil.ReadFile("../encoding/arrow/testdata/RowIDsShards.arrow")
+		if err != nil {
+			t.Fatal(err)
+		} else if !bytes.Equal(w.Body.Bytes(), exp) {
+			t.Fatalf("unexpected body: %x", w.Body.Bytes())
+		}
+	})
+
+	t.Run("Count arrow", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := test.MustNewHTTPRequest("POST", "/index/i0/query", strings.NewReader("Count(Row(f0=30))"))
+		r.Header.Set("Accept", arrow.ContentType)
+		h.ServeHTTP(w, r)
+		if w.Code != gohttp.StatusNotAcceptable {
+			t.Fatalf("unexpected status code: %d", w.Code)
+		}
+	})
+
+	t.Run("SQL", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, test.MustNewHTTPRequest("POST", "/sql", strings.NewReader("SELECT COUNT(*) FROM i0 WHERE f0 = 30")))
+		if w.Code != gohttp.StatusOK {
+			t.Fatalf("unexpected status code: %d %s", w.Code, w.Body.String())
+		} else if body := w.Body.String(); body != `{"columns":["count(*)"],"rows":[[3]]}`+"\n" {
+			t.Fatalf("unexpected body: %q", body)
+		}
+	})
+
+	t.Run("SQL unsupported", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, test.MustNewHTTPRequest("POST", "/sql", strings.NewReader("SELECT COUNT(*) FROM i0 ORDER BY f0")))
+		if w.Code != gohttp.StatusBadRequest {
+			t.Fatalf("unexpected status code: %d", w.Code)
+		} else if !strings.Contains(w.Body.String(), "ORDER BY is not supported") {
+			t.Fatalf("unexpected body: %q", w.Body.String())
+		}
+	})
+
+	t.Run("DeleteColumn", func(t *testing.T) {
+		hldr.SetBit("i-del", "f", 1, 10)
+		hldr.SetBit("i-del", "f", 1, 20)
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, test.MustNewHTTPRequest("DELETE", "/index/i-del/column/10?attrs=true", nil))
+		if w.Code != gohttp.StatusOK {
+			t.Fatalf("unexpected status code: %d %s", w.Code, w.Body.String())
+		} else if bits := hldr.Row("i-del", "f", 1).Columns(); !reflect.DeepEqual(bits, []uint64{20}) {
+			t.Fatalf("unexpected columns: %v", bits)
+		}
+
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, test.MustNewHTTPRequest("DELETE", "/index/i-del/column/x", nil))
+		if w.Code != gohttp.StatusBadRequest {
+			t.Fatalf("unexpected status code: %d", w.Code)
+		}
+	})
+
+	t.Run("Ingest", func(t *testing.T) {
+		cmd.MustCreateIndex(t, "i-ingest", pilosa.IndexOptions{Keys: true, TrackExistence: true})
+		cmd.MustCreateField(t, "i-ingest", "color", pilosa.OptFieldKeys())
+		cmd.MustCreateField(t, "i-ingest", "tags")
+		cmd.MustCreateField(t, "i-ingest", "age", pilosa.OptFieldTypeInt(0, 200))
+		cmd.MustCreateField(t, "i-ingest", "active", pilosa.OptFieldTypeBool())
+		cmd.MustCreateField(t, "i-ingest", "visit", pilosa.OptFieldTypeTime("YMD"))
+
+		ingest := func(contentType, args, body string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			req := test.MustNewHTTPRequest("POST", "/index/i-ingest/ingest?"+args, strings.NewReader(body))
+			req.Header.Set("Content-Type", contentType)
+			h.ServeHTTP(w, req)
+			return w
+		}
+		query := func(q string) interface{} {
+			res, err := cmd.API.Query(context.Background(), &pilosa.QueryRequest{Index: "i-ingest", Query: q})
+			if err != nil {
+				t.Fatal(err)
+			}
+			return res.Results[0]
+		}
+
+		w := ingest("application/x-ndjson", "column=id&set=color,tags,active&int=age&time=visit:at", `
+			{"id": "a", "color": "red", "tags": [1, 2], "age": 30, "active": true, "visit": 1, "at": "2019-01-02T00:00:00Z"}
+			{"id": "b", "color": "blue", "tags": 2, "active": false, "visit": [1, 2], "at": "2019-02-03T04:05"}
+		`)
+		if w.Code != gohttp.StatusOK {
+			t.Fatalf("unexpected status code: %d %s", w.Code, w.Body.String())
+		}
+		w = ingest("text/csv", "column=id&set=color&int=age", "id,color,age\nc,red,40\nb,,25\n")
+		if w.Code != gohttp.StatusOK {
+			t.Fatalf("unexpected status code: %d %s", w.Code, w.Body.String())
+		}
+
+		if keys := query(`Row(color="red")`).(*pilosa.Row).Keys; !reflect.DeepEqual(keys, []string{"a", "c"}) {
+			t.Fatalf("unexpected red columns: %v", keys)
+		} else if n := query(`Count(Row(tags=2))`).(uint64); n != 2 {
+			t.Fatalf("unexpected tags count: %d", n)
+		} else if vc := query(`Sum(field=age)`).(pilosa.ValCount); vc != (pilosa.ValCount{Val: 95, Count: 3}) {
+			t.Fatalf("unexpected age sum: %+v", vc)
+		} else if keys := query(`Row(active=false)`).(*pilosa.Row).Keys; !reflect.DeepEqual(keys, []string{"b"}) {
+			t.Fatalf("unexpected inactive columns: %v", keys)
+		} else if keys := query(`Row(visit=1, from="2019-02-01T00:00", to="2019-03-01T00:00")`).(*pilosa.Row).Keys; !reflect.DeepEqual(keys, []string{"b"}) {
+			t.Fatalf("unexpected visit columns: %v", keys)
+		}
+
+		w = ingest("text/csv", "column=id&int=tags", "id,tags\na,1\n")
+		if w.Code != gohttp.StatusBadRequest {
+			t.Fatalf("unexpected status code: %d %s", w.Code, w.Body.String())
+		}
+		w = ingest("application/x-ndjson", "column=id&set=tags", `{"id": "a", "tags": "x"}`)
+		if w.Code != gohttp.StatusBadRequest || !strings.Contains(w.Body.String(), "record 0") {
+			t.Fatalf("unexpected response: %d %s", w.Code, w.Body.String())
+		}
+		w = ingest("application/x-ndjson", "column=id&set=visit&time=visit:at", `{"id": "a", "visit": 1}`)
+		if w.Code != gohttp.StatusBadRequest || !strings.Contains(w.Body.String(), "more than once") {
+			t.Fatalf("unexpected response: %d %s", w.Code, w.Body.String())
+		}
+	})
+
 	t.Run("Row columnattrs protobuf", func(t *testing.T) {
 		// Encode request body.
 		buf, err := cmd.API.Serializer.Marshal(&pilosa.QueryRequest{
@@ -44,6 +44,7 @@ import (
 	"github.com/pilosa/pilosa/v2/gossip"
 	"github.com/pilosa/pilosa/v2/http"
 	"github.com/pilosa/pilosa/v2/logger"
+	"github.com/pilosa/pilosa/v2/postgres"
 	"github.com/pilosa/pilosa/v2/prometheus"
 	"github.com/pilosa/pilosa/v2/stats"
 	"github.com/pilosa/pilosa/v2/statsd"
@@ -85,6 +86,9 @@ type Command struct {
 	listenURI    *pilosa.URI
 	closeTimeout time.Duration
 
+	// postgres serves the PostgreSQL wire protocol if enabled.
+	postgres *postgres.Server
+
 	serverOptions []pilosa.ServerOption
 }
 
@@ -164,6 +168,15 @@ func (m *Command) Start() (err error) {
 
 	m.logger.Printf("listening as %s\n", m.listenURI)
 
+	if m.postgres != nil {
+		go func() {
+			if err := m.postgres.Serve(); err != nil {
+				m.logger.Printf("postgres serve error: %v", err)
+			}
+		}()
+		m.logger.Printf("accepting postgres connections on %s\n", m.postgres.Addr())
+	}
+
 	close(m.Started)
 	return nil
 }
@@ -307,13 +320,19 @@ func (m *Command) SetupServer() error {
 
 	serverOptions := []pilosa.ServerOption{
 		pilosa.OptServerAntiEntropyInterval(time.Duration(m.Config.AntiEntropy.Interval)),
+		pilosa.OptServerHintedHandoffInterval(time.Duration(m.Config.HintedHandoff.Interval)),
+		pilosa.OptServerWriteConsistency(pilosa.WriteConsistency(m.Config.Cluster.WriteConsistency)),
 		pilosa.OptServerLongQueryTime(time.Duration(m.Config.Cluster.LongQueryTime)),
 		pilosa.OptServerDataDir(m.Config.DataDir),
 		pilosa.OptServerReplicaN(m.Config.Cluster.ReplicaN),
 		pilosa.OptServerMaxWritesPerRequest(m.Config.MaxWritesPerRequest),
+		pilosa.OptServerQuarantineCorruptFragments(m.Config.QuarantineCorruptFragments),
+		pilosa.OptServerStorageChecksums(m.Config.Storage.Checksums),
+		pilosa.OptServerStorageColdAfter(time.Duration(m.Config.Storage.ColdAfter)),
 		pilosa.OptServerMetricInterval(time.Duration(m.Config.Metric.PollInterval)),
 		pilosa.OptServerDiagnosticsInterval(diagnosticsInterval),
 		pilosa.OptServerExecutorPoolSize(m.Config.WorkerPoolSize),
+		pilosa.OptServerResultCache(m.Config.ResultCache.MaxEntries, m.Config.ResultCache.MaxResultSize),
 		pilosa.OptServerOpenTranslateStore(boltdb.OpenTranslateStore),
 		pilosa.OptServerOpenTranslateReader(http.GetOpenTranslateReaderFunc(c)),
 		pilosa.OptServerLogger(m.logger),
@@ -351,7 +370,32 @@ func (m *Command) SetupServer() error {
 		http.OptHandlerListener(m.ln),
 		http.OptHandlerCloseTimeout(m.closeTimeout),
 	)
-	return errors.Wrap(err, "new handler")
+	if err != nil {
+		return errors.Wrap(err, "new handler")
+	}
+
+	// The postgres listener is opened last, so that no later step can fail
+	// and leave it open.
+	if m.Config.Postgres.Bind != "" {
+		if TLSConfig == nil && !m.Config.Postgres.Insecure {
+			return errors.New("postgres listener requires TLS; configure the server to serve https or set postgres.insecure")
+		}
+		ln, err := net.Listen("tcp", m.Config.Postgres.Bind)
+		if err != nil {
+			return errors.Wrap(err, "getting postgres listener")
+		}
+		m.postgres, err = postgres.NewServer(
+			postgres.OptServerAPI(m.API),
+			postgres.OptServerLogger(m.logger),
+			postgres.OptServerListener(ln),
+			postgres.OptServerTLSConfig(TLSConfig),
+		)
+		if err != nil {
+			ln.Close()
+			return errors.Wrap(err, "new postgres server")
+		}
+	}
+	return nil
 }
 
 // setupNetworking sets up internode communication based on the configuration.
@@ -404,6 +448,9 @@ func (m *Command) Close() error {
 	if m.gossipMemberSet != nil {
 		eg.Go(m.gossipMemberSet.Close)
 	}
+	if m.postgres != nil {
+		eg.Go(m.postgres.Close)
+	}
 	if closer, ok := m.logOutput.(io.Closer); ok {
 		// If closer is os.Stdout or os.Stderr, don't close it.
 		if closer != os.Stdout && closer != os.Stderr {
@@ -300,6 +300,27 @@ func TestMain_GroupBy(t *testing.T) {
 	}
 }
 
+// Ensure the postgres listener only accepts unencrypted connections if
+// explicitly allowed.
+func TestMain_PostgresInsecure(t *testing.T) {
+	conf := server.NewConfig()
+	conf.Postgres.Bind = "localhost:0"
+	m := test.NewCommandNode(true, server.OptCommandConfig(conf))
+	if err := m.Start(); err == nil || !strings.Contains(err.Error(), "postgres listener requires TLS") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	m.Close()
+
+	conf = server.NewConfig()
+	conf.Postgres.Bind = "localhost:0"
+	conf.Postgres.Insecure = true
+	m = test.NewCommandNode(true, server.OptCommandConfig(conf))
+	if err := m.Start(); err != nil {
+		t.Fatal(err)
+	}
+	m.Close()
+}
+
 // Ensure the host can be parsed.
 func TestConfig_Parse_Host(t *testing.T) {
 	if c, err := ParseConfig(`bind = "local"`); err != nil {
@@ -0,0 +1,162 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pilosa
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pilosa/pilosa/v2/roaring"
+)
+
+// querySnapshot pins a read-only copy of the fragments read by a query, so
+// that the query sees each shard as it was at a single instant.
+//
+// The first time a query reads from a shard, every fragment of the index in
+// that shard is copied while all of them are locked. The copies share their
+// roaring containers with the live fragments, which are frozen so that
+// later writes copy the containers they change instead of modifying them.
+type querySnapshot struct {
+	holder *Holder
+	index  string
+
+	mu     sync.Mutex
+	shards map[uint64]map[fragmentKey]*fragment
+}
+
+// fragmentKey identifies a fragment within a shard.
+type fragmentKey struct {
+	field string
+	view  string
+}
+
+// newQuerySnapshot returns a snapshot of index which pins shards as they
+// are read.
+func newQuerySnapshot(holder *Holder, index string) *querySnapshot {
+	return &querySnapshot{
+		holder: holder,
+		index:  index,
+		shards: make(map[uint64]map[fragmentKey]*fragment),
+	}
+}
+
+// fragment returns the pinned copy of a fragment, or nil if the fragment
+// didn't exist when its shard was pinned.
+func (s *querySnapshot) fragment(field, view string, shard uint64) *fragment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	frags, ok := s.shards[shard]
+	if !ok {
+		frags = s.pin(shard)
+		s.shards[shard] = frags
+	}
+	return frags[fragmentKey{field: field, view: view}]
+}
+
+// pin copies every fragment of the index in shard. The fragments are locked
+// in path order, as in lockFragments, so that a write which spans several of
+// them is either seen entirely or not at all.
+func (s *querySnapshot) pin(shard uint64) map[fragmentKey]*fragment {
+	idx := s.holder.Index(s.index)
+	if idx == nil {
+		return nil
+	}
+
+	var live []*fragment
+	for _, field := range idx.Fields() {
+		for _, view := range field.views() {
+			if frag := view.Fragment(shard); frag != nil {
+				live = append(live, frag)
+			}
+		}
+	}
+
+	_, unlock := lockFragmentsNoReopen(live)
+	defer unlock()
+	frags := make(map[fragmentKey]*fragment, len(live))
+	for _, frag := range live {
+		if frag.storage != nil {
+			frags[fragmentKey{field: frag.field, view: frag.view}] = frag.unprotectedFrozenCopy()
+		}
+	}
+	return frags
+}
+
+// unprotectedFrozenCopy returns a read-only copy of the fragment which
+// shares its storage containers. Writes to either fragment after the copy
+// is made are not seen by the other. f.mu must be held for writing, since
+// freezing the storage marks the live containers. If the storage of a cold
+// fragment can't be loaded, every read of the copy returns the error.
+func (f *fragment) unprotectedFrozenCopy() *fragment {
+	other := newFragment(f.path, f.index, f.field, f.view, f.shard, f.flags)
+	if err := f.load(); err != nil {
+		other.storage = roaring.NewBitmap()
+		other.cache = globalNopCache
+		other.rowCache = &simpleCache{make(map[uint64]*Row)}
+		other.Logger = f.Logger
+		other.unloaded, other.loadErr = true, err
+		return other
+	}
+	other.storage = f.storage.Freeze()
+	other.CacheType = f.CacheType
+	other.CacheSize = f.CacheSize
+	other.maxRowID = f.maxRowID
+	other.rowCache = &simpleCache{make(map[uint64]*Row)}
+	other.Logger = f.Logger
+	other.RowAttrStore = f.RowAttrStore
+	other.stats = f.stats
+	other.version = f.currentVersion()
+
+	// Copy the row counts, which are kept in step with the storage.
+	switch f.CacheType {
+	case CacheTypeRanked:
+		other.cache = NewRankCache(f.CacheSize)
+	case CacheTypeLRU:
+		other.cache = newLRUCache(f.CacheSize)
+	default:
+		other.cache = globalNopCache
+	}
+	if other.cache != globalNopCache {
+		for _, id := range f.cache.IDs() {
+			other.cache.BulkAdd(id, f.cache.Get(id))
+		}
+		other.cache.Recalculate()
+	}
+	return other
+}
+
+// querySnapshotKey is the context key of a query's snapshot.
+type querySnapshotKey struct{}
+
+// withQuerySnapshot returns a context which reads from s.
+func withQuerySnapshot(ctx context.Context, s *querySnapshot) context.Context {
+	return context.WithValue(ctx, querySnapshotKey{}, s)
+}
+
+// querySnapshotFromContext returns the snapshot a query reads from, if any.
+func querySnapshotFromContext(ctx context.Context) *querySnapshot {
+	s, _ := ctx.Value(querySnapshotKey{}).(*querySnapshot)
+	return s
+}
+
+// fragment returns a fragment for a query to read from. If the query reads
+// from a snapshot, the pinned copy of the fragment is returned.
+func (e *executor) fragment(ctx context.Context, index, field, view string, shard uint64) *fragment {
+	if s := querySnapshotFromContext(ctx); s != nil && s.index == index {
+		return s.fragment(field, view, shard)
+	}
+	return e.Holder.fragment(index, field, view, shard)
+}
@@ -0,0 +1,79 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pilosa
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Ensure a snapshot doesn't see writes made after its shard was pinned.
+func TestQuerySnapshot(t *testing.T) {
+	h := newHolder()
+	if err := h.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	h.SetBit("i", "f", 1, 100)
+	h.SetBit("i", "f", 1, 200)
+	h.SetBit("i", "g", 2, 100)
+
+	s := newQuerySnapshot(h.Holder, "i")
+	f := s.fragment("f", viewStandard, 0)
+	if f == nil {
+		t.Fatal("expected pinned fragment")
+	}
+
+	// Writes after the shard is pinned, to a pinned fragment, to another
+	// field in the same shard, and to a new shard.
+	h.SetBit("i", "f", 1, 300)
+	h.SetBit("i", "f", 3, 100)
+	if _, err := h.Field("i", "g").ClearBit(2, 100); err != nil {
+		t.Fatal(err)
+	}
+	h.SetBit("i", "h", 1, 100)
+	h.SetBit("i", "f", 1, ShardWidth)
+
+	if cols := f.row(1).Columns(); !reflect.DeepEqual(cols, []uint64{100, 200}) {
+		t.Fatalf("unexpected row: %v", cols)
+	} else if cols := f.row(3).Columns(); len(cols) != 0 {
+		t.Fatalf("unexpected row: %v", cols)
+	} else if pairs, err := f.top(topOptions{}); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(pairs, []Pair{{ID: 1, Count: 2}}) {
+		t.Fatalf("unexpected top: %v", pairs)
+	}
+	if g := s.fragment("g", viewStandard, 0); g == nil {
+		t.Fatal("expected pinned fragment")
+	} else if cols := g.row(2).Columns(); !reflect.DeepEqual(cols, []uint64{100}) {
+		t.Fatalf("unexpected row: %v", cols)
+	}
+	if s.fragment("h", viewStandard, 0) != nil {
+		t.Fatal("expected no fragment for field created after pin")
+	}
+
+	// A shard is pinned when it is first read.
+	if f1 := s.fragment("f", viewStandard, 1); f1 == nil {
+		t.Fatal("expected pinned fragment")
+	} else if cols := f1.row(1).Columns(); !reflect.DeepEqual(cols, []uint64{ShardWidth}) {
+		t.Fatalf("unexpected row: %v", cols)
+	}
+
+	// The live fragment sees every write.
+	if cols := h.Row("i", "f", 1).Columns(); !reflect.DeepEqual(cols, []uint64{100, 200, 300, ShardWidth}) {
+		t.Fatalf("unexpected live row: %v", cols)
+	}
+}
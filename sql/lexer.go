@@ -0,0 +1,160 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sql
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type tokenType int
+
+const (
+	tokEOF tokenType = iota
+	tokIdent
+	tokQuotedIdent
+	tokNumber
+	tokString
+	tokComma
+	tokSemicolon
+	tokLParen
+	tokRParen
+	tokStar
+	tokEQ
+	tokNEQ
+	tokLT
+	tokLTE
+	tokGT
+	tokGTE
+)
+
+// token is a lexical token of a SQL statement.
+type token struct {
+	typ tokenType
+	val string
+	pos int
+}
+
+// String returns a description of the token for use in errors.
+func (t token) String() string {
+	switch t.typ {
+	case tokEOF:
+		return "end of statement"
+	case tokString:
+		return fmt.Sprintf("'%s'", t.val)
+	case tokQuotedIdent:
+		return fmt.Sprintf("%q", t.val)
+	default:
+		return fmt.Sprintf("%q", t.val)
+	}
+}
+
+// lex splits s into tokens, ending with an EOF token.
+func lex(s string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(s); {
+		ch := s[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			i++
+
+		case isIdentStart(ch):
+			start := i
+			for i < len(s) && isIdentChar(s[i]) {
+				i++
+			}
+			toks = append(toks, token{typ: tokIdent, val: s[start:i], pos: start})
+
+		case isDigit(ch) || (ch == '-' && i+1 < len(s) && isDigit(s[i+1])):
+			start := i
+			i++
+			for i < len(s) && isDigit(s[i]) {
+				i++
+			}
+			if i < len(s) && s[i] == '.' {
+				return nil, errors.Errorf("decimal numbers are not supported at position %d", start)
+			}
+			toks = append(toks, token{typ: tokNumber, val: s[start:i], pos: start})
+
+		case ch == '\'' || ch == '"':
+			// Strings are single quoted and identifiers double quoted. A
+			// quote is escaped by doubling it.
+			start := i
+			var buf strings.Builder
+			for i++; ; i++ {
+				if i >= len(s) {
+					return nil, errors.Errorf("unterminated quote at position %d", start)
+				}
+				if s[i] == ch {
+					if i+1 < len(s) && s[i+1] == ch {
+						buf.WriteByte(ch)
+						i++
+						continue
+					}
+					i++
+					break
+				}
+				buf.WriteByte(s[i])
+			}
+			typ := tokString
+			if ch == '"' {
+				typ = tokQuotedIdent
+			}
+			toks = append(toks, token{typ: typ, val: buf.String(), pos: start})
+
+		default:
+			typ, n := tokEOF, 1
+			switch {
+			case ch == ',':
+				typ = tokComma
+			case ch == ';':
+				typ = tokSemicolon
+			case ch == '(':
+				typ = tokLParen
+			case ch == ')':
+				typ = tokRParen
+			case ch == '*':
+				typ = tokStar
+			case ch == '=':
+				typ = tokEQ
+			case strings.HasPrefix(s[i:], "!="), strings.HasPrefix(s[i:], "<>"):
+				typ, n = tokNEQ, 2
+			case strings.HasPrefix(s[i:], "<="):
+				typ, n = tokLTE, 2
+			case strings.HasPrefix(s[i:], ">="):
+				typ, n = tokGTE, 2
+			case ch == '<':
+				typ = tokLT
+			case ch == '>':
+				typ = tokGT
+			default:
+				return nil, errors.Errorf("unexpected character %q at position %d", ch, i)
+			}
+			toks = append(toks, token{typ: typ, val: s[i : i+n], pos: i})
+			i += n
+		}
+	}
+	return append(toks, token{typ: tokEOF, pos: len(s)}), nil
+}
+
+func isDigit(ch byte) bool { return ch >= '0' && ch <= '9' }
+
+func isIdentStart(ch byte) bool {
+	return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || ch == '_'
+}
+
+func isIdentChar(ch byte) bool { return isIdentStart(ch) || isDigit(ch) }
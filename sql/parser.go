@@ -0,0 +1,347 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sql implements a small subset of SQL which is translated into PQL.
+//
+// Supported statements have the form:
+//
+//	SELECT <select list> FROM <index>
+//	    [WHERE <condition> [AND <condition> ...]]
+//	    [GROUP BY <field> [, <field> ...]]
+//	    [LIMIT <n>]
+//
+// where the select list holds COUNT(*), SUM(field), MIN(field), MAX(field),
+// grouped fields, or _id, and each condition compares a field to a literal.
+package sql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pilosa/pilosa/v2/pql"
+	"github.com/pkg/errors"
+)
+
+// Statement is a parsed SELECT statement.
+type Statement struct {
+	Index   string
+	Select  []SelectItem
+	Where   []Condition
+	GroupBy []string
+
+	// Limit is the maximum number of rows to return. Zero means no limit.
+	Limit uint64
+}
+
+// SelectItem is a single item of a select list. Func is empty for a plain
+// field, otherwise it is one of COUNT, SUM, MIN, or MAX.
+type SelectItem struct {
+	Func  string
+	Field string
+}
+
+// String returns the SQL representation of the item, which is also used as
+// the name of the corresponding result column.
+func (i SelectItem) String() string {
+	if i.Func == "" {
+		return i.Field
+	}
+	return fmt.Sprintf("%s(%s)", strings.ToLower(i.Func), i.Field)
+}
+
+// Condition compares a field to a literal value. Value is an int64, string,
+// or bool.
+type Condition struct {
+	Field string
+	Op    pql.Token
+	Value interface{}
+}
+
+// Parse parses a single SELECT statement.
+func Parse(s string) (*Statement, error) {
+	toks, err := lex(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	stmt, err := p.parseStatement()
+	if err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
+// unsupportedKeywords are recognized only to give a clear error.
+var unsupportedKeywords = map[string]string{
+	"OR":       "OR conditions are not supported; conditions may only be combined with AND",
+	"NOT":      "NOT is not supported",
+	"IN":       "IN is not supported",
+	"BETWEEN":  "BETWEEN is not supported; use >= and <=",
+	"LIKE":     "LIKE is not supported",
+	"IS":       "IS NULL is not supported",
+	"JOIN":     "JOIN is not supported",
+	"ORDER":    "ORDER BY is not supported",
+	"HAVING":   "HAVING is not supported",
+	"OFFSET":   "OFFSET is not supported",
+	"DISTINCT": "DISTINCT is not supported",
+	"UNION":    "UNION is not supported",
+	"AS":       "column aliases are not supported",
+}
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+func (p *parser) peek() token { return p.toks[p.pos] }
+
+func (p *parser) next() token {
+	tok := p.toks[p.pos]
+	if tok.typ != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// unexpected returns an error describing tok, which was found where
+// something else was expected.
+func (p *parser) unexpected(tok token, expected string) error {
+	if tok.typ == tokIdent {
+		if msg, ok := unsupportedKeywords[strings.ToUpper(tok.val)]; ok {
+			return errors.New(msg)
+		}
+	}
+	return errors.Errorf("expected %s, found %s at position %d", expected, tok, tok.pos)
+}
+
+// keyword consumes the next token if it is the keyword kw.
+func (p *parser) keyword(kw string) bool {
+	if tok := p.peek(); tok.typ == tokIdent && strings.EqualFold(tok.val, kw) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectKeyword(kw string) error {
+	if !p.keyword(kw) {
+		return p.unexpected(p.peek(), kw)
+	}
+	return nil
+}
+
+func (p *parser) expect(typ tokenType, expected string) (token, error) {
+	tok := p.next()
+	if tok.typ != typ {
+		return tok, p.unexpected(tok, expected)
+	}
+	return tok, nil
+}
+
+// ident parses an identifier, either bare or double quoted.
+func (p *parser) ident(expected string) (string, error) {
+	tok := p.next()
+	if tok.typ != tokIdent && tok.typ != tokQuotedIdent {
+		return "", p.unexpected(tok, expected)
+	}
+	if tok.typ == tokIdent && isReserved(tok.val) {
+		return "", p.unexpected(tok, expected)
+	}
+	return tok.val, nil
+}
+
+func (p *parser) parseStatement() (*Statement, error) {
+	stmt := &Statement{}
+	if err := p.expectKeyword("SELECT"); err != nil {
+		return nil, err
+	}
+	for {
+		item, err := p.parseSelectItem()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Select = append(stmt.Select, item)
+		if p.peek().typ != tokComma {
+			break
+		}
+		p.next()
+	}
+
+	if err := p.expectKeyword("FROM"); err != nil {
+		return nil, err
+	}
+	index, err := p.ident("index name")
+	if err != nil {
+		return nil, err
+	}
+	stmt.Index = index
+
+	if p.keyword("WHERE") {
+		for {
+			cond, err := p.parseCondition()
+			if err != nil {
+				return nil, err
+			}
+			stmt.Where = append(stmt.Where, cond)
+			if !p.keyword("AND") {
+				break
+			}
+		}
+	}
+
+	if p.keyword("GROUP") {
+		if err := p.expectKeyword("BY"); err != nil {
+			return nil, err
+		}
+		for {
+			field, err := p.ident("field name")
+			if err != nil {
+				return nil, err
+			}
+			stmt.GroupBy = append(stmt.GroupBy, field)
+			if p.peek().typ != tokComma {
+				break
+			}
+			p.next()
+		}
+	}
+
+	if p.keyword("LIMIT") {
+		tok, err := p.expect(tokNumber, "limit")
+		if err != nil {
+			return nil, err
+		}
+		n, err := strconv.ParseUint(tok.val, 10, 64)
+		if err != nil {
+			return nil, errors.Errorf("invalid limit: %s", tok.val)
+		}
+		stmt.Limit = n
+	}
+
+	if p.peek().typ == tokSemicolon {
+		p.next()
+	}
+	if tok := p.peek(); tok.typ != tokEOF {
+		return nil, p.unexpected(tok, "end of statement")
+	}
+	return stmt, nil
+}
+
+func (p *parser) parseSelectItem() (SelectItem, error) {
+	tok := p.peek()
+	if tok.typ == tokStar {
+		return SelectItem{}, errors.New("SELECT * is not supported; select COUNT(*), aggregates, grouped fields, or _id")
+	}
+	if tok.typ == tokIdent {
+		switch fn := strings.ToUpper(tok.val); fn {
+		case "COUNT", "SUM", "MIN", "MAX":
+			p.next()
+			if _, err := p.expect(tokLParen, "("); err != nil {
+				return SelectItem{}, err
+			}
+			var item SelectItem
+			if fn == "COUNT" {
+				if _, err := p.expect(tokStar, "*"); err != nil {
+					return SelectItem{}, errors.New("only COUNT(*) is supported")
+				}
+				item = SelectItem{Func: fn, Field: "*"}
+			} else {
+				field, err := p.ident("field name")
+				if err != nil {
+					return SelectItem{}, err
+				}
+				item = SelectItem{Func: fn, Field: field}
+			}
+			if _, err := p.expect(tokRParen, ")"); err != nil {
+				return SelectItem{}, err
+			}
+			return item, nil
+		case "AVG":
+			return SelectItem{}, errors.New("AVG is not supported; select SUM and COUNT(*)")
+		}
+	}
+	field, err := p.ident("select item")
+	if err != nil {
+		return SelectItem{}, err
+	}
+	return SelectItem{Field: field}, nil
+}
+
+func (p *parser) parseCondition() (Condition, error) {
+	field, err := p.ident("field name")
+	if err != nil {
+		return Condition{}, err
+	}
+
+	var op pql.Token
+	switch tok := p.next(); tok.typ {
+	case tokEQ:
+		op = pql.EQ
+	case tokNEQ:
+		op = pql.NEQ
+	case tokLT:
+		op = pql.LT
+	case tokLTE:
+		op = pql.LTE
+	case tokGT:
+		op = pql.GT
+	case tokGTE:
+		op = pql.GTE
+	default:
+		return Condition{}, p.unexpected(tok, "comparison operator")
+	}
+
+	var value interface{}
+	switch tok := p.next(); tok.typ {
+	case tokNumber:
+		n, err := strconv.ParseInt(tok.val, 10, 64)
+		if err != nil {
+			return Condition{}, errors.Errorf("invalid integer: %s", tok.val)
+		}
+		value = n
+	case tokString:
+		value = tok.val
+	case tokIdent:
+		switch strings.ToUpper(tok.val) {
+		case "TRUE":
+			value = true
+		case "FALSE":
+			value = false
+		case "NULL":
+			return Condition{}, errors.New("NULL comparisons are not supported")
+		default:
+			return Condition{}, errors.Errorf("comparisons between fields are not supported: %s", tok.val)
+		}
+	default:
+		return Condition{}, p.unexpected(tok, "literal value")
+	}
+
+	return Condition{Field: field, Op: op, Value: value}, nil
+}
+
+// reserved words may not be used as bare identifiers.
+var reserved = map[string]struct{}{
+	"SELECT": {}, "FROM": {}, "WHERE": {}, "AND": {}, "GROUP": {}, "BY": {},
+	"LIMIT": {}, "TRUE": {}, "FALSE": {}, "NULL": {},
+}
+
+func isReserved(s string) bool {
+	s = strings.ToUpper(s)
+	if _, ok := reserved[s]; ok {
+		return true
+	}
+	_, ok := unsupportedKeywords[s]
+	return ok
+}
@@ -0,0 +1,143 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sql_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pilosa/pilosa/v2/sql"
+)
+
+var testSchema = sql.Schema{
+	Fields: map[string]string{
+		"color":   "set",
+		"age":     "int",
+		"active":  "bool",
+		"region":  "mutex",
+		"my-keys": "set",
+	},
+	TrackExistence: true,
+}
+
+func TestTranslate(t *testing.T) {
+	tests := []struct {
+		sql  string
+		typ  sql.PlanType
+		pql  string
+		cols string
+	}{
+		{
+			sql:  "SELECT COUNT(*) FROM i",
+			typ:  sql.PlanAggregate,
+			pql:  "Count(Not(Union()))",
+			cols: "count(*)",
+		},
+		{
+			sql:  "select Count(*), SUM(age) from i where color = 1 and age > -5;",
+			typ:  sql.PlanAggregate,
+			pql:  "Count(Intersect(Row(color=1), Row(age > -5)))\nSum(Intersect(Row(color=1), Row(age > -5)), field=\"age\")",
+			cols: "count(*),sum(age)",
+		},
+		{
+			sql:  `SELECT MIN(age), MAX(age) FROM "my-index" WHERE "my-keys" = 'it''s'`,
+			typ:  sql.PlanAggregate,
+			pql:  "Min(Row(my-keys=\"it's\"), field=\"age\")\nMax(Row(my-keys=\"it's\"), field=\"age\")",
+			cols: "min(age),max(age)",
+		},
+		{
+			sql:  "SELECT _id FROM i WHERE color <> 2 AND active = false",
+			typ:  sql.PlanColumns,
+			pql:  "Intersect(Not(Row(color=2)), Row(active=false))",
+			cols: "_id",
+		},
+		{
+			sql:  "SELECT color, COUNT(*), region FROM i WHERE age <= 10 GROUP BY region, color LIMIT 5",
+			typ:  sql.PlanGroupBy,
+			pql:  "GroupBy(Rows(_field=\"region\"), Rows(_field=\"color\"), filter=Row(age <= 10), limit=5)",
+			cols: "color,count(*),region",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.sql, func(t *testing.T) {
+			stmt, err := sql.Parse(test.sql)
+			if err != nil {
+				t.Fatal(err)
+			}
+			plan, err := sql.Translate(stmt, testSchema)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if plan.Type != test.typ {
+				t.Fatalf("unexpected plan type: %v", plan.Type)
+			} else if s := plan.Query.String(); s != test.pql {
+				t.Fatalf("unexpected pql:\n%s", s)
+			} else if s := strings.Join(plan.Columns, ","); s != test.cols {
+				t.Fatalf("unexpected columns: %s", s)
+			}
+		})
+	}
+}
+
+func TestTranslate_Errors(t *testing.T) {
+	tests := []struct {
+		sql    string
+		schema *sql.Schema
+		err    string
+	}{
+		{sql: "SELECT * FROM i", err: "SELECT * is not supported"},
+		{sql: "SELECT COUNT(color) FROM i", err: "only COUNT(*) is supported"},
+		{sql: "SELECT AVG(age) FROM i", err: "AVG is not supported"},
+		{sql: "SELECT COUNT(*) FROM i WHERE color = 1 OR color = 2", err: "OR conditions are not supported"},
+		{sql: "SELECT COUNT(*) FROM i ORDER BY color", err: "ORDER BY is not supported"},
+		{sql: "SELECT COUNT(*) FROM i JOIN j", err: "JOIN is not supported"},
+		{sql: "SELECT COUNT(*) FROM i WHERE color IN (1, 2)", err: "IN is not supported"},
+		{sql: "SELECT COUNT(*) FROM i WHERE age = 1.5", err: "decimal numbers are not supported"},
+		{sql: "SELECT COUNT(*) FROM i WHERE color = age", err: "comparisons between fields are not supported"},
+		{sql: "SELECT COUNT(*) FROM i WHERE color = 'x", err: "unterminated quote"},
+		{sql: "SELECT COUNT(*) FROM i LIMIT 1 1", err: "expected end of statement"},
+		{sql: "SELECT COUNT(*) i", err: "expected FROM"},
+		{sql: "DELETE FROM i", err: "expected SELECT"},
+		{sql: "SELECT COUNT(*) FROM i WHERE nope = 1", err: "field not found: nope"},
+		{sql: "SELECT COUNT(*) FROM i WHERE color > 1", err: "set field color only supports = and !="},
+		{sql: "SELECT COUNT(*) FROM i WHERE age = 'x'", err: "int field age can only be compared to integers"},
+		{sql: "SELECT COUNT(*) FROM i WHERE active = 1", err: "bool field active can only be compared to TRUE or FALSE"},
+		{sql: "SELECT COUNT(*) FROM i WHERE _id = 1", err: "conditions on _id are not supported"},
+		{sql: "SELECT SUM(color) FROM i", err: "SUM requires an int field"},
+		{sql: "SELECT color FROM i", err: "selecting color requires it to appear in GROUP BY"},
+		{sql: "SELECT COUNT(*), color FROM i", err: "color must appear in GROUP BY"},
+		{sql: "SELECT _id, COUNT(*) FROM i", err: "_id can't be selected with other items"},
+		{sql: "SELECT color, age FROM i GROUP BY color", err: "age must appear in GROUP BY"},
+		{sql: "SELECT SUM(age) FROM i GROUP BY color", err: "SUM is not supported with GROUP BY"},
+		{sql: "SELECT age FROM i GROUP BY age", err: "can't GROUP BY int field age"},
+		{sql: "SELECT COUNT(*) FROM i", schema: &sql.Schema{}, err: "index does not track existence"},
+		{sql: "SELECT COUNT(*) FROM i WHERE color != 1", schema: &sql.Schema{Fields: map[string]string{"color": "set"}}, err: "requires an index which tracks existence"},
+	}
+	for _, test := range tests {
+		t.Run(test.sql, func(t *testing.T) {
+			schema := testSchema
+			if test.schema != nil {
+				schema = *test.schema
+			}
+			stmt, err := sql.Parse(test.sql)
+			if err == nil {
+				_, err = sql.Translate(stmt, schema)
+			}
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("expected error containing %q, got %v", test.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,326 @@
+// Copyright 2017 Pilosa Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sql
+
+import (
+	"github.com/pilosa/pilosa/v2/pql"
+	"github.com/pkg/errors"
+)
+
+// IDColumn is the pseudo-field naming the column ID (or key) of a record.
+const IDColumn = "_id"
+
+// Field types, matching those of the pilosa package.
+const (
+	fieldTypeSet   = "set"
+	fieldTypeInt   = "int"
+	fieldTypeTime  = "time"
+	fieldTypeMutex = "mutex"
+	fieldTypeBool  = "bool"
+)
+
+// Schema describes the index a statement is translated against.
+type Schema struct {
+	// Fields maps field names to their types.
+	Fields map[string]string
+
+	// TrackExistence must be set for statements which match every record,
+	// such as COUNT(*) without a WHERE clause or != on a set field.
+	TrackExistence bool
+}
+
+// PlanType describes how the results of a plan's query form a table.
+type PlanType int
+
+const (
+	// PlanAggregate plans hold one call per select item, each returning a
+	// single value. They produce exactly one row.
+	PlanAggregate PlanType = iota
+
+	// PlanGroupBy plans hold a single GroupBy call. Each group produces a
+	// row.
+	PlanGroupBy
+
+	// PlanColumns plans hold a single bitmap call. Each column produces a
+	// row holding its ID or key.
+	PlanColumns
+)
+
+// Plan is a statement translated into PQL.
+type Plan struct {
+	Type   PlanType
+	Query  *pql.Query
+	Select []SelectItem
+
+	// Columns are the names of the result columns.
+	Columns []string
+
+	// Limit is the maximum number of rows to return. Zero means no limit.
+	Limit uint64
+}
+
+// Translate translates stmt into a PQL query against the index described by
+// schema.
+func Translate(stmt *Statement, schema Schema) (*Plan, error) {
+	t := &translator{schema: schema}
+
+	plan := &Plan{
+		Query:  &pql.Query{},
+		Select: stmt.Select,
+		Limit:  stmt.Limit,
+	}
+	for _, item := range stmt.Select {
+		plan.Columns = append(plan.Columns, item.String())
+	}
+
+	filter, err := t.filter(stmt.Where)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case len(stmt.GroupBy) > 0:
+		plan.Type = PlanGroupBy
+		call, err := t.groupBy(stmt, filter)
+		if err != nil {
+			return nil, err
+		}
+		plan.Query.Calls = []*pql.Call{call}
+
+	case isAggregate(stmt.Select[0]):
+		plan.Type = PlanAggregate
+		for _, item := range stmt.Select {
+			call, err := t.aggregate(item, filter)
+			if err != nil {
+				return nil, err
+			}
+			plan.Query.Calls = append(plan.Query.Calls, call)
+		}
+
+	case len(stmt.Select) == 1 && stmt.Select[0].Field == IDColumn:
+		plan.Type = PlanColumns
+		if filter == nil {
+			if filter, err = t.all(); err != nil {
+				return nil, err
+			}
+		}
+		plan.Query.Calls = []*pql.Call{filter}
+
+	default:
+		for _, item := range stmt.Select {
+			if !isAggregate(item) && item.Field != IDColumn {
+				if _, err := t.fieldType(item.Field); err != nil {
+					return nil, err
+				}
+				return nil, errors.Errorf("selecting %s requires it to appear in GROUP BY", item.Field)
+			}
+		}
+		return nil, errors.Errorf("%s can't be selected with other items", IDColumn)
+	}
+
+	return plan, nil
+}
+
+func isAggregate(item SelectItem) bool { return item.Func != "" }
+
+type translator struct {
+	schema Schema
+}
+
+// fieldType returns the type of the named field.
+func (t *translator) fieldType(name string) (string, error) {
+	if name == IDColumn {
+		return "", errors.Errorf("%s can only be selected on its own", IDColumn)
+	}
+	typ, ok := t.schema.Fields[name]
+	if !ok {
+		return "", errors.Errorf("field not found: %s", name)
+	}
+	return typ, nil
+}
+
+// all returns a call matching every record in the index.
+func (t *translator) all() (*pql.Call, error) {
+	if !t.schema.TrackExistence {
+		return nil, errors.New("index does not track existence; a WHERE clause is required")
+	}
+	return &pql.Call{Name: "Not", Children: []*pql.Call{{Name: "Union"}}}, nil
+}
+
+// filter returns the intersection of conds, or nil if there are none.
+func (t *translator) filter(conds []Condition) (*pql.Call, error) {
+	calls := make([]*pql.Call, 0, len(conds))
+	for _, cond := range conds {
+		call, err := t.condition(cond)
+		if err != nil {
+			return nil, err
+		}
+		calls = append(calls, call)
+	}
+
+	switch len(calls) {
+	case 0:
+		return nil, nil
+	case 1:
+		return calls[0], nil
+	default:
+		return &pql.Call{Name: "Intersect", Children: calls}, nil
+	}
+}
+
+// condition translates a single comparison into a Row call.
+func (t *translator) condition(cond Condition) (*pql.Call, error) {
+	if cond.Field == IDColumn {
+		return nil, errors.Errorf("conditions on %s are not supported", IDColumn)
+	}
+	typ, err := t.fieldType(cond.Field)
+	if err != nil {
+		return nil, err
+	}
+
+	switch typ {
+	case fieldTypeInt:
+		value, ok := cond.Value.(int64)
+		if !ok {
+			return nil, errors.Errorf("int field %s can only be compared to integers", cond.Field)
+		}
+		return row(cond.Field, &pql.Condition{Op: cond.Op, Value: value}), nil
+
+	case fieldTypeBool:
+		value, ok := cond.Value.(bool)
+		if !ok {
+			return nil, errors.Errorf("bool field %s can only be compared to TRUE or FALSE", cond.Field)
+		}
+		switch cond.Op {
+		case pql.EQ:
+			return row(cond.Field, value), nil
+		case pql.NEQ:
+			return row(cond.Field, !value), nil
+		}
+		return nil, errors.Errorf("bool field %s only supports = and !=", cond.Field)
+
+	case fieldTypeSet, fieldTypeMutex, fieldTypeTime:
+		switch v := cond.Value.(type) {
+		case int64:
+			if v < 0 {
+				return nil, errors.Errorf("row IDs can't be negative: %d", v)
+			}
+		case string:
+		default:
+			return nil, errors.Errorf("%s field %s can only be compared to row IDs or keys", typ, cond.Field)
+		}
+		switch cond.Op {
+		case pql.EQ:
+			return row(cond.Field, cond.Value), nil
+		case pql.NEQ:
+			if !t.schema.TrackExistence {
+				return nil, errors.Errorf("!= on %s requires an index which tracks existence", cond.Field)
+			}
+			return &pql.Call{Name: "Not", Children: []*pql.Call{row(cond.Field, cond.Value)}}, nil
+		}
+		return nil, errors.Errorf("%s field %s only supports = and !=", typ, cond.Field)
+
+	default:
+		return nil, errors.Errorf("unsupported field type %q: %s", typ, cond.Field)
+	}
+}
+
+func row(field string, value interface{}) *pql.Call {
+	return &pql.Call{Name: "Row", Args: map[string]interface{}{field: value}}
+}
+
+// aggregate translates an aggregate select item.
+func (t *translator) aggregate(item SelectItem, filter *pql.Call) (*pql.Call, error) {
+	if item.Field == IDColumn {
+		return nil, errors.Errorf("%s can't be selected with other items", IDColumn)
+	} else if !isAggregate(item) {
+		return nil, errors.Errorf("%s must appear in GROUP BY when selected with aggregates", item.Field)
+	}
+
+	if item.Func == "COUNT" {
+		if filter == nil {
+			all, err := t.all()
+			if err != nil {
+				return nil, err
+			}
+			filter = all
+		}
+		return &pql.Call{Name: "Count", Children: []*pql.Call{filter}}, nil
+	}
+
+	typ, err := t.fieldType(item.Field)
+	if err != nil {
+		return nil, err
+	} else if typ != fieldTypeInt {
+		return nil, errors.Errorf("%s requires an int field: %s", item.Func, item.Field)
+	}
+
+	var name string
+	switch item.Func {
+	case "SUM":
+		name = "Sum"
+	case "MIN":
+		name = "Min"
+	case "MAX":
+		name = "Max"
+	}
+	call := &pql.Call{Name: name, Args: map[string]interface{}{"field": item.Field}}
+	if filter != nil {
+		call.Children = []*pql.Call{filter}
+	}
+	return call, nil
+}
+
+// groupBy translates a grouped statement into a GroupBy call.
+func (t *translator) groupBy(stmt *Statement, filter *pql.Call) (*pql.Call, error) {
+	grouped := make(map[string]struct{}, len(stmt.GroupBy))
+	call := &pql.Call{Name: "GroupBy", Args: map[string]interface{}{}}
+	for _, field := range stmt.GroupBy {
+		typ, err := t.fieldType(field)
+		if err != nil {
+			return nil, err
+		}
+		switch typ {
+		case fieldTypeSet, fieldTypeMutex, fieldTypeTime, fieldTypeBool:
+		default:
+			return nil, errors.Errorf("can't GROUP BY %s field %s", typ, field)
+		}
+		if _, ok := grouped[field]; ok {
+			return nil, errors.Errorf("duplicate GROUP BY field: %s", field)
+		}
+		grouped[field] = struct{}{}
+		call.Children = append(call.Children, &pql.Call{Name: "Rows", Args: map[string]interface{}{"_field": field}})
+	}
+
+	for _, item := range stmt.Select {
+		switch {
+		case item.Func == "COUNT":
+		case isAggregate(item):
+			return nil, errors.Errorf("%s is not supported with GROUP BY; only COUNT(*) is", item.Func)
+		default:
+			if _, ok := grouped[item.Field]; !ok {
+				return nil, errors.Errorf("%s must appear in GROUP BY", item.Field)
+			}
+		}
+	}
+
+	if filter != nil {
+		call.Args["filter"] = filter
+	}
+	if stmt.Limit > 0 {
+		call.Args["limit"] = stmt.Limit
+	}
+	return call, nil
+}
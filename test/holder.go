@@ -61,10 +61,12 @@ func (h *Holder) Close() error {
 // Reopen instantiates and opens a new holder.
 // Note that the holder must be Closed first.
 func (h *Holder) Reopen() error {
-	path, logger := h.Path, h.Holder.Logger
+	path, logger, quarantine, storage := h.Path, h.Holder.Logger, h.Holder.QuarantineCorruptFragments, h.Holder.Storage
 	h.Holder = pilosa.NewHolder()
 	h.Holder.Path = path
 	h.Holder.Logger = logger
+	h.Holder.QuarantineCorruptFragments = quarantine
+	h.Holder.Storage = storage
 	h.Holder.NewAttrStore = boltdb.NewAttrStore
 	return h.Holder.Open()
 }
@@ -46,6 +46,10 @@ type TranslateStore interface {
 	TranslateKey(key string) (uint64, error)
 	TranslateKeys(key []string) ([]uint64, error)
 
+	// Returns the IDs of existing keys without creating new ones.
+	// Unknown keys are returned as zero.
+	FindKeys(keys []string) ([]uint64, error)
+
 	// Converts an integer ID to its associated string key.
 	TranslateID(id uint64) (string, error)
 	TranslateIDs(id []uint64) ([]string, error)
@@ -53,6 +57,9 @@ type TranslateStore interface {
 	// Forces the write of a key/id pair, even if read only. Used by replication.
 	ForceSet(id uint64, key string) error
 
+	// Removes the keys associated with the given IDs, even if read only.
+	DeleteIDs(ids []uint64) error
+
 	// Returns a reader from the given ID offset.
 	EntryReader(ctx context.Context, offset uint64) (TranslateEntryReader, error)
 }
@@ -268,6 +275,19 @@ func (s *InMemTranslateStore) TranslateKeys(keys []string) ([]uint64, error) {
 	return ids, nil
 }
 
+// FindKeys returns the IDs associated with keys. Keys which do not have
+// an associated id are returned as zero.
+func (s *InMemTranslateStore) FindKeys(keys []string) ([]uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]uint64, len(keys))
+	for i := range keys {
+		ids[i] = s.lookup[keys[i]]
+	}
+	return ids, nil
+}
+
 func (s *InMemTranslateStore) translateKey(key string) uint64 {
 	// Return id if it has been added.
 	if id, ok := s.lookup[key]; ok {
@@ -315,9 +335,27 @@ func (s *InMemTranslateStore) ForceSet(id uint64, key string) error {
 	return nil
 }
 
-// set assigns the id/key pair to the store.
+// DeleteIDs removes the keys associated with the given IDs. IDs are not
+// reused, so a deleted ID remains reserved and translates to a blank key.
+func (s *InMemTranslateStore) DeleteIDs(ids []uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range ids {
+		if key := s.translateID(id); key != "" {
+			delete(s.lookup, key)
+			s.keys[id-1] = ""
+		}
+	}
+	return nil
+}
+
+// set assigns the id/key pair to the store. IDs skipped by a forced
+// assignment are left blank.
 func (s *InMemTranslateStore) set(id uint64, key string) {
-	s.keys = append(s.keys, key)
+	for uint64(len(s.keys)) < id {
+		s.keys = append(s.keys, "")
+	}
+	s.keys[id-1] = key
 	s.lookup[key] = id
 	s.notifyWrite()
 }
@@ -390,10 +428,13 @@ func (r *inMemTranslateEntryReader) ReadEntry(entry *TranslateEntry) error {
 			}
 		}
 
-		// Translate key for offset.
+		// Translate key for offset, skipping deleted keys.
 		key, err := r.store.TranslateID(r.offset)
 		if err != nil {
 			return err
+		} else if key == "" {
+			r.offset++
+			continue
 		}
 
 		// Copy id/key pair to entry argument and increment offset for next read.
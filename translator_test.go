@@ -74,6 +74,34 @@ func TestInMemTranslateStore_TranslateID(t *testing.T) {
 	}
 }
 
+func TestInMemTranslateStore_ForceSet(t *testing.T) {
+	s := pilosa.NewInMemTranslateStore("IDX", "FLD")
+
+	if err := s.ForceSet(1, "foo"); err != nil {
+		t.Fatal(err)
+	} else if err := s.ForceSet(3, "bar"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Ensure the forced keys translate.
+	if keys, err := s.TranslateIDs([]uint64{1, 2, 3}); err != nil {
+		t.Fatal(err)
+	} else if diff := cmp.Diff(keys, []string{"foo", "", "bar"}); diff != "" {
+		t.Fatal(diff)
+	} else if id, err := s.TranslateKey("bar"); err != nil {
+		t.Fatal(err)
+	} else if got, want := id, uint64(3); got != want {
+		t.Fatalf("TranslateKey()=%d, want %d", got, want)
+	}
+
+	// Ensure a new key is assigned an ID after the forced ones.
+	if id, err := s.TranslateKey("baz"); err != nil {
+		t.Fatal(err)
+	} else if got, want := id, uint64(4); got != want {
+		t.Fatalf("TranslateKey()=%d, want %d", got, want)
+	}
+}
+
 func TestMultiTranslateEntryReader(t *testing.T) {
 	t.Run("None", func(t *testing.T) {
 		r := pilosa.NewMultiTranslateEntryReader(context.Background(), nil)
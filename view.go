@@ -37,7 +37,9 @@ import (
 const (
 	viewStandard = "standard"
 
-	viewBSIGroupPrefix = "bsig_"
+	// ViewBSIGroupPrefix is the prefix of the names of the views holding
+	// the values of int fields.
+	ViewBSIGroupPrefix = "bsig_"
 )
 
 // view represents a container for field data.
@@ -60,6 +62,8 @@ type view struct {
 	rowAttrStore  AttrStore
 	logger        logger.Logger
 	snapshotQueue chan *fragment
+	quarantine    *fragmentQuarantine
+	storageOpt    StorageOptions
 }
 
 // newView returns a new instance of View.
@@ -86,7 +90,7 @@ func newView(path, index, field, name string, fieldOptions FieldOptions) *view {
 func (v *view) open() error {
 
 	// Never keep a cache for field views.
-	if strings.HasPrefix(v.name, viewBSIGroupPrefix) {
+	if strings.HasPrefix(v.name, ViewBSIGroupPrefix) {
 		v.cacheType = CacheTypeNone
 	}
 
@@ -161,6 +165,10 @@ fileLoop:
 				}()
 				frag := v.newFragment(v.fragmentPath(shard), shard)
 				if err := frag.Open(); err != nil {
+					if _, ok := errors.Cause(err).(*fragmentCorruptError); ok && v.quarantine != nil {
+						v.stats.Count("quarantinedFragments", 1, 1.0)
+						return v.quarantine.add(frag, err)
+					}
 					return fmt.Errorf("open fragment: shard=%d, err=%s", frag.shard, err)
 				}
 				frag.RowAttrStore = v.rowAttrStore
@@ -253,10 +261,13 @@ func (v *view) allFragments() []*fragment {
 }
 
 // recalculateCaches recalculates the cache on every fragment in the view.
-func (v *view) recalculateCaches() {
+func (v *view) recalculateCaches() error {
 	for _, fragment := range v.allFragments() {
-		fragment.RecalculateCache()
+		if err := fragment.RecalculateCache(); err != nil {
+			return errors.Wrapf(err, "shard %d", fragment.shard)
+		}
 	}
+	return nil
 }
 
 // CreateFragmentIfNotExists returns a fragment in the view by shard.
@@ -310,6 +321,7 @@ func (v *view) newFragment(path string, shard uint64) *fragment {
 	frag.Logger = v.logger
 	frag.stats = v.stats
 	frag.snapshotQueue = v.snapshotQueue
+	frag.storageOpt = v.storageOpt
 	if v.fieldType == FieldTypeMutex {
 		frag.mutexVector = newRowsVector(frag)
 	} else if v.fieldType == FieldTypeBool {
@@ -350,17 +362,18 @@ func (v *view) deleteFragment(shard uint64) error {
 }
 
 // row returns a row for a shard of the view.
-func (v *view) row(rowID uint64) *Row {
+func (v *view) row(rowID uint64) (*Row, error) {
 	row := NewRow()
 	for _, frag := range v.allFragments() {
-		fr := frag.row(rowID)
-		if fr == nil {
+		fr, err := frag.readRow(rowID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading shard %d", frag.shard)
+		} else if fr == nil {
 			continue
 		}
 		row.Merge(fr)
 	}
-	return row
-
+	return row, nil
 }
 
 // setBit sets a bit within the view.